@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/janto-pee/Horizon-Travels.git/util"
+)
+
+// runCheck validates every stored document with its model's Validate
+// method and lists the ones that fail
+func runCheck(ctx context.Context, config util.Config, args []string) error {
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	selected := datasets(store)
+	if len(args) > 0 {
+		selected = nil
+		for _, name := range args {
+			ds, err := findDataset(store, name)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, ds)
+		}
+	}
+
+	invalid := 0
+	for _, ds := range selected {
+		checked, failed := 0, 0
+		err := ds.each(ctx, func(doc json.RawMessage, problem error) error {
+			checked++
+			if problem != nil {
+				failed++
+				var ref struct {
+					ID string `json:"id"`
+				}
+				json.Unmarshal(doc, &ref)
+				fmt.Printf("%s %s: %v\n", ds.name, ref.ID, problem)
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			return fmt.Errorf("checking %s: %w", ds.name, err)
+		}
+		log.Printf("%s: %d checked, %d invalid", ds.name, checked, failed)
+		invalid += failed
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid documents", invalid)
+	}
+	return nil
+}
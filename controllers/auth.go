@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	UserID primitive.ObjectID
+	Admin  bool
+}
+
+// identityKey holds the caller's Identity in the gin context
+const identityKey = "identity"
+
+// authenticate reads the bearer token of a request, signed with
+// TOKEN_SECRET, into the caller's Identity. Requests without a token go on
+// anonymously; requests with an invalid one are refused.
+func (server *Server) authenticate(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		c.Next()
+		return
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("expected a bearer token")))
+		return
+	}
+	claims, err := util.VerifyToken(server.config.TokenSecret, token, time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(util.ErrInvalidToken))
+		return
+	}
+	c.Set(identityKey, Identity{UserID: userID, Admin: claims.Admin})
+	c.Next()
+}
+
+// caller returns the authenticated caller of the request, if any
+func caller(c *gin.Context) (Identity, bool) {
+	identity, ok := c.Get(identityKey)
+	if !ok {
+		return Identity{}, false
+	}
+	return identity.(Identity), true
+}
+
+// requireUser refuses anonymous requests
+func requireUser(c *gin.Context) {
+	if _, ok := caller(c); !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("authentication required")))
+		return
+	}
+	c.Next()
+}
+
+// requireAdmin refuses requests from anyone but administrators
+func requireAdmin(c *gin.Context) {
+	identity, ok := caller(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("authentication required")))
+		return
+	}
+	if !identity.Admin {
+		c.AbortWithStatusJSON(http.StatusForbidden, errorResponse(errors.New("administrators only")))
+		return
+	}
+	c.Next()
+}
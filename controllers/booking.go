@@ -0,0 +1,357 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Defaults for the booking settings of util.Config
+const (
+	defaultBookingHoldTTL     = 15 * time.Minute
+	defaultHoldExpiryInterval = time.Minute
+)
+
+// expiryBatchSize bounds the holds released in one pass of the expirer
+const expiryBatchSize = 100
+
+func (server *Server) registerBookingRoutes(bookings *gin.RouterGroup) {
+	bookings.POST("", server.CreateBooking)
+	bookings.GET("/:id", server.GetBooking)
+	bookings.POST("/:id/confirm", server.ConfirmBooking)
+	bookings.POST("/:id/cancel", server.CancelBooking)
+}
+
+// Create Booking
+type CreateBookingRequest struct {
+	HotelID      string `json:"hotel_id" binding:"required"`
+	RoomTypeID   string `json:"room_type_id" binding:"required"`
+	CheckIn      string `json:"check_in" binding:"required"`
+	CheckOut     string `json:"check_out" binding:"required"`
+	Rooms        int    `json:"rooms"`  // defaults to 1
+	Adults       int    `json:"adults"` // defaults to 1
+	ChildrenAges []int  `json:"children_ages"`
+	GuestName    string `json:"guest_name"`
+	GuestEmail   string `json:"guest_email"`
+}
+
+// CreateBooking holds rooms for a stay. The hold keeps its inventory until
+// it is confirmed or cancelled, or until BOOKING_HOLD_TTL passes.
+func (server *Server) CreateBooking(c *gin.Context) {
+	var req CreateBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	now := time.Now()
+	fields := model.FieldErrors{}
+	hotelID, err := primitive.ObjectIDFromHex(req.HotelID)
+	if err != nil {
+		fields.Add("hotel_id", "is not a valid ID")
+	}
+	checkIn, err := time.Parse(dateLayout, req.CheckIn)
+	if err != nil {
+		fields.Add("check_in", "must be a date in YYYY-MM-DD format")
+	} else if checkIn.Before(model.Night(now)) {
+		fields.Add("check_in", "cannot be in the past")
+	}
+	checkOut, err := time.Parse(dateLayout, req.CheckOut)
+	if err != nil {
+		fields.Add("check_out", "must be a date in YYYY-MM-DD format")
+	} else if len(model.Nights(checkIn, checkOut)) > maxStayNights {
+		fields.Add("check_out", fmt.Sprintf("must be at most %d nights after check_in", maxStayNights))
+	}
+
+	booking := model.Booking{
+		HotelID:       hotelID,
+		RoomTypeID:    req.RoomTypeID,
+		CheckIn:       checkIn,
+		CheckOut:      checkOut,
+		Rooms:         max(req.Rooms, 1),
+		Adults:        max(req.Adults, 1),
+		ChildrenAges:  req.ChildrenAges,
+		GuestName:     req.GuestName,
+		GuestEmail:    req.GuestEmail,
+		Status:        model.BookingHeld,
+		HoldExpiresAt: now.Add(server.holdTTL()),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := booking.Validate(); err != nil {
+		var invalid model.FieldErrors
+		errors.As(err, &invalid)
+		for field, message := range invalid {
+			fields.Add(field, message)
+		}
+	}
+	if err := fields.Err(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hotel, err := server.store.Hotels.Get(ctx, hotelID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(errors.New("hotel not found")))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	roomType, ok := hotel.RoomType(req.RoomTypeID)
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(model.FieldErrors{"room_type_id": "is not a room type of this hotel"}))
+		return
+	}
+	if perRoom := (booking.Guests() + booking.Rooms - 1) / booking.Rooms; perRoom > roomType.MaxGuests {
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(model.FieldErrors{
+			"rooms": fmt.Sprintf("%d guests do not fit in %d %s", booking.Guests(), booking.Rooms, roomType.Name),
+		}))
+		return
+	}
+
+	err = server.store.Inventory.Reserve(ctx, hotelID, booking.RoomTypeID, booking.CheckIn, booking.CheckOut, booking.Rooms)
+	if errors.Is(err, repository.ErrNotReleased) {
+		log.Printf("rooms of hotel %s are still held after a failed booking: %v", hotelID.Hex(), err)
+	}
+	switch {
+	case errors.Is(err, repository.ErrUnavailable):
+		c.JSON(http.StatusConflict, errorResponse(errors.New("the room type is not available for these dates")))
+		return
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, errorResponse(err))
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.store.Bookings.Create(ctx, &booking); err != nil {
+		server.release(ctx, &booking)
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusCreated, booking)
+}
+
+// bookingFromPath loads the booking whose ID is in the path, writing the
+// error response and returning false when it cannot
+func (server *Server) bookingFromPath(ctx context.Context, c *gin.Context) (*model.Booking, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return nil, false
+	}
+	booking, err := server.store.Bookings.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(errors.New("booking not found")))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	return booking, true
+}
+
+// Get Booking by ID
+func (server *Server) GetBooking(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	booking, ok := server.bookingFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+// ConfirmBooking turns a hold into a confirmed booking. Confirming again
+// is a no-op, and a hold past its expiry is expired instead.
+func (server *Server) ConfirmBooking(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	booking, ok := server.bookingFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	switch {
+	case booking.Status == model.BookingConfirmed:
+		c.JSON(http.StatusOK, booking)
+		return
+	case booking.Expired(now):
+		if err := server.expireHold(ctx, booking); err != nil && !errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusConflict, errorResponse(errors.New("the hold has expired")))
+		return
+	case booking.Status != model.BookingHeld:
+		c.JSON(http.StatusConflict, errorResponse(fmt.Errorf("the booking is %s", booking.Status)))
+		return
+	}
+
+	booking.Status = model.BookingConfirmed
+	booking.ConfirmedAt = &now
+	booking.UpdatedAt = now
+	if err := server.store.Bookings.Transition(ctx, booking, model.BookingHeld); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, errorResponse(errors.New("the booking was changed by another request")))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+// Cancel Booking
+type CancelBookingRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CancelBooking cancels a hold or a confirmed booking and gives its rooms
+// back. The hotel's cancellation policy at that moment is recorded on the
+// booking.
+func (server *Server) CancelBooking(c *gin.Context) {
+	var req CancelBookingRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	booking, ok := server.bookingFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	if booking.Status != model.BookingHeld && booking.Status != model.BookingConfirmed {
+		c.JSON(http.StatusConflict, errorResponse(fmt.Errorf("the booking is %s", booking.Status)))
+		return
+	}
+
+	var policy string
+	hotel, err := server.store.Hotels.Get(ctx, booking.HotelID)
+	switch {
+	case err == nil:
+		policy = hotel.Policies.Cancellation
+	case !errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	now := time.Now()
+	booking.Status = model.BookingCancelled
+	booking.Cancellation = &model.Cancellation{Reason: req.Reason, Policy: policy, CancelledAt: now}
+	booking.UpdatedAt = now
+	if err := server.store.Bookings.Transition(ctx, booking, model.BookingHeld, model.BookingConfirmed); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, errorResponse(errors.New("the booking was changed by another request")))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.release(ctx, booking)
+	c.JSON(http.StatusOK, booking)
+}
+
+// expireHold marks a hold as expired and gives its rooms back. The status
+// changes first, so a hold confirmed meanwhile keeps its rooms.
+func (server *Server) expireHold(ctx context.Context, booking *model.Booking) error {
+	expired := *booking
+	expired.Status = model.BookingExpired
+	expired.UpdatedAt = time.Now()
+	if err := server.store.Bookings.Transition(ctx, &expired, model.BookingHeld); err != nil {
+		return err
+	}
+	*booking = expired
+	server.release(ctx, booking)
+	return nil
+}
+
+// release gives the rooms of a booking back to the inventory. A failure
+// leaves the rooms sold, which undersells but never oversells, so it is
+// logged rather than returned. The rooms are given back even if the
+// request or worker that freed them is cancelled meanwhile.
+func (server *Server) release(ctx context.Context, booking *model.Booking) {
+	err := server.store.Inventory.Release(context.WithoutCancel(ctx), booking.HotelID, booking.RoomTypeID, booking.CheckIn, booking.CheckOut, booking.Rooms)
+	if err != nil {
+		log.Printf("could not release the rooms of booking %s: %v", booking.ID.Hex(), err)
+	}
+}
+
+// ExpireHolds expires the holds that ran out before now and returns how
+// many it expired
+func (server *Server) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	holds, _, err := server.store.Bookings.List(ctx, repository.Query{
+		Conditions: []repository.Condition{
+			repository.Eq("status", model.BookingHeld),
+			repository.Lte("hold_expires_at", now),
+		},
+		Sort:  []repository.SortField{repository.Asc("hold_expires_at")},
+		Limit: expiryBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range holds {
+		err := server.expireHold(ctx, &holds[i])
+		switch {
+		case err == nil:
+			expired++
+		case !errors.Is(err, repository.ErrConflict):
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// holdTTL is how long a new hold keeps its rooms
+func (server *Server) holdTTL() time.Duration {
+	if server.config.BookingHoldTTL > 0 {
+		return server.config.BookingHoldTTL
+	}
+	return defaultBookingHoldTTL
+}
+
+// holdExpirer is the Worker that expires holds every interval
+type holdExpirer struct {
+	server   *Server
+	interval time.Duration
+}
+
+func (w *holdExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := w.server.ExpireHolds(ctx, now); err != nil && ctx.Err() == nil {
+				log.Printf("could not expire booking holds: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestBookingLifecycle(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	hotel := model.Hotel{
+		Title:     "Harbour View",
+		RoomTypes: []model.RoomType{{ID: "double", Name: "Double", MaxGuests: 2}},
+		Policies:  model.HotelPolicies{Cancellation: "Free cancellation until 48 hours before check-in"},
+	}
+	if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+		t.Fatal(err)
+	}
+	checkIn := model.Night(time.Now().UTC().AddDate(0, 0, 20))
+	checkOut := checkIn.AddDate(0, 0, 2)
+	for _, date := range model.Nights(checkIn, checkOut) {
+		if err := server.store.Inventory.Create(ctx, &model.Inventory{HotelID: hotel.ID, RoomTypeID: "double", Date: date, Units: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sold := func() int {
+		t.Helper()
+		nights, err := server.store.Inventory.Nights(ctx, hotel.ID, "double", checkIn, checkOut)
+		if err != nil || len(nights) != 2 || nights[0].Sold != nights[1].Sold {
+			t.Fatalf("unexpected inventory %+v (%v)", nights, err)
+		}
+		return nights[0].Sold
+	}
+
+	request := CreateBookingRequest{
+		HotelID:    hotel.ID.Hex(),
+		RoomTypeID: "double",
+		CheckIn:    checkIn.Format(dateLayout),
+		CheckOut:   checkOut.Format(dateLayout),
+		Adults:     2,
+		GuestName:  "Ada Obi",
+		GuestEmail: "ada@example.com",
+	}
+	book := func() (int, model.Booking) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPost, "/api/v1/bookings", request)
+		var booking model.Booking
+		json.Unmarshal(recorder.Body.Bytes(), &booking)
+		return recorder.Code, booking
+	}
+	post := func(path string, body interface{}) (int, model.Booking) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPost, path, body)
+		var booking model.Booking
+		json.Unmarshal(recorder.Body.Bytes(), &booking)
+		return recorder.Code, booking
+	}
+
+	code, held := book()
+	if code != http.StatusCreated || held.Status != model.BookingHeld || sold() != 1 {
+		t.Fatalf("expected a hold on the last room, got %d %+v", code, held)
+	}
+	if code, _ := book(); code != http.StatusConflict {
+		t.Errorf("expected %d booking a sold out room, got %d", http.StatusConflict, code)
+	}
+
+	code, confirmed := post("/api/v1/bookings/"+held.ID.Hex()+"/confirm", nil)
+	if code != http.StatusOK || confirmed.Status != model.BookingConfirmed || confirmed.ConfirmedAt == nil {
+		t.Fatalf("confirm: got %d %+v", code, confirmed)
+	}
+	code, cancelled := post("/api/v1/bookings/"+held.ID.Hex()+"/cancel", CancelBookingRequest{Reason: "change of plans"})
+	if code != http.StatusOK || cancelled.Status != model.BookingCancelled || sold() != 0 {
+		t.Fatalf("cancel: got %d %+v", code, cancelled)
+	}
+	if cancelled.Cancellation == nil || cancelled.Cancellation.Policy != hotel.Policies.Cancellation {
+		t.Errorf("expected the hotel's cancellation policy on the booking, got %+v", cancelled.Cancellation)
+	}
+	if code, _ := post("/api/v1/bookings/"+held.ID.Hex()+"/confirm", nil); code != http.StatusConflict {
+		t.Errorf("expected %d confirming a cancelled booking, got %d", http.StatusConflict, code)
+	}
+
+	// A hold that is not confirmed in time gives its room back
+	code, held = book()
+	if code != http.StatusCreated {
+		t.Fatalf("expected the cancelled room to be bookable again, got %d", code)
+	}
+	expired, err := server.ExpireHolds(ctx, held.HoldExpiresAt.Add(time.Second))
+	if err != nil || expired != 1 || sold() != 0 {
+		t.Fatalf("expected one hold expired, got %d (%v)", expired, err)
+	}
+	if code, _ := post("/api/v1/bookings/"+held.ID.Hex()+"/confirm", nil); code != http.StatusConflict {
+		t.Errorf("expected %d confirming an expired hold, got %d", http.StatusConflict, code)
+	}
+
+	request.Adults, request.CheckOut = 3, checkIn.Format(dateLayout)
+	recorder := performRequest(server, http.MethodPost, "/api/v1/bookings", request)
+	var invalid struct {
+		Fields map[string]string `json:"fields"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &invalid)
+	if recorder.Code != http.StatusUnprocessableEntity || invalid.Fields["check_out"] == "" {
+		t.Errorf("expected a field error for check_out, got %d %s", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,498 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxRentalDays is the longest car rental the searches price
+const maxRentalDays = 30
+
+// timeLayout is the format of the pick-up and drop-off times
+const timeLayout = "15:04"
+
+// defaultPickUpTime is used when a search leaves out pickUpTime
+const defaultPickUpTime = "10:00"
+
+func (server *Server) carSupplierResource() *Resource[model.CarSupplier] {
+	return &Resource[model.CarSupplier]{
+		Name: "car supplier",
+		Repo: server.store.CarSuppliers,
+		Sort: []repository.SortField{repository.Asc("name")},
+		BeforeDelete: func(c *gin.Context, doc *model.CarSupplier) error {
+			return refuseInUse(c.Request.Context(), server.store.CarLocations,
+				"the supplier still has locations", repository.Eq("supplier_id", doc.ID))
+		},
+	}
+}
+
+func (server *Server) carLocationResource() *Resource[model.CarLocation] {
+	return &Resource[model.CarLocation]{
+		Name: "car rental location",
+		Repo: server.store.CarLocations,
+		Sort: []repository.SortField{repository.Asc("name")},
+		BeforeCreate: func(c *gin.Context, doc *model.CarLocation) error {
+			return server.checkCarSupplier(c.Request.Context(), doc.SupplierID)
+		},
+		BeforeUpdate: func(c *gin.Context, old, doc *model.CarLocation) error {
+			return server.checkCarSupplier(c.Request.Context(), doc.SupplierID)
+		},
+		BeforeDelete: func(c *gin.Context, doc *model.CarLocation) error {
+			return refuseInUse(c.Request.Context(), server.store.RentalCars, "cars are still picked up or dropped off there",
+				repository.Or(
+					[]repository.Condition{repository.Eq("location_id", doc.ID)},
+					[]repository.Condition{repository.Eq("one_way_fees.location_id", doc.ID)},
+				))
+		},
+	}
+}
+
+func (server *Server) rentalCarResource() *Resource[model.RentalCar] {
+	return &Resource[model.RentalCar]{
+		Name:    "rental car",
+		Repo:    server.store.RentalCars,
+		Present: pricesInCurrency[model.RentalCar](server),
+		BeforeCreate: func(c *gin.Context, doc *model.RentalCar) error {
+			return server.checkCarLocations(c.Request.Context(), doc)
+		},
+		BeforeUpdate: func(c *gin.Context, old, doc *model.RentalCar) error {
+			return server.checkCarLocations(c.Request.Context(), doc)
+		},
+	}
+}
+
+func (server *Server) registerCarRoutes(v1 *gin.RouterGroup) {
+	suppliers := v1.Group("/car-suppliers")
+	server.carSuppliers.Register(suppliers)
+	suppliers.GET("/:id/locations", server.carLocations.ListBy(ofCarSupplier))
+	suppliers.GET("/:id/cars", server.rentalCars.ListBy(ofCarSupplier))
+
+	locations := v1.Group("/car-locations")
+	locations.GET("/search", server.SearchCarLocations)
+	server.carLocations.Register(locations)
+	locations.GET("/:id/cars", server.rentalCars.ListBy(atCarLocation))
+
+	cars := v1.Group("/rental-cars")
+	cars.GET("/search/same-dropoff", server.SearchCars(false))
+	cars.GET("/search/different-dropoff", server.SearchCars(true))
+	server.rentalCars.Register(cars)
+}
+
+// ofCarSupplier selects the documents of the car supplier in the path
+func ofCarSupplier(c *gin.Context) ([]repository.Condition, error) {
+	supplierID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, errors.New("invalid car supplier ID format")
+	}
+	return []repository.Condition{repository.Eq("supplier_id", supplierID)}, nil
+}
+
+// atCarLocation selects the cars picked up at the location in the path
+func atCarLocation(c *gin.Context) ([]repository.Condition, error) {
+	locationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, errors.New("invalid car rental location ID format")
+	}
+	return []repository.Condition{repository.Eq("location_id", locationID)}, nil
+}
+
+// refuseInUse is a BeforeDelete check failing with 409 Conflict while repo
+// has documents matching conditions
+func refuseInUse[T any](ctx context.Context, repo repository.Repository[T], reason string, conditions ...repository.Condition) error {
+	_, total, err := repo.List(ctx, repository.Query{Conditions: conditions, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return &StatusError{Status: http.StatusConflict, Err: errors.New(reason)}
+	}
+	return nil
+}
+
+// checkCarSupplier reports a field error unless the supplier exists
+func (server *Server) checkCarSupplier(ctx context.Context, supplierID primitive.ObjectID) error {
+	_, err := server.store.CarSuppliers.Get(ctx, supplierID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return model.FieldErrors{"supplier_id": "is not a car supplier"}
+	}
+	return err
+}
+
+// checkCarLocations reports field errors unless the car's pick-up location
+// and every one-way drop-off location belong to its supplier
+func (server *Server) checkCarLocations(ctx context.Context, car *model.RentalCar) error {
+	if err := server.checkCarSupplier(ctx, car.SupplierID); err != nil {
+		return err
+	}
+	ids := []interface{}{car.LocationID}
+	for _, fee := range car.OneWayFees {
+		ids = append(ids, fee.LocationID)
+	}
+	locations, _, err := server.store.CarLocations.List(ctx, repository.Query{Conditions: []repository.Condition{
+		repository.In("_id", ids...),
+		repository.Eq("supplier_id", car.SupplierID),
+	}})
+	if err != nil {
+		return err
+	}
+	known := map[primitive.ObjectID]bool{}
+	for _, location := range locations {
+		known[location.ID] = true
+	}
+
+	fields := model.FieldErrors{}
+	if !known[car.LocationID] {
+		fields.Add("location_id", "is not a location of the supplier")
+	}
+	for _, fee := range car.OneWayFees {
+		if !known[fee.LocationID] {
+			fields.Add("one_way_fees", "must name locations of the supplier")
+			break
+		}
+	}
+	return fields.Err()
+}
+
+// Search Rental Cars Location
+type CarLocationSearchRequest struct {
+	Pagination
+	Query           string   `form:"query"`
+	GeoID           int      `form:"geoId"`
+	PlaceType       []string `form:"placeType"`
+	Supplier        string   `form:"supplier"` // supplier ID
+	DistFrom        string   `form:"distFrom"`
+	DistFromMaxDist float64  `form:"distFromMaxDistance"`
+}
+
+// SearchCarLocations finds the places cars can be picked up: by name, city
+// or airport code, by geoId, or around a point, nearest first
+func (server *Server) SearchCarLocations(c *gin.Context) {
+	var req CarLocationSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	fields := model.FieldErrors{}
+	var conditions []repository.Condition
+	if query := strings.TrimSpace(req.Query); query != "" {
+		var alternatives [][]repository.Condition
+		for _, field := range []string{"name", "additional_names", "city"} {
+			alternatives = append(alternatives, []repository.Condition{repository.Contains(field, query)})
+		}
+		conditions = append(conditions, repository.Or(alternatives...))
+	}
+	if req.GeoID != 0 {
+		conditions = append(conditions, repository.Eq("geo_id", req.GeoID))
+	}
+	if types := splitList(req.PlaceType); len(types) > 0 {
+		values := make([]interface{}, len(types))
+		for i, placeType := range types {
+			if values[i] = strings.ToLower(placeType); !contains(model.CarPlaceTypes, values[i].(string)) {
+				fields.Add("placeType", "must be one of "+strings.Join(model.CarPlaceTypes, ", "))
+			}
+		}
+		conditions = append(conditions, repository.In("place_type", values...))
+	}
+	if req.Supplier != "" {
+		if supplierID, err := primitive.ObjectIDFromHex(req.Supplier); err != nil {
+			fields.Add("supplier", "is not a valid ID")
+		} else {
+			conditions = append(conditions, repository.Eq("supplier_id", supplierID))
+		}
+	}
+	query := repository.Query{Sort: []repository.SortField{repository.Asc("name")}}
+	if req.DistFrom != "" {
+		center, ok := parsePoint(req.DistFrom)
+		switch {
+		case !ok:
+			fields.Add("distFrom", "must be a latitude,longitude pair")
+		case req.DistFromMaxDist < 0 || req.DistFromMaxDist > 20000:
+			fields.Add("distFromMaxDistance", "must be between 0 and 20000 kilometers")
+		default:
+			radius := req.DistFromMaxDist
+			if radius == 0 {
+				radius = defaultDistFromMaxDistance
+			}
+			conditions = append(conditions, repository.Near("coordinates", center, radius*metersPer["km"]))
+			query.Sort = []repository.SortField{repository.ByDistance("coordinates")}
+		}
+	}
+	if err := fields.Err(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	query.Conditions = conditions
+	locations, total, err := server.store.CarLocations.List(ctx, query.Page(req.PageID, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, successResponse(locations, paginationResponse(req.PageID, req.PageSize, len(locations), total)))
+}
+
+// carSorts are the orders a CarSearchRequest can ask for
+var carSorts = sortOptions{
+	"price_low":  {repository.Asc("price")},
+	"price_high": {repository.Desc("price")},
+	"seats":      {repository.Desc("seats")},
+	"newest":     {repository.Desc("created_at")},
+}
+
+// CarSearchRequest takes the parameters of the Search Cars Same DropOff and
+// Search Cars Different DropOff endpoints. Times are local to the
+// locations; pickUpTime defaults to 10:00 and dropOffTime to pickUpTime.
+// List parameters accept repeated or comma-separated values.
+type CarSearchRequest struct {
+	Pagination
+	PickUpLocationID  string   `form:"pickUpLocationId"`
+	DropOffLocationID string   `form:"dropOffLocationId"`
+	PickUpDate        string   `form:"pickUpDate"`
+	PickUpTime        string   `form:"pickUpTime"`
+	DropOffDate       string   `form:"dropOffDate"`
+	DropOffTime       string   `form:"dropOffTime"`
+	DriverAge         int      `form:"driverAge"`
+	Category          []string `form:"category"`
+	Transmission      string   `form:"transmission"`
+	Supplier          []string `form:"supplier"` // supplier IDs
+	Seats             int      `form:"seats"`    // at least
+	PricedSearch               // prices per day
+
+	// Set by Validate
+	pickUp, dropOff                 time.Time
+	pickUpLocation, dropOffLocation primitive.ObjectID
+	suppliers                       []interface{}
+}
+
+// Validate checks the parameters against each other and parses the ones
+// that are not plain values. A one-way search needs a drop-off location
+// other than the pick-up location; the other search takes none, or the
+// same one. It returns model.FieldErrors.
+func (r *CarSearchRequest) Validate(oneWay bool, now time.Time) error {
+	fields := model.FieldErrors{}
+
+	var err error
+	if r.pickUpLocation, err = primitive.ObjectIDFromHex(r.PickUpLocationID); err != nil {
+		fields.Add("pickUpLocationId", "is required and must be a valid ID")
+	}
+	switch {
+	case oneWay && r.DropOffLocationID == "":
+		fields.Add("dropOffLocationId", "is required")
+	case r.DropOffLocationID == "":
+		r.dropOffLocation = r.pickUpLocation
+	default:
+		if r.dropOffLocation, err = primitive.ObjectIDFromHex(r.DropOffLocationID); err != nil {
+			fields.Add("dropOffLocationId", "must be a valid ID")
+		} else if oneWay && r.dropOffLocation == r.pickUpLocation {
+			fields.Add("dropOffLocationId", "must differ from pickUpLocationId; search with the same drop-off instead")
+		} else if !oneWay && r.dropOffLocation != r.pickUpLocation {
+			fields.Add("dropOffLocationId", "must be pickUpLocationId; search with a different drop-off instead")
+		}
+	}
+
+	if r.PickUpTime == "" {
+		r.PickUpTime = defaultPickUpTime
+	}
+	if r.DropOffTime == "" {
+		r.DropOffTime = r.PickUpTime
+	}
+	dates := model.FieldErrors{}
+	parse := func(dateField, date, timeField, clock string) time.Time {
+		day, err := time.Parse(dateLayout, date)
+		if err != nil {
+			dates.Add(dateField, "is required in YYYY-MM-DD format")
+		}
+		hour, err := time.Parse(timeLayout, clock)
+		if err != nil {
+			dates.Add(timeField, "must be a time in HH:MM format")
+		}
+		return day.Add(time.Duration(hour.Hour())*time.Hour + time.Duration(hour.Minute())*time.Minute)
+	}
+	r.pickUp = parse("pickUpDate", r.PickUpDate, "pickUpTime", r.PickUpTime)
+	r.dropOff = parse("dropOffDate", r.DropOffDate, "dropOffTime", r.DropOffTime)
+	if len(dates) == 0 {
+		switch {
+		case r.pickUp.Before(model.Night(now)):
+			dates.Add("pickUpDate", "cannot be in the past")
+		case !r.dropOff.After(r.pickUp):
+			dates.Add("dropOffDate", "must be after the pick-up")
+		case model.RentalDays(r.pickUp, r.dropOff) > maxRentalDays:
+			dates.Add("dropOffDate", fmt.Sprintf("must be at most %d days after the pick-up", maxRentalDays))
+		}
+	}
+	for field, message := range dates {
+		fields.Add(field, message)
+	}
+
+	if r.DriverAge != 0 && (r.DriverAge < 16 || r.DriverAge > 99) {
+		fields.Add("driverAge", "must be between 16 and 99")
+	}
+	for _, category := range lowerList(r.Category) {
+		if !contains(model.CarCategories, category) {
+			fields.Add("category", "must be one of "+strings.Join(model.CarCategories, ", "))
+		}
+	}
+	if r.Transmission != "" && !contains(model.CarTransmissions, strings.ToLower(r.Transmission)) {
+		fields.Add("transmission", "must be one of "+strings.Join(model.CarTransmissions, ", "))
+	}
+	for _, supplier := range splitList(r.Supplier) {
+		id, err := primitive.ObjectIDFromHex(supplier)
+		if err != nil {
+			fields.Add("supplier", "must be supplier IDs")
+			break
+		}
+		r.suppliers = append(r.suppliers, id)
+	}
+	if r.Seats < 0 {
+		fields.Add("seats", "cannot be negative")
+	}
+	r.PricedSearch.validate(fields, carSorts)
+	return fields.Err()
+}
+
+// query translates the validated request into a repository query. Prices
+// are in currency, which has a rate.
+func (r *CarSearchRequest) query(rates model.ExchangeRates, currency string) repository.Query {
+	conditions := []repository.Condition{repository.Eq("location_id", r.pickUpLocation)}
+	if r.dropOffLocation != r.pickUpLocation {
+		conditions = append(conditions, repository.Eq("one_way_fees.location_id", r.dropOffLocation))
+	}
+	conditions = append(conditions, in("category", lowerList(r.Category))...)
+	if r.Transmission != "" {
+		conditions = append(conditions, repository.Eq("transmission", strings.ToLower(r.Transmission)))
+	}
+	if len(r.suppliers) > 0 {
+		conditions = append(conditions, repository.In("supplier_id", r.suppliers...))
+	}
+	if r.Seats > 0 {
+		conditions = append(conditions, repository.Gte("seats", r.Seats))
+	}
+	if r.DriverAge > 0 {
+		conditions = append(conditions, repository.Lte("min_driver_age", r.DriverAge))
+	}
+	conditions = append(conditions, priceConditions(rates, currency, r.PriceMin, r.PriceMax)...)
+
+	return repository.Query{Conditions: conditions, Sort: r.order(carSorts, "price_low")}
+}
+
+// CarOffer is a car found by a search, with where it is picked up and
+// dropped off and what the rental costs
+type CarOffer struct {
+	Car      model.RentalCar    `json:"car"`
+	Supplier *model.CarSupplier `json:"supplier,omitempty"`
+	PickUp   model.CarLocation  `json:"pick_up"`
+	DropOff  model.CarLocation  `json:"drop_off"`
+	Quote    model.CarQuote     `json:"quote"`
+}
+
+// SearchCars returns the handler of one of the car searches. Both find the
+// cars picked up at pickUpLocationId; a one-way search keeps the ones that
+// can be dropped off at dropOffLocationId and adds the one-way fee to their
+// price.
+func (server *Server) SearchCars(oneWay bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CarSearchRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		if err := req.Validate(oneWay, time.Now().UTC()); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		field, code := req.currency()
+		currency, rates, ok := server.hotelCurrency(ctx, c, field, code)
+		if !ok {
+			return
+		}
+		pickUp, ok := server.carLocationOf(ctx, c, "pickUpLocationId", req.pickUpLocation)
+		if !ok {
+			return
+		}
+		dropOff := pickUp
+		if oneWay {
+			if dropOff, ok = server.carLocationOf(ctx, c, "dropOffLocationId", req.dropOffLocation); !ok {
+				return
+			}
+		}
+
+		query := req.query(rates, currency)
+		cars, total, err := server.store.RentalCars.List(ctx, query.Page(req.PageID, req.PageSize))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		convertPrices(cars, rates, currency)
+		suppliers, err := server.carSuppliersOf(ctx, cars)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		offers := make([]CarOffer, 0, len(cars))
+		for _, car := range cars {
+			quote, ok := car.Quote(req.pickUp, req.dropOff, dropOff.ID)
+			if !ok {
+				continue
+			}
+			offer := CarOffer{Car: car, PickUp: *pickUp, DropOff: *dropOff, Quote: quote}
+			if supplier, ok := suppliers[car.SupplierID]; ok {
+				offer.Supplier = &supplier
+			}
+			offers = append(offers, offer)
+		}
+		c.JSON(http.StatusOK, successResponse(offers, paginationResponse(req.PageID, req.PageSize, len(offers), total)))
+	}
+}
+
+// carLocationOf loads a location named by a query parameter, writing the
+// error response itself when it cannot
+func (server *Server) carLocationOf(ctx context.Context, c *gin.Context, field string, id primitive.ObjectID) (*model.CarLocation, bool) {
+	location, err := server.store.CarLocations.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, errorResponse(model.FieldErrors{field: "is not a car rental location"}))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	return location, true
+}
+
+// carSuppliersOf loads the suppliers of cars by ID
+func (server *Server) carSuppliersOf(ctx context.Context, cars []model.RentalCar) (map[primitive.ObjectID]model.CarSupplier, error) {
+	bySupplier := map[primitive.ObjectID]model.CarSupplier{}
+	if len(cars) == 0 {
+		return bySupplier, nil
+	}
+	ids := make([]interface{}, len(cars))
+	for i, car := range cars {
+		ids[i] = car.SupplierID
+	}
+	suppliers, _, err := server.store.CarSuppliers.List(ctx, repository.Query{Conditions: []repository.Condition{repository.In("_id", ids...)}})
+	if err != nil {
+		return nil, err
+	}
+	for _, supplier := range suppliers {
+		bySupplier[supplier.ID] = supplier
+	}
+	return bySupplier, nil
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestRentalCars(t *testing.T) {
+	server := newTestServer(t)
+
+	create := func(path string, doc, created interface{}) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPost, "/api/v1/"+path, doc)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", path, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+		response := struct {
+			Data interface{} `json:"data"`
+		}{created}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var hertz, avis model.CarSupplier
+	create("car-suppliers", model.CarSupplier{Name: "Hertz", Code: "hz"}, &hertz)
+	create("car-suppliers", model.CarSupplier{Name: "Avis", Code: "AV"}, &avis)
+
+	var heathrow, kingsCross, avisHeathrow model.CarLocation
+	create("car-locations", model.CarLocation{SupplierID: hertz.ID, Name: "London Heathrow Airport", AdditionalNames: "LHR", GeoID: 186338, PlaceType: "Airport", City: "London"}, &heathrow)
+	create("car-locations", model.CarLocation{SupplierID: hertz.ID, Name: "London Kings Cross", GeoID: 186338, PlaceType: "train_station", City: "London"}, &kingsCross)
+	create("car-locations", model.CarLocation{SupplierID: avis.ID, Name: "Heathrow Terminal 5", AdditionalNames: "LHR", GeoID: 186338, PlaceType: "airport", City: "London"}, &avisHeathrow)
+
+	// A Monday, with a bank holiday on the Tuesday
+	pickUp := model.Night(time.Now().UTC().AddDate(0, 0, 14))
+	for pickUp.Weekday() != time.Monday {
+		pickUp = pickUp.AddDate(0, 0, 1)
+	}
+	day := func(offset int) string {
+		return pickUp.AddDate(0, 0, offset).Format(dateLayout)
+	}
+	holiday := pickUp.AddDate(0, 0, 1)
+
+	var polo, bmw model.RentalCar
+	create("rental-cars", model.RentalCar{
+		SupplierID: hertz.ID, LocationID: heathrow.ID, Name: "VW Polo or similar", Category: "economy", Transmission: "manual", Seats: 5, Price: 40,
+		Seasons:         []model.Season{{Name: "Bank holiday", From: holiday, To: holiday, Rate: 60}},
+		RentalDiscounts: []model.RentalDiscount{{MinDays: 7, Percent: 10}},
+		OneWayFees:      []model.OneWayFee{{LocationID: kingsCross.ID, Fee: 25}},
+	}, &polo)
+	create("rental-cars", model.RentalCar{SupplierID: hertz.ID, LocationID: heathrow.ID, Name: "BMW 5 Series or similar", Category: "premium", Seats: 5, Price: 120, MinDriverAge: 25}, &bmw)
+	create("rental-cars", model.RentalCar{SupplierID: avis.ID, LocationID: avisHeathrow.ID, Name: "Ford Focus or similar", Category: "compact", Seats: 5, Price: 50}, new(model.RentalCar))
+
+	elsewhere := model.RentalCar{SupplierID: avis.ID, LocationID: heathrow.ID, Name: "Fiat 500", Category: "mini", Seats: 4, Price: 30}
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/rental-cars", elsewhere); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for another supplier's location, got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+
+	locations := func(params string) []string {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/car-locations/search?page_id=1&page_size=5&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.CarLocation `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, location := range list.Data {
+			names = append(names, location.Name)
+		}
+		return names
+	}
+	if names := locations("query=lhr"); !equalStrings(names, []string{"Heathrow Terminal 5", "London Heathrow Airport"}) {
+		t.Errorf("expected both Heathrow desks, got %v", names)
+	}
+	if names := locations("geoId=186338&placeType=train_station"); !equalStrings(names, []string{"London Kings Cross"}) {
+		t.Errorf("expected the station, got %v", names)
+	}
+
+	search := func(path, params string) []CarOffer {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/rental-cars/search/"+path+"?page_id=1&page_size=5&pickUpLocationId="+heathrow.ID.Hex()+"&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []CarOffer `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		return list.Data
+	}
+
+	// Three days and two hours are charged as four
+	dates := "pickUpDate=" + day(0) + "&dropOffDate=" + day(3) + "&dropOffTime=12:00"
+	offers := search("same-dropoff", dates)
+	if len(offers) != 2 || offers[0].Car.ID != polo.ID || offers[1].Car.ID != bmw.ID {
+		t.Fatalf("expected the Polo then the BMW, got %+v", offers)
+	}
+	if quote := offers[0].Quote; len(quote.Days) != 4 || quote.Total != 180 || quote.OneWayFee != 0 {
+		t.Errorf("expected 4 days at 40, 60, 40 and 40, got %+v", quote)
+	}
+	if offers[0].Supplier == nil || offers[0].Supplier.Code != "HZ" || offers[0].DropOff.ID != heathrow.ID {
+		t.Errorf("expected the offer to name Hertz and Heathrow, got %+v", offers[0])
+	}
+	if offers := search("same-dropoff", dates+"&driverAge=21"); len(offers) != 1 || offers[0].Car.ID != polo.ID {
+		t.Errorf("expected only the Polo for a 21-year-old, got %+v", offers)
+	}
+
+	week := search("same-dropoff", "pickUpDate="+day(0)+"&dropOffDate="+day(7)+"&category=economy")
+	if len(week) != 1 {
+		t.Fatalf("expected the Polo for a week, got %+v", week)
+	}
+	if quote := week[0].Quote; quote.Subtotal != 300 || quote.Discount != 30 || quote.PricePerDay != "$38.57" || quote.StrikethroughPrice != "$42.86" {
+		t.Errorf("expected 300 less 10%%, got %+v", quote)
+	}
+
+	offers = search("different-dropoff", dates+"&dropOffLocationId="+kingsCross.ID.Hex())
+	if len(offers) != 1 || offers[0].Car.ID != polo.ID {
+		t.Fatalf("expected only the Polo to be dropped off at Kings Cross, got %+v", offers)
+	}
+	if quote := offers[0].Quote; quote.OneWayFee != 25 || quote.Total != 205 || quote.PriceSummary != "$205 total for 4 days, including a $25 one-way fee" {
+		t.Errorf("expected the one-way fee on top, got %+v", quote)
+	}
+
+	for _, test := range []struct{ path, params string }{
+		{"different-dropoff", dates + "&dropOffLocationId=" + heathrow.ID.Hex()},
+		{"different-dropoff", dates},
+		{"same-dropoff", dates + "&dropOffLocationId=" + kingsCross.ID.Hex()},
+		{"same-dropoff", "pickUpDate=" + day(3) + "&dropOffDate=" + day(0)},
+		{"same-dropoff", "pickUpDate=" + day(0) + "&dropOffDate=" + day(40)},
+	} {
+		recorder := performRequest(server, http.MethodGet, "/api/v1/rental-cars/search/"+test.path+"?page_id=1&page_size=5&pickUpLocationId="+heathrow.ID.Hex()+"&"+test.params, nil)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected %d, got %d", test.path, test.params, http.StatusBadRequest, recorder.Code)
+		}
+	}
+
+	// Locations and suppliers in use cannot be deleted
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/car-locations/"+kingsCross.ID.Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a one-way drop-off location, got %d", http.StatusConflict, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/car-suppliers/"+hertz.ID.Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a supplier with locations, got %d", http.StatusConflict, recorder.Code)
+	}
+}
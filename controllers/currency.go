@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+)
+
+// rateCacheTTL is how long the exchange rate table is kept before it is
+// read again, so rates changed through another server show up
+const rateCacheTTL = time.Minute
+
+// rateCache keeps the exchange rate table between requests
+type rateCache struct {
+	mu       sync.Mutex
+	rates    model.ExchangeRates
+	loadedAt time.Time
+}
+
+func (cache *rateCache) invalidate() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.rates = nil
+}
+
+func (server *Server) exchangeRateResource() *Resource[model.ExchangeRate] {
+	invalidate := func(c *gin.Context, doc *model.ExchangeRate) {
+		server.rates.invalidate()
+	}
+	return &Resource[model.ExchangeRate]{
+		Name: "exchange rate",
+		Repo: server.store.ExchangeRates,
+		Sort: []repository.SortField{repository.Asc("currency")},
+		BeforeCreate: func(c *gin.Context, doc *model.ExchangeRate) error {
+			return server.checkRateUnique(c.Request.Context(), doc)
+		},
+		BeforeUpdate: func(c *gin.Context, old, doc *model.ExchangeRate) error {
+			return server.checkRateUnique(c.Request.Context(), doc)
+		},
+		AfterCreate: invalidate,
+		AfterUpdate: func(c *gin.Context, old, doc *model.ExchangeRate) {
+			server.rates.invalidate()
+		},
+		AfterDelete: invalidate,
+	}
+}
+
+// checkRateUnique rejects a second rate for the same currency
+func (server *Server) checkRateUnique(ctx context.Context, rate *model.ExchangeRate) error {
+	conditions := []repository.Condition{repository.Eq("currency", rate.Currency)}
+	if !rate.ID.IsZero() {
+		conditions = append(conditions, repository.Ne("_id", rate.ID))
+	}
+	_, total, err := server.store.ExchangeRates.List(ctx, repository.Query{Conditions: conditions, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return &StatusError{Status: http.StatusConflict, Err: errors.New("there is already a rate for " + rate.Currency)}
+	}
+	return nil
+}
+
+// currentRates returns the exchange rate table, reading it from the store
+// when the cached one is stale. The table is shared and must not be changed.
+func (server *Server) currentRates(ctx context.Context) (model.ExchangeRates, error) {
+	cache := &server.rates
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.rates != nil && time.Since(cache.loadedAt) < rateCacheTTL {
+		return cache.rates, nil
+	}
+
+	stored, _, err := server.store.ExchangeRates.List(ctx, repository.Query{})
+	if err != nil {
+		return nil, err
+	}
+	cache.rates, cache.loadedAt = model.NewExchangeRates(stored), time.Now()
+	return cache.rates, nil
+}
+
+// priceCurrency returns the exchange rates along with currency, upper-cased,
+// after checking that prices can be converted to it. An empty currency
+// keeps prices as they are stored. The error is model.FieldErrors when the
+// currency has no rate.
+func (server *Server) priceCurrency(ctx context.Context, field, currency string) (string, model.ExchangeRates, error) {
+	rates, err := server.currentRates(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency != "" && !rates.Has(currency) {
+		return "", nil, model.FieldErrors{field: "has no exchange rate"}
+	}
+	return currency, rates, nil
+}
+
+// convertPrices shows the prices of docs in currency. Documents without
+// prices, or in a currency without a rate, are left as they are.
+func convertPrices[T any](docs []T, rates model.ExchangeRates, currency string) {
+	if currency == "" {
+		return
+	}
+	for i := range docs {
+		if priced, ok := any(&docs[i]).(model.Priced); ok {
+			priced.ConvertPrices(rates, currency)
+		}
+	}
+}
+
+// hotelCurrency is priceCurrency for the hotel handlers, which write the
+// error response themselves
+func (server *Server) hotelCurrency(ctx context.Context, c *gin.Context, field, currency string) (string, model.ExchangeRates, bool) {
+	currency, rates, err := server.priceCurrency(ctx, field, currency)
+	var fields model.FieldErrors
+	switch {
+	case errors.As(err, &fields):
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return "", nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return "", nil, false
+	}
+	return currency, rates, true
+}
+
+// pricesInCurrency is a Resource.Present hook that converts prices to the
+// currency query parameter
+func pricesInCurrency[T any](server *Server) func(c *gin.Context, docs []T) error {
+	return func(c *gin.Context, docs []T) error {
+		currency, rates, err := server.priceCurrency(c.Request.Context(), "currency", c.Query("currency"))
+		var fields model.FieldErrors
+		switch {
+		case errors.As(err, &fields):
+			return &StatusError{Status: http.StatusBadRequest, Err: err}
+		case err != nil:
+			return &StatusError{Status: http.StatusInternalServerError, Err: err}
+		}
+		convertPrices(docs, rates, currency)
+		return nil
+	}
+}
+
+// priceConditions selects the documents whose price is between minPrice
+// and maxPrice, both in currency. Zero bounds are open. The bounds are
+// converted to every currency with a rate, so documents priced in different
+// currencies compare by value; documents in a currency without a rate are
+// left out.
+// A document without a currency is priced in model.BaseCurrency.
+func priceConditions(rates model.ExchangeRates, currency string, minPrice, maxPrice float64) []repository.Condition {
+	if minPrice <= 0 && maxPrice <= 0 {
+		return nil
+	}
+	if currency == "" {
+		currency = model.BaseCurrency
+	}
+
+	var alternatives [][]repository.Condition
+	for _, to := range rates.Currencies() {
+		var bounds []repository.Condition
+		if minPrice > 0 {
+			low, _ := rates.Convert(minPrice, currency, to)
+			bounds = append(bounds, repository.Gte("price", low))
+		}
+		if maxPrice > 0 {
+			high, _ := rates.Convert(maxPrice, currency, to)
+			bounds = append(bounds, repository.Lte("price", high))
+		}
+		alternatives = append(alternatives, append([]repository.Condition{repository.Eq("currency", to)}, bounds...))
+		if to == model.BaseCurrency {
+			alternatives = append(alternatives,
+				append([]repository.Condition{repository.Eq("currency", "")}, bounds...),
+				append([]repository.Condition{repository.Eq("currency", nil)}, bounds...))
+		}
+	}
+	return []repository.Condition{repository.Or(alternatives...)}
+}
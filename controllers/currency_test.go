@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestCurrencyConversion(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/admin/exchange-rates", model.ExchangeRate{Currency: "EUR", Rate: 0.1}); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d for an anonymous rate, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+	for _, rate := range []model.ExchangeRate{{Currency: "eur", Rate: 0.5}, {Currency: "NGN", Rate: 1000}} {
+		if recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/admin/exchange-rates", rate); recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", rate.Currency, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+	}
+	if recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/admin/exchange-rates", model.ExchangeRate{Currency: "EUR", Rate: 0.6}); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a second EUR rate, got %d", http.StatusConflict, recorder.Code)
+	}
+	if recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/admin/exchange-rates", model.ExchangeRate{Currency: "USD", Rate: 2}); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for a base rate other than 1, got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/exchange-rates", nil); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "NGN") {
+		t.Errorf("expected the rates to be public, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/exchange-rates", model.ExchangeRate{Currency: "GBP", Rate: 0.8}); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected no public route to create rates, got %d", recorder.Code)
+	}
+
+	// 120, 101.10, 150 and an unknown amount of dollars
+	hotels := []model.Hotel{
+		{Title: "Harbour View", Provider: "Expedia", Price: 120, Currency: "USD", Rating: 4.5},
+		{Title: "Rive Gauche", Provider: "Expedia", Price: 50.55, Currency: "EUR", Rating: 4.4, RoomTypes: []model.RoomType{{ID: "double", Name: "Double", Price: 60, MaxGuests: 2}}},
+		{Title: "City Lodge", Provider: "Expedia", Price: 150000, Currency: "NGN", Rating: 4.3},
+		{Title: "Thames House", Provider: "Expedia", Price: 40, Currency: "GBP", Rating: 4.2},
+	}
+	for i := range hotels {
+		if err := server.store.Hotels.Create(ctx, &hotels[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := func(params string) []model.Hotel {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/filter?page_id=1&page_size=5&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.Hotel `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		return list.Data
+	}
+	if list := filter("max_price=110"); len(list) != 1 || list[0].Title != "Rive Gauche" || list[0].Price != 50.55 {
+		t.Errorf("expected only the hotel costing 101.10 dollars, as stored, got %+v", list)
+	}
+	list := filter("currency=eur&min_price=55&max_price=80")
+	if len(list) != 2 || list[0].Title != "Harbour View" || list[1].Title != "City Lodge" {
+		t.Fatalf("expected the hotels costing 60 and 75 euros, got %+v", list)
+	}
+	if list[0].Price != 60 || list[0].Currency != "EUR" || list[1].Price != 75 {
+		t.Errorf("expected prices in euros, got %+v", list)
+	}
+
+	// Yen have no minor unit, and the cached table picks up a new rate
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/"+hotels[1].ID.Hex()+"?currency=JPY", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for a currency without a rate, got %d", http.StatusBadRequest, recorder.Code)
+	}
+	if recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/admin/exchange-rates", model.ExchangeRate{Currency: "JPY", Rate: 150.37}); recorder.Code != http.StatusCreated {
+		t.Fatalf("create JPY: expected %d, got %d", http.StatusCreated, recorder.Code)
+	}
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/"+hotels[1].ID.Hex()+"?currency=JPY", nil)
+	var hotel model.Hotel
+	if err := json.Unmarshal(recorder.Body.Bytes(), &hotel); err != nil {
+		t.Fatal(err)
+	}
+	if hotel.Price != 15202 || hotel.RoomTypes[0].Price != 18044 || hotel.Currency != "JPY" {
+		t.Errorf("expected whole yen, got %v and %v %s", hotel.Price, hotel.RoomTypes[0].Price, hotel.Currency)
+	}
+	if got := model.FormatPrice("JPY", hotel.Price); got != "¥15,202" {
+		t.Errorf("expected ¥15,202, got %s", got)
+	}
+}
@@ -3,16 +3,18 @@ package controllers
 import (
 	"context"
 	"errors"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/janto-pee/Horizon-Travels.git/model"
-	"github.com/janto-pee/Horizon-Travels.git/util"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Common success response function
@@ -30,58 +32,50 @@ type Pagination struct {
 	PageSize int64 `form:"page_size" binding:"required,min=5,max=100"`
 }
 
+// paginationResponse describes the page that was returned out of total
+func paginationResponse(pageID, pageSize int64, count int, total int64) gin.H {
+	return gin.H{
+		"page_id":   pageID,
+		"page_size": pageSize,
+		"count":     count,
+		"total":     total,
+		"pages":     (total + pageSize - 1) / pageSize,
+	}
+}
+
 // List Hotels with pagination
 type ListHotelsRequest struct {
 	Pagination
+	Currency string `form:"currency"`
 }
 
-func ListHotels(c *gin.Context) {
+func (server *Server) ListHotels(c *gin.Context) {
 	var req ListHotelsRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	skip := (req.PageID - 1) * req.PageSize
-	opts := options.Find().
-		SetLimit(req.PageSize).
-		SetSkip(skip).
-		SetSort(bson.D{{"created_at", -1}})
-
-	// Count total documents for better pagination
-	total, err := util.Db.CountDocuments(ctx, bson.D{{}})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
 		return
 	}
-
-	cursor, err := util.Db.Find(ctx, bson.D{{}}, opts)
+	query := repository.Query{Sort: []repository.SortField{repository.Desc("created_at")}}
+	hotels, total, err := server.store.Hotels.List(ctx, query.Page(req.PageID, req.PageSize))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var hotels []model.Hotel
-	if err = cursor.All(ctx, &hotels); err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
+	convertPrices(hotels, rates, currency)
 
-	c.JSON(http.StatusOK, successResponse(hotels, gin.H{
-		"page_id":   req.PageID,
-		"page_size": req.PageSize,
-		"count":     len(hotels),
-		"total":     total,
-		"pages":     (total + req.PageSize - 1) / req.PageSize,
-	}))
+	c.JSON(http.StatusOK, successResponse(hotels, paginationResponse(req.PageID, req.PageSize, len(hotels), total)))
 }
 
 // Get Hotel by ID
-func GetHotelByID(c *gin.Context) {
+func (server *Server) GetHotelByID(c *gin.Context) {
 	idStr := c.Param("id")
 
 	id, err := primitive.ObjectIDFromHex(idStr)
@@ -90,182 +84,511 @@ func GetHotelByID(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	var hotel model.Hotel
-	err = util.Db.FindOne(ctx, bson.D{{"_id", id}}).Decode(&hotel)
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", c.Query("currency"))
+	if !ok {
+		return
+	}
+	hotel, err := server.store.Hotels.Get(ctx, id)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusNotFound, errorResponse(errors.New("hotel not found")))
 			return
 		}
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	if currency != "" {
+		hotel.ConvertPrices(rates, currency)
+	}
 
 	c.JSON(http.StatusOK, hotel)
 }
 
 // Search Hotels
 type SearchHotelsRequest struct {
-	PageID   int    `form:"page_id" binding:"required,min=1"`
-	PageSize int    `form:"page_size" binding:"required,min=5,max=100"`
+	PageID   int64  `form:"page_id" binding:"required,min=1"`
+	PageSize int64  `form:"page_size" binding:"required,min=5,max=100"`
 	Keyword  string `form:"keyword" binding:"required,min=1"`
+	Currency string `form:"currency"`
 }
 
-func SearchHotels(c *gin.Context) {
+func (server *Server) SearchHotels(c *gin.Context) {
 	var req SearchHotelsRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.D{{"$text", bson.D{{"$search", req.Keyword}}}}
-	skip := (req.PageID - 1) * req.PageSize
-
-	// Count total matching documents
-	total, err := util.Db.CountDocuments(ctx, filter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
 		return
 	}
-
-	opts := options.Find().
-		SetLimit(int64(req.PageSize)).
-		SetSkip(int64(skip)).
-		// Add projection for text search score
-		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
-		// Sort by text search score
-		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}})
-
-	cursor, err := util.Db.Find(ctx, filter, opts)
+	// Results are ordered by text search relevance
+	query := repository.Query{Text: req.Keyword}
+	hotels, total, err := server.store.Hotels.List(ctx, query.Page(req.PageID, req.PageSize))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var hotels []model.Hotel
-	if err = cursor.All(ctx, &hotels); err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
+	convertPrices(hotels, rates, currency)
 
-	c.JSON(http.StatusOK, successResponse(hotels, gin.H{
-		"page_id":   req.PageID,
-		"page_size": req.PageSize,
-		"count":     len(hotels),
-		"total":     total,
-		"pages":     (total + int64(req.PageSize) - 1) / int64(req.PageSize),
-	}))
+	c.JSON(http.StatusOK, successResponse(hotels, paginationResponse(req.PageID, req.PageSize, len(hotels), total)))
 }
 
-// Filter Hotels
-type FilterHotelsRequest struct {
-	PageID    int64   `form:"page_id" binding:"required,min=1"`
-	PageSize  int64   `form:"page_size" binding:"required,min=5,max=100"`
+// HotelFilters are the query parameters that narrow down hotel listings.
+// Prices are in Currency, which the results are shown in as well, or in
+// model.BaseCurrency when it is not set.
+type HotelFilters struct {
 	Provider  string  `form:"provider"`
 	Location  string  `form:"location"`
 	MinPrice  float64 `form:"min_price"`
 	MaxPrice  float64 `form:"max_price"`
 	MinRating float64 `form:"min_rating"`
+	Currency  string  `form:"currency"`
+}
+
+// conditions translates the filters that were set into query conditions
+func (f HotelFilters) conditions(rates model.ExchangeRates) []repository.Condition {
+	var conditions []repository.Condition
+	if f.Provider != "" {
+		conditions = append(conditions, repository.Contains("provider", f.Provider))
+	}
+	if f.Location != "" {
+		conditions = append(conditions, repository.Contains("location.city", f.Location))
+	}
+	conditions = append(conditions, priceConditions(rates, f.Currency, f.MinPrice, f.MaxPrice)...)
+	if f.MinRating > 0 {
+		conditions = append(conditions, repository.Gte("rating", f.MinRating))
+	}
+	return conditions
+}
+
+// Filter Hotels
+type FilterHotelsRequest struct {
+	PageID   int64 `form:"page_id" binding:"required,min=1"`
+	PageSize int64 `form:"page_size" binding:"required,min=5,max=100"`
+	HotelFilters
 }
 
-func FilterHotels(c *gin.Context) {
+func (server *Server) FilterHotels(c *gin.Context) {
 	var req FilterHotelsRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	// Build filter
-	filter := bson.D{}
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
+		return
+	}
+	req.Currency = currency
+	query := repository.Query{
+		Conditions: req.conditions(rates),
+		Sort:       []repository.SortField{repository.Desc("rating")},
+	}
+	hotels, total, err := server.store.Hotels.List(ctx, query.Page(req.PageID, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	convertPrices(hotels, rates, currency)
+
+	c.JSON(http.StatusOK, successResponse(hotels, paginationResponse(req.PageID, req.PageSize, len(hotels), total)))
+}
 
-	if req.Provider != "" {
-		filter = append(filter, bson.E{"provider", bson.D{{"$regex", req.Provider}, {"$options", "i"}}})
+// Hotels near a point
+type NearbyHotelsRequest struct {
+	PageID   int64    `form:"page_id" binding:"required,min=1"`
+	PageSize int64    `form:"page_size" binding:"required,min=5,max=100"`
+	Lat      *float64 `form:"lat" binding:"required,min=-90,max=90"`
+	Lng      *float64 `form:"lng" binding:"required,min=-180,max=180"`
+	Radius   float64  `form:"radius" binding:"required,gt=0,max=20000"`
+	Unit     string   `form:"unit" binding:"omitempty,oneof=km mi"`
+	Sort     string   `form:"sort" binding:"omitempty,oneof=distance rating price"`
+	HotelFilters
+}
+
+// metersPer converts a distance unit of NearbyHotelsRequest to meters
+var metersPer = map[string]float64{"km": 1000, "mi": 1609.344}
+
+// NearbyHotel is a hotel with its distance from the searched point, in the
+// unit of the request
+type NearbyHotel struct {
+	model.Hotel
+	Distance float64 `json:"distance"`
+	Unit     string  `json:"distance_unit"`
+}
+
+// hotelLocationField stores a hotel's [longitude, latitude]
+const hotelLocationField = "location.coordinates"
+
+// SearchHotelsNearby lists the hotels within radius of lat and lng, nearest
+// first unless sort asks for the best rated or cheapest. The hotel filters
+// apply as well.
+func (server *Server) SearchHotelsNearby(c *gin.Context) {
+	var req NearbyHotelsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Unit == "" {
+		req.Unit = "km"
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
+		return
+	}
+	req.Currency = currency
+	center := repository.Point{Lng: *req.Lng, Lat: *req.Lat}
+	query := repository.Query{
+		Conditions: append(req.conditions(rates), repository.Near(hotelLocationField, center, req.Radius*metersPer[req.Unit])),
+	}
+	switch req.Sort {
+	case "rating":
+		query.Sort = []repository.SortField{repository.Desc("rating"), repository.ByDistance(hotelLocationField)}
+	case "price":
+		query.Sort = []repository.SortField{repository.Asc("price"), repository.ByDistance(hotelLocationField)}
+	default:
+		query.Sort = []repository.SortField{repository.ByDistance(hotelLocationField)}
 	}
 
-	if req.Location != "" {
-		filter = append(filter, bson.E{"location", bson.D{{"$regex", req.Location}, {"$options", "i"}}})
+	hotels, total, err := server.store.Hotels.List(ctx, query.Page(req.PageID, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 
-	if req.MinPrice > 0 || req.MaxPrice > 0 {
-		priceFilter := bson.D{}
-		if req.MinPrice > 0 {
-			priceFilter = append(priceFilter, bson.E{"$gte", req.MinPrice})
+	convertPrices(hotels, rates, currency)
+	results := make([]NearbyHotel, len(hotels))
+	for i, hotel := range hotels {
+		results[i] = NearbyHotel{Hotel: hotel, Unit: req.Unit}
+		if point, ok := repository.PointOf(hotel.Location.Coordinates); ok {
+			results[i].Distance = math.Round(repository.Distance(center, point)/metersPer[req.Unit]*1000) / 1000
 		}
-		if req.MaxPrice > 0 {
-			priceFilter = append(priceFilter, bson.E{"$lte", req.MaxPrice})
+	}
+	c.JSON(http.StatusOK, successResponse(results, paginationResponse(req.PageID, req.PageSize, len(results), total)))
+}
+
+// HotelQueryRequest takes the search parameters of request-template.sql.
+// List parameters accept repeated or comma-separated values.
+type HotelQueryRequest struct {
+	Pagination
+	Keyword  string `form:"keyword"`
+	Location string `form:"location"`
+	StayRequest
+	PricedSearch
+	Amenity         []string `form:"amenity"`
+	Neighborhood    []string `form:"neighborhood"`
+	Rating          float64  `form:"rating"`
+	Class           []string `form:"class"`
+	Style           []string `form:"style"`
+	Brand           []string `form:"brand"`
+	DistFrom        string   `form:"distFrom"`
+	DistFromMaxDist float64  `form:"distFromMaxDistance"`
+
+	center *repository.Point // set by Validate
+}
+
+// defaultDistFromMaxDistance is the radius in kilometers around distFrom
+// when distFromMaxDistance is not given
+const defaultDistFromMaxDistance = 5
+
+// hotelSorts are the orders a HotelQueryRequest can ask for
+var hotelSorts = sortOptions{
+	"recommended": {repository.Desc("rating"), repository.Desc("review_count")},
+	"rating":      {repository.Desc("rating")},
+	"popularity":  {repository.Desc("review_count")},
+	"price_low":   {repository.Asc("price")},
+	"price_high":  {repository.Desc("price")},
+	"newest":      {repository.Desc("created_at")},
+	"distance":    {repository.ByDistance(hotelLocationField)},
+}
+
+// Validate checks the parameters against each other and parses the ones
+// that are not plain values. It returns model.FieldErrors.
+func (r *HotelQueryRequest) Validate(now time.Time) error {
+	fields := model.FieldErrors{}
+
+	r.StayRequest.validate(fields, now)
+	r.PricedSearch.validate(fields, hotelSorts)
+
+	if r.Rating < 0 || r.Rating > 5 {
+		fields.Add("rating", "must be between 0 and 5")
+	}
+
+	if r.DistFrom != "" {
+		if center, ok := parsePoint(r.DistFrom); ok {
+			r.center = &center
+		} else {
+			fields.Add("distFrom", "must be a latitude,longitude pair")
 		}
-		filter = append(filter, bson.E{"price", priceFilter})
+	}
+	switch {
+	case r.DistFromMaxDist < 0 || r.DistFromMaxDist > 20000:
+		fields.Add("distFromMaxDistance", "must be between 0 and 20000 kilometers")
+	case r.DistFromMaxDist > 0 && r.DistFrom == "":
+		fields.Add("distFromMaxDistance", "requires distFrom")
 	}
 
-	if req.MinRating > 0 {
-		filter = append(filter, bson.E{"rating", bson.D{{"$gte", req.MinRating}}})
+	if contains(splitList(r.Sort), "distance") {
+		switch {
+		case r.DistFrom == "":
+			fields.Add("sort", "distance requires distFrom")
+		case r.Keyword != "":
+			// MongoDB cannot order the results of a text search by distance
+			fields.Add("sort", "distance cannot be combined with keyword")
+		}
 	}
+	return fields.Err()
+}
 
-	// Count total matching documents
-	total, err := util.Db.CountDocuments(ctx, filter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+// query translates the validated request into a repository query. Prices
+// are in currency, which has a rate.
+func (r *HotelQueryRequest) query(rates model.ExchangeRates, currency string) repository.Query {
+	filters := HotelFilters{Location: r.Location, MinPrice: r.PriceMin, MaxPrice: r.PriceMax, MinRating: r.Rating, Currency: currency}
+	conditions := filters.conditions(rates)
+
+	// A hotel needs every amenity asked for, and one of the values asked
+	// for in each tag group
+	for _, amenity := range splitList(r.Amenity) {
+		conditions = append(conditions, repository.Eq("amenities", amenity))
+	}
+	for _, group := range [][]string{r.Neighborhood, r.Class, r.Style, r.Brand} {
+		conditions = append(conditions, in("tags", splitList(group))...)
 	}
 
-	skip := (req.PageID - 1) * req.PageSize
-	opts := options.Find().
-		SetLimit(req.PageSize).
-		SetSkip(skip).
-		SetSort(bson.D{{"rating", -1}})
+	// Some room type must fit the party when it is spread over the rooms
+	if guests := r.guests(); guests > 0 {
+		rooms := max(r.Rooms, 1)
+		conditions = append(conditions, repository.Gte("room_types.max_guests", (guests+rooms-1)/rooms))
+	}
 
-	cursor, err := util.Db.Find(ctx, filter, opts)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+	if r.center != nil {
+		radius := r.DistFromMaxDist
+		if radius == 0 {
+			radius = defaultDistFromMaxDistance
+		}
+		conditions = append(conditions, repository.Near(hotelLocationField, *r.center, radius*metersPer["km"]))
+	}
+
+	fallback := "recommended"
+	if r.center != nil && r.Keyword == "" {
+		fallback = "distance"
+	}
+	return repository.Query{Conditions: conditions, Text: r.Keyword, Sort: r.order(hotelSorts, fallback)}
+}
+
+// QueryHotels searches hotels with the full set of client search parameters.
+// With checkIn and checkOut, hotels sold out for the stay are left out.
+func (server *Server) QueryHotels(c *gin.Context) {
+	var req HotelQueryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := req.Validate(time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-	defer cursor.Close(ctx)
 
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	field, code := req.currency()
+	currency, rates, ok := server.hotelCurrency(ctx, c, field, code)
+	if !ok {
+		return
+	}
+	query := req.query(rates, currency)
 	var hotels []model.Hotel
-	if err = cursor.All(ctx, &hotels); err != nil {
+	var total int64
+	var err error
+	if req.dated() {
+		hotels, total, err = server.availableHotels(ctx, query, &req.StayRequest, req.PageID, req.PageSize)
+	} else {
+		hotels, total, err = server.store.Hotels.List(ctx, query.Page(req.PageID, req.PageSize))
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, successResponse(hotels, gin.H{
-		"page_id":   req.PageID,
-		"page_size": req.PageSize,
-		"count":     len(hotels),
-		"total":     total,
-		"pages":     (total + req.PageSize - 1) / req.PageSize,
-	}))
+	convertPrices(hotels, rates, currency)
+	c.JSON(http.StatusOK, successResponse(hotels, paginationResponse(req.PageID, req.PageSize, len(hotels), total)))
+}
+
+// splitList flattens repeated and comma-separated query values, dropping
+// empty ones
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// lowerList is splitList with the values in lower case
+func lowerList(values []string) []string {
+	list := splitList(values)
+	for i := range list {
+		list[i] = strings.ToLower(list[i])
+	}
+	return list
+}
+
+// in matches field against any of list. It is no condition at all when list
+// is empty.
+func in(field string, list []string) []repository.Condition {
+	if len(list) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(list))
+	for i, item := range list {
+		values[i] = item
+	}
+	return []repository.Condition{repository.In(field, values...)}
+}
+
+// sortOptions are the orders a search can ask for, by name
+type sortOptions map[string][]repository.SortField
+
+// validate adds an error to fields when keys name an order that is not in
+// s, or name one twice
+func (s sortOptions) validate(fields model.FieldErrors, keys []string) {
+	seen := map[string]bool{}
+	for _, key := range keys {
+		switch {
+		case s[key] == nil:
+			names := make([]string, 0, len(s))
+			for name := range s {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fields.Add("sort", "must be one of "+strings.Join(names, ", "))
+		case seen[key]:
+			fields.Add("sort", "cannot repeat "+key)
+		}
+		seen[key] = true
+	}
+}
+
+// order chains the orders keys name, or is the fallback order when keys
+// name none
+func (s sortOptions) order(keys []string, fallback string) []repository.SortField {
+	var order []repository.SortField
+	for _, key := range keys {
+		order = append(order, s[key]...)
+	}
+	if len(order) == 0 {
+		return s[fallback]
+	}
+	return order
+}
+
+// PricedSearch takes the price range, currency and sort parameters the
+// hotel, vacation rental and car searches share
+type PricedSearch struct {
+	PriceMin     float64  `form:"priceMin"`
+	PriceMax     float64  `form:"priceMax"`
+	CurrencyCode string   `form:"currencyCode"` // same as currency, which wins
+	Currency     string   `form:"currency"`
+	Sort         []string `form:"sort"`
+}
+
+// validate adds the errors of the price range, the currency and the sort,
+// which must name orders in sorts, to fields
+func (r *PricedSearch) validate(fields model.FieldErrors, sorts sortOptions) {
+	if r.PriceMin < 0 {
+		fields.Add("priceMin", "cannot be negative")
+	}
+	if r.PriceMax < 0 {
+		fields.Add("priceMax", "cannot be negative")
+	} else if r.PriceMax > 0 && r.PriceMax < r.PriceMin {
+		fields.Add("priceMax", "cannot be less than priceMin")
+	}
+	if r.CurrencyCode != "" && !isCurrencyCode(r.CurrencyCode) {
+		fields.Add("currencyCode", "must be a 3-letter currency code such as USD")
+	}
+	if r.Currency != "" && !isCurrencyCode(r.Currency) {
+		fields.Add("currency", "must be a 3-letter currency code such as USD")
+	}
+	sorts.validate(fields, splitList(r.Sort))
+}
+
+// currency is the parameter prices are shown and filtered in, and its name
+func (r *PricedSearch) currency() (field, currency string) {
+	if r.Currency != "" {
+		return "currency", r.Currency
+	}
+	return "currencyCode", r.CurrencyCode
+}
+
+// order is the order the search asked for among sorts, or the fallback one
+func (r *PricedSearch) order(sorts sortOptions, fallback string) []repository.SortField {
+	return sorts.order(splitList(r.Sort), fallback)
+}
+
+// parsePoint reads a "latitude,longitude" pair
+func parsePoint(s string) (repository.Point, bool) {
+	lat, lng, found := strings.Cut(s, ",")
+	if !found {
+		return repository.Point{}, false
+	}
+	y, err1 := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	x, err2 := strconv.ParseFloat(strings.TrimSpace(lng), 64)
+	if err1 != nil || err2 != nil || math.Abs(y) > 90 || math.Abs(x) > 180 {
+		return repository.Point{}, false
+	}
+	return repository.Point{Lng: x, Lat: y}, true
+}
+
+// isCurrencyCode reports whether s looks like an ISO 4217 code
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range strings.ToUpper(s) {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
 }
 
 // Aggregate Hotels
-func AggregateHotels(c *gin.Context) {
+func (server *Server) AggregateHotels(c *gin.Context) {
 	var pipeline []bson.M
 	if err := c.ShouldBindJSON(&pipeline); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	cursor, err := util.Db.Aggregate(ctx, pipeline)
+	result, err := server.store.Hotels.Aggregate(ctx, pipeline)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var result []bson.M
-	if err = cursor.All(ctx, &result); err != nil {
+		if errors.Is(err, repository.ErrUnsupported) {
+			c.JSON(http.StatusNotImplemented, errorResponse(err))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
@@ -275,27 +598,27 @@ func AggregateHotels(c *gin.Context) {
 
 // Create Hotel
 type CreateHotelRequest struct {
-	Title         string  `json:"title" binding:"required"`
-	Content       string  `json:"content" binding:"required"`
-	PrimaryInfo   string  `json:"primary_info"`
-	SecondaryInfo string  `json:"secondary_info"`
-	AccentedLabel string  `json:"accented_label"`
-	Provider      string  `json:"provider" binding:"required"`
-	PriceDetails  string  `json:"price_details"`
-	PriceSummary  string  `json:"price_summary"`
-	Price         float64 `json:"price" binding:"required"`
-	Location      string  `json:"location" binding:"required"`
-	Rating        float64 `json:"rating"`
-}
-
-func CreateHotel(c *gin.Context) {
+	Title         string           `json:"title" binding:"required"`
+	Content       string           `json:"content" binding:"required"`
+	PrimaryInfo   string           `json:"primary_info"`
+	SecondaryInfo string           `json:"secondary_info"`
+	AccentedLabel string           `json:"accented_label"`
+	Provider      string           `json:"provider" binding:"required"`
+	PriceDetails  string           `json:"price_details"`
+	PriceSummary  string           `json:"price_summary"`
+	Price         float64          `json:"price" binding:"required"`
+	Location      model.Location   `json:"location" binding:"required"`
+	RoomTypes     []model.RoomType `json:"room_types"`
+}
+
+func (server *Server) CreateHotel(c *gin.Context) {
 	var req CreateHotelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	now := time.Now()
@@ -311,36 +634,35 @@ func CreateHotel(c *gin.Context) {
 		PriceSummary:  req.PriceSummary,
 		Price:         req.Price,
 		Location:      req.Location,
-		Rating:        req.Rating,
+		RoomTypes:     req.RoomTypes,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
+	hotel.AssignRoomTypeIDs()
 
-	result, err := util.Db.InsertOne(ctx, hotel)
-	if err != nil {
+	if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	hotel.ID = result.InsertedID.(primitive.ObjectID)
 	c.JSON(http.StatusCreated, hotel)
 }
 
 // Update Hotel
 type UpdateHotelRequest struct {
-	Title         string  `json:"title"`
-	Content       string  `json:"content"`
-	PrimaryInfo   string  `json:"primary_info"`
-	SecondaryInfo string  `json:"secondary_info"`
-	AccentedLabel string  `json:"accented_label"`
-	Provider      string  `json:"provider"`
-	PriceDetails  string  `json:"price_details"`
-	PriceSummary  string  `json:"price_summary"`
-	Location      string  `json:"location"`
-	Rating        float64 `json:"rating"`
-}
-
-func UpdateHotel(c *gin.Context) {
+	Title         string           `json:"title"`
+	Content       string           `json:"content"`
+	PrimaryInfo   string           `json:"primary_info"`
+	SecondaryInfo string           `json:"secondary_info"`
+	AccentedLabel string           `json:"accented_label"`
+	Provider      string           `json:"provider"`
+	PriceDetails  string           `json:"price_details"`
+	PriceSummary  string           `json:"price_summary"`
+	Location      *model.Location  `json:"location"`
+	RoomTypes     []model.RoomType `json:"room_types"` // replaces every room type when set
+}
+
+func (server *Server) UpdateHotel(c *gin.Context) {
 	idStr := c.Param("id")
 
 	id, err := primitive.ObjectIDFromHex(idStr)
@@ -355,72 +677,62 @@ func UpdateHotel(c *gin.Context) {
 		return
 	}
 
-	// Check if hotel exists
-	var existingHotel model.Hotel
-	err = util.Db.FindOne(context.TODO(), bson.D{{"_id", id}}).Decode(&existingHotel)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	// The rating and review count are kept up to date by reviews and
+	// ratings, so the edit never writes over a score folded in meanwhile
+	hotel, err := server.store.Hotels.Edit(ctx, id, req.apply)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Hotel not found"})
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hotel not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	// Build update document
-	update := bson.M{"$set": bson.M{"updated_at": time.Now()}}
+	c.JSON(http.StatusOK, hotel)
+}
 
+// apply sets the fields of hotel that were provided
+func (req UpdateHotelRequest) apply(hotel *model.Hotel) {
 	if req.Title != "" {
-		update["$set"].(bson.M)["title"] = req.Title
+		hotel.Title = req.Title
 	}
 	if req.Content != "" {
-		update["$set"].(bson.M)["content"] = req.Content
+		hotel.Content = req.Content
 	}
 	if req.PrimaryInfo != "" {
-		update["$set"].(bson.M)["primary_info"] = req.PrimaryInfo
+		hotel.PrimaryInfo = req.PrimaryInfo
 	}
 	if req.SecondaryInfo != "" {
-		update["$set"].(bson.M)["secondary_info"] = req.SecondaryInfo
+		hotel.SecondaryInfo = req.SecondaryInfo
 	}
 	if req.AccentedLabel != "" {
-		update["$set"].(bson.M)["accented_label"] = req.AccentedLabel
+		hotel.AccentedLabel = req.AccentedLabel
 	}
 	if req.Provider != "" {
-		update["$set"].(bson.M)["provider"] = req.Provider
+		hotel.Provider = req.Provider
 	}
 	if req.PriceDetails != "" {
-		update["$set"].(bson.M)["price_details"] = req.PriceDetails
+		hotel.PriceDetails = req.PriceDetails
 	}
 	if req.PriceSummary != "" {
-		update["$set"].(bson.M)["price_summary"] = req.PriceSummary
+		hotel.PriceSummary = req.PriceSummary
 	}
-	if req.Location != "" {
-		update["$set"].(bson.M)["location"] = req.Location
-	}
-	if req.Rating != 0 {
-		update["$set"].(bson.M)["rating"] = req.Rating
-	}
-
-	result, err := util.Db.UpdateByID(context.TODO(), id, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+	if req.Location != nil {
+		hotel.Location = *req.Location
 	}
-
-	if result.ModifiedCount == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "No changes applied"})
-		return
+	if req.RoomTypes != nil {
+		hotel.RoomTypes = req.RoomTypes
+		hotel.AssignRoomTypeIDs()
 	}
-
-	// Get updated hotel
-	var updatedHotel model.Hotel
-	err = util.Db.FindOne(context.TODO(), bson.D{{"_id", id}}).Decode(&updatedHotel)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	c.JSON(http.StatusOK, updatedHotel)
+	hotel.UpdatedAt = time.Now()
 }
 
 // Delete Hotel
-func DeleteHotel(c *gin.Context) {
+func (server *Server) DeleteHotel(c *gin.Context) {
 	idStr := c.Param("id")
 
 	id, err := primitive.ObjectIDFromHex(idStr)
@@ -429,14 +741,12 @@ func DeleteHotel(c *gin.Context) {
 		return
 	}
 
-	result, err := util.Db.DeleteOne(c, bson.M{"id": id})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating"})
-		return
-	}
-
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
+	if err := server.store.Hotels.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hotel not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete hotel"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "hotel deleted"})
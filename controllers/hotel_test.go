@@ -1,8 +1,21 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"github.com/janto-pee/Horizon-Travels.git/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestValidateRequestMethod(t *testing.T) {
@@ -41,17 +54,291 @@ func TestValidateRequestMethod(t *testing.T) {
 		},
 	}
 
-	// for _, tt := range tests {
-	// 	t.Run(tt.name, func(t *testing.T) {
-	// 		err := validateRequestMethod(tt.request, tt.allowedMethods...)
-	// 		if tt.expectError {
-	// 			assert.Error(t, err)
-	// 			assert.Equal(t, tt.errorMessage, err.Error())
-	// 		} else {
-	// 			assert.NoError(t, err)
-	// 		}
-	// 	})
-	// }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRequestMethod(tt.request, tt.allowedMethods...)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil", tt.errorMessage)
+				}
+				if err.Error() != tt.errorMessage {
+					t.Errorf("expected error %q, got %q", tt.errorMessage, err.Error())
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server, err := NewServer(util.Config{TokenSecret: testTokenSecret}, repository.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("could not create server: %v", err)
+	}
+	return server
+}
+
+func performRequest(server *Server, method, path string, body interface{}) *httptest.ResponseRecorder {
+	return performRequestAs(server, nil, method, path, body)
+}
+
+// testTokenSecret signs the tokens of the callers in tests
+const testTokenSecret = "test-secret"
+
+// testAdmin is the administrator callers in tests act as
+var testAdmin = &Identity{UserID: primitive.NewObjectID(), Admin: true}
+
+// performRequestAs makes a request as the caller identity, or anonymously
+// when it is nil
+func performRequestAs(server *Server, identity *Identity, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		payload, _ := json.Marshal(body)
+		reader = bytes.NewReader(payload)
+	}
+	request := httptest.NewRequest(method, path, reader)
+	request.Header.Set("Content-Type", "application/json")
+	if identity != nil {
+		token, _ := util.NewToken(testTokenSecret, util.TokenClaims{
+			Subject:   identity.UserID.Hex(),
+			Admin:     identity.Admin,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		})
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestHotelLifecycle(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := performRequest(server, http.MethodPost, "/api/v1/hotels", CreateHotelRequest{
+		Title:    "Harbour View",
+		Content:  "Waterfront rooms close to the marina",
+		Provider: "Expedia",
+		Price:    120,
+		Location: model.Location{City: "Lagos", Country: "NG"},
+	})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create: expected %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body)
+	}
+	var created model.Hotel
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	recorder = performRequest(server, http.MethodPut, "/api/v1/hotels/"+created.ID.Hex(), UpdateHotelRequest{Title: "Harbour View Hotel"})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("update: expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels/"+created.ID.Hex(), nil)
+	var fetched model.Hotel
+	if err := json.Unmarshal(recorder.Body.Bytes(), &fetched); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if fetched.Title != "Harbour View Hotel" || fetched.Provider != "Expedia" {
+		t.Errorf("unexpected hotel after update: %+v", fetched)
+	}
+
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels?page_id=1&page_size=5", nil)
+	var list struct {
+		Data       []model.Hotel  `json:"data"`
+		Pagination map[string]int `json:"pagination"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list.Data) != 1 || list.Pagination["total"] != 1 {
+		t.Errorf("expected one hotel in the listing, got %s", recorder.Body)
+	}
+
+	recorder = performRequest(server, http.MethodDelete, "/api/v1/hotels/"+created.ID.Hex(), nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("delete: expected %d, got %d", http.StatusOK, recorder.Code)
+	}
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels/"+created.ID.Hex(), nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("get after delete: expected %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestFilterHotels(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	for _, hotel := range []model.Hotel{
+		{Title: "Harbour View", Provider: "Expedia", Price: 120, Rating: 4.5, Location: model.Location{City: "Lagos"}},
+		{Title: "City Lodge", Provider: "Booking.com", Price: 80, Rating: 3.9, Location: model.Location{City: "Abuja"}},
+		{Title: "Palm Suites", Provider: "Expedia", Price: 200, Rating: 4.8, Location: model.Location{City: "Lagos"}},
+	} {
+		if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/filter?page_id=1&page_size=5&provider=expedia&max_price=150", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var list struct {
+		Data []model.Hotel `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Title != "Harbour View" {
+		t.Errorf("unexpected filter result: %s", recorder.Body)
+	}
+}
+
+func TestSearchHotelsNearby(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	// Victoria Island, Ikeja and Abuja, searched from Lagos Island
+	for _, hotel := range []model.Hotel{
+		{Title: "Harbour View", Provider: "Expedia", Price: 120, Rating: 4.5, Location: model.Location{City: "Lagos", Coordinates: []float64{3.4219, 6.4281}}},
+		{Title: "Airport Inn", Provider: "Expedia", Price: 90, Rating: 4.9, Location: model.Location{City: "Lagos", Coordinates: []float64{3.3515, 6.6018}}},
+		{Title: "City Lodge", Provider: "Booking.com", Price: 80, Rating: 3.9, Location: model.Location{City: "Abuja", Coordinates: []float64{7.4951, 9.0579}}},
+		{Title: "Nowhere Hotel", Provider: "Expedia", Price: 50, Rating: 5, Location: model.Location{City: "Lagos"}},
+	} {
+		if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	var list struct {
+		Data []NearbyHotel `json:"data"`
+	}
+	search := func(params string) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/location?page_id=1&page_size=5&lat=6.4550&lng=3.3841&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		list.Data = nil
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search("radius=30")
+	if len(list.Data) != 2 || list.Data[0].Title != "Harbour View" || list.Data[1].Title != "Airport Inn" {
+		t.Fatalf("expected the two Lagos hotels nearest first, got %+v", list.Data)
+	}
+	if d := list.Data[0].Distance; d < 4.5 || d > 5.5 || list.Data[0].Unit != "km" {
+		t.Errorf("expected Harbour View about 5 km away, got %v %s", d, list.Data[0].Unit)
+	}
+
+	search("radius=30&unit=mi&sort=rating&max_price=100")
+	if len(list.Data) != 1 || list.Data[0].Title != "Airport Inn" || list.Data[0].Unit != "mi" {
+		t.Errorf("expected the filters to apply within the radius, got %+v", list.Data)
+	}
+
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/location?page_id=1&page_size=5&lat=95&lng=3&radius=10", nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an invalid latitude, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestQueryHotels(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	family := []model.RoomType{{Name: "Family Suite", MaxGuests: 4}, {Name: "Double", MaxGuests: 2}}
+	double := []model.RoomType{{Name: "Double", MaxGuests: 2}}
+	for _, hotel := range []model.Hotel{
+		{Title: "Harbour View", Price: 120, Currency: "USD", Rating: 4.5, ReviewCount: 80, Amenities: []string{"pool", "wifi"}, Tags: []string{"Victoria Island", "Luxury"}, RoomTypes: family, Location: model.Location{City: "Lagos", Coordinates: []float64{3.4219, 6.4281}}},
+		{Title: "Airport Inn", Price: 90, Currency: "USD", Rating: 4.5, ReviewCount: 300, Amenities: []string{"wifi"}, Tags: []string{"Ikeja", "Business"}, RoomTypes: double, Location: model.Location{City: "Lagos", Coordinates: []float64{3.3515, 6.6018}}},
+		{Title: "Palm Suites", Price: 200, Currency: "USD", Rating: 4.8, ReviewCount: 20, Amenities: []string{"pool", "wifi", "spa"}, Tags: []string{"Victoria Island", "Luxury"}, RoomTypes: family, Location: model.Location{City: "Lagos", Coordinates: []float64{3.4300, 6.4300}}},
+		{Title: "City Lodge", Price: 30000, Currency: "NGN", Rating: 3.9, Amenities: []string{"wifi"}, RoomTypes: double, Location: model.Location{City: "Abuja", Coordinates: []float64{7.4951, 9.0579}}},
+	} {
+		if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	search := func(params string) []string {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/query?page_id=1&page_size=5&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.Hotel `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		titles := make([]string, len(list.Data))
+		for i, hotel := range list.Data {
+			titles[i] = hotel.Title
+		}
+		return titles
+	}
+	expect := func(params string, want ...string) {
+		t.Helper()
+		got := search(params)
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s: expected %v, got %v", params, want, got)
+		}
+	}
+
+	expect("", "Palm Suites", "Airport Inn", "Harbour View", "City Lodge")
+	expect("amenity=pool,wifi&neighborhood=Victoria+Island&sort=price_low", "Harbour View", "Palm Suites")
+	expect("adults=3&childrenAges=4&sort=rating", "Palm Suites", "Harbour View")
+	expect("adults=4&rooms=2&style=Business", "Airport Inn")
+	expect("currencyCode=usd&priceMax=150&sort=popularity", "Airport Inn", "Harbour View")
+	expect("distFrom=6.4550,3.3841&distFromMaxDistance=30", "Harbour View", "Palm Suites", "Airport Inn")
+	expect("distFrom=6.4550,3.3841&distFromMaxDistance=30&sort=rating,distance", "Palm Suites", "Harbour View", "Airport Inn")
+	// A keyword search near a point is not ordered by distance
+	expect("keyword=suites&distFrom=6.4550,3.3841&distFromMaxDistance=30", "Palm Suites")
+
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/query?page_id=1&page_size=5&keyword=harbour&distFrom=6.4550,3.3841&sort=distance", nil)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "distance cannot be combined with keyword") {
+		t.Errorf("expected %d for a keyword search sorted by distance, got %d: %s", http.StatusBadRequest, recorder.Code, recorder.Body)
+	}
+
+	checkIn := time.Now().UTC().AddDate(0, 0, 10).Format("2006-01-02")
+	checkOut := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels/query?page_id=1&page_size=5&checkIn="+checkIn+"&checkOut="+checkOut+"&rooms=3&adults=2&sort=distance&priceMin=50&priceMax=10", nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d: %s", http.StatusBadRequest, recorder.Code, recorder.Body)
+	}
+	var invalid struct {
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &invalid); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"checkOut", "rooms", "sort", "priceMax"} {
+		if invalid.Fields[field] == "" {
+			t.Errorf("expected an error for %s, got %v", field, invalid.Fields)
+		}
+	}
+}
+
+func TestLegacyHotelRoutesAreDeprecated(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := performRequest(server, http.MethodGet, "/api/hotels?page_id=1&page_size=5", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if recorder.Header().Get("Deprecation") == "" {
+		t.Errorf("legacy route did not send a Deprecation header")
+	}
+	if link := recorder.Header().Get("Link"); link != `</api/v1/hotels>; rel="successor-version"` {
+		t.Errorf("unexpected Link header %q", link)
+	}
+
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels?page_id=1&page_size=5", nil)
+	if recorder.Header().Get("Deprecation") != "" {
+		t.Errorf("v1 route must not be marked deprecated")
+	}
 }
 
 // func TestExtractParams(t *testing.T) {
@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dateLayout is the format of the dates of stays and calendars
+const dateLayout = "2006-01-02"
+
+// maxStayNights bounds stays and availability queries
+const maxStayNights = 30
+
+// maxCalendarNights bounds the range of one calendar read or update
+const maxCalendarNights = 366
+
+// StayRequest is the dates and party of a stay, with the parameter names of
+// request-template.sql
+type StayRequest struct {
+	CheckIn      string   `form:"checkIn"`
+	CheckOut     string   `form:"checkOut"`
+	Adults       int      `form:"adults"`
+	Rooms        int      `form:"rooms"`
+	ChildrenAges []string `form:"childrenAges"`
+
+	// Set by validate
+	checkIn, checkOut time.Time
+	childrenAges      []int
+}
+
+// validate adds the problems with the stay to fields and parses the dates
+// and ages. The dates are optional, but go together.
+func (r *StayRequest) validate(fields model.FieldErrors, now time.Time) {
+	if r.CheckIn != "" || r.CheckOut != "" {
+		dates := model.FieldErrors{}
+		if r.CheckIn == "" {
+			dates.Add("checkIn", "is required with checkOut")
+		}
+		if r.CheckOut == "" {
+			dates.Add("checkOut", "is required with checkIn")
+		}
+		var err error
+		if r.checkIn, err = time.Parse(dateLayout, r.CheckIn); err != nil {
+			dates.Add("checkIn", "must be a date in YYYY-MM-DD format")
+		}
+		if r.checkOut, err = time.Parse(dateLayout, r.CheckOut); err != nil {
+			dates.Add("checkOut", "must be a date in YYYY-MM-DD format")
+		}
+		if _, invalid := dates["checkIn"]; !invalid && r.checkIn.Before(model.Night(now)) {
+			dates.Add("checkIn", "cannot be in the past")
+		}
+		if len(dates) == 0 {
+			switch nights := len(model.Nights(r.checkIn, r.checkOut)); {
+			case nights == 0:
+				dates.Add("checkOut", "must be after checkIn")
+			case nights > maxStayNights:
+				dates.Add("checkOut", fmt.Sprintf("must be at most %d nights after checkIn", maxStayNights))
+			}
+		}
+		for field, message := range dates {
+			fields.Add(field, message)
+		}
+	}
+
+	for _, value := range splitList(r.ChildrenAges) {
+		age, err := strconv.Atoi(value)
+		if err != nil || age < 0 || age > 17 {
+			fields.Add("childrenAges", "must be ages between 0 and 17")
+			break
+		}
+		r.childrenAges = append(r.childrenAges, age)
+	}
+	switch {
+	case r.Adults < 0 || r.Adults > 32:
+		fields.Add("adults", "must be between 1 and 32")
+	case r.Adults == 0 && len(r.childrenAges) > 0:
+		fields.Add("adults", "is required when travelling with children")
+	}
+	switch {
+	case r.Rooms < 0 || r.Rooms > 8:
+		fields.Add("rooms", "must be between 1 and 8")
+	case r.Rooms > 0 && r.Adults == 0:
+		fields.Add("adults", "is required with rooms")
+	case r.Rooms > r.Adults:
+		fields.Add("rooms", "cannot exceed adults")
+	}
+}
+
+// validateDated validates a stay that must have dates, returning
+// model.FieldErrors
+func (r *StayRequest) validateDated(now time.Time) error {
+	fields := model.FieldErrors{}
+	if r.CheckIn == "" && r.CheckOut == "" {
+		fields.Add("checkIn", "is required")
+		fields.Add("checkOut", "is required")
+	}
+	r.validate(fields, now)
+	return fields.Err()
+}
+
+// dated reports whether the stay has dates. Call it after validate.
+func (r *StayRequest) dated() bool {
+	return !r.checkIn.IsZero() && !r.checkOut.IsZero()
+}
+
+// guests counts the adults and children of the stay
+func (r *StayRequest) guests() int {
+	return r.Adults + len(r.childrenAges)
+}
+
+// availabilityBatch is how many matching hotels availableHotels checks at once
+const availabilityBatch = 100
+
+// availableHotels lists the page of hotels matching query that still have a
+// room type fitting the party for the nights of stay, with how many such
+// hotels there are. Matching hotels are read a batch at a time and only their
+// inventory is loaded, so the work follows the hotels the search matches
+// rather than the whole catalogue.
+func (server *Server) availableHotels(ctx context.Context, query repository.Query, stay *StayRequest, pageID, pageSize int64) ([]model.Hotel, int64, error) {
+	skip := (pageID - 1) * pageSize
+	page := make([]model.Hotel, 0, pageSize)
+	var available int64
+	for batch := query.Page(1, availabilityBatch); ; batch.Skip += availabilityBatch {
+		hotels, total, err := server.store.Hotels.List(ctx, batch)
+		if err != nil {
+			return nil, 0, err
+		}
+		soldOut, err := server.soldOutHotels(ctx, hotels, stay)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, hotel := range hotels {
+			if soldOut[hotel.ID] {
+				continue
+			}
+			if available >= skip && int64(len(page)) < pageSize {
+				page = append(page, hotel)
+			}
+			available++
+		}
+		if len(hotels) == 0 || batch.Skip+availabilityBatch >= total {
+			return page, available, nil
+		}
+	}
+}
+
+// soldOutHotels reports which of hotels manage inventory for the nights of
+// stay but have no room type left that fits the party. Hotels without
+// inventory for those nights are not considered sold out.
+func (server *Server) soldOutHotels(ctx context.Context, hotels []model.Hotel, stay *StayRequest) (map[primitive.ObjectID]bool, error) {
+	if len(hotels) == 0 {
+		return nil, nil
+	}
+	hotelIDs := make([]interface{}, len(hotels))
+	for i := range hotels {
+		hotelIDs[i] = hotels[i].ID
+	}
+	nights, _, err := server.store.Inventory.List(ctx, repository.Query{Conditions: []repository.Condition{
+		repository.In("hotel_id", hotelIDs...),
+		repository.Gte("date", stay.checkIn),
+		repository.Lt("date", stay.checkOut),
+	}})
+	if err != nil || len(nights) == 0 {
+		return nil, err
+	}
+
+	byHotel := map[primitive.ObjectID][]model.Inventory{}
+	for _, night := range nights {
+		byHotel[night.HotelID] = append(byHotel[night.HotelID], night)
+	}
+	guests, rooms := max(stay.guests(), 1), max(stay.Rooms, 1)
+	soldOut := map[primitive.ObjectID]bool{}
+	for i := range hotels {
+		if byHotel[hotels[i].ID] == nil {
+			continue
+		}
+		if len(model.AvailableRooms(&hotels[i], byHotel[hotels[i].ID], stay.checkIn, stay.checkOut, guests, rooms)) == 0 {
+			soldOut[hotels[i].ID] = true
+		}
+	}
+	return soldOut, nil
+}
+
+// hotelFromPath loads the hotel whose ID is in the path, writing the error
+// response and returning false when it cannot
+func (server *Server) hotelFromPath(ctx context.Context, c *gin.Context) (*model.Hotel, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return nil, false
+	}
+	hotel, err := server.store.Hotels.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(errors.New("hotel not found")))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	return hotel, true
+}
+
+// Hotel availability
+type AvailabilityRequest struct {
+	StayRequest
+}
+
+// GetHotelAvailability lists the room types of a hotel that can host the
+// party on every night of the stay. Adults default to one.
+func (server *Server) GetHotelAvailability(c *gin.Context) {
+	var req AvailabilityRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := req.validateDated(time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hotel, ok := server.hotelFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	nights, err := server.store.Inventory.Nights(ctx, hotel.ID, "", req.checkIn, req.checkOut)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rooms := model.AvailableRooms(hotel, nights, req.checkIn, req.checkOut, max(req.guests(), 1), max(req.Rooms, 1))
+	if rooms == nil {
+		rooms = []model.RoomAvailability{}
+	}
+	c.JSON(http.StatusOK, successResponse(rooms, nil))
+}
+
+// Hotel inventory calendar
+type CalendarRequest struct {
+	RoomTypeID string `form:"room_type_id"`
+	From       string `form:"from" binding:"required"`
+	To         string `form:"to" binding:"required"` // inclusive
+}
+
+// GetHotelInventory returns a hotel's inventory for the nights from from to
+// to, by room type and date
+func (server *Server) GetHotelInventory(c *gin.Context) {
+	var req CalendarRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	fields := model.FieldErrors{}
+	from, to := parseDateRange(fields, "", req.From, req.To)
+	if err := fields.Err(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hotel, ok := server.hotelFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	nights, err := server.store.Inventory.Nights(ctx, hotel.ID, req.RoomTypeID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if nights == nil {
+		nights = []model.Inventory{}
+	}
+	c.JSON(http.StatusOK, successResponse(nights, nil))
+}
+
+// CalendarUpdate sets the units or arrival restriction of one room type
+// over a range of nights. Fields left out keep their stored value.
+type CalendarUpdate struct {
+	RoomTypeID      string `json:"room_type_id"`
+	From            string `json:"from"`
+	To              string `json:"to"` // inclusive, defaults to From
+	Units           *int   `json:"units"`
+	ClosedToArrival *bool  `json:"closed_to_arrival"`
+}
+
+// Bulk hotel inventory update
+type UpdateInventoryRequest struct {
+	Updates []CalendarUpdate `json:"updates" binding:"required,min=1"`
+}
+
+// UpdateHotelInventory applies calendar updates in order. Nights without
+// inventory are created, with no units unless the update sets them. Every
+// update is checked before any is written.
+func (server *Server) UpdateHotelInventory(c *gin.Context) {
+	var req UpdateInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	hotel, ok := server.hotelFromPath(ctx, c)
+	if !ok {
+		return
+	}
+
+	// Merge the updates into the stored nights they cover
+	fields := model.FieldErrors{}
+	type key struct {
+		roomTypeID string
+		date       time.Time
+	}
+	changed := map[key]*model.Inventory{}
+	var order []key
+	for i, update := range req.Updates {
+		prefix := fmt.Sprintf("updates[%d].", i)
+		problems := len(fields)
+		if _, ok := hotel.RoomType(update.RoomTypeID); !ok {
+			fields.Add(prefix+"room_type_id", "is not a room type of this hotel")
+		}
+		if update.Units != nil && *update.Units < 0 {
+			fields.Add(prefix+"units", "cannot be negative")
+		}
+		if update.To == "" {
+			update.To = update.From
+		}
+		from, to := parseDateRange(fields, prefix, update.From, update.To)
+		if len(fields) > problems {
+			continue
+		}
+
+		stored, err := server.store.Inventory.Nights(ctx, hotel.ID, update.RoomTypeID, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		for j := range stored {
+			k := key{update.RoomTypeID, model.Night(stored[j].Date)}
+			if _, ok := changed[k]; !ok {
+				changed[k] = &stored[j]
+			}
+		}
+		for _, date := range model.Nights(from, to) {
+			k := key{update.RoomTypeID, date}
+			night, ok := changed[k]
+			if !ok {
+				night = &model.Inventory{HotelID: hotel.ID, RoomTypeID: update.RoomTypeID, Date: date}
+				changed[k] = night
+			}
+			if update.Units != nil {
+				if *update.Units < night.Sold {
+					fields.Add(prefix+"units", fmt.Sprintf("cannot be less than the %d units sold on %s", night.Sold, date.Format(dateLayout)))
+				}
+				night.Units = *update.Units
+			}
+			if update.ClosedToArrival != nil {
+				night.ClosedToArrival = *update.ClosedToArrival
+			}
+			order = append(order, k)
+		}
+	}
+	if err := fields.Err(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return
+	}
+
+	now := time.Now()
+	saved := make([]model.Inventory, 0, len(order))
+	written := map[key]bool{}
+	for _, k := range order {
+		if written[k] {
+			continue
+		}
+		written[k] = true
+		night := changed[k]
+		night.UpdatedAt = now
+		var err error
+		if night.ID.IsZero() {
+			err = server.store.Inventory.Create(ctx, night)
+		} else {
+			// Bookings may have sold units since the night was read
+			err = server.store.Inventory.UpdateCalendar(ctx, night)
+		}
+		switch {
+		case errors.Is(err, repository.ErrUnavailable), errors.Is(err, repository.ErrConflict):
+			c.JSON(http.StatusConflict, errorResponse(fmt.Errorf("%s on %s: %w", night.RoomTypeID, night.Date.Format(dateLayout), err)))
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		saved = append(saved, *night)
+	}
+	c.JSON(http.StatusOK, successResponse(saved, nil))
+}
+
+// parseDateRange parses an inclusive range of dates, adding problems to
+// fields under prefix. It returns the first night and the day after the
+// last.
+func parseDateRange(fields model.FieldErrors, prefix, fromValue, toValue string) (from, to time.Time) {
+	from, err := time.Parse(dateLayout, fromValue)
+	if err != nil {
+		fields.Add(prefix+"from", "must be a date in YYYY-MM-DD format")
+	}
+	last, err := time.Parse(dateLayout, toValue)
+	if err != nil {
+		fields.Add(prefix+"to", "must be a date in YYYY-MM-DD format")
+		return from, last
+	}
+	switch nights := len(model.Nights(from, last)) + 1; {
+	case last.Before(from):
+		fields.Add(prefix+"to", "cannot be before from")
+	case nights > maxCalendarNights:
+		fields.Add(prefix+"to", fmt.Sprintf("must be at most %d nights after from", maxCalendarNights-1))
+	}
+	return from, last.AddDate(0, 0, 1)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestHotelInventory(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	full := model.Hotel{Title: "Harbour View", Rating: 4.5, RoomTypes: []model.RoomType{
+		{ID: "double", Name: "Double", MaxGuests: 2},
+		{ID: "family", Name: "Family Suite", MaxGuests: 4},
+	}}
+	open := model.Hotel{Title: "Palm Suites", Rating: 4.0, RoomTypes: []model.RoomType{{ID: "double", Name: "Double", MaxGuests: 2}}}
+	unmanaged := model.Hotel{Title: "City Lodge", Rating: 3.5, RoomTypes: []model.RoomType{{ID: "dorm", Name: "Dorm", MaxGuests: 6}}}
+	for _, hotel := range []*model.Hotel{&full, &open, &unmanaged} {
+		if err := server.store.Hotels.Create(ctx, hotel); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	day := func(days int) string {
+		return time.Now().UTC().AddDate(0, 0, days).Format(dateLayout)
+	}
+	units := func(n int) *int { return &n }
+	closed := true
+	update := func(hotel model.Hotel, updates ...CalendarUpdate) int {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPut, "/api/v1/hotels/"+hotel.ID.Hex()+"/inventory", UpdateInventoryRequest{Updates: updates})
+		return recorder.Code
+	}
+
+	if code := update(full,
+		CalendarUpdate{RoomTypeID: "double", From: day(10), To: day(13), Units: units(2)},
+		CalendarUpdate{RoomTypeID: "family", From: day(10), To: day(13), Units: units(1)},
+		CalendarUpdate{RoomTypeID: "family", From: day(10), ClosedToArrival: &closed},
+	); code != http.StatusOK {
+		t.Fatalf("update: expected %d, got %d", http.StatusOK, code)
+	}
+	if code := update(open, CalendarUpdate{RoomTypeID: "double", From: day(10), To: day(13), Units: units(5)}); code != http.StatusOK {
+		t.Fatalf("update: expected %d, got %d", http.StatusOK, code)
+	}
+	if code := update(full, CalendarUpdate{RoomTypeID: "penthouse", From: day(10), Units: units(1)}); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for an unknown room type, got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/"+full.ID.Hex()+"/inventory?room_type_id=family&from="+day(10)+"&to="+day(13), nil)
+	var calendar struct {
+		Data []model.Inventory `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &calendar); err != nil {
+		t.Fatal(err)
+	}
+	if len(calendar.Data) != 4 || !calendar.Data[0].ClosedToArrival || calendar.Data[1].ClosedToArrival || calendar.Data[3].Units != 1 {
+		t.Fatalf("unexpected calendar %+v", calendar.Data)
+	}
+
+	availability := func(params string) []string {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/"+full.ID.Hex()+"/availability?"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.RoomAvailability `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, room := range list.Data {
+			ids = append(ids, room.RoomType.ID)
+		}
+		return ids
+	}
+	if ids := availability("checkIn=" + day(11) + "&checkOut=" + day(13) + "&adults=3"); len(ids) != 1 || ids[0] != "family" {
+		t.Errorf("expected only the family suite for three adults, got %v", ids)
+	}
+	if ids := availability("checkIn=" + day(10) + "&checkOut=" + day(12) + "&adults=3"); len(ids) != 0 {
+		t.Errorf("expected no arrivals on a closed night, got %v", ids)
+	}
+	if ids := availability("checkIn=" + day(12) + "&checkOut=" + day(15) + "&adults=2"); len(ids) != 0 {
+		t.Errorf("expected nothing past the end of the inventory, got %v", ids)
+	}
+	if ids := availability("checkIn=" + day(11) + "&checkOut=" + day(12) + "&adults=4&rooms=2"); len(ids) != 1 || ids[0] != "double" {
+		t.Errorf("expected two doubles for four adults in two rooms, got %v", ids)
+	}
+
+	// Selling out the family suite leaves Harbour View without a room for
+	// four, while City Lodge has no inventory to run out of
+	night, err := server.store.Inventory.Nights(ctx, full.ID, "family", model.Night(time.Now().UTC().AddDate(0, 0, 11)), model.Night(time.Now().UTC().AddDate(0, 0, 12)))
+	if err != nil || len(night) != 1 {
+		t.Fatalf("expected one night, got %v (%v)", night, err)
+	}
+	night[0].Sold = 1
+	if err := server.store.Inventory.Update(ctx, &night[0]); err != nil {
+		t.Fatal(err)
+	}
+	if code := update(full, CalendarUpdate{RoomTypeID: "family", From: day(11), Units: units(0)}); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d lowering units below those sold, got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels/query?page_id=1&page_size=5&adults=4&checkIn="+day(11)+"&checkOut="+day(13), nil)
+	var list struct {
+		Data []model.Hotel `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Title != "City Lodge" {
+		t.Errorf("expected only the hotel without inventory, got %s", recorder.Body)
+	}
+}
+
+func TestHotelQueryPagesPastSoldOutHotels(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	night := model.Night(time.Now().UTC().AddDate(0, 0, 10))
+	soldOut := map[string]bool{}
+	for i := 0; i < availabilityBatch+10; i++ {
+		hotel := model.Hotel{Title: fmt.Sprintf("Hotel %03d", i), RoomTypes: []model.RoomType{{ID: "double", Name: "Double", MaxGuests: 2}}}
+		if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+		if i%2 == 1 {
+			continue
+		}
+		soldOut[hotel.Title] = true
+		if err := server.store.Inventory.Create(ctx, &model.Inventory{HotelID: hotel.ID, RoomTypeID: "double", Date: night, Units: 0}); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	dates := "&checkIn=" + night.Format(dateLayout) + "&checkOut=" + night.AddDate(0, 0, 1).Format(dateLayout)
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/query?page_id=3&page_size=20&adults=2"+dates, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var list struct {
+		Data       []model.Hotel  `json:"data"`
+		Pagination map[string]int `json:"pagination"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 15 || list.Pagination["total"] != 55 || list.Pagination["pages"] != 3 {
+		t.Fatalf("expected the last 15 of 55 open hotels, got %d with %v", len(list.Data), list.Pagination)
+	}
+	for _, hotel := range list.Data {
+		if soldOut[hotel.Title] {
+			t.Errorf("expected %s to be left out as sold out", hotel.Title)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// menuOrder lists sections and dishes in the order they appear on the menu
+var menuOrder = []repository.SortField{repository.Asc("position"), repository.Asc("name")}
+
+func (server *Server) menuSectionResource() *Resource[model.MenuSection] {
+	return &Resource[model.MenuSection]{
+		Name:    "menu section",
+		Repo:    server.store.MenuSections,
+		Sort:    menuOrder,
+		IDParam: "section_id",
+		Scope:   ofRestaurant,
+		Adopt: func(c *gin.Context, doc *model.MenuSection) error {
+			var err error
+			doc.RestaurantID, err = server.restaurantInPath(c)
+			return err
+		},
+		BeforeDelete: func(c *gin.Context, doc *model.MenuSection) error {
+			return refuseInUse(c.Request.Context(), server.store.MenuItems,
+				"the section still has dishes", repository.Eq("section_id", doc.ID))
+		},
+	}
+}
+
+func (server *Server) menuItemResource() *Resource[model.MenuItem] {
+	refresh := func(c *gin.Context, doc *model.MenuItem) {
+		server.refreshMenu(c.Request.Context(), doc.RestaurantID)
+	}
+	return &Resource[model.MenuItem]{
+		Name:    "menu item",
+		Repo:    server.store.MenuItems,
+		Sort:    menuOrder,
+		IDParam: "item_id",
+		Scope:   ofRestaurant,
+		Present: pricesInCurrency[model.MenuItem](server),
+		Adopt: func(c *gin.Context, doc *model.MenuItem) error {
+			var err error
+			doc.RestaurantID, err = server.restaurantInPath(c)
+			return err
+		},
+		BeforeCreate: func(c *gin.Context, doc *model.MenuItem) error {
+			return server.checkMenuItem(c.Request.Context(), doc)
+		},
+		BeforeUpdate: func(c *gin.Context, old, doc *model.MenuItem) error {
+			return server.checkMenuItem(c.Request.Context(), doc)
+		},
+		AfterCreate: refresh,
+		AfterUpdate: func(c *gin.Context, old, doc *model.MenuItem) {
+			refresh(c, doc)
+		},
+		AfterDelete: refresh,
+	}
+}
+
+func (server *Server) registerMenuRoutes(menu *gin.RouterGroup) {
+	menu.GET("", server.GetMenu)
+	server.menuSections.Register(menu.Group("/sections"))
+	server.menuItems.Register(menu.Group("/items"))
+}
+
+// ofRestaurant selects the documents of the restaurant in the path
+func ofRestaurant(c *gin.Context) ([]repository.Condition, error) {
+	restaurantID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, errors.New("invalid restaurant ID format")
+	}
+	return []repository.Condition{repository.Eq("restaurant_id", restaurantID)}, nil
+}
+
+// restaurantInPath returns the ID of the restaurant in the path, failing
+// with 404 Not Found unless it exists
+func (server *Server) restaurantInPath(c *gin.Context) (primitive.ObjectID, error) {
+	restaurantID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return restaurantID, errors.New("invalid restaurant ID format")
+	}
+	if _, err := server.store.Restaurants.Get(c.Request.Context(), restaurantID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return restaurantID, &StatusError{Status: http.StatusNotFound, Err: errors.New("restaurant not found")}
+		}
+		return restaurantID, err
+	}
+	return restaurantID, nil
+}
+
+// checkMenuItem reports field errors unless the item's section is on the
+// same restaurant's menu and its cuisine exists
+func (server *Server) checkMenuItem(ctx context.Context, item *model.MenuItem) error {
+	section, err := server.store.MenuSections.Get(ctx, item.SectionID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound) || err == nil && section.RestaurantID != item.RestaurantID:
+		return model.FieldErrors{"section_id": "is not a section of the restaurant's menu"}
+	case err != nil:
+		return err
+	}
+	if item.CuisineID != nil {
+		_, err := server.store.Cuisines.Get(ctx, *item.CuisineID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return model.FieldErrors{"cuisine_id": "is not a cuisine"}
+		}
+		return err
+	}
+	return nil
+}
+
+// refreshMenu derives the menu summary and price range of a restaurant
+// from its menu items. A failure leaves the summary stale until the next
+// change to the menu, so it is logged rather than returned.
+func (server *Server) refreshMenu(ctx context.Context, restaurantID primitive.ObjectID) {
+	if err := server.summarizeMenu(ctx, restaurantID); err != nil {
+		log.Printf("could not refresh the menu summary of restaurant %s: %v", restaurantID.Hex(), err)
+	}
+}
+
+func (server *Server) summarizeMenu(ctx context.Context, restaurantID primitive.ObjectID) error {
+	restaurant, err := server.store.Restaurants.Get(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+	items, _, err := server.store.MenuItems.List(ctx, repository.Query{
+		Conditions: []repository.Condition{repository.Eq("restaurant_id", restaurantID)},
+	})
+	if err != nil {
+		return err
+	}
+	rates, err := server.currentRates(ctx)
+	if err != nil {
+		return err
+	}
+	restaurant.SetMenu(items, rates)
+	restaurant.UpdatedAt = time.Now()
+	return server.store.Restaurants.Update(ctx, restaurant)
+}
+
+// Menu is a restaurant's menu, its dishes grouped by section
+type Menu struct {
+	RestaurantID primitive.ObjectID `json:"restaurant_id"`
+	Summary      model.MenuSummary  `json:"summary"`
+	Sections     []MenuSection      `json:"sections"`
+}
+
+// MenuSection is a section of a Menu with its dishes
+type MenuSection struct {
+	model.MenuSection
+	Items []model.MenuItem `json:"items"`
+}
+
+// Get a restaurant's menu
+type MenuRequest struct {
+	Dietary  []string `form:"dietary"` // only dishes with every tag
+	Currency string   `form:"currency"`
+}
+
+// GetMenu returns the menu of a restaurant in order. With dietary, only
+// the dishes suitable for all of the tags are listed, and sections left
+// without dishes are left out.
+func (server *Server) GetMenu(c *gin.Context) {
+	var req MenuRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	tags, err := dietaryTags(req.Dietary)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	restaurant, ok := server.restaurantFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	restaurantID := restaurant.ID
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
+		return
+	}
+
+	ofMenu := repository.Eq("restaurant_id", restaurantID)
+	sections, _, err := server.store.MenuSections.List(ctx, repository.Query{Conditions: []repository.Condition{ofMenu}, Sort: menuOrder})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	conditions := []repository.Condition{ofMenu}
+	for _, tag := range tags {
+		conditions = append(conditions, repository.Eq("dietary", tag))
+	}
+	items, _, err := server.store.MenuItems.List(ctx, repository.Query{Conditions: conditions, Sort: menuOrder})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	convertPrices(items, rates, currency)
+	restaurants := []model.Restaurant{*restaurant}
+	convertPrices(restaurants, rates, currency)
+
+	menu := Menu{RestaurantID: restaurantID, Summary: restaurants[0].Menu, Sections: []MenuSection{}}
+	for _, section := range sections {
+		entry := MenuSection{MenuSection: section, Items: []model.MenuItem{}}
+		for _, item := range items {
+			if item.SectionID == section.ID {
+				entry.Items = append(entry.Items, item)
+			}
+		}
+		if len(entry.Items) > 0 || len(tags) == 0 {
+			menu.Sections = append(menu.Sections, entry)
+		}
+	}
+	c.JSON(http.StatusOK, menu)
+}
+
+// dietaryTags normalizes the dietary tags of a filter. The error is
+// model.FieldErrors.
+func dietaryTags(list []string) ([]string, error) {
+	var tags []string
+	for _, tag := range splitList(list) {
+		tag = model.DietaryTag(tag)
+		if !contains(model.DietaryTags, tag) {
+			return nil, model.FieldErrors{"dietary": "must be among " + strings.Join(model.DietaryTags, ", ")}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestRestaurantMenus(t *testing.T) {
+	server := newTestServer(t)
+
+	create := func(path string, doc, created interface{}) {
+		t.Helper()
+		recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/"+path, doc)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", path, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+		response := struct {
+			Data interface{} `json:"data"`
+		}{created}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create("admin/exchange-rates", model.ExchangeRate{Currency: "EUR", Rate: 0.5}, new(model.ExchangeRate))
+
+	var trattoria, diner model.Restaurant
+	create("restaurants", model.Restaurant{Name: "Trattoria", PriceRange: "$$$$"}, &trattoria)
+	create("restaurants", model.Restaurant{Name: "Diner"}, &diner)
+	trattoriaMenu := "restaurants/" + trattoria.ID.Hex() + "/menu"
+	dinerMenu := "restaurants/" + diner.ID.Hex() + "/menu"
+
+	var mains, starters, burgers model.MenuSection
+	create(trattoriaMenu+"/sections", model.MenuSection{Name: "Mains", Position: 1}, &mains)
+	create(trattoriaMenu+"/sections", model.MenuSection{Name: "Starters"}, &starters)
+	create(dinerMenu+"/sections", model.MenuSection{Name: "Burgers"}, &burgers)
+	if mains.RestaurantID != trattoria.ID {
+		t.Errorf("expected the section to belong to the restaurant in the path, got %s", mains.RestaurantID.Hex())
+	}
+
+	var bruschetta, risotto, steak model.MenuItem
+	create(trattoriaMenu+"/items", model.MenuItem{SectionID: starters.ID, Name: "Bruschetta", Price: 8, Currency: "eur", Dietary: []string{"Vegan"}}, &bruschetta)
+	create(trattoriaMenu+"/items", model.MenuItem{SectionID: mains.ID, Name: "Risotto", Price: 20, Currency: "EUR", Dietary: []string{"Gluten-free", "vegetarian"}}, &risotto)
+	create(trattoriaMenu+"/items", model.MenuItem{SectionID: mains.ID, Name: "Steak", Price: 40, Currency: "EUR"}, &steak)
+	create(dinerMenu+"/items", model.MenuItem{SectionID: burgers.ID, Name: "Burger", Price: 12, Dietary: []string{"halal"}}, new(model.MenuItem))
+	if !equalStrings(bruschetta.Dietary, []string{"vegan", "vegetarian"}) {
+		t.Errorf("expected a vegan dish to be vegetarian, got %v", bruschetta.Dietary)
+	}
+
+	for _, test := range []struct {
+		path   string
+		item   model.MenuItem
+		status int
+	}{
+		{dinerMenu + "/items", model.MenuItem{SectionID: mains.ID, Name: "Pasta", Price: 10}, http.StatusUnprocessableEntity},
+		{trattoriaMenu + "/items", model.MenuItem{SectionID: mains.ID, Name: "Pizza", Price: 10, Dietary: []string{"paleo"}}, http.StatusUnprocessableEntity},
+		{"restaurants/" + mains.ID.Hex() + "/menu/items", model.MenuItem{SectionID: mains.ID, Name: "Pizza", Price: 10}, http.StatusNotFound},
+	} {
+		if recorder := performRequest(server, http.MethodPost, "/api/v1/"+test.path, test.item); recorder.Code != test.status {
+			t.Errorf("create %s: expected %d, got %d: %s", test.item.Name, test.status, recorder.Code, recorder.Body)
+		}
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/"+dinerMenu+"/items/"+steak.ID.Hex(), nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected %d for another restaurant's dish, got %d", http.StatusNotFound, recorder.Code)
+	}
+
+	restaurant := func(id, params string) model.Restaurant {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/"+id+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var got model.Restaurant
+		if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	// 22.67 euros on average, which shows as 45.34 dollars
+	got := restaurant(trattoria.ID.Hex(), "")
+	if got.Menu.Items != 3 || got.Menu.AveragePrice != 22.67 || got.Menu.MinPrice != 8 || got.Menu.MaxPrice != 40 || got.Menu.Currency != "EUR" {
+		t.Errorf("expected the menu summarized in euros, got %+v", got.Menu)
+	}
+	if !equalStrings(got.Menu.Dietary, []string{"gluten_free", "vegan", "vegetarian"}) || got.PriceRange != "$$$" {
+		t.Errorf("expected the dietary tags and a $$$ price range, got %v and %s", got.Menu.Dietary, got.PriceRange)
+	}
+	if got := restaurant(trattoria.ID.Hex(), "?currency=USD"); got.Menu.AveragePrice != 45.34 || got.Menu.Currency != "USD" {
+		t.Errorf("expected the summary in dollars, got %+v", got.Menu)
+	}
+	performRequest(server, http.MethodPut, "/api/v1/restaurants/"+trattoria.ID.Hex(), map[string]interface{}{"price_range": "$", "menu": model.MenuSummary{}})
+	if got := restaurant(trattoria.ID.Hex(), ""); got.PriceRange != "$$$" || got.Menu.Items != 3 {
+		t.Errorf("expected the derived menu fields to be kept, got %s and %+v", got.PriceRange, got.Menu)
+	}
+
+	search := func(params string) []string {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/search?page_id=1&page_size=5&sort=name&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.Restaurant `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, restaurant := range list.Data {
+			names = append(names, restaurant.Name)
+		}
+		return names
+	}
+	for _, test := range []struct {
+		params string
+		names  []string
+	}{
+		{"dietary=vegan", []string{"Trattoria"}},
+		{"dietary=Halal", []string{"Diner"}},
+		{"dietary=vegan,halal", nil},
+		{"price_range=$&price_range=$$", []string{"Diner"}},
+	} {
+		if names := search(test.params); !equalStrings(names, test.names) {
+			t.Errorf("%s: expected %v, got %v", test.params, test.names, names)
+		}
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/search?page_id=1&page_size=5&dietary=paleo", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an unknown dietary tag, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	menu := func(params string) Menu {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/"+trattoriaMenu+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var got Menu
+		if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	if got := menu(""); len(got.Sections) != 2 || got.Sections[0].Name != "Starters" || len(got.Sections[1].Items) != 2 || got.Sections[1].Items[0].Name != "Risotto" {
+		t.Errorf("expected the starters then the two mains, got %+v", got.Sections)
+	}
+	if got := menu("?dietary=gluten-free&currency=USD"); len(got.Sections) != 1 || len(got.Sections[0].Items) != 1 || got.Sections[0].Items[0].Price != 40 {
+		t.Errorf("expected only the risotto at $40, got %+v", got.Sections)
+	}
+
+	// A section with dishes and a restaurant with a menu cannot be deleted
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/"+trattoriaMenu+"/sections/"+mains.ID.Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a section with dishes, got %d", http.StatusConflict, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/restaurants/"+trattoria.ID.Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a restaurant with a menu, got %d", http.StatusConflict, recorder.Code)
+	}
+
+	// Without the steak the average falls to 14 euros, or 28 dollars
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/"+trattoriaMenu+"/items/"+steak.ID.Hex(), nil); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if got := restaurant(trattoria.ID.Hex(), ""); got.Menu.Items != 2 || got.Menu.AveragePrice != 14 || got.PriceRange != "$$" {
+		t.Errorf("expected the summary to follow the menu, got %s and %+v", got.PriceRange, got.Menu)
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Give a hotel or restaurant the owner who answers its reviews
+type ListingOwnerRequest struct {
+	OwnerID string `json:"owner_id"` // empty removes the owner
+}
+
+// SetHotelOwner names the user who owns a hotel. Owners are kept out of
+// the public listing and only administrators can change them.
+func (server *Server) SetHotelOwner(c *gin.Context) {
+	hotelID, ownerID, ok := listingOwnerRequest(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hotel, err := server.store.Hotels.Edit(ctx, hotelID, func(hotel *model.Hotel) {
+		hotel.OwnerID, hotel.UpdatedAt = ownerID, time.Now()
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hotel not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": hotel.ID, "owner_id": ownerHex(hotel.OwnerID)})
+}
+
+// SetRestaurantOwner names the user who owns a restaurant
+func (server *Server) SetRestaurantOwner(c *gin.Context) {
+	restaurantID, ownerID, ok := listingOwnerRequest(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	restaurant, err := server.store.Restaurants.Get(ctx, restaurantID)
+	if err == nil {
+		restaurant.OwnerID, restaurant.UpdatedAt = ownerID, time.Now()
+		err = server.store.Restaurants.Update(ctx, restaurant)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": restaurant.ID, "owner_id": ownerHex(restaurant.OwnerID)})
+}
+
+// listingOwnerRequest reads the listing in the path and the owner in the
+// body, writing the error response itself when it cannot
+func listingOwnerRequest(c *gin.Context) (listingID, ownerID primitive.ObjectID, ok bool) {
+	var req ListingOwnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return listingID, ownerID, false
+	}
+	listingID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return listingID, ownerID, false
+	}
+	if req.OwnerID != "" {
+		if ownerID, err = primitive.ObjectIDFromHex(req.OwnerID); err != nil {
+			fields := model.FieldErrors{}
+			fields.Add("owner_id", "is not a valid ID")
+			c.JSON(http.StatusUnprocessableEntity, errorResponse(fields.Err()))
+			return listingID, ownerID, false
+		}
+	}
+	return listingID, ownerID, true
+}
+
+// ownerHex is the owner's ID, or empty for a listing without one
+func ownerHex(ownerID primitive.ObjectID) string {
+	if ownerID.IsZero() {
+		return ""
+	}
+	return ownerID.Hex()
+}
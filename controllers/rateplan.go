@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (server *Server) ratePlanResource() *Resource[model.RatePlan] {
+	return &Resource[model.RatePlan]{
+		Name:    "rate plan",
+		Repo:    server.store.RatePlans,
+		Present: pricesInCurrency[model.RatePlan](server),
+		BeforeCreate: func(c *gin.Context, doc *model.RatePlan) error {
+			return server.checkRoomType(c.Request.Context(), doc.HotelID, doc.RoomTypeID)
+		},
+		BeforeUpdate: func(c *gin.Context, old, doc *model.RatePlan) error {
+			return server.checkRoomType(c.Request.Context(), doc.HotelID, doc.RoomTypeID)
+		},
+	}
+}
+
+// checkRoomType reports a field error unless the hotel exists and has the
+// room type
+func (server *Server) checkRoomType(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string) error {
+	hotel, err := server.store.Hotels.Get(ctx, hotelID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return model.FieldErrors{"hotel_id": "is not a hotel"}
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := hotel.RoomType(roomTypeID); !ok {
+		return model.FieldErrors{"room_type_id": "is not a room type of this hotel"}
+	}
+	return nil
+}
+
+// Hotel quote
+type QuoteRequest struct {
+	StayRequest
+	RoomTypeID string `form:"room_type_id"`
+	RatePlanID string `form:"rate_plan_id"`
+	Currency   string `form:"currency"`
+}
+
+// QuoteHotel prices a stay under every rate plan of the hotel whose room
+// type fits the party, cheapest first. Adults default to one. Prices are
+// in the currency of each plan unless currency asks for another.
+func (server *Server) QuoteHotel(c *gin.Context) {
+	var req QuoteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := req.validateDated(time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
+		return
+	}
+	hotel, ok := server.hotelFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	conditions := []repository.Condition{repository.Eq("hotel_id", hotel.ID)}
+	if req.RoomTypeID != "" {
+		conditions = append(conditions, repository.Eq("room_type_id", req.RoomTypeID))
+	}
+	if req.RatePlanID != "" {
+		id, err := primitive.ObjectIDFromHex(req.RatePlanID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(model.FieldErrors{"rate_plan_id": "is not a valid ID"}))
+			return
+		}
+		conditions = append(conditions, repository.Eq("_id", id))
+	}
+	plans, _, err := server.store.RatePlans.List(ctx, repository.Query{Conditions: conditions})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	convertPrices(plans, rates, currency)
+
+	req.Adults, req.Rooms = max(req.Adults, 1), max(req.Rooms, 1)
+	perRoom := (req.guests() + req.Rooms - 1) / req.Rooms
+	quotes := []model.Quote{}
+	for i := range plans {
+		roomType, ok := hotel.RoomType(plans[i].RoomTypeID)
+		if !ok || roomType.MaxGuests < perRoom {
+			continue
+		}
+		quotes = append(quotes, plans[i].Quote(req.checkIn, req.checkOut, req.Rooms, req.Adults, req.childrenAges))
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Total < quotes[j].Total })
+	c.JSON(http.StatusOK, successResponse(quotes, nil))
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestQuoteHotel(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	hotel := model.Hotel{Title: "Harbour View", RoomTypes: []model.RoomType{
+		{ID: "double", Name: "Double", MaxGuests: 2},
+		{ID: "family", Name: "Family Suite", MaxGuests: 5},
+	}}
+	if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Thursday, so a week's stay takes in a Friday and a Saturday night
+	checkIn := model.Night(time.Now().UTC().AddDate(0, 0, 14))
+	for checkIn.Weekday() != time.Thursday {
+		checkIn = checkIn.AddDate(0, 0, 1)
+	}
+	plans := []model.RatePlan{
+		{
+			HotelID: hotel.ID, RoomTypeID: "family", Name: "Flexible", BaseRate: 100, WeekendUplift: 25,
+			Seasons:               []model.Season{{Name: "Festival", From: checkIn, To: checkIn, Rate: 150}},
+			LengthOfStayDiscounts: []model.StayDiscount{{MinNights: 3, Percent: 5}, {MinNights: 7, Percent: 10}},
+			ExtraAdultRate:        30,
+			ChildRates:            []model.ChildRate{{MaxAge: 12, Rate: 15}, {MaxAge: 2, Rate: 0}},
+		},
+		{HotelID: hotel.ID, RoomTypeID: "double", Name: "Saver", BaseRate: 80},
+	}
+	for _, plan := range plans {
+		if recorder := performRequest(server, http.MethodPost, "/api/v1/rate-plans", plan); recorder.Code != http.StatusCreated {
+			t.Fatalf("create: expected %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body)
+		}
+	}
+	invalid := model.RatePlan{HotelID: hotel.ID, RoomTypeID: "penthouse", Name: "Suite", BaseRate: 500}
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/rate-plans", invalid); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for an unknown room type, got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+
+	quote := func(params string) []model.Quote {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/"+hotel.ID.Hex()+"/quote?"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.Quote `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		return list.Data
+	}
+	day := func(nights int) string {
+		return checkIn.AddDate(0, 0, nights).Format(dateLayout)
+	}
+
+	// Festival Thursday, then a Friday with the weekend uplift
+	quotes := quote("checkIn=" + day(0) + "&checkOut=" + day(2) + "&adults=2")
+	if len(quotes) != 2 || quotes[0].RatePlan != "Saver" || quotes[0].Total != 160 {
+		t.Fatalf("expected the saver plan first, got %+v", quotes)
+	}
+	flexible := quotes[1]
+	if flexible.Total != 275 || flexible.PriceForDisplay != "$137.50" || flexible.StrikethroughPrice != "" {
+		t.Errorf("unexpected two night quote %+v", flexible)
+	}
+	if flexible.PriceSummary != "$275 total for 2 nights, 1 room, 2 adults" {
+		t.Errorf("unexpected price summary %q", flexible.PriceSummary)
+	}
+
+	// Only the family suite fits five, who pay for the extra adult and the
+	// older child every night and get the weekly discount
+	quotes = quote("checkIn=" + day(0) + "&checkOut=" + day(7) + "&adults=3&childrenAges=1&childrenAges=8")
+	if len(quotes) != 1 || quotes[0].RoomTypeID != "family" {
+		t.Fatalf("expected only the family suite, got %+v", quotes)
+	}
+	week := quotes[0]
+	if len(week.Nights) != 7 || week.Nights[2].Rate != 125 || week.Nights[3].Rate != 100 || week.Nights[0].Extras != 45 {
+		t.Errorf("unexpected nights %+v", week.Nights)
+	}
+	if week.Subtotal != 1115 || week.Discount != 111.5 || week.Total != 1003.5 {
+		t.Errorf("expected 1115 less 10%%, got %v less %v is %v", week.Subtotal, week.Discount, week.Total)
+	}
+	if week.PriceForDisplay != "$143.36" || week.StrikethroughPrice != "$159.29" {
+		t.Errorf("expected $143.36 struck through from $159.29, got %s from %s", week.PriceForDisplay, week.StrikethroughPrice)
+	}
+	if want := "$1,003.50 total for 7 nights, 1 room, 3 adults and 2 children (10% off)"; week.PriceSummary != want {
+		t.Errorf("expected price summary %q, got %q", want, week.PriceSummary)
+	}
+
+	recorder := performRequest(server, http.MethodGet, "/api/v1/hotels/"+hotel.ID.Hex()+"/quote?adults=2", nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d without dates, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
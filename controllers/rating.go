@@ -2,247 +2,213 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"log"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/janto-pee/Horizon-Travels.git/model"
-	"github.com/janto-pee/Horizon-Travels.git/util"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// RatingRequest represents the structure for a rating request
-type RatingRequest struct {
-	UserID  string  `json:"user_id" binding:"required"`
-	HotelID string  `json:"hotel_id" binding:"required"`
-	Score   float64 `json:"score" binding:"required,min=1,max=5"`
-	Comment string  `json:"comment"`
+func (server *Server) ratingResource() *Resource[model.Rating] {
+	return &Resource[model.Rating]{
+		Name: "rating",
+		Repo: server.store.Ratings,
+		AfterCreate: func(c *gin.Context, doc *model.Rating) {
+			server.rescore(c.Request.Context(), nil, ratingScore(doc))
+		},
+		AfterUpdate: func(c *gin.Context, old, doc *model.Rating) {
+			server.rescore(c.Request.Context(), ratingScore(old), ratingScore(doc))
+		},
+		AfterDelete: func(c *gin.Context, doc *model.Rating) {
+			server.rescore(c.Request.Context(), ratingScore(doc), nil)
+		},
+	}
 }
 
-// CreateRating handles the creation of a new rating
-func CreateRating(c *gin.Context) {
-	var req RatingRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// hotelScore is the score a rating or review adds to a hotel's rating
+type hotelScore struct {
+	hotelID primitive.ObjectID
+	score   float64
+}
 
-	// Convert string IDs to ObjectIDs
-	userID, err := primitive.ObjectIDFromHex(req.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
+// ratingScore is nil for ratings of anything but a hotel
+func ratingScore(rating *model.Rating) *hotelScore {
+	if rating == nil || rating.HotelID.IsZero() {
+		return nil
 	}
+	return &hotelScore{hotelID: rating.HotelID, score: rating.Score}
+}
 
-	hotelID, err := primitive.ObjectIDFromHex(req.HotelID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
-		return
+// reviewScore is nil for reviews of anything but a hotel, and for reviews
+// the public cannot see
+func reviewScore(review *model.Review) *hotelScore {
+	if review == nil || review.EntityType != "hotel" || !review.Published() {
+		return nil
 	}
+	return &hotelScore{hotelID: review.EntityID, score: review.Rating}
+}
 
-	now := time.Now()
-	rating := model.Rating{
-		ID:        primitive.NewObjectID(),
-		UserID:    userID,
-		HotelID:   hotelID,
-		Score:     req.Score,
-		Comment:   req.Comment,
-		CreatedAt: now,
-		UpdatedAt: now,
+// rescore moves the rating of hotels from a score before a change to the
+// score after it, either of which is nil when the change created or deleted
+// it. A failure leaves the rating stale until it is recomputed, so it is
+// logged rather than returned. Scores of hotels that do not exist are
+// ignored.
+func (server *Server) rescore(ctx context.Context, before, after *hotelScore) {
+	rate := func(hotelID primitive.ObjectID, add, remove []float64) {
+		err := server.store.Hotels.Rate(ctx, hotelID, add, remove)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("could not update the rating of hotel %s: %v", hotelID.Hex(), err)
+		}
 	}
-
-	// Save the rating to the database
-	collection := util.MongoClient.Database(util.DbName).Collection(rating.CollectionName())
-	result, err := collection.InsertOne(context.TODO(), rating)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rating"})
-		return
+	switch {
+	case before != nil && after != nil && before.hotelID == after.hotelID:
+		if before.score != after.score {
+			rate(after.hotelID, []float64{after.score}, []float64{before.score})
+		}
+	default:
+		if before != nil {
+			rate(before.hotelID, nil, []float64{before.score})
+		}
+		if after != nil {
+			rate(after.hotelID, []float64{after.score}, nil)
+		}
 	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Rating created successfully",
-		"data":    rating,
-		"id":      result.InsertedID,
-	})
 }
 
-// GetRatingByID retrieves a rating by its ID
-func GetRatingByID(c *gin.Context) {
-	id := c.Param("id")
-
-	ratingID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
-	}
-
-	var rating model.Rating
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Rating{}.CollectionName())
-	err = collection.FindOne(context.TODO(), bson.M{"_id": ratingID}).Decode(&rating)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
+// RecomputeHotelRatings derives the rating and review count of every hotel
+// from its ratings and reviews again, repairing any drift. Hotels imported
+// with the rating of another site get the rating of their own scores.
+func (server *Server) RecomputeHotelRatings(c *gin.Context) {
+	ctx := c.Request.Context()
+	const pageSize = 100
+	var hotels, updated int
+	for pageID := int64(1); ; pageID++ {
+		query := repository.Query{Sort: []repository.SortField{repository.Asc("_id")}}
+		page, _, err := server.store.Hotels.List(ctx, query.Page(pageID, pageSize))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rating"})
-		return
+		for _, hotel := range page {
+			average, err := server.scores(ctx, "hotel", hotel.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			hotels++
+			if int64(hotel.ReviewCount) == average.Count && math.Abs(hotel.Rating-average.Value) < 1e-9 {
+				continue
+			}
+			if err := server.store.Hotels.SetRating(ctx, hotel.ID, average); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			updated++
+		}
+		if len(page) < pageSize {
+			break
+		}
 	}
-
-	c.JSON(http.StatusOK, rating)
+	c.JSON(http.StatusOK, gin.H{"hotels": hotels, "updated": updated})
 }
 
-// GetRatingsByHotel retrieves all ratings for a specific hotel
-func GetRatingsByHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
-
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
+// scores averages the scores of an entity's ratings and reviews together
+func (server *Server) scores(ctx context.Context, entityType string, entityID primitive.ObjectID) (repository.Average, error) {
+	ratings, err := server.store.Ratings.AverageOf(ctx, entityType, entityID, "score")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
-		return
+		return repository.Average{}, err
 	}
-
-	var ratings []model.Rating
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Rating{}.CollectionName())
-
-	cursor, err := collection.Find(context.TODO(), bson.M{"hotel_id": objectID})
+	reviews, err := server.store.Reviews.AverageRating(ctx, entityType, entityID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ratings"})
-		return
+		return repository.Average{}, err
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &ratings); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode ratings"})
-		return
+	average := repository.Average{Count: ratings.Count + reviews.Count}
+	if average.Count > 0 {
+		average.Value = (ratings.Value*float64(ratings.Count) + reviews.Value*float64(reviews.Count)) / float64(average.Count)
 	}
-
-	c.JSON(http.StatusOK, ratings)
+	return average, nil
 }
 
-// UpdateRating updates an existing rating
-func UpdateRating(c *gin.Context) {
-	id := c.Param("id")
-
-	ratingID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
-	}
-
-	var req RatingRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// RatingSummary sums up how travelers rated a hotel, restaurant or rental
+// in their ratings and reviews
+type RatingSummary struct {
+	EntityType string             `json:"entity_type"`
+	EntityID   primitive.ObjectID `json:"entity_id"`
+	Average    float64            `json:"average"`
+	Count      int64              `json:"count"`
+	// Histogram counts the scores by the star, 1 to 5, they round to
+	Histogram map[int]int64 `json:"histogram"`
+	// Categories averages the sub-ratings of each category that was rated
+	Categories map[string]CategoryRating `json:"categories"`
+}
 
-	update := bson.M{
-		"$set": bson.M{
-			"score":      req.Score,
-			"comment":    req.Comment,
-			"updated_at": time.Now(),
-		},
-	}
+// CategoryRating is the average of the sub-ratings of one category
+type CategoryRating struct {
+	Average float64 `json:"average"`
+	Count   int64   `json:"count"`
+}
 
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Rating{}.CollectionName())
-	result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": ratingID}, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rating"})
-		return
-	}
+// GetRatingSummary returns a handler summing up the ratings and reviews of
+// the entityType named in the path
+func (server *Server) GetRatingSummary(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entityID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+			return
+		}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
-		return
-	}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
 
-	// Get the updated rating
-	var updatedRating model.Rating
-	err = collection.FindOne(context.TODO(), bson.M{"_id": ratingID}).Decode(&updatedRating)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated rating"})
-		return
+		summary, err := server.summarizeRatings(ctx, entityType, entityID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, summary)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Rating updated successfully",
-		"data":    updatedRating,
-	})
 }
 
-// DeleteRating deletes a rating
-func DeleteRating(c *gin.Context) {
-	id := c.Param("id")
-
-	ratingID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
-	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Rating{}.CollectionName())
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": ratingID})
+func (server *Server) summarizeRatings(ctx context.Context, entityType string, entityID primitive.ObjectID) (*RatingSummary, error) {
+	average, err := server.scores(ctx, entityType, entityID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating"})
-		return
+		return nil, err
 	}
-
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
-		return
+	summary := &RatingSummary{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Average:    math.Round(average.Value*100) / 100,
+		Count:      average.Count,
+		Histogram:  map[int]int64{},
+		Categories: map[string]CategoryRating{},
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Rating deleted successfully"})
-}
-
-// GetAverageRatingForHotel calculates the average rating for a hotel
-func GetAverageRatingForHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
-
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
+	ratingStars, err := server.store.Ratings.Stars(ctx, entityType, entityID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
-		return
+		return nil, err
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Rating{}.CollectionName())
-
-	// MongoDB aggregation pipeline to calculate average
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.M{"hotel_id": objectID}}},
-		{{"$group", bson.M{
-			"_id":          "$hotel_id",
-			"averageScore": bson.M{"$avg": "$score"},
-			"count":        bson.M{"$sum": 1},
-		}}},
-	}
-
-	cursor, err := collection.Aggregate(context.TODO(), pipeline)
+	reviewStars, err := server.store.Reviews.Stars(ctx, entityType, entityID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate average rating"})
-		return
+		return nil, err
 	}
-	defer cursor.Close(context.TODO())
-
-	var results []bson.M
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results"})
-		return
+	for i := range ratingStars {
+		summary.Histogram[i+1] = ratingStars[i] + reviewStars[i]
 	}
 
-	if len(results) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"hotel_id":      hotelID,
-			"average_score": 0,
-			"count":         0,
-		})
-		return
+	for _, category := range model.RatingCategories {
+		average, err := server.store.Ratings.AverageOf(ctx, entityType, entityID, "sub_ratings."+category)
+		if err != nil {
+			return nil, err
+		}
+		if average.Count > 0 {
+			summary.Categories[category] = CategoryRating{Average: math.Round(average.Value*100) / 100, Count: average.Count}
+		}
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"hotel_id":      hotelID,
-		"average_score": results[0]["averageScore"],
-		"count":         results[0]["count"],
-	})
+	return summary, nil
 }
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHotelRatingAggregates(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	// An imported hotel arrives with two reviews averaging 4.5
+	imported := model.Hotel{Title: "Harbour Hotel", Rating: 4.5, ReviewCount: 2}
+	other := model.Hotel{Title: "Palm Lodge"}
+	for _, hotel := range []*model.Hotel{&imported, &other} {
+		if err := server.store.Hotels.Create(ctx, hotel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	create := func(path string, doc, created interface{}) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPost, "/api/v1/"+path, doc)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", path, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+		response := struct {
+			Data interface{} `json:"data"`
+		}{created}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	change := func(method, path string, body interface{}) {
+		t.Helper()
+		if recorder := performRequest(server, method, "/api/v1/"+path, body); recorder.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected %d, got %d: %s", method, path, http.StatusOK, recorder.Code, recorder.Body)
+		}
+	}
+	expect := func(step string, hotel model.Hotel, rating float64, count int) {
+		t.Helper()
+		stored, err := server.store.Hotels.Get(ctx, hotel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(stored.Rating-rating) > 1e-9 || stored.ReviewCount != count {
+			t.Errorf("%s: expected %s rated %.4f from %d reviews, got %.4f from %d", step, hotel.Title, rating, count, stored.Rating, stored.ReviewCount)
+		}
+	}
+
+	var rating model.Rating
+	create("ratings", model.Rating{UserID: primitive.NewObjectID(), HotelID: imported.ID, Score: 3}, &rating)
+	expect("rating", imported, 4, 3)
+	review := createReview(t, server, model.Review{UserID: primitive.NewObjectID(), EntityType: "hotel", EntityID: imported.ID, Title: "Lovely", Content: "Great view", Rating: 5})
+	expect("pending review", imported, 4, 3)
+	publishReview(t, server, review.ID)
+	expect("published review", imported, 4.25, 4)
+	publishReview(t, server, createReview(t, server, model.Review{UserID: primitive.NewObjectID(), EntityType: "restaurant", EntityID: imported.ID, Title: "Tasty", Content: "Good food", Rating: 1}).ID)
+	expect("review of a restaurant", imported, 4.25, 4)
+	create("ratings", model.Rating{UserID: primitive.NewObjectID(), HotelID: primitive.NewObjectID(), Score: 2}, new(model.Rating))
+
+	change(http.MethodPut, "hotels/"+imported.ID.Hex(), map[string]interface{}{"title": "Harbour Inn", "rating": 1, "review_count": 9})
+	imported.Title = "Harbour Inn"
+	expect("hotel edit", imported, 4.25, 4)
+	change(http.MethodPut, "ratings/"+rating.ID.Hex(), map[string]interface{}{"score": 1})
+	expect("lowered rating", imported, 3.75, 4)
+	change(http.MethodDelete, "reviews/"+review.ID.Hex(), nil)
+	expect("deleted review", imported, 10.0/3, 3)
+	change(http.MethodPut, "ratings/"+rating.ID.Hex(), map[string]interface{}{"hotel_id": other.ID})
+	expect("rating moved away", imported, 4.5, 2)
+	expect("rating moved in", other, 1, 1)
+
+	// Recomputing drops the imported reviews, which have no scores here
+	recorder := performRequest(server, http.MethodPost, "/api/v1/admin/hotels/ratings/recompute", nil)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d recomputing anonymously, got %d: %s", http.StatusUnauthorized, recorder.Code, recorder.Body)
+	}
+	recorder = performRequestAs(server, &Identity{UserID: primitive.NewObjectID()}, http.MethodPost, "/api/v1/admin/hotels/ratings/recompute", nil)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected %d recomputing as a traveler, got %d: %s", http.StatusForbidden, recorder.Code, recorder.Body)
+	}
+	recorder = performRequestAs(server, &Identity{UserID: primitive.NewObjectID(), Admin: true}, http.MethodPost, "/api/v1/admin/hotels/ratings/recompute", nil)
+	var recount struct {
+		Hotels  int `json:"hotels"`
+		Updated int `json:"updated"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &recount); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || recount.Hotels != 2 || recount.Updated != 1 {
+		t.Errorf("expected one of two hotels to be updated, got %d: %s", recorder.Code, recorder.Body)
+	}
+	expect("recomputed", imported, 0, 0)
+	expect("recomputed", other, 1, 1)
+
+	change(http.MethodDelete, "ratings/"+rating.ID.Hex(), nil)
+	expect("last rating deleted", other, 0, 0)
+}
+
+func TestRatingSummary(t *testing.T) {
+	server := newTestServer(t)
+	hotelID, restaurantID, rentalID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	rate := func(rating model.Rating, status int) model.Rating {
+		t.Helper()
+		rating.UserID = primitive.NewObjectID()
+		recorder := performRequest(server, http.MethodPost, "/api/v1/ratings", rating)
+		if recorder.Code != status {
+			t.Fatalf("expected %d, got %d: %s", status, recorder.Code, recorder.Body)
+		}
+		response := struct {
+			Data *model.Rating `json:"data"`
+		}{&rating}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return rating
+	}
+	// Ratings that only name a hotel are ratings of that hotel
+	if rating := rate(model.Rating{HotelID: hotelID, Score: 5, SubRatings: model.SubRatings{Cleanliness: 5, Rooms: 4}}, http.StatusCreated); rating.EntityType != "hotel" || rating.EntityID != hotelID {
+		t.Errorf("expected a rating of the hotel, got %+v", rating)
+	}
+	rate(model.Rating{EntityType: "hotel", EntityID: hotelID, Score: 4.6, SubRatings: model.SubRatings{Cleanliness: 4, Service: 3}}, http.StatusCreated)
+	rate(model.Rating{EntityType: "hotel", EntityID: hotelID, Score: 2}, http.StatusCreated)
+	publishReview(t, server, createReview(t, server, model.Review{UserID: primitive.NewObjectID(), EntityType: "hotel", EntityID: hotelID, Title: "Fine", Content: "Nothing special", Rating: 3}).ID)
+	createReview(t, server, model.Review{UserID: primitive.NewObjectID(), EntityType: "hotel", EntityID: hotelID, Title: "Awful", Content: "Not yet moderated", Rating: 1})
+	if rating := rate(model.Rating{EntityType: "restaurant", EntityID: restaurantID, HotelID: hotelID, Score: 4, SubRatings: model.SubRatings{Service: 5}}, http.StatusCreated); !rating.HotelID.IsZero() {
+		t.Errorf("expected a restaurant's rating to have no hotel, got %+v", rating)
+	}
+	rate(model.Rating{EntityType: "vacation_rental", EntityID: rentalID, Score: 1, SubRatings: model.SubRatings{Value: 2}}, http.StatusCreated)
+	for _, invalid := range []model.Rating{
+		{EntityType: "restaurant", EntityID: restaurantID, Score: 4, SubRatings: model.SubRatings{Rooms: 3}},
+		{EntityType: "hotel", EntityID: hotelID, Score: 4, SubRatings: model.SubRatings{Value: 6}},
+		{EntityType: "cuisine", EntityID: restaurantID, Score: 4},
+	} {
+		rate(invalid, http.StatusUnprocessableEntity)
+	}
+
+	summary := func(path string) RatingSummary {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/"+path+"/ratings/summary", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var got RatingSummary
+		if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	// 4.6 rounds to five stars, and the review counts as three
+	hotel := summary("hotels/" + hotelID.Hex())
+	if hotel.Count != 4 || hotel.Average != 3.65 || hotel.Histogram[1] != 0 || hotel.Histogram[2] != 1 || hotel.Histogram[3] != 1 || hotel.Histogram[5] != 2 {
+		t.Errorf("expected four scores averaging 3.65, got %+v", hotel)
+	}
+	if hotel.Categories["cleanliness"] != (CategoryRating{Average: 4.5, Count: 2}) || hotel.Categories["service"] != (CategoryRating{Average: 3, Count: 1}) ||
+		hotel.Categories["rooms"] != (CategoryRating{Average: 4, Count: 1}) || len(hotel.Categories) != 3 {
+		t.Errorf("expected the rated categories, got %+v", hotel.Categories)
+	}
+	if restaurant := summary("restaurants/" + restaurantID.Hex()); restaurant.Count != 1 || restaurant.Histogram[4] != 1 || restaurant.Categories["service"].Average != 5 {
+		t.Errorf("expected the restaurant's rating, got %+v", restaurant)
+	}
+	if rental := summary("vacation-rentals/" + rentalID.Hex()); rental.EntityType != "vacation_rental" || rental.Average != 1 || rental.Categories["value"].Count != 1 {
+		t.Errorf("expected the rental's rating, got %+v", rental)
+	}
+	if empty := summary("hotels/" + primitive.NewObjectID().Hex()); empty.Count != 0 || len(empty.Histogram) != 5 || len(empty.Categories) != 0 {
+		t.Errorf("expected an empty summary, got %+v", empty)
+	}
+
+	recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/"+restaurantID.Hex()+"/ratings", nil)
+	var list struct {
+		Data []model.Rating `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Score != 4 {
+		t.Errorf("expected the restaurant's rating, got %s", recorder.Body)
+	}
+}
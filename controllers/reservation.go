@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seatAttempts bounds how often a reservation looks for another table when
+// the one it picked is taken meanwhile
+const seatAttempts = 5
+
+func (server *Server) diningTableResource() *Resource[model.DiningTable] {
+	return &Resource[model.DiningTable]{
+		Name:    "table",
+		Repo:    server.store.DiningTables,
+		Sort:    []repository.SortField{repository.Asc("name")},
+		IDParam: "table_id",
+		Scope:   ofRestaurant,
+		Adopt: func(c *gin.Context, doc *model.DiningTable) error {
+			var err error
+			doc.RestaurantID, err = server.restaurantInPath(c)
+			return err
+		},
+		BeforeCreate: func(c *gin.Context, doc *model.DiningTable) error {
+			doc.Bookings, doc.Version = nil, 0
+			return nil
+		},
+		BeforeDelete: func(c *gin.Context, doc *model.DiningTable) error {
+			if doc.Upcoming(time.Now()) {
+				return &StatusError{Status: http.StatusConflict, Err: errors.New("the table has upcoming reservations")}
+			}
+			return nil
+		},
+	}
+}
+
+func (server *Server) registerReservationRoutes(reservations *gin.RouterGroup) {
+	reservations.GET("/slots", server.GetTableSlots)
+	reservations.POST("", server.CreateReservation)
+	reservations.GET("/:reservation_id", server.GetReservation)
+	reservations.PUT("/:reservation_id", server.ModifyReservation)
+	reservations.POST("/:reservation_id/cancel", server.CancelReservation)
+}
+
+// takesReservations writes a 409 Conflict response unless the restaurant
+// has the opening hours reservations are checked against
+func takesReservations(c *gin.Context, restaurant *model.Restaurant) bool {
+	if !restaurant.OpeningHours.Known() {
+		c.JSON(http.StatusConflict, errorResponse(errors.New("the restaurant has no opening hours to take reservations in")))
+		return false
+	}
+	return true
+}
+
+// Find the times a party can book
+type TableSlotsRequest struct {
+	Date      string `form:"date" binding:"required"` // YYYY-MM-DD at the restaurant
+	PartySize int    `form:"party_size" binding:"required"`
+}
+
+// GetTableSlots lists the times of a date a party can be seated at, with
+// how many tables are free for it at each
+func (server *Server) GetTableSlots(c *gin.Context) {
+	var req TableSlotsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	day, err := time.Parse(dateLayout, req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(model.FieldErrors{"date": "must be a date in YYYY-MM-DD format"}))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	restaurant, ok := server.restaurantFromPath(ctx, c)
+	if !ok || !takesReservations(c, restaurant) {
+		return
+	}
+	if err := restaurant.Reservations.CheckPartySize(req.PartySize); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	tables, err := server.restaurantTables(ctx, restaurant.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, successResponse(restaurant.TableSlots(tables, day, req.PartySize, time.Now()), nil))
+}
+
+// Book a table
+type CreateReservationRequest struct {
+	Date       string `json:"date" binding:"required"` // YYYY-MM-DD at the restaurant
+	Time       string `json:"time" binding:"required"` // HH:MM at the restaurant
+	PartySize  int    `json:"party_size" binding:"required"`
+	GuestName  string `json:"guest_name"`
+	GuestEmail string `json:"guest_email"`
+	GuestPhone string `json:"guest_phone"`
+	Notes      string `json:"notes"`
+}
+
+// CreateReservation books the smallest table free for the party at the
+// time. The reservation is confirmed straight away.
+func (server *Server) CreateReservation(c *gin.Context) {
+	var req CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	restaurant, ok := server.restaurantFromPath(ctx, c)
+	if !ok || !takesReservations(c, restaurant) {
+		return
+	}
+	now := time.Now()
+	reservation := model.TableReservation{
+		ID:           primitive.NewObjectID(),
+		RestaurantID: restaurant.ID,
+		PartySize:    req.PartySize,
+		Date:         req.Date,
+		Time:         req.Time,
+		GuestName:    req.GuestName,
+		GuestEmail:   req.GuestEmail,
+		GuestPhone:   req.GuestPhone,
+		Notes:        req.Notes,
+		Status:       model.ReservationConfirmed,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := schedule(restaurant, &reservation, now); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return
+	}
+
+	if !server.seat(ctx, c, &reservation, primitive.NilObjectID) {
+		return
+	}
+	if err := server.store.Reservations.Create(ctx, &reservation); err != nil {
+		server.unseat(ctx, reservation.TableID, reservation.ID)
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// schedule sets when the reservation starts and ends from its local date
+// and time, and checks the restaurant can seat the party then. The error
+// is model.FieldErrors.
+func schedule(restaurant *model.Restaurant, reservation *model.TableReservation, now time.Time) error {
+	if err := reservation.Validate(); err != nil {
+		return err
+	}
+	wall, err := time.Parse(dateLayout+" "+timeLayout, reservation.Date+" "+reservation.Time)
+	if err != nil {
+		return model.FieldErrors{"time": "must be a time in HH:MM format"}
+	}
+	reservation.StartsAt = restaurant.OpeningHours.LocalTime(wall)
+	reservation.EndsAt = reservation.StartsAt.Add(restaurant.Reservations.TurnTime(reservation.PartySize))
+	return restaurant.CheckSeating(reservation.StartsAt, reservation.PartySize, now)
+}
+
+// seat claims a table for the reservation's time, preferred if it is free,
+// and sets the reservation's TableID. When no table is free it writes the
+// error response and returns false.
+func (server *Server) seat(ctx context.Context, c *gin.Context, reservation *model.TableReservation, preferred primitive.ObjectID) bool {
+	for attempt := 0; attempt < seatAttempts; attempt++ {
+		tables, err := server.restaurantTables(ctx, reservation.RestaurantID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+		table, ok := model.PickTable(tables, reservation.PartySize, reservation.StartsAt, reservation.EndsAt, reservation.ID, preferred)
+		if !ok {
+			break
+		}
+		// Another reservation may take the table first, in which case the
+		// next attempt picks among the tables still free
+		err = server.store.DiningTables.Claim(ctx, table.ID, reservation.Booking())
+		switch {
+		case err == nil:
+			reservation.TableID = table.ID
+			return true
+		case !errors.Is(err, repository.ErrUnavailable) && !errors.Is(err, repository.ErrConflict):
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+	}
+	c.JSON(http.StatusConflict, errorResponse(fmt.Errorf("no table for %d is free at %s on %s", reservation.PartySize, reservation.Time, reservation.Date)))
+	return false
+}
+
+// unseat frees a table of a reservation's booking. A failure leaves the
+// table booked, which turns guests away but never seats two parties at
+// once, so it is logged rather than returned. The table is freed even if
+// the request is cancelled meanwhile.
+func (server *Server) unseat(ctx context.Context, tableID, reservationID primitive.ObjectID) {
+	if err := server.store.DiningTables.Unclaim(context.WithoutCancel(ctx), tableID, reservationID); err != nil {
+		log.Printf("could not free table %s of reservation %s: %v", tableID.Hex(), reservationID.Hex(), err)
+	}
+}
+
+// restaurantTables lists every table of a restaurant
+func (server *Server) restaurantTables(ctx context.Context, restaurantID primitive.ObjectID) ([]model.DiningTable, error) {
+	tables, _, err := server.store.DiningTables.List(ctx, repository.Query{
+		Conditions: []repository.Condition{repository.Eq("restaurant_id", restaurantID)},
+	})
+	return tables, err
+}
+
+// reservationFromPath loads the reservation whose ID is in the path, which
+// must be of the restaurant in the path. It writes the error response and
+// returns false when it cannot.
+func (server *Server) reservationFromPath(ctx context.Context, c *gin.Context) (*model.TableReservation, bool) {
+	restaurantID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("reservation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid reservation ID format")))
+		return nil, false
+	}
+	reservation, err := server.store.Reservations.Get(ctx, id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound) || err == nil && reservation.RestaurantID != restaurantID:
+		c.JSON(http.StatusNotFound, errorResponse(errors.New("reservation not found")))
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	return reservation, true
+}
+
+// Get Reservation by ID
+func (server *Server) GetReservation(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	reservation, ok := server.reservationFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, reservation)
+}
+
+// checkChangeable writes a 409 Conflict response unless the reservation
+// is confirmed and has not started at now
+func checkChangeable(c *gin.Context, reservation *model.TableReservation, now time.Time) bool {
+	switch {
+	case reservation.Status != model.ReservationConfirmed:
+		c.JSON(http.StatusConflict, errorResponse(fmt.Errorf("the reservation is %s", reservation.Status)))
+		return false
+	case !now.Before(reservation.StartsAt):
+		c.JSON(http.StatusConflict, errorResponse(errors.New("the reservation has already started")))
+		return false
+	}
+	return true
+}
+
+// Modify a reservation. Fields left out keep their value.
+type ModifyReservationRequest struct {
+	Date       *string `json:"date"`
+	Time       *string `json:"time"`
+	PartySize  *int    `json:"party_size"`
+	GuestName  *string `json:"guest_name"`
+	GuestEmail *string `json:"guest_email"`
+	GuestPhone *string `json:"guest_phone"`
+	Notes      *string `json:"notes"`
+}
+
+// ModifyReservation changes a confirmed reservation. A new date, time or
+// party size keeps the table when it is still free and fits, and otherwise
+// moves the party to another one; without a free table the reservation is
+// left as it was.
+func (server *Server) ModifyReservation(c *gin.Context) {
+	var req ModifyReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	old, ok := server.reservationFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if !checkChangeable(c, old, now) {
+		return
+	}
+
+	reservation := *old
+	if req.Date != nil {
+		reservation.Date = *req.Date
+	}
+	if req.Time != nil {
+		reservation.Time = *req.Time
+	}
+	if req.PartySize != nil {
+		reservation.PartySize = *req.PartySize
+	}
+	if req.GuestName != nil {
+		reservation.GuestName = *req.GuestName
+	}
+	if req.GuestEmail != nil {
+		reservation.GuestEmail = *req.GuestEmail
+	}
+	if req.GuestPhone != nil {
+		reservation.GuestPhone = *req.GuestPhone
+	}
+	if req.Notes != nil {
+		reservation.Notes = *req.Notes
+	}
+	reservation.UpdatedAt = now
+
+	moved := reservation.Date != old.Date || reservation.Time != old.Time || reservation.PartySize != old.PartySize
+	if !moved {
+		if err := reservation.Validate(); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+	} else {
+		restaurant, ok := server.restaurantFromPath(ctx, c)
+		if !ok || !takesReservations(c, restaurant) {
+			return
+		}
+		if err := schedule(restaurant, &reservation, now); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		if !server.seat(ctx, c, &reservation, old.TableID) {
+			return
+		}
+	}
+
+	if err := server.store.Reservations.Transition(ctx, &reservation, model.ReservationConfirmed); err != nil {
+		if moved {
+			server.restoreSeat(ctx, &reservation)
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, errorResponse(errors.New("the reservation was changed by another request")))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if moved && reservation.TableID != old.TableID {
+		server.unseat(ctx, old.TableID, old.ID)
+	}
+	c.JSON(http.StatusOK, reservation)
+}
+
+// restoreSeat undoes the table claim of a modification that was not
+// stored. The stored reservation decides what the table keeps: the booking
+// of a modification that won on the same table, or of the reservation as
+// it was, and nothing once the reservation is cancelled.
+func (server *Server) restoreSeat(ctx context.Context, modified *model.TableReservation) {
+	ctx = context.WithoutCancel(ctx)
+	stored, err := server.store.Reservations.Get(ctx, modified.ID)
+	if err != nil {
+		log.Printf("could not restore the table of reservation %s: %v", modified.ID.Hex(), err)
+		return
+	}
+	if stored.Status != model.ReservationConfirmed || stored.TableID != modified.TableID {
+		server.unseat(ctx, modified.TableID, modified.ID)
+		return
+	}
+	if err := server.store.DiningTables.Claim(ctx, stored.TableID, stored.Booking()); err != nil {
+		log.Printf("could not restore the table of reservation %s: %v", stored.ID.Hex(), err)
+	}
+}
+
+// Cancel Reservation
+type CancelReservationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CancelReservation cancels a confirmed reservation that has not started
+// and frees its table
+func (server *Server) CancelReservation(c *gin.Context) {
+	var req CancelReservationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	reservation, ok := server.reservationFromPath(ctx, c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if !checkChangeable(c, reservation, now) {
+		return
+	}
+
+	reservation.Status = model.ReservationCancelled
+	reservation.Cancellation = &model.Cancellation{Reason: req.Reason, CancelledAt: now}
+	reservation.UpdatedAt = now
+	if err := server.store.Reservations.Transition(ctx, reservation, model.ReservationConfirmed); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, errorResponse(errors.New("the reservation was changed by another request")))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	server.unseat(ctx, reservation.TableID, reservation.ID)
+	c.JSON(http.StatusOK, reservation)
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+)
+
+func TestTableReservations(t *testing.T) {
+	server := newTestServer(t)
+
+	create := func(path string, doc, created interface{}) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPost, "/api/v1/"+path, doc)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", path, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+		response := struct {
+			Data interface{} `json:"data"`
+		}{created}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Lunch and dinner every day, with longer turns for large parties
+	bistro := model.Restaurant{
+		Name:         "Bistro",
+		OpeningHours: model.OpeningHours{TimeZone: "Europe/Paris"},
+		Reservations: model.ReservationRules{
+			TurnMinutes:     90,
+			TurnTimes:       []model.PartyTurnTime{{MinPartySize: 6, Minutes: 120}},
+			IntervalMinutes: 30,
+			MaxPartySize:    8,
+		},
+	}
+	for _, weekday := range model.Weekdays {
+		bistro.OpeningHours.Weekly = append(bistro.OpeningHours.Weekly,
+			model.OpeningPeriod{Day: weekday, TimeRange: model.TimeRange{Opens: "12:00", Closes: "15:00"}},
+			model.OpeningPeriod{Day: weekday, TimeRange: model.TimeRange{Opens: "19:00", Closes: "23:00"}})
+	}
+	var cafe model.Restaurant
+	create("restaurants", &bistro, &bistro)
+	create("restaurants", model.Restaurant{Name: "Cafe"}, &cafe)
+	tablesPath := "restaurants/" + bistro.ID.Hex() + "/tables"
+	reservationsPath := "/api/v1/restaurants/" + bistro.ID.Hex() + "/reservations"
+
+	tables := map[string]*model.DiningTable{}
+	for _, table := range []model.DiningTable{
+		{Name: "1", MaxSeats: 2},
+		{Name: "2", MaxSeats: 2},
+		{Name: "3", MinSeats: 2, MaxSeats: 4, Area: "Terrace"},
+		{Name: "10", MinSeats: 5, MaxSeats: 8},
+	} {
+		created := new(model.DiningTable)
+		create(tablesPath, table, created)
+		tables[table.Name] = created
+	}
+	if invalid := (model.DiningTable{Name: "4", MinSeats: 4, MaxSeats: 2}); performRequest(server, http.MethodPost, "/api/v1/"+tablesPath, invalid).Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for more minimum than maximum seats", http.StatusUnprocessableEntity)
+	}
+
+	date := model.Night(time.Now().UTC().AddDate(0, 0, 14)).Format(dateLayout)
+	slots := func(partySize string) []model.TableSlot {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, reservationsPath+"/slots?date="+date+"&party_size="+partySize, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.TableSlot `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		return list.Data
+	}
+	times := func(slots []model.TableSlot) []string {
+		var times []string
+		for _, slot := range slots {
+			times = append(times, slot.Time)
+		}
+		return times
+	}
+	free := func(slots []model.TableSlot, at string) int {
+		for _, slot := range slots {
+			if slot.Time == at {
+				return slot.Tables
+			}
+		}
+		return 0
+	}
+
+	// A 90 minute turn must end by closing time
+	pair := slots("2")
+	if got := times(pair); !equalStrings(got, []string{"12:00", "12:30", "13:00", "13:30", "19:00", "19:30", "20:00", "20:30", "21:00", "21:30"}) {
+		t.Errorf("expected lunch and dinner times for two, got %v", got)
+	}
+	if free(pair, "19:00") != 3 || pair[0].EndsAt.Sub(pair[0].StartsAt) != 90*time.Minute {
+		t.Errorf("expected three tables for two with a 90 minute turn, got %+v", pair[0])
+	}
+	if got := times(slots("7")); !equalStrings(got, []string{"12:00", "12:30", "13:00", "19:00", "19:30", "20:00", "20:30", "21:00"}) {
+		t.Errorf("expected the two hour turn of large parties, got %v", got)
+	}
+	if recorder := performRequest(server, http.MethodGet, reservationsPath+"/slots?date="+date+"&party_size=9", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for a party above the maximum, got %d", http.StatusBadRequest, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/"+cafe.ID.Hex()+"/reservations/slots?date="+date+"&party_size=2", nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d without opening hours, got %d", http.StatusConflict, recorder.Code)
+	}
+
+	book := func(at string, partySize int) (model.TableReservation, int) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPost, reservationsPath, CreateReservationRequest{
+			Date: date, Time: at, PartySize: partySize, GuestName: "Ada", GuestEmail: "ada@example.com",
+		})
+		var reservation model.TableReservation
+		if recorder.Code == http.StatusCreated {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &reservation); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return reservation, recorder.Code
+	}
+	// The smallest free table is taken first
+	first, _ := book("19:00", 2)
+	second, _ := book("19:30", 2)
+	third, _ := book("20:00", 2)
+	for i, test := range []struct {
+		reservation model.TableReservation
+		table       string
+	}{{first, "1"}, {second, "2"}, {third, "3"}} {
+		if test.reservation.TableID != tables[test.table].ID || test.reservation.Status != model.ReservationConfirmed {
+			t.Errorf("reservation %d: expected a confirmed booking of table %s, got %+v", i+1, test.table, test.reservation)
+		}
+	}
+	if _, status := book("20:00", 2); status != http.StatusConflict {
+		t.Errorf("expected %d once every table is taken, got %d", http.StatusConflict, status)
+	}
+	pair = slots("2")
+	if free(pair, "19:00") != 0 || free(pair, "20:30") != 1 || free(pair, "21:00") != 2 || free(pair, "21:30") != 3 {
+		t.Errorf("expected the tables to be freed as the turns end, got %+v", pair)
+	}
+	for _, at := range []string{"14:00", "16:00", "19:15", "25:00"} {
+		if _, status := book(at, 2); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected %d, got %d", at, http.StatusUnprocessableEntity, status)
+		}
+	}
+
+	modify := func(reservation model.TableReservation, changes map[string]interface{}) (model.TableReservation, int) {
+		t.Helper()
+		recorder := performRequest(server, http.MethodPut, reservationsPath+"/"+reservation.ID.Hex(), changes)
+		var modified model.TableReservation
+		if recorder.Code == http.StatusOK {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &modified); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return modified, recorder.Code
+	}
+	// The only table for four is taken from 20:00
+	if _, status := modify(first, map[string]interface{}{"party_size": 4}); status != http.StatusConflict {
+		t.Errorf("expected %d without a table for four, got %d", http.StatusConflict, status)
+	}
+	moved, status := modify(first, map[string]interface{}{"time": "21:30", "notes": "Birthday"})
+	if status != http.StatusOK || moved.TableID != first.TableID || moved.Time != "21:30" || moved.Notes != "Birthday" || moved.GuestName != "Ada" {
+		t.Errorf("expected the reservation moved to 21:30 at the same table, got %d %+v", status, moved)
+	}
+	if pair := slots("2"); free(pair, "19:00") != 1 || free(pair, "21:30") != 2 {
+		t.Errorf("expected the old time freed and the new one taken, got %+v", pair)
+	}
+
+	cancel := func(reservation model.TableReservation) int {
+		return performRequest(server, http.MethodPost, reservationsPath+"/"+reservation.ID.Hex()+"/cancel", CancelReservationRequest{Reason: "Plans changed"}).Code
+	}
+	if status := cancel(third); status != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, status)
+	}
+	if status := cancel(third); status != http.StatusConflict {
+		t.Errorf("expected %d for a cancelled reservation, got %d", http.StatusConflict, status)
+	}
+	if _, status := modify(third, map[string]interface{}{"notes": "Window"}); status != http.StatusConflict {
+		t.Errorf("expected %d for modifying a cancelled reservation, got %d", http.StatusConflict, status)
+	}
+	if four, status := book("20:00", 4); status != http.StatusCreated || four.TableID != tables["3"].ID {
+		t.Errorf("expected the cancelled table to be booked again, got %d %+v", status, four)
+	}
+
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/"+cafe.ID.Hex()+"/reservations/"+second.ID.Hex(), nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected %d for another restaurant's reservation, got %d", http.StatusNotFound, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/"+tablesPath+"/"+tables["1"].ID.Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a table with upcoming reservations, got %d", http.StatusConflict, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodDelete, "/api/v1/restaurants/"+bistro.ID.Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected %d for a restaurant with tables, got %d", http.StatusConflict, recorder.Code)
+	}
+
+	// Of two modifications read at the same time only one is stored, and
+	// one that lost to a cancellation does not keep the table booked
+	ctx := context.Background()
+	late, _ := book("21:00", 2)
+	stored, err := server.store.Reservations.Get(ctx, late.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	winner, loser := *stored, *stored
+	winner.Notes, loser.Notes = "Window", "Terrace"
+	if err := server.store.Reservations.Transition(ctx, &winner, model.ReservationConfirmed); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.store.Reservations.Transition(ctx, &loser, model.ReservationConfirmed); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("expected the second modification to conflict, got %v", err)
+	}
+	if status := cancel(late); status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+	loser.Time = "21:30"
+	loser.StartsAt, loser.EndsAt = loser.StartsAt.Add(30*time.Minute), loser.EndsAt.Add(30*time.Minute)
+	if err := server.store.DiningTables.Claim(ctx, loser.TableID, loser.Booking()); err != nil {
+		t.Fatal(err)
+	}
+	server.restoreSeat(ctx, &loser)
+	if table, _ := server.store.DiningTables.Get(ctx, late.TableID); len(table.Bookings) != 1 || table.Bookings[0].ReservationID == late.ID {
+		t.Errorf("expected the cancelled reservation's table to be freed, got %+v", table.Bookings)
+	}
+
+	// Editing a table keeps its bookings
+	performRequest(server, http.MethodPut, "/api/v1/"+tablesPath+"/"+tables["2"].ID.Hex(), map[string]interface{}{"area": "Window", "bookings": []model.TableBooking{}})
+	recorder := performRequest(server, http.MethodGet, "/api/v1/"+tablesPath+"/"+tables["2"].ID.Hex(), nil)
+	var edited model.DiningTable
+	if err := json.Unmarshal(recorder.Body.Bytes(), &edited); err != nil {
+		t.Fatal(err)
+	}
+	if edited.Area != "window" || len(edited.Bookings) != 1 || edited.Bookings[0].ReservationID != second.ID {
+		t.Errorf("expected the new area and the booking kept, got %+v", edited)
+	}
+}
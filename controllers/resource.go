@@ -0,0 +1,514 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// readOnlyFields are the JSON fields a client can never set; they are owned
+// by the storage layer and the timestamps applied by Resource
+var readOnlyFields = []string{"id", "created_at", "updated_at"}
+
+// Resource serves the create, get, list, update and delete endpoints of a
+// model stored in a repository. A model registers with its Name and Repo;
+// the optional hooks add validation and side effects without rewriting the
+// handlers.
+//
+// Models that implement Validate() error or SetDefaults() get those called
+// before every write. Fields named CreatedAt and UpdatedAt are maintained
+// automatically.
+type Resource[T any] struct {
+	// Name is the singular, lower-case name used in messages, e.g. "photo"
+	Name string
+	Repo repository.Repository[T]
+	// Sort orders List results. Newest first when empty.
+	Sort []repository.SortField
+	// Sorts are the other orders a list can ask for with ?sort=, by name.
+	// Resources without any ignore the parameter.
+	Sorts map[string][]repository.SortField
+
+	// IDParam is the path parameter naming a document, "id" by default.
+	// Resources nested under another one's /:id need a different name.
+	IDParam string
+	// Scope nests the resource under the document named in the path, as
+	// menu items are under /restaurants/:id/menu/items. Lists only return
+	// the documents it selects, and other documents are not found.
+	Scope func(c *gin.Context) ([]repository.Condition, error)
+	// Adopt makes a created or updated document belong to the one named in
+	// the path. It runs before validation, so it can set required fields.
+	Adopt func(c *gin.Context, doc *T) error
+
+	// Validate adds checks to the model's own Validate method. Return a
+	// model.FieldErrors to report which fields are invalid.
+	Validate func(doc *T) error
+
+	// BeforeCreate runs after validation and may reject the document
+	BeforeCreate func(c *gin.Context, doc *T) error
+	// AfterCreate runs once the document has been stored
+	AfterCreate func(c *gin.Context, doc *T)
+	// BeforeUpdate receives the stored document and its replacement
+	BeforeUpdate func(c *gin.Context, old, doc *T) error
+	// AfterUpdate receives the replaced document and its replacement once
+	// the replacement has been stored
+	AfterUpdate func(c *gin.Context, old, doc *T)
+	// BeforeDelete may reject the deletion of doc
+	BeforeDelete func(c *gin.Context, doc *T) error
+	// AfterDelete runs once doc has been removed
+	AfterDelete func(c *gin.Context, doc *T)
+
+	// Present adjusts the documents returned by every endpoint, for example
+	// to show their prices in another currency
+	Present func(c *gin.Context, docs []T) error
+}
+
+// Register mounts the resource's endpoints on group
+func (r *Resource[T]) Register(group *gin.RouterGroup) {
+	r.RegisterReads(group)
+	r.RegisterWrites(group)
+}
+
+// RegisterReads mounts the list and get endpoints on group
+func (r *Resource[T]) RegisterReads(group *gin.RouterGroup) {
+	group.GET("", r.List)
+	group.GET("/:"+r.idParam(), r.Get)
+}
+
+// RegisterWrites mounts the create, update and delete endpoints on group,
+// for resources only some callers may change
+func (r *Resource[T]) RegisterWrites(group *gin.RouterGroup) {
+	group.POST("", r.Create)
+	group.PUT("/:"+r.idParam(), r.Update)
+	group.DELETE("/:"+r.idParam(), r.Delete)
+}
+
+func (r *Resource[T]) idParam() string {
+	if r.IDParam == "" {
+		return "id"
+	}
+	return r.IDParam
+}
+
+// listRequest pages a resource list. Both parameters are optional so nested
+// lists such as /hotels/:id/reviews keep working without them.
+type listRequest struct {
+	PageID   int64  `form:"page_id" binding:"omitempty,min=1"`
+	PageSize int64  `form:"page_size" binding:"omitempty,min=5,max=100"`
+	Sort     string `form:"sort"` // one of the resource's Sorts
+}
+
+const defaultPageSize = 20
+
+// List returns a page of documents, those in Scope if it is set
+func (r *Resource[T]) List(c *gin.Context) {
+	if r.Scope != nil {
+		r.ListBy(r.Scope)(c)
+		return
+	}
+	r.list(c, nil)
+}
+
+// ListBy returns a handler that lists the documents selected by scope, such
+// as the reviews of the hotel named in the path
+func (r *Resource[T]) ListBy(scope func(c *gin.Context) ([]repository.Condition, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conditions, err := scope(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		r.list(c, conditions)
+	}
+}
+
+// GetBy returns a handler that gets the document named in the path if scope
+// selects it, such as a review the public can see. Other documents are not
+// found.
+func (r *Resource[T]) GetBy(scope func(c *gin.Context) ([]repository.Condition, error)) gin.HandlerFunc {
+	scoped := *r
+	scoped.Scope = func(c *gin.Context) ([]repository.Condition, error) {
+		conditions, err := scope(c)
+		if err != nil || r.Scope == nil {
+			return conditions, err
+		}
+		outer, err := r.Scope(c)
+		return append(outer, conditions...), err
+	}
+	return scoped.Get
+}
+
+func (r *Resource[T]) list(c *gin.Context, conditions []repository.Condition) {
+	var req listRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.PageID == 0 {
+		req.PageID = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+
+	query := repository.Query{Conditions: conditions, Sort: r.Sort}
+	if req.Sort != "" && r.Sorts != nil {
+		order, ok := r.Sorts[req.Sort]
+		if !ok {
+			c.JSON(http.StatusBadRequest, errorResponse(model.FieldErrors{"sort": "must be one of " + strings.Join(r.sortNames(), ", ")}))
+			return
+		}
+		query.Sort = order
+	}
+	if len(query.Sort) == 0 {
+		query.Sort = []repository.SortField{repository.Desc("created_at")}
+	}
+	docs, total, err := r.Repo.List(c.Request.Context(), query.Page(req.PageID, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve %ss", r.Name)})
+		return
+	}
+	if r.Present != nil {
+		if err := r.Present(c, docs); err != nil {
+			r.abort(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, successResponse(docs, paginationResponse(req.PageID, req.PageSize, len(docs), total)))
+}
+
+// sortNames lists the names of the resource's Sorts in order
+func (r *Resource[T]) sortNames() []string {
+	names := make([]string, 0, len(r.Sorts))
+	for name := range r.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the document named by the id path parameter
+func (r *Resource[T]) Get(c *gin.Context) {
+	doc, ok := r.load(c)
+	if !ok {
+		return
+	}
+	if doc, ok = r.present(c, doc); ok {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// present runs the Present hook on a copy of doc, writing the error
+// response when it fails
+func (r *Resource[T]) present(c *gin.Context, doc *T) (*T, bool) {
+	if r.Present == nil {
+		return doc, true
+	}
+	docs := []T{*doc}
+	if err := r.Present(c, docs); err != nil {
+		r.abort(c, err)
+		return nil, false
+	}
+	return &docs[0], true
+}
+
+// Create stores the document in the request body
+func (r *Resource[T]) Create(c *gin.Context) {
+	doc := new(T)
+	if err := decodeWritable(c.Request.Body, doc); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !r.adopt(c, doc) || !r.check(c, doc) {
+		return
+	}
+	now := time.Now()
+	setTimeField(doc, "CreatedAt", now)
+	setTimeField(doc, "UpdatedAt", now)
+	if r.BeforeCreate != nil {
+		if err := r.BeforeCreate(c, doc); err != nil {
+			r.abort(c, err)
+			return
+		}
+	}
+
+	if err := r.Repo.Create(c.Request.Context(), doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create " + r.Name})
+		return
+	}
+	if r.AfterCreate != nil {
+		r.AfterCreate(c, doc)
+	}
+
+	if doc, ok := r.present(c, doc); ok {
+		c.JSON(http.StatusCreated, gin.H{
+			"message": r.title() + " created successfully",
+			"data":    doc,
+			"id":      documentID(doc),
+		})
+	}
+}
+
+// Update applies the fields present in the request body to the stored
+// document; fields that are left out keep their current value
+func (r *Resource[T]) Update(c *gin.Context) {
+	old, ok := r.load(c)
+	if !ok {
+		return
+	}
+
+	// Decoding into a shallow copy would write through the slices, maps
+	// and pointers it shares with old, which the hooks compare against
+	doc, err := cloneDocument(old)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update " + r.Name})
+		return
+	}
+	if err := decodeWritable(c.Request.Body, doc); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !r.adopt(c, doc) || !r.check(c, doc) {
+		return
+	}
+	setTimeField(doc, "UpdatedAt", time.Now())
+	if r.BeforeUpdate != nil {
+		if err := r.BeforeUpdate(c, old, doc); err != nil {
+			r.abort(c, err)
+			return
+		}
+	}
+
+	if err := r.Repo.Update(c.Request.Context(), doc); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": r.title() + " not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": r.title() + " was changed meanwhile, try again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update " + r.Name})
+		return
+	}
+	if r.AfterUpdate != nil {
+		r.AfterUpdate(c, old, doc)
+	}
+
+	if doc, ok := r.present(c, doc); ok {
+		c.JSON(http.StatusOK, gin.H{
+			"message": r.title() + " updated successfully",
+			"data":    doc,
+		})
+	}
+}
+
+// Delete removes the document named by the id path parameter
+func (r *Resource[T]) Delete(c *gin.Context) {
+	doc, ok := r.load(c)
+	if !ok {
+		return
+	}
+	if r.BeforeDelete != nil {
+		if err := r.BeforeDelete(c, doc); err != nil {
+			r.abort(c, err)
+			return
+		}
+	}
+
+	if err := r.Repo.Delete(c.Request.Context(), documentID(doc)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": r.title() + " not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete " + r.Name})
+		return
+	}
+	if r.AfterDelete != nil {
+		r.AfterDelete(c, doc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": r.title() + " deleted successfully"})
+}
+
+// load fetches the document named by the IDParam path parameter, writing
+// the error response itself when it cannot
+func (r *Resource[T]) load(c *gin.Context) (*T, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param(r.idParam()))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return nil, false
+	}
+
+	doc, err := r.get(c, id)
+	if err != nil {
+		var status *StatusError
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": r.title() + " not found"})
+			return nil, false
+		case errors.As(err, &status):
+			r.abort(c, err)
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve " + r.Name})
+		return nil, false
+	}
+	return doc, true
+}
+
+// get fetches the document with id, which must be in Scope if it is set
+func (r *Resource[T]) get(c *gin.Context, id primitive.ObjectID) (*T, error) {
+	if r.Scope == nil {
+		return r.Repo.Get(c.Request.Context(), id)
+	}
+	conditions, err := r.Scope(c)
+	if err != nil {
+		return nil, &StatusError{Status: http.StatusBadRequest, Err: err}
+	}
+	docs, _, err := r.Repo.List(c.Request.Context(), repository.Query{
+		Conditions: append(conditions, repository.Eq("_id", id)),
+		Limit:      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &docs[0], nil
+}
+
+// adopt runs the Adopt hook, writing the error response when it fails
+func (r *Resource[T]) adopt(c *gin.Context, doc *T) bool {
+	if r.Adopt == nil {
+		return true
+	}
+	if err := r.Adopt(c, doc); err != nil {
+		r.abort(c, err)
+		return false
+	}
+	return true
+}
+
+// check applies the model's defaults and every validation, writing the
+// error response when the document is invalid
+func (r *Resource[T]) check(c *gin.Context, doc *T) bool {
+	if d, ok := any(doc).(interface{ SetDefaults() }); ok {
+		d.SetDefaults()
+	}
+	if v, ok := any(doc).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			r.abort(c, err)
+			return false
+		}
+	}
+	if r.Validate != nil {
+		if err := r.Validate(doc); err != nil {
+			r.abort(c, err)
+			return false
+		}
+	}
+	return true
+}
+
+// abort writes the response for an error returned by a hook
+func (r *Resource[T]) abort(c *gin.Context, err error) {
+	var status *StatusError
+	var fields model.FieldErrors
+	switch {
+	case errors.As(err, &status):
+		c.JSON(status.Status, errorResponse(status.Err))
+	case errors.As(err, &fields):
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, errorResponse(err))
+	default:
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+	}
+}
+
+// title is Name with its first letter upper-cased, for the start of messages
+func (r *Resource[T]) title() string {
+	if r.Name == "" {
+		return ""
+	}
+	return strings.ToUpper(r.Name[:1]) + r.Name[1:]
+}
+
+// StatusError makes a hook's error produce a specific HTTP status
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// decodeWritable decodes a JSON object onto doc, ignoring readOnlyFields.
+// Fields missing from the object are left untouched.
+func decodeWritable(body io.Reader, doc interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&fields); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	for _, field := range readOnlyFields {
+		delete(fields, field)
+	}
+
+	writable, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(writable, doc); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// cloneDocument returns a deep copy of doc, made by a round trip through
+// the BSON it is stored as
+func cloneDocument[T any](doc *T) (*T, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	clone := new(T)
+	if err := bson.Unmarshal(raw, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// setTimeField sets the time.Time field called name on the struct doc
+// points to, if it has one
+func setTimeField(doc interface{}, name string, t time.Time) {
+	field := reflect.ValueOf(doc).Elem().FieldByName(name)
+	if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(t) {
+		field.Set(reflect.ValueOf(t))
+	}
+}
+
+// documentID returns the ID field of the struct doc points to
+func documentID(doc interface{}) primitive.ObjectID {
+	field := reflect.ValueOf(doc).Elem().FieldByName("ID")
+	if !field.IsValid() {
+		return primitive.NilObjectID
+	}
+	id, _ := field.Interface().(primitive.ObjectID)
+	return id
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResourceLifecycle(t *testing.T) {
+	server := newTestServer(t)
+	hotelID := primitive.NewObjectID()
+
+	recorder := performRequest(server, http.MethodPost, "/api/v1/ratings", gin.H{
+		"id":       primitive.NewObjectID().Hex(),
+		"user_id":  primitive.NewObjectID().Hex(),
+		"hotel_id": hotelID.Hex(),
+		"score":    4,
+		"comment":  "Quiet rooms",
+	})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create: expected %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body)
+	}
+	var created struct {
+		Data model.Rating       `json:"data"`
+		ID   primitive.ObjectID `json:"id"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.ID.IsZero() || created.ID != created.Data.ID || created.Data.CreatedAt.IsZero() {
+		t.Fatalf("create did not assign an ID and timestamps: %s", recorder.Body)
+	}
+
+	// Fields left out of an update keep their value; the ID cannot change
+	recorder = performRequest(server, http.MethodPut, "/api/v1/ratings/"+created.ID.Hex(), gin.H{
+		"id":    primitive.NewObjectID().Hex(),
+		"score": 5,
+	})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("update: expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	recorder = performRequest(server, http.MethodGet, "/api/v1/ratings/"+created.ID.Hex(), nil)
+	var fetched model.Rating
+	if err := json.Unmarshal(recorder.Body.Bytes(), &fetched); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if fetched.Score != 5 || fetched.Comment != "Quiet rooms" || fetched.HotelID != hotelID {
+		t.Errorf("unexpected rating after update: %+v", fetched)
+	}
+
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels/"+hotelID.Hex()+"/ratings", nil)
+	var list struct {
+		Data       []model.Rating `json:"data"`
+		Pagination map[string]int `json:"pagination"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list.Data) != 1 || list.Pagination["total"] != 1 || list.Pagination["page_size"] != defaultPageSize {
+		t.Errorf("expected one rating on the default page, got %s", recorder.Body)
+	}
+
+	recorder = performRequest(server, http.MethodDelete, "/api/v1/ratings/"+created.ID.Hex(), nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("delete: expected %d, got %d", http.StatusOK, recorder.Code)
+	}
+	recorder = performRequest(server, http.MethodGet, "/api/v1/ratings/"+created.ID.Hex(), nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("get after delete: expected %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestResourceValidationReportsFields(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := performRequest(server, http.MethodPost, "/api/v1/reviews", gin.H{
+		"entity_type": "hotel",
+		"title":       "Great stay",
+		"rating":      9,
+	})
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected %d, got %d: %s", http.StatusUnprocessableEntity, recorder.Code, recorder.Body)
+	}
+	var response struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"user_id", "entity_id", "content", "rating"} {
+		if _, ok := response.Fields[field]; !ok {
+			t.Errorf("expected an error for %q, got %s", field, recorder.Body)
+		}
+	}
+	if _, ok := response.Fields["title"]; ok {
+		t.Errorf("title is valid but was reported: %s", recorder.Body)
+	}
+}
+
+func TestResourceHooks(t *testing.T) {
+	server := newTestServer(t)
+	var deleted []string
+	cuisines := &Resource[model.Cuisine]{
+		Name: "cuisine",
+		Repo: server.store.Cuisines,
+		BeforeDelete: func(c *gin.Context, cuisine *model.Cuisine) error {
+			if cuisine.Name == "Jollof" {
+				return &StatusError{Status: http.StatusConflict, Err: errors.New("cuisine is still served")}
+			}
+			return nil
+		},
+		AfterDelete: func(c *gin.Context, cuisine *model.Cuisine) {
+			deleted = append(deleted, cuisine.Name)
+		},
+	}
+	router := gin.New()
+	cuisines.Register(router.Group("/cuisines"))
+	server.router = router
+
+	ids := map[string]primitive.ObjectID{}
+	for _, name := range []string{"Jollof", "Suya"} {
+		recorder := performRequest(server, http.MethodPost, "/cuisines", gin.H{"name": name})
+		var created struct {
+			ID primitive.ObjectID `json:"id"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = created.ID
+	}
+
+	if recorder := performRequest(server, http.MethodDelete, "/cuisines/"+ids["Jollof"].Hex(), nil); recorder.Code != http.StatusConflict {
+		t.Errorf("expected the hook to reject the delete with %d, got %d", http.StatusConflict, recorder.Code)
+	}
+	if recorder := performRequest(server, http.MethodDelete, "/cuisines/"+ids["Suya"].Hex(), nil); recorder.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if len(deleted) != 1 || deleted[0] != "Suya" {
+		t.Errorf("AfterDelete saw %v", deleted)
+	}
+}
+
+func TestResourceUpdateKeepsOld(t *testing.T) {
+	server := newTestServer(t)
+	var before model.Restaurant
+	restaurants := &Resource[model.Restaurant]{
+		Name: "restaurant",
+		Repo: server.store.Restaurants,
+		AfterUpdate: func(c *gin.Context, old, doc *model.Restaurant) {
+			before = *old
+		},
+	}
+	router := gin.New()
+	restaurants.Register(router.Group("/restaurants"))
+	server.router = router
+
+	restaurant := model.Restaurant{
+		Name:         "Bistro",
+		Offers:       []model.Offer{{Type: "RESERVATION", Label: "Book a table"}},
+		OpeningHours: model.OpeningHours{Weekly: []model.OpeningPeriod{{Day: "monday", TimeRange: model.TimeRange{Opens: "12:00", Closes: "15:00"}}}},
+	}
+	if err := server.store.Restaurants.Create(context.Background(), &restaurant); err != nil {
+		t.Fatal(err)
+	}
+	recorder := performRequest(server, http.MethodPut, "/restaurants/"+restaurant.ID.Hex(), gin.H{
+		"offers":        []gin.H{{"type": "DELIVERY", "label": "Order in"}},
+		"opening_hours": gin.H{"weekly": []gin.H{{"day": "monday", "opens": "18:00", "closes": "23:00"}}},
+	})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if len(before.Offers) != 1 || before.Offers[0].Label != "Book a table" || before.OpeningHours.Weekly[0].Opens != "12:00" {
+		t.Errorf("expected the hooks to see the stored restaurant, got %+v %+v", before.Offers, before.OpeningHours.Weekly)
+	}
+}
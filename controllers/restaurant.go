@@ -2,247 +2,271 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/janto-pee/Horizon-Travels.git/model"
-	"github.com/janto-pee/Horizon-Travels.git/util"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type RestaurantRequest struct {
-	Name          string            `json:"name" binding:"required"`
-	Description   string            `json:"description" binding:"required"`
-	Origin        string            `json:"origin" binding:"required"`
-	Category      string            `json:"category" binding:"required"`
-	Address       string            `json:"address" `
-	Location      string            `json:"location" `
-	PriceRange    string            `json:"price_range" `
-	Rating        float64           `json:"rating" json:"rating"`
-	ContactNumber string            `json:"contact_number"  `
-	Email         string            `json:"email" `
-	Website       string            `json:"website" json:"website"`
-	OpeningHours  map[string]string `json:"opening_hours" json:"opening_hours"`
-}
-
-// CreateRestaurant handles the creation of a new restaurant
-func CreateRestaurant(c *gin.Context) {
-	var req RestaurantRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// openAtLayout is the format of the open_at filter, a wall-clock time at
+// the restaurant
+const openAtLayout = "2006-01-02T15:04"
+
+// atFormatMessage explains the format of the at parameter
+const atFormatMessage = "must be a time in RFC 3339 format such as 2024-05-01T19:30:00Z"
+
+func (server *Server) restaurantResource() *Resource[model.Restaurant] {
+	prices := pricesInCurrency[model.Restaurant](server)
+	return &Resource[model.Restaurant]{
+		Name: "restaurant",
+		Repo: server.store.Restaurants,
+		Present: func(c *gin.Context, docs []model.Restaurant) error {
+			if err := prices(c, docs); err != nil {
+				return err
+			}
+			at, err := statusInstant(c.Query("at"))
+			if err != nil {
+				return &StatusError{Status: http.StatusBadRequest, Err: err}
+			}
+			setOpenStatus(docs, at, nil)
+			return nil
+		},
+		// The menu summary is derived from the menu items, and so is the
+		// price range of a restaurant with a priced menu
+		BeforeCreate: func(c *gin.Context, doc *model.Restaurant) error {
+			doc.Menu = model.MenuSummary{}
+			return nil
+		},
+		BeforeUpdate: func(c *gin.Context, old, doc *model.Restaurant) error {
+			doc.Menu = old.Menu
+			if old.Menu.AveragePrice > 0 {
+				doc.PriceRange = old.PriceRange
+			}
+			return nil
+		},
+		BeforeDelete: func(c *gin.Context, doc *model.Restaurant) error {
+			ofRestaurant := repository.Eq("restaurant_id", doc.ID)
+			if err := refuseInUse(c.Request.Context(), server.store.MenuSections, "the restaurant still has a menu", ofRestaurant); err != nil {
+				return err
+			}
+			return refuseInUse(c.Request.Context(), server.store.DiningTables, "the restaurant still has tables", ofRestaurant)
+		},
 	}
+}
 
-	now := time.Now()
-	restaurant := model.Restaurant{
-		ID:          primitive.NewObjectID(),
-		Name:        req.Name,
-		Description: req.Description,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
+func (server *Server) registerRestaurantRoutes(restaurants *gin.RouterGroup) {
+	restaurants.GET("/search", server.SearchRestaurants)
+	server.restaurants.Register(restaurants)
+	server.registerMenuRoutes(restaurants.Group("/:id/menu"))
+	server.diningTables.Register(restaurants.Group("/:id/tables"))
+	server.registerReservationRoutes(restaurants.Group("/:id/reservations"))
+	restaurants.GET("/:id/ratings", server.ratings.ListBy(entityOfRestaurant))
+	restaurants.GET("/:id/ratings/summary", server.GetRatingSummary("restaurant"))
+}
 
-	// Save the restaurant to the database
-	collection := util.MongoClient.Database(util.DbName).Collection(restaurant.CollectionName())
-	result, err := collection.InsertOne(context.TODO(), restaurant)
+// entityOfRestaurant selects the documents attached to the restaurant in
+// the path through their entity_type and entity_id
+func entityOfRestaurant(c *gin.Context) ([]repository.Condition, error) {
+	restaurantID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create restaurant"})
-		return
+		return nil, errors.New("invalid restaurant ID format")
 	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Restaurant created successfully",
-		"data":    restaurant,
-		"id":      result.InsertedID,
-	})
+	return []repository.Condition{
+		repository.Eq("entity_type", "restaurant"),
+		repository.Eq("entity_id", restaurantID),
+	}, nil
 }
 
-// GetRestaurantByID retrieves a restaurant by its ID
-func GetRestaurantByID(c *gin.Context) {
-	id := c.Param("id")
-
-	restaurantID, err := primitive.ObjectIDFromHex(id)
+// restaurantFromPath loads the restaurant whose ID is in the path, writing
+// the error response and returning false when it cannot
+func (server *Server) restaurantFromPath(ctx context.Context, c *gin.Context) (*model.Restaurant, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return nil, false
 	}
-
-	var restaurant model.Restaurant
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Restaurant{}.CollectionName())
-	err = collection.FindOne(context.TODO(), bson.M{"_id": restaurantID}).Decode(&restaurant)
+	restaurant, err := server.store.Restaurants.Get(ctx, id)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
-			return
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(errors.New("restaurant not found")))
+			return nil, false
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve restaurant"})
-		return
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
 	}
-
-	c.JSON(http.StatusOK, restaurant)
+	return restaurant, true
 }
 
-// GetRestaurantsByHotel retrieves all restaurants for a specific hotel
-func GetRestaurantsByHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
-
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
-		return
+// statusInstant parses the at parameter, the instant open statuses are
+// given for. It is now when empty, and the error is model.FieldErrors.
+func statusInstant(at string) (time.Time, error) {
+	if at == "" {
+		return time.Now(), nil
 	}
-
-	var restaurants []model.Restaurant
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Restaurant{}.CollectionName())
-
-	cursor, err := collection.Find(context.TODO(), bson.M{"hotel_id": objectID})
+	instant, err := time.Parse(time.RFC3339, at)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve restaurants"})
-		return
+		return time.Time{}, model.FieldErrors{"at": atFormatMessage}
 	}
-	defer cursor.Close(context.TODO())
+	return instant, nil
+}
 
-	if err = cursor.All(context.TODO(), &restaurants); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode restaurants"})
-		return
+// setOpenStatus sets the open status of the restaurants with opening hours:
+// at the wall-clock time openAt in each restaurant's time zone when it is
+// given, or else at the instant at
+func setOpenStatus(restaurants []model.Restaurant, at time.Time, openAt *time.Time) {
+	for i := range restaurants {
+		hours := &restaurants[i].OpeningHours
+		if !hours.Known() {
+			continue
+		}
+		instant := at
+		if openAt != nil {
+			instant = hours.LocalTime(*openAt)
+		}
+		status := hours.Status(instant)
+		restaurants[i].OpenStatus = &status
 	}
+}
 
-	c.JSON(http.StatusOK, restaurants)
+// restaurantSorts are the orders a RestaurantSearchRequest can ask for
+var restaurantSorts = sortOptions{
+	"rating":  {repository.Desc("rating"), repository.Desc("review_count")},
+	"reviews": {repository.Desc("review_count")},
+	"name":    {repository.Asc("name")},
+	"newest":  {repository.Desc("created_at")},
 }
 
-// UpdateRestaurant updates an existing restaurant
-func UpdateRestaurant(c *gin.Context) {
-	id := c.Param("id")
+// Search restaurants
+type RestaurantSearchRequest struct {
+	Pagination
+	Location   string   `form:"location"`
+	Cuisine    []string `form:"cuisine"` // cuisine IDs
+	MinRating  float64  `form:"min_rating"`
+	Dietary    []string `form:"dietary"`     // dishes for every tag on the menu
+	PriceRange []string `form:"price_range"` // any of model.PriceLevels
+	OpenAt     string   `form:"open_at"`     // YYYY-MM-DDTHH:MM at the restaurant
+	At         string   `form:"at"`          // RFC 3339 instant of the open status
+	Currency   string   `form:"currency"`
+	Sort       []string `form:"sort"`
+
+	// Set by Validate
+	cuisines []interface{}
+	dietary  []string
+	prices   []interface{}
+	openAt   *time.Time
+	at       time.Time
+}
 
-	restaurantID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
+// Validate checks the parameters and parses the ones that are not plain
+// values. It returns model.FieldErrors.
+func (r *RestaurantSearchRequest) Validate() error {
+	fields := model.FieldErrors{}
+	for _, cuisine := range splitList(r.Cuisine) {
+		id, err := primitive.ObjectIDFromHex(cuisine)
+		if err != nil {
+			fields.Add("cuisine", "must be cuisine IDs")
+			break
+		}
+		r.cuisines = append(r.cuisines, id)
 	}
-
-	var req RestaurantRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if r.MinRating < 0 || r.MinRating > 5 {
+		fields.Add("min_rating", "must be between 0 and 5")
 	}
-
-	// Check if restaurant exists
-	var existingRestaurant model.Restaurant
-	err = util.Db.FindOne(context.TODO(), bson.D{{"_id", restaurantID}}).Decode(&existingRestaurant)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
-		return
+	var err error
+	if r.dietary, err = dietaryTags(r.Dietary); err != nil {
+		fields.Add("dietary", "must be among "+strings.Join(model.DietaryTags, ", "))
 	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"origin":     req.Origin,
-			"name":       req.Name,
-			"updated_at": time.Now(),
-		},
+	for _, level := range splitList(r.PriceRange) {
+		if !contains(model.PriceLevels, level) {
+			fields.Add("price_range", "must be among "+strings.Join(model.PriceLevels, ", "))
+			break
+		}
+		r.prices = append(r.prices, level)
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Restaurant{}.CollectionName())
-	result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": restaurantID}, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update restaurant"})
-		return
+	if r.OpenAt != "" {
+		if openAt, err := time.Parse(openAtLayout, r.OpenAt); err != nil {
+			fields.Add("open_at", "must be a local time in YYYY-MM-DDTHH:MM format")
+		} else {
+			r.openAt = &openAt
+		}
 	}
-
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
-		return
+	if r.at, err = statusInstant(r.At); err != nil {
+		fields.Add("at", atFormatMessage)
 	}
-
-	// Get the updated restaurant
-	var updatedRestaurant model.Restaurant
-	err = collection.FindOne(context.TODO(), bson.M{"_id": restaurantID}).Decode(&updatedRestaurant)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated restaurant"})
-		return
+	if r.Currency != "" && !isCurrencyCode(r.Currency) {
+		fields.Add("currency", "must be a 3-letter currency code such as USD")
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Restaurant updated successfully",
-		"data":    updatedRestaurant,
-	})
+	restaurantSorts.validate(fields, splitList(r.Sort))
+	return fields.Err()
 }
 
-// DeleteRestaurant deletes a restaurant
-func DeleteRestaurant(c *gin.Context) {
-	id := c.Param("id")
-
-	restaurantID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
+// query translates the validated request into a repository query
+func (r *RestaurantSearchRequest) query() repository.Query {
+	var conditions []repository.Condition
+	if r.Location != "" {
+		conditions = append(conditions, repository.Contains("location", r.Location))
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Restaurant{}.CollectionName())
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": restaurantID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete restaurant"})
-		return
+	if len(r.cuisines) > 0 {
+		conditions = append(conditions, repository.In("cuisines", r.cuisines...))
 	}
-
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
-		return
+	if r.MinRating > 0 {
+		conditions = append(conditions, repository.Gte("rating", r.MinRating))
+	}
+	for _, tag := range r.dietary {
+		conditions = append(conditions, repository.Eq("menu.dietary", tag))
+	}
+	if len(r.prices) > 0 {
+		conditions = append(conditions, repository.In("price_range", r.prices...))
+	}
+	if r.openAt != nil {
+		// The weekly hours hold unless the date has an exception
+		weekly, date, exception := model.OpenSlot(*r.openAt)
+		conditions = append(conditions, repository.Or(
+			[]repository.Condition{
+				repository.NotIn("opening_hours.exception_dates", date),
+				repository.Eq("opening_hours.weekly_slots", weekly),
+			},
+			[]repository.Condition{repository.Eq("opening_hours.exception_slots", exception)},
+		))
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Restaurant deleted successfully"})
+	return repository.Query{Conditions: conditions, Sort: restaurantSorts.order(splitList(r.Sort), "rating")}
 }
 
-// GetAverageRestaurantForHotel calculates the average restaurant for a hotel
-func GetAverageRestaurantForHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
-
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
+// SearchRestaurants searches restaurants. With open_at, only restaurants
+// open at that local time are returned, and their open status is given for
+// it; otherwise the status is for at, or now.
+func (server *Server) SearchRestaurants(c *gin.Context) {
+	var req RestaurantSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Restaurant{}.CollectionName())
-
-	// MongoDB aggregation pipeline to calculate average
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.M{"hotel_id": objectID}}},
-		{{"$group", bson.M{
-			"_id":          "$hotel_id",
-			"averageScore": bson.M{"$avg": "$score"},
-			"count":        bson.M{"$sum": 1},
-		}}},
-	}
-
-	cursor, err := collection.Aggregate(context.TODO(), pipeline)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate average restaurant"})
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-	defer cursor.Close(context.TODO())
 
-	var results []bson.M
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
 		return
 	}
-
-	if len(results) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"hotel_id":      hotelID,
-			"average_score": 0,
-			"count":         0,
-		})
+	query := req.query()
+	restaurants, total, err := server.store.Restaurants.List(ctx, query.Page(req.PageID, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	convertPrices(restaurants, rates, currency)
+	setOpenStatus(restaurants, req.at, req.openAt)
 
-	c.JSON(http.StatusOK, gin.H{
-		"hotel_id":      hotelID,
-		"average_score": results[0]["averageScore"],
-		"count":         results[0]["count"],
-	})
+	c.JSON(http.StatusOK, successResponse(restaurants, paginationResponse(req.PageID, req.PageSize, len(restaurants), total)))
 }
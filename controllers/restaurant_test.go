@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+)
+
+func TestRestaurantOpeningHours(t *testing.T) {
+	server := newTestServer(t)
+
+	monday := model.Night(time.Now().UTC().AddDate(0, 0, 14))
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	day := func(offset int) string {
+		return monday.AddDate(0, 0, offset).Format(dateLayout)
+	}
+
+	// Lunch and a late dinner from Monday to Saturday, closed on a holiday
+	// Tuesday
+	tapas := model.Restaurant{Name: "Tapas Bar", Location: "Madrid", Rating: 4.6, OpeningHours: model.OpeningHours{
+		TimeZone:   "Europe/Madrid",
+		Exceptions: []model.HoursException{{Date: day(1), Name: "Holiday"}},
+	}}
+	for _, weekday := range model.Weekdays[1:] {
+		tapas.OpeningHours.Weekly = append(tapas.OpeningHours.Weekly,
+			model.OpeningPeriod{Day: weekday, TimeRange: model.TimeRange{Opens: "13:00", Closes: "16:00"}},
+			model.OpeningPeriod{Day: weekday, TimeRange: model.TimeRange{Opens: "20:00", Closes: "00:30"}})
+	}
+	noodles := model.Restaurant{Name: "Noodle House", Location: "Bangkok", Rating: 4.4, OpeningHours: model.OpeningHours{TimeZone: "Asia/Bangkok"}}
+	for _, weekday := range model.Weekdays {
+		noodles.OpeningHours.Weekly = append(noodles.OpeningHours.Weekly, model.OpeningPeriod{Day: weekday, TimeRange: model.TimeRange{Opens: "10:00", Closes: "22:00"}})
+	}
+	restaurants := []*model.Restaurant{&tapas, &noodles, {Name: "Corner Cafe", Rating: 4.0}}
+	for _, restaurant := range restaurants {
+		recorder := performRequest(server, http.MethodPost, "/api/v1/restaurants", restaurant)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", restaurant.Name, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+		created := struct {
+			Data *model.Restaurant `json:"data"`
+		}{restaurant}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	invalid := model.Restaurant{Name: "Mars Diner", OpeningHours: model.OpeningHours{TimeZone: "Mars/Olympus"}}
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/restaurants", invalid); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for an unknown time zone, got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+
+	search := func(openAt string) []model.Restaurant {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/search?page_id=1&page_size=5&open_at="+openAt, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", openAt, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.Restaurant `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		return list.Data
+	}
+	for _, test := range []struct {
+		openAt string
+		names  []string
+	}{
+		{day(0) + "T14:00", []string{"Tapas Bar", "Noodle House"}},
+		{day(0) + "T23:45", []string{"Tapas Bar"}},
+		// Monday's dinner runs into the holiday, which has no hours of its own
+		{day(1) + "T00:15", []string{"Tapas Bar"}},
+		{day(1) + "T14:00", []string{"Noodle House"}},
+		{day(2) + "T00:15", nil},
+		{day(2) + "T14:00", []string{"Tapas Bar", "Noodle House"}},
+		{day(6) + "T14:00", []string{"Noodle House"}},
+	} {
+		var names []string
+		for _, restaurant := range search(test.openAt) {
+			names = append(names, restaurant.Name)
+		}
+		if !equalStrings(names, test.names) {
+			t.Errorf("open at %s: expected %v, got %v", test.openAt, test.names, names)
+		}
+	}
+	if list := search(day(0) + "T15:30"); len(list) != 2 || list[0].OpenStatus == nil || list[0].OpenStatus.Text != "Closes soon" || list[1].OpenStatus.Text != "Open now" {
+		t.Errorf("expected the status at the local time of each restaurant, got %+v", list)
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/search?page_id=1&page_size=5&open_at=tonight", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an invalid open_at, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func(restaurant *model.Restaurant, at time.Time) *model.OpenStatus {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/restaurants/"+restaurant.ID.Hex()+"?at="+url.QueryEscape(at.Format(time.RFC3339)), nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var got model.Restaurant
+		if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got.OpenStatus
+	}
+	at := func(offset, hour, minute int) time.Time {
+		date := monday.AddDate(0, 0, offset)
+		return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, madrid)
+	}
+	if got := status(&tapas, at(0, 14, 0)); got == nil || got.Category != model.OpenStatusOpen || !got.ClosesAt.Equal(at(0, 16, 0)) {
+		t.Errorf("expected open until 16:00, got %+v", got)
+	}
+	if got := status(&tapas, at(0, 23, 45)); got == nil || got.Category != model.OpenStatusClosing || !got.ClosesAt.Equal(at(1, 0, 30)) {
+		t.Errorf("expected closing soon at 00:30, got %+v", got)
+	}
+	if got := status(&tapas, at(0, 17, 0)); got == nil || got.Category != model.OpenStatusClosed || !got.OpensAt.Equal(at(0, 20, 0)) {
+		t.Errorf("expected closed until 20:00, got %+v", got)
+	}
+	if got := status(&tapas, at(1, 12, 0)); got == nil || got.Category != model.OpenStatusClosed || !got.OpensAt.Equal(at(2, 13, 0)) {
+		t.Errorf("expected closed for the holiday until Wednesday lunch, got %+v", got)
+	}
+	if got := status(restaurants[2], at(0, 14, 0)); got != nil {
+		t.Errorf("expected no status without opening hours, got %+v", got)
+	}
+}
@@ -2,205 +2,488 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/janto-pee/Horizon-Travels.git/model"
-	"github.com/janto-pee/Horizon-Travels.git/util"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type ReviewRequest struct {
-	UserID     primitive.ObjectID `json:"user_id"  binding:"required"`
-	EntityID   primitive.ObjectID `json:"entity_id" binding:"required"`
-	EntityType string             `json:"entity_type" binding:"required"` // "hotel", "restaurant", etc.
-	Title      string             `json:"title" binding:"required"`
-	Content    string             `json:"content" binding:"required"`
-	Rating     float64            `json:"rating" binding:"required"`
-	Helpful    int                `json:"helpful" binding:"required"`
+func (server *Server) reviewResource() *Resource[model.Review] {
+	return &Resource[model.Review]{
+		Name: "review",
+		Repo: server.store.Reviews,
+		Sorts: map[string][]repository.SortField{
+			"newest":       {repository.Desc("created_at")},
+			"most_helpful": {repository.Desc("helpful"), repository.Asc("not_helpful"), repository.Desc("created_at")},
+		},
+		// Reviews wait for a moderator before the public sees them, and
+		// start without votes or a response
+		BeforeCreate: func(c *gin.Context, doc *model.Review) error {
+			doc.Status, doc.Moderation, doc.Reports, doc.ReportCount = model.ReviewPending, nil, nil, 0
+			doc.Helpful, doc.NotHelpful, doc.Votes, doc.Response, doc.Version = 0, 0, nil, nil, 0
+			return nil
+		},
+		// Only moderators and reports change the status, except that an
+		// edited review is moderated again. Only votes change the counts,
+		// and only the owner the response. The edit is only stored if none
+		// of them changed the review since it was read.
+		BeforeUpdate: func(c *gin.Context, old, doc *model.Review) error {
+			doc.Status, doc.Moderation, doc.Reports, doc.ReportCount = old.Status, old.Moderation, old.Reports, old.ReportCount
+			doc.Helpful, doc.NotHelpful, doc.Votes, doc.Response = old.Helpful, old.NotHelpful, old.Votes, old.Response
+			doc.Version = old.Version
+			if !doc.SameText(old) {
+				doc.Status = model.ReviewPending
+			}
+			return nil
+		},
+		AfterCreate: func(c *gin.Context, doc *model.Review) {
+			server.rescore(c.Request.Context(), nil, reviewScore(doc))
+		},
+		AfterUpdate: func(c *gin.Context, old, doc *model.Review) {
+			server.rescore(c.Request.Context(), reviewScore(old), reviewScore(doc))
+		},
+		AfterDelete: func(c *gin.Context, doc *model.Review) {
+			server.rescore(c.Request.Context(), reviewScore(doc), nil)
+		},
+		// Who reported a review is for moderators only
+		Present: func(c *gin.Context, docs []model.Review) error {
+			for i := range docs {
+				docs[i].Status = docs[i].CurrentStatus()
+				docs[i].Reports = nil
+			}
+			return nil
+		},
+	}
 }
 
-// CreateReview handles the creation of a new review
-func CreateReview(c *gin.Context) {
-	var req ReviewRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+func (server *Server) registerReviewRoutes(reviews *gin.RouterGroup) {
+	reviews.GET("", server.reviews.ListBy(published(nil)))
+	reviews.POST("", server.reviews.Create)
+	reviews.GET("/:id", server.reviews.GetBy(published(nil)))
+	reviews.PUT("/:id", server.reviews.Update)
+	reviews.DELETE("/:id", server.reviews.Delete)
+	reviews.POST("/:id/report", requireUser, server.ReportReview)
+	reviews.PUT("/:id/vote", requireUser, server.VoteOnReview)
+	reviews.DELETE("/:id/vote", requireUser, server.RetractReviewVote)
+	reviews.POST("/:id/response", requireUser, server.RespondToReview)
+	reviews.PUT("/:id/response", requireUser, server.EditReviewResponse)
+}
 
-	now := time.Now()
-	review := model.Review{
-		ID:         primitive.NewObjectID(),
-		UserID:     req.UserID,
-		EntityID:   req.EntityID,
-		EntityType: req.EntityType,
-		Title:      req.Title,
-		Content:    req.Content,
-		Rating:     req.Rating,
-		Helpful:    req.Helpful,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+func (server *Server) registerModerationRoutes(moderation *gin.RouterGroup) {
+	moderation.GET("/reviews", server.ListReviewQueue)
+	moderation.GET("/reviews/:id", server.GetModeratedReview)
+	moderation.POST("/reviews/:id/approve", server.moderateReview(model.ReviewPublished,
+		model.ReviewPending, model.ReviewFlagged, model.ReviewRejected))
+	moderation.POST("/reviews/:id/reject", server.moderateReview(model.ReviewRejected,
+		model.ReviewPending, model.ReviewFlagged, model.ReviewPublished))
+}
+
+// published narrows scope to the reviews the public can see. A nil scope
+// selects every published review.
+func published(scope func(c *gin.Context) ([]repository.Condition, error)) func(c *gin.Context) ([]repository.Condition, error) {
+	return func(c *gin.Context) ([]repository.Condition, error) {
+		var conditions []repository.Condition
+		if scope != nil {
+			var err error
+			if conditions, err = scope(c); err != nil {
+				return nil, err
+			}
+		}
+		return append(conditions, repository.PublishedReview), nil
 	}
+}
 
-	// Save the review to the database
-	collection := util.MongoClient.Database(util.DbName).Collection(review.CollectionName())
-	result, err := collection.InsertOne(context.TODO(), review)
+// List the reviews waiting for moderation
+type ReviewQueueRequest struct {
+	listRequest
+	Status     []string `form:"status"` // pending and flagged by default
+	EntityType string   `form:"entity_type"`
+	EntityID   string   `form:"entity_id"`
+	Reported   bool     `form:"reported"` // only reviews travelers reported
+}
+
+// ListReviewQueue lists reviews for moderators, oldest first, with who
+// reported them
+func (server *Server) ListReviewQueue(c *gin.Context) {
+	var req ReviewQueueRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	conditions, err := req.conditions()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	if req.PageID == 0 {
+		req.PageID = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Review created successfully",
-		"data":    review,
-		"id":      result.InsertedID,
-	})
-}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
 
-// GetReviewByID retrieves a review by its ID
-func GetReviewByID(c *gin.Context) {
-	id := c.Param("id")
+	query := repository.Query{Conditions: conditions, Sort: []repository.SortField{repository.Asc("created_at")}}
+	reviews, total, err := server.store.Reviews.List(ctx, query.Page(req.PageID, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	for i := range reviews {
+		reviews[i].Status = reviews[i].CurrentStatus()
+	}
+	c.JSON(http.StatusOK, successResponse(reviews, paginationResponse(req.PageID, req.PageSize, len(reviews), total)))
+}
 
-	reviewID, err := primitive.ObjectIDFromHex(id)
+// GetModeratedReview returns a review for moderators, whatever its status,
+// with who reported it
+func (server *Server) GetModeratedReview(c *gin.Context) {
+	reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
 		return
 	}
 
-	var review model.Review
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Review{}.CollectionName())
-	err = collection.FindOne(context.TODO(), bson.M{"_id": reviewID}).Decode(&review)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	review, err := server.store.Reviews.Get(ctx, reviewID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve review"})
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-
+	review.Status = review.CurrentStatus()
 	c.JSON(http.StatusOK, review)
 }
 
-// GetReviewsByHotel retrieves all reviews for a specific hotel
-func GetReviewsByHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
+// conditions translates the filters into repository conditions. The error
+// is model.FieldErrors.
+func (r ReviewQueueRequest) conditions() ([]repository.Condition, error) {
+	fields := model.FieldErrors{}
+	statuses := splitList(r.Status)
+	if len(statuses) == 0 {
+		statuses = []string{model.ReviewPending, model.ReviewFlagged}
+	}
+	var alternatives [][]repository.Condition
+	for _, status := range statuses {
+		if !contains(model.ReviewStatuses, status) {
+			fields.Add("status", "must be among "+strings.Join(model.ReviewStatuses, ", "))
+			break
+		}
+		if status == model.ReviewPublished {
+			alternatives = append(alternatives, []repository.Condition{repository.PublishedReview})
+			continue
+		}
+		alternatives = append(alternatives, []repository.Condition{repository.Eq("status", status)})
+	}
+	conditions := []repository.Condition{repository.Or(alternatives...)}
 
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
-		return
+	if r.EntityType != "" {
+		conditions = append(conditions, repository.Eq("entity_type", r.EntityType))
 	}
+	if r.EntityID != "" {
+		entityID, err := primitive.ObjectIDFromHex(r.EntityID)
+		if err != nil {
+			fields.Add("entity_id", "is not a valid ID")
+		}
+		conditions = append(conditions, repository.Eq("entity_id", entityID))
+	}
+	if r.Reported {
+		conditions = append(conditions, repository.Gt("report_count", 0))
+	}
+	return conditions, fields.Err()
+}
 
-	var reviews []model.Review
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Review{}.CollectionName())
+// Approve or reject a review
+type ModerateReviewRequest struct {
+	Reason string `json:"reason"` // required to reject
+}
 
-	cursor, err := collection.Find(context.TODO(), bson.M{"hotel_id": objectID})
+// moderateReview returns a handler giving a review the status the calling
+// moderator decided on, if its status is one of from
+func (server *Server) moderateReview(status string, from ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ModerateReviewRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, errorResponse(err))
+				return
+			}
+		}
+		reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+			return
+		}
+		if req.Reason = strings.TrimSpace(req.Reason); status == model.ReviewRejected && req.Reason == "" {
+			c.JSON(http.StatusUnprocessableEntity, errorResponse(model.FieldErrors{"reason": "is required to reject a review"}))
+			return
+		}
+		moderator, _ := caller(c)
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		decision := model.ReviewModeration{ModeratorID: moderator.UserID, Status: status, Reason: req.Reason, ModeratedAt: time.Now()}
+		before, after, err := server.store.Reviews.Moderate(ctx, reviewID, decision, from...)
+		if !server.reviewChanged(ctx, c, reviewID, before, after, err) {
+			return
+		}
+		c.JSON(http.StatusOK, after)
+	}
+}
+
+// Report a review
+type ReportReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReportReview records the calling traveler's report of a published
+// review. Once model.ReviewReportsToFlag travelers reported it, the review
+// is flagged and hidden until a moderator looks at it.
+func (server *Server) ReportReview(c *gin.Context) {
+	var req ReportReviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
 		return
 	}
-	defer cursor.Close(context.TODO())
+	reporter, _ := caller(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
 
-	if err = cursor.All(context.TODO(), &reviews); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode reviews"})
+	report := model.ReviewReport{UserID: reporter.UserID, Reason: strings.TrimSpace(req.Reason), ReportedAt: time.Now()}
+	before, after, err := server.store.Reviews.Report(ctx, reviewID, report)
+	if errors.Is(err, model.ErrAlreadyReported) {
+		c.JSON(http.StatusConflict, errorResponse(err))
 		return
 	}
-
-	c.JSON(http.StatusOK, reviews)
+	if !server.reviewChanged(ctx, c, reviewID, before, after, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": after.CurrentStatus(), "report_count": after.ReportCount})
 }
 
-// UpdateReview updates an existing review
-func UpdateReview(c *gin.Context) {
-	id := c.Param("id")
+// Vote on whether a review was helpful
+type VoteReviewRequest struct {
+	Helpful *bool `json:"helpful" binding:"required"`
+}
 
-	reviewID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+// VoteOnReview records whether the calling traveler found a published
+// review helpful. Voting again replaces their vote.
+func (server *Server) VoteOnReview(c *gin.Context) {
+	var req VoteReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-
-	var req ReviewRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	reviewID, userID, ok := reviewVoter(c)
+	if !ok {
 		return
 	}
 
-	// Check if review exists
-	var existingReview model.Review
-	err = util.Db.FindOne(context.TODO(), bson.D{{"_id", reviewID}}).Decode(&existingReview)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	review, err := server.store.Reviews.Vote(ctx, reviewID, model.ReviewVote{UserID: userID, Helpful: *req.Helpful, VotedAt: time.Now()})
+	if errors.Is(err, model.ErrOwnReview) {
+		c.JSON(http.StatusForbidden, errorResponse(err))
 		return
 	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"title":      req.Title,
-			"EntityID":   req.EntityID,
-			"EntityType": req.EntityType,
-			"Title":      req.Title,
-			"updated_at": time.Now(),
-		},
+	if server.reviewFailed(ctx, c, reviewID, err) {
+		return
 	}
+	c.JSON(http.StatusOK, voteCounts(review))
+}
 
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Review{}.CollectionName())
-	result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": reviewID}, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+// RetractReviewVote removes the calling traveler's vote
+func (server *Server) RetractReviewVote(c *gin.Context) {
+	reviewID, userID, ok := reviewVoter(c)
+	if !ok {
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	review, err := server.store.Reviews.Unvote(ctx, reviewID, userID)
+	if errors.Is(err, model.ErrNoVote) {
+		c.JSON(http.StatusNotFound, errorResponse(err))
 		return
 	}
+	if server.reviewFailed(ctx, c, reviewID, err) {
+		return
+	}
+	c.JSON(http.StatusOK, voteCounts(review))
+}
 
-	// Get the updated review
-	var updatedReview model.Review
-	err = collection.FindOne(context.TODO(), bson.M{"_id": reviewID}).Decode(&updatedReview)
+// reviewVoter reads the review named in the path and the calling traveler,
+// or writes the error response and returns false
+func reviewVoter(c *gin.Context) (reviewID, userID primitive.ObjectID, ok bool) {
+	reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated review"})
-		return
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return reviewID, userID, false
 	}
+	voter, _ := caller(c)
+	return reviewID, voter.UserID, true
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Review updated successfully",
-		"data":    updatedReview,
+func voteCounts(review *model.Review) gin.H {
+	return gin.H{"helpful": review.Helpful, "not_helpful": review.NotHelpful}
+}
+
+// Respond to a review, or edit the response
+type ReviewResponseRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// RespondToReview publishes the reply of the owner of the reviewed hotel or
+// restaurant to a published review. A review has one response, which the
+// owner can edit.
+func (server *Server) RespondToReview(c *gin.Context) {
+	server.respondToReview(c, http.StatusCreated, func(ctx context.Context, reviewID, ownerID primitive.ObjectID, text string, at time.Time) (*model.Review, error) {
+		return server.store.Reviews.Respond(ctx, reviewID, model.ReviewResponse{OwnerID: ownerID, Text: text, CreatedAt: at, UpdatedAt: at})
 	})
 }
 
-// DeleteReview deletes a review
-func DeleteReview(c *gin.Context) {
-	id := c.Param("id")
+// EditReviewResponse replaces the text of the owner's response, keeping
+// the earlier text in its history
+func (server *Server) EditReviewResponse(c *gin.Context) {
+	server.respondToReview(c, http.StatusOK, server.store.Reviews.EditResponse)
+}
 
-	reviewID, err := primitive.ObjectIDFromHex(id)
+// respondToReview checks that the caller owns the reviewed listing before
+// write changes the response. A response is not a
+// review, so the listing's ratings stay as they are.
+func (server *Server) respondToReview(c *gin.Context, status int, write func(ctx context.Context, reviewID, ownerID primitive.ObjectID, text string, at time.Time) (*model.Review, error)) {
+	var req ReviewResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
 		return
 	}
+	if req.Text = strings.TrimSpace(req.Text); req.Text == "" {
+		fields := model.FieldErrors{}
+		fields.Add("text", "is required")
+		c.JSON(http.StatusUnprocessableEntity, errorResponse(fields.Err()))
+		return
+	}
+	identity, _ := caller(c)
+	ownerID := identity.UserID
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
 
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Review{}.CollectionName())
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": reviewID})
+	review, err := server.store.Reviews.Get(ctx, reviewID)
+	if server.reviewFailed(ctx, c, reviewID, err) {
+		return
+	}
+	owner, err := server.listingOwner(ctx, review)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete review"})
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if owner.IsZero() || owner != ownerID {
+		c.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("only the owner of the %s can respond to its reviews", review.EntityType)))
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	review, err = write(ctx, reviewID, ownerID, req.Text, time.Now())
+	switch {
+	case errors.Is(err, model.ErrResponded):
+		c.JSON(http.StatusConflict, errorResponse(err))
 		return
+	case errors.Is(err, model.ErrNoResponse):
+		c.JSON(http.StatusNotFound, errorResponse(err))
+		return
+	}
+	if server.reviewFailed(ctx, c, reviewID, err) {
+		return
+	}
+	c.JSON(status, review.Response)
+}
+
+// listingOwner returns the owner of the hotel or restaurant a review is
+// about. Other listings, and those without an owner, have none.
+func (server *Server) listingOwner(ctx context.Context, review *model.Review) (primitive.ObjectID, error) {
+	var owner primitive.ObjectID
+	var err error
+	switch review.EntityType {
+	case "hotel":
+		var hotel *model.Hotel
+		if hotel, err = server.store.Hotels.Get(ctx, review.EntityID); err == nil {
+			owner = hotel.OwnerID
+		}
+	case "restaurant":
+		var restaurant *model.Restaurant
+		if restaurant, err = server.store.Restaurants.Get(ctx, review.EntityID); err == nil {
+			owner = restaurant.OwnerID
+		}
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return owner, nil
 	}
+	return owner, err
+}
+
+// reviewChanged keeps hotel ratings in step with a review whose status
+// changed, or writes the error response for err and returns false
+func (server *Server) reviewChanged(ctx context.Context, c *gin.Context, reviewID primitive.ObjectID, before, after *model.Review, err error) bool {
+	if server.reviewFailed(ctx, c, reviewID, err) {
+		return false
+	}
+	server.rescore(ctx, reviewScore(before), reviewScore(after))
+	return true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+// reviewFailed writes the error response for a failed change to a review
+// and reports whether there was one. A conflict is reported with the
+// review's current status.
+func (server *Server) reviewFailed(ctx context.Context, c *gin.Context, reviewID primitive.ObjectID, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, errorResponse(errors.New("review not found")))
+	case errors.Is(err, repository.ErrConflict):
+		if review, err := server.store.Reviews.Get(ctx, reviewID); err == nil {
+			c.JSON(http.StatusConflict, errorResponse(fmt.Errorf("the review is %s", review.CurrentStatus())))
+			return true
+		}
+		c.JSON(http.StatusConflict, errorResponse(errors.New("the review was changed by another request")))
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+	default:
+		return false
+	}
+	return true
 }
 
 // GetAverageReviewForHotel calculates the average review for a hotel
-func GetAverageReviewForHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
+func (server *Server) GetAverageReviewForHotel(c *gin.Context) {
+	hotelID := c.Param("id")
 
 	objectID, err := primitive.ObjectIDFromHex(hotelID)
 	if err != nil {
@@ -208,43 +491,15 @@ func GetAverageReviewForHotel(c *gin.Context) {
 		return
 	}
 
-	collection := util.MongoClient.Database(util.DbName).Collection(model.Review{}.CollectionName())
-
-	// MongoDB aggregation pipeline to calculate average
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.M{"hotel_id": objectID}}},
-		{{"$group", bson.M{
-			"_id":          "$hotel_id",
-			"averageScore": bson.M{"$avg": "$score"},
-			"count":        bson.M{"$sum": 1},
-		}}},
-	}
-
-	cursor, err := collection.Aggregate(context.TODO(), pipeline)
+	average, err := server.store.Reviews.AverageRating(c.Request.Context(), "hotel", objectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate average review"})
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	var results []bson.M
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results"})
-		return
-	}
-
-	if len(results) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"hotel_id":      hotelID,
-			"average_score": 0,
-			"count":         0,
-		})
-		return
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"hotel_id":      hotelID,
-		"average_score": results[0]["averageScore"],
-		"count":         results[0]["count"],
+		"average_score": average.Value,
+		"count":         average.Count,
 	})
 }
@@ -0,0 +1,471 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createReview posts review and returns it as stored
+func createReview(t *testing.T, server *Server, review model.Review) model.Review {
+	t.Helper()
+	recorder := performRequest(server, http.MethodPost, "/api/v1/reviews", review)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create review: expected %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body)
+	}
+	var created struct {
+		Data model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	return created.Data
+}
+
+// publishReview approves a review as a moderator would
+func publishReview(t *testing.T, server *Server, id primitive.ObjectID) {
+	t.Helper()
+	recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/moderation/reviews/"+id.Hex()+"/approve", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("approve review: expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+}
+
+func TestReviewModeration(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	hotel := model.Hotel{Title: "Harbour Hotel"}
+	if err := server.store.Hotels.Create(ctx, &hotel); err != nil {
+		t.Fatal(err)
+	}
+	hotelReviews := "/api/v1/hotels/" + hotel.ID.Hex() + "/reviews"
+
+	titles := func(path string) []string {
+		t.Helper()
+		recorder := performRequestAs(server, testAdmin, http.MethodGet, path, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", path, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.Review `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, review := range list.Data {
+			titles = append(titles, review.Title)
+		}
+		return titles
+	}
+	moderate := func(id primitive.ObjectID, action string, req ModerateReviewRequest) (model.Review, int) {
+		t.Helper()
+		recorder := performRequestAs(server, testAdmin, http.MethodPost, "/api/v1/moderation/reviews/"+id.Hex()+"/"+action, req)
+		var review model.Review
+		if recorder.Code == http.StatusOK {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return review, recorder.Code
+	}
+	report := func(id, userID primitive.ObjectID) (string, int) {
+		t.Helper()
+		recorder := performRequestAs(server, &Identity{UserID: userID}, http.MethodPost, "/api/v1/reviews/"+id.Hex()+"/report", ReportReviewRequest{Reason: "Spam"})
+		var response struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return response.Status, recorder.Code
+	}
+	rating := func() (float64, int) {
+		t.Helper()
+		stored, err := server.store.Hotels.Get(ctx, hotel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.Rating, stored.ReviewCount
+	}
+
+	// New reviews wait for a moderator, whatever status they ask for
+	written := model.Review{UserID: primitive.NewObjectID(), EntityType: "hotel", EntityID: hotel.ID, Title: "Lovely", Content: "Great view", Rating: 5, Status: model.ReviewPublished}
+	review := createReview(t, server, written)
+	if review.Status != model.ReviewPending {
+		t.Errorf("expected a pending review, got %s", review.Status)
+	}
+	// Reviews from before moderation have no status and stay published
+	legacy := model.Review{UserID: primitive.NewObjectID(), EntityType: "hotel", EntityID: hotel.ID, Title: "Older", Content: "Fine", Rating: 3, CreatedAt: time.Now().AddDate(-1, 0, 0)}
+	if err := server.store.Reviews.Create(ctx, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(hotelReviews); !equalStrings(got, []string{"Older"}) {
+		t.Errorf("expected only the published review, got %v", got)
+	}
+	if got := titles("/api/v1/moderation/reviews"); !equalStrings(got, []string{"Lovely"}) {
+		t.Errorf("expected the pending review in the queue, got %v", got)
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/reviews/"+review.ID.Hex(), nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected %d for a pending review, got %d: %s", http.StatusNotFound, recorder.Code, recorder.Body)
+	}
+	if _, status := report(review.ID, primitive.NewObjectID()); status != http.StatusConflict {
+		t.Errorf("expected %d for reporting a pending review, got %d", http.StatusConflict, status)
+	}
+
+	// Only administrators moderate, and the decision is recorded as theirs
+	for _, identity := range []*Identity{nil, {UserID: review.UserID}} {
+		for _, path := range []string{"/api/v1/moderation/reviews", "/api/v1/moderation/reviews/" + review.ID.Hex(), "/api/v1/moderation/reviews/" + review.ID.Hex() + "/approve"} {
+			method, status := http.MethodGet, http.StatusForbidden
+			if strings.HasSuffix(path, "/approve") {
+				method = http.MethodPost
+			}
+			if identity == nil {
+				status = http.StatusUnauthorized
+			}
+			if recorder := performRequestAs(server, identity, method, path, nil); recorder.Code != status {
+				t.Errorf("%s %s: expected %d, got %d: %s", method, path, status, recorder.Code, recorder.Body)
+			}
+		}
+	}
+	if _, status := moderate(review.ID, "reject", ModerateReviewRequest{}); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for a rejection without a reason, got %d", http.StatusUnprocessableEntity, status)
+	}
+	approved, status := moderate(review.ID, "approve", ModerateReviewRequest{Reason: "Genuine"})
+	if status != http.StatusOK || approved.Status != model.ReviewPublished || approved.Moderation == nil || approved.Moderation.ModeratorID != testAdmin.UserID || approved.Moderation.Reason != "Genuine" {
+		t.Errorf("expected the approval to be recorded, got %d %+v", status, approved)
+	}
+	if _, status := moderate(review.ID, "approve", ModerateReviewRequest{}); status != http.StatusConflict {
+		t.Errorf("expected %d for approving a published review, got %d", http.StatusConflict, status)
+	}
+	if got := titles(hotelReviews); len(got) != 2 {
+		t.Errorf("expected both published reviews, got %v", got)
+	}
+	if average, count := rating(); average != 5 || count != 1 {
+		t.Errorf("expected the approved review in the hotel's rating, got %.2f from %d", average, count)
+	}
+
+	// Enough reports take a review down until a moderator looks at it
+	reporter := primitive.NewObjectID()
+	if got, status := report(review.ID, reporter); status != http.StatusOK || got != model.ReviewPublished {
+		t.Errorf("expected the first report to leave the review published, got %d %s", status, got)
+	}
+	recorder := performRequest(server, http.MethodGet, "/api/v1/reviews/"+review.ID.Hex(), nil)
+	var public model.Review
+	if err := json.Unmarshal(recorder.Body.Bytes(), &public); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || len(public.Reports) != 0 || public.ReportCount != 1 {
+		t.Errorf("expected the reporters to be hidden from the public, got %d %+v", recorder.Code, public)
+	}
+	recorder = performRequest(server, http.MethodPut, "/api/v1/reviews/"+review.ID.Hex(), gin.H{"helpful": 9})
+	var updated struct {
+		Data model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || len(updated.Data.Reports) != 0 || updated.Data.ReportCount != 1 {
+		t.Errorf("expected the reporters to be hidden from the review's author, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/reviews/"+review.ID.Hex()+"/report", ReportReviewRequest{Reason: "Spam"}); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d for an anonymous report, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+	if _, status := report(review.ID, reporter); status != http.StatusConflict {
+		t.Errorf("expected %d for a second report by the same traveler, got %d", http.StatusConflict, status)
+	}
+	report(review.ID, primitive.NewObjectID())
+	if got, _ := report(review.ID, primitive.NewObjectID()); got != model.ReviewFlagged {
+		t.Errorf("expected the review to be flagged, got %s", got)
+	}
+	if got := titles(hotelReviews); !equalStrings(got, []string{"Older"}) {
+		t.Errorf("expected the flagged review to be hidden, got %v", got)
+	}
+	if average, count := rating(); average != 0 || count != 0 {
+		t.Errorf("expected the flagged review out of the hotel's rating, got %.2f from %d", average, count)
+	}
+
+	recorder = performRequestAs(server, testAdmin, http.MethodGet, "/api/v1/moderation/reviews?reported=true&entity_id="+hotel.ID.Hex(), nil)
+	var queue struct {
+		Data []model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &queue); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Data) != 1 || queue.Data[0].Status != model.ReviewFlagged || len(queue.Data[0].Reports) != 3 || queue.Data[0].Reports[0].UserID != reporter {
+		t.Errorf("expected the flagged review and its reports in the queue, got %s", recorder.Body)
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/reviews/"+review.ID.Hex(), nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected %d for a flagged review, got %d: %s", http.StatusNotFound, recorder.Code, recorder.Body)
+	}
+	recorder = performRequestAs(server, testAdmin, http.MethodGet, "/api/v1/moderation/reviews/"+review.ID.Hex(), nil)
+	var flagged model.Review
+	if err := json.Unmarshal(recorder.Body.Bytes(), &flagged); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || flagged.Status != model.ReviewFlagged || len(flagged.Reports) != 3 {
+		t.Errorf("expected moderators to see the flagged review and its reports, got %d %s", recorder.Code, recorder.Body)
+	}
+	if recorder := performRequestAs(server, testAdmin, http.MethodGet, "/api/v1/moderation/reviews?status=hidden", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an unknown status, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	rejected, status := moderate(review.ID, "reject", ModerateReviewRequest{Reason: "Off topic"})
+	if status != http.StatusOK || rejected.Status != model.ReviewRejected || rejected.Moderation.Reason != "Off topic" {
+		t.Errorf("expected the rejection to be recorded, got %d %+v", status, rejected)
+	}
+	if got := titles("/api/v1/moderation/reviews?status=rejected,published"); !equalStrings(got, []string{"Older", "Lovely"}) {
+		t.Errorf("expected the rejected and published reviews, got %v", got)
+	}
+
+	// An edited review is moderated again, and the status cannot be set
+	if recorder := performRequest(server, http.MethodPut, "/api/v1/reviews/"+legacy.ID.Hex(), gin.H{"status": model.ReviewPublished}); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if got := titles("/api/v1/reviews"); !equalStrings(got, []string{"Older"}) {
+		t.Errorf("expected the untouched review to stay published, got %v", got)
+	}
+	if recorder := performRequest(server, http.MethodPut, "/api/v1/reviews/"+legacy.ID.Hex(), gin.H{"content": "Fine, but noisy"}); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if got := titles("/api/v1/reviews"); len(got) != 0 {
+		t.Errorf("expected the edited review to wait for a moderator, got %v", got)
+	}
+}
+
+func TestReviewVotes(t *testing.T) {
+	server := newTestServer(t)
+	hotelID, authorID := primitive.NewObjectID(), primitive.NewObjectID()
+	write := func(title string, publish bool) model.Review {
+		t.Helper()
+		review := createReview(t, server, model.Review{UserID: authorID, EntityType: "hotel", EntityID: hotelID, Title: title, Content: "Worth it", Rating: 4, Helpful: 50})
+		if review.Helpful != 0 {
+			t.Errorf("expected a new review without votes, got %d", review.Helpful)
+		}
+		if publish {
+			publishReview(t, server, review.ID)
+		}
+		return review
+	}
+	quiet, useful, pending := write("Quiet", true), write("Useful", true), write("Pending", false)
+
+	type counts struct {
+		Helpful    int `json:"helpful"`
+		NotHelpful int `json:"not_helpful"`
+	}
+	vote := func(method string, review model.Review, userID primitive.ObjectID, body interface{}) (counts, int) {
+		t.Helper()
+		var identity *Identity
+		if !userID.IsZero() {
+			identity = &Identity{UserID: userID}
+		}
+		recorder := performRequestAs(server, identity, method, "/api/v1/reviews/"+review.ID.Hex()+"/vote", body)
+		var got counts
+		if recorder.Code == http.StatusOK {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return got, recorder.Code
+	}
+	helpful := func(yes bool) gin.H { return gin.H{"helpful": yes} }
+
+	first, second, third := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	for i, test := range []struct {
+		review model.Review
+		userID primitive.ObjectID
+		body   interface{}
+		status int
+		counts counts
+	}{
+		{useful, primitive.NilObjectID, helpful(true), http.StatusUnauthorized, counts{}},
+		{useful, first, helpful(true), http.StatusOK, counts{1, 0}},
+		{useful, first, helpful(true), http.StatusOK, counts{1, 0}}, // voting again changes nothing
+		{useful, first, helpful(false), http.StatusOK, counts{0, 1}},
+		{useful, second, helpful(true), http.StatusOK, counts{1, 1}},
+		{useful, third, helpful(true), http.StatusOK, counts{2, 1}},
+		{quiet, second, helpful(true), http.StatusOK, counts{1, 0}},
+		{useful, authorID, helpful(true), http.StatusForbidden, counts{}},
+		{pending, first, helpful(true), http.StatusConflict, counts{}},
+		{useful, first, gin.H{}, http.StatusBadRequest, counts{}},
+	} {
+		if got, status := vote(http.MethodPut, test.review, test.userID, test.body); status != test.status || got != test.counts {
+			t.Errorf("vote %d: expected %d %+v, got %d %+v", i+1, test.status, test.counts, status, got)
+		}
+	}
+	if got, status := vote(http.MethodDelete, useful, first, nil); status != http.StatusOK || got != (counts{2, 0}) {
+		t.Errorf("expected the retracted vote to be uncounted, got %d %+v", status, got)
+	}
+	if _, status := vote(http.MethodDelete, useful, first, nil); status != http.StatusNotFound {
+		t.Errorf("expected %d for a vote already retracted, got %d", http.StatusNotFound, status)
+	}
+
+	// The counts only change by voting
+	if recorder := performRequest(server, http.MethodPut, "/api/v1/reviews/"+quiet.ID.Hex(), gin.H{"helpful": 100}); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	path := "/api/v1/hotels/" + hotelID.Hex() + "/reviews?sort=most_helpful"
+	recorder := performRequest(server, http.MethodGet, path, nil)
+	var list struct {
+		Data []model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 2 || list.Data[0].ID != useful.ID || list.Data[0].Helpful != 2 || list.Data[1].Helpful != 1 {
+		t.Errorf("expected the most helpful review first, got %s", recorder.Body)
+	}
+	if strings.Contains(recorder.Body.String(), "votes") {
+		t.Errorf("expected the voters to be hidden, got %s", recorder.Body)
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/reviews?sort=rating", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an unknown sort, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestReviewResponses(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	ownerID, restaurantOwnerID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	recorder := performRequest(server, http.MethodPost, "/api/v1/hotels", gin.H{
+		"title": "Harbour Hotel", "content": "Rooms by the sea", "provider": "direct", "price": 120,
+		"location": model.Location{City: "Lisbon", Country: "Portugal"}, "owner_id": ownerID,
+	})
+	var hotel model.Hotel
+	if err := json.Unmarshal(recorder.Body.Bytes(), &hotel); err != nil {
+		t.Fatal(err)
+	}
+	restaurant := model.Restaurant{Name: "Bistro"}
+	if err := server.store.Restaurants.Create(ctx, &restaurant); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only administrators name owners, and nobody else learns who they are
+	admin := &Identity{UserID: primitive.NewObjectID(), Admin: true}
+	for i, test := range []struct {
+		identity *Identity
+		path     string
+		owner    string
+		status   int
+	}{
+		{nil, "hotels/" + hotel.ID.Hex(), ownerID.Hex(), http.StatusUnauthorized},
+		{&Identity{UserID: ownerID}, "hotels/" + hotel.ID.Hex(), ownerID.Hex(), http.StatusForbidden},
+		{admin, "hotels/" + hotel.ID.Hex(), "someone", http.StatusUnprocessableEntity},
+		{admin, "hotels/" + primitive.NewObjectID().Hex(), ownerID.Hex(), http.StatusNotFound},
+		{admin, "hotels/" + hotel.ID.Hex(), ownerID.Hex(), http.StatusOK},
+		{admin, "restaurants/" + restaurant.ID.Hex(), restaurantOwnerID.Hex(), http.StatusOK},
+	} {
+		path := "/api/v1/admin/" + test.path + "/owner"
+		if recorder := performRequestAs(server, test.identity, http.MethodPut, path, ListingOwnerRequest{OwnerID: test.owner}); recorder.Code != test.status {
+			t.Errorf("owner %d: expected %d, got %d: %s", i+1, test.status, recorder.Code, recorder.Body)
+		}
+	}
+	performRequest(server, http.MethodPut, "/api/v1/hotels/"+hotel.ID.Hex(), gin.H{"owner_id": restaurantOwnerID})
+	performRequest(server, http.MethodPut, "/api/v1/restaurants/"+restaurant.ID.Hex(), gin.H{"owner_id": ownerID})
+	for _, path := range []string{"/api/v1/hotels/" + hotel.ID.Hex(), "/api/v1/restaurants/" + restaurant.ID.Hex()} {
+		if recorder := performRequest(server, http.MethodGet, path, nil); recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "owner_id") {
+			t.Errorf("expected %s to hide its owner, got %d: %s", path, recorder.Code, recorder.Body)
+		}
+	}
+	if stored, _ := server.store.Hotels.Get(ctx, hotel.ID); stored.OwnerID != ownerID {
+		t.Errorf("expected the hotel to keep the owner an administrator named, got %s", stored.OwnerID.Hex())
+	}
+	if stored, _ := server.store.Restaurants.Get(ctx, restaurant.ID); stored.OwnerID != restaurantOwnerID {
+		t.Errorf("expected the restaurant to keep the owner an administrator named, got %s", stored.OwnerID.Hex())
+	}
+
+	write := func(entityType string, entityID primitive.ObjectID, publish bool) model.Review {
+		t.Helper()
+		review := createReview(t, server, model.Review{UserID: primitive.NewObjectID(), EntityType: entityType, EntityID: entityID, Title: "Stayed", Content: "Noisy at night", Rating: 2})
+		if publish {
+			publishReview(t, server, review.ID)
+		}
+		return review
+	}
+	review, pending := write("hotel", hotel.ID, true), write("hotel", hotel.ID, false)
+	respond := func(method string, review model.Review, ownerID primitive.ObjectID, text string) (model.ReviewResponse, int) {
+		t.Helper()
+		var identity *Identity
+		if !ownerID.IsZero() {
+			identity = &Identity{UserID: ownerID}
+		}
+		recorder := performRequestAs(server, identity, method, "/api/v1/reviews/"+review.ID.Hex()+"/response", ReviewResponseRequest{Text: text})
+		var response model.ReviewResponse
+		if recorder.Code == http.StatusOK || recorder.Code == http.StatusCreated {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return response, recorder.Code
+	}
+
+	for i, test := range []struct {
+		method string
+		review model.Review
+		owner  primitive.ObjectID
+		text   string
+		status int
+	}{
+		{http.MethodPost, review, primitive.NilObjectID, "Sorry", http.StatusUnauthorized},
+		{http.MethodPost, review, restaurantOwnerID, "Sorry", http.StatusForbidden},
+		{http.MethodPost, review, ownerID, "  ", http.StatusUnprocessableEntity},
+		{http.MethodPost, pending, ownerID, "Sorry", http.StatusConflict},
+		{http.MethodPut, review, ownerID, "Sorry", http.StatusNotFound},
+		{http.MethodPost, write("restaurant", restaurant.ID, true), restaurantOwnerID, "Thanks", http.StatusCreated},
+		{http.MethodPost, write("vacation_rental", primitive.NewObjectID(), true), ownerID, "Thanks", http.StatusForbidden},
+	} {
+		if _, status := respond(test.method, test.review, test.owner, test.text); status != test.status {
+			t.Errorf("response %d: expected %d, got %d", i+1, test.status, status)
+		}
+	}
+
+	first, status := respond(http.MethodPost, review, ownerID, "Sorry, we have fixed the windows")
+	if status != http.StatusCreated || first.Text == "" || first.CreatedAt.IsZero() {
+		t.Errorf("expected the owner's response, got %d %+v", status, first)
+	}
+	if stored, _ := server.store.Reviews.Get(ctx, review.ID); stored.Response == nil || stored.Response.OwnerID != ownerID {
+		t.Errorf("expected the response to record its owner, got %+v", stored.Response)
+	}
+	if _, status := respond(http.MethodPost, review, ownerID, "Again"); status != http.StatusConflict {
+		t.Errorf("expected %d for a second response, got %d", http.StatusConflict, status)
+	}
+	edited, status := respond(http.MethodPut, review, ownerID, "Sorry, the windows are now double glazed")
+	if status != http.StatusOK || len(edited.History) != 1 || edited.History[0].Text != first.Text {
+		t.Errorf("expected the edit to keep the first text, got %d %+v", status, edited)
+	}
+
+	// A reviewer's edit keeps the response, and the response is not a rating
+	performRequest(server, http.MethodPut, "/api/v1/reviews/"+review.ID.Hex(), gin.H{"response": nil, "title": "Stayed twice"})
+	recorder = performRequestAs(server, testAdmin, http.MethodGet, "/api/v1/moderation/reviews", nil)
+	var queue struct {
+		Data []model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &queue); err != nil {
+		t.Fatal(err)
+	}
+	for _, queued := range queue.Data {
+		if queued.ID == review.ID && (queued.Response == nil || queued.Response.Text != edited.Text) {
+			t.Errorf("expected the response to survive the reviewer's edit, got %+v", queued.Response)
+		}
+	}
+	publishReview(t, server, review.ID)
+	recorder = performRequest(server, http.MethodGet, "/api/v1/hotels/"+hotel.ID.Hex()+"/reviews", nil)
+	var list struct {
+		Data []model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Response == nil || len(list.Data[0].Response.History) != 1 {
+		t.Errorf("expected the response in the review list, got %s", recorder.Body)
+	}
+	if stored, err := server.store.Hotels.Get(ctx, hotel.ID); err != nil || stored.Rating != 2 || stored.ReviewCount != 1 {
+		t.Errorf("expected the hotel rated by the review alone, got %+v %v", stored, err)
+	}
+}
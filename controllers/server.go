@@ -1,24 +1,112 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"github.com/janto-pee/Horizon-Travels.git/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// apiVersionPrefix is the mount point of the current API version
+const apiVersionPrefix = "/api/v1"
+
 type Server struct {
-	config util.Config
-	router *gin.Engine
+	config     util.Config
+	store      *repository.Store
+	router     *gin.Engine
+	httpServer *http.Server
+
+	reviews         *Resource[model.Review]
+	ratings         *Resource[model.Rating]
+	photos          *Resource[model.Photo]
+	thumbnails      *Resource[model.Thumbnail]
+	cuisines        *Resource[model.Cuisine]
+	restaurants     *Resource[model.Restaurant]
+	vacationRentals *Resource[model.VacationRental]
+	ratePlans       *Resource[model.RatePlan]
+	exchangeRates   *Resource[model.ExchangeRate]
+	carSuppliers    *Resource[model.CarSupplier]
+	carLocations    *Resource[model.CarLocation]
+	rentalCars      *Resource[model.RentalCar]
+	menuSections    *Resource[model.MenuSection]
+	menuItems       *Resource[model.MenuItem]
+	diningTables    *Resource[model.DiningTable]
+
+	rates rateCache
+
+	mu          sync.Mutex
+	workers     []Worker
+	workerGroup sync.WaitGroup
+	workerCtx   context.Context
+	stopWorkers context.CancelFunc
+	stopping    bool
+}
+
+// Worker is a background job that runs alongside the HTTP server until its
+// context is cancelled during shutdown
+type Worker interface {
+	Run(ctx context.Context)
 }
 
-func NewServer(config util.Config) (*Server, error) {
+// NewServer creates a server that reads and writes through store
+func NewServer(config util.Config, store *repository.Store) (*Server, error) {
 	server := &Server{
 		config: config,
+		store:  store,
 	}
+	server.workerCtx, server.stopWorkers = context.WithCancel(context.Background())
 	server.setUpRouter()
+
+	interval := config.HoldExpiryInterval
+	if interval <= 0 {
+		interval = defaultHoldExpiryInterval
+	}
+	server.AddWorker(&holdExpirer{server: server, interval: interval})
+
+	server.httpServer = &http.Server{
+		Handler:      server.router,
+		ReadTimeout:  config.HTTPReadTimeout,
+		WriteTimeout: config.HTTPWriteTimeout,
+		IdleTimeout:  config.HTTPIdleTimeout,
+	}
 	return server, nil
 }
 
+// AddWorker registers a background job. Workers are started by Start and
+// stopped by Shutdown, so they must be added before the server starts.
+func (server *Server) AddWorker(worker Worker) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.workers = append(server.workers, worker)
+}
+
 func (server *Server) setUpRouter() {
+	server.reviews = server.reviewResource()
+	server.ratings = server.ratingResource()
+	server.photos = server.photoResource()
+	server.thumbnails = server.thumbnailResource()
+	server.cuisines = server.cuisineResource()
+	server.restaurants = server.restaurantResource()
+	server.vacationRentals = server.vacationRentalResource()
+	server.ratePlans = server.ratePlanResource()
+	server.exchangeRates = server.exchangeRateResource()
+	server.carSuppliers = server.carSupplierResource()
+	server.carLocations = server.carLocationResource()
+	server.rentalCars = server.rentalCarResource()
+	server.menuSections = server.menuSectionResource()
+	server.menuItems = server.menuItemResource()
+	server.diningTables = server.diningTableResource()
+
 	router := gin.Default()
 
 	router.GET("/", func(c *gin.Context) {
@@ -26,28 +114,212 @@ func (server *Server) setUpRouter() {
 			"message": "Hello World",
 		})
 	})
-	router.GET("/api/hotels", ListHotels)
-	router.GET("/api/hotels/:id", GetHotelByID)
-	router.GET("/api/hotels/filter", FilterHotels)
-	router.GET("/api/hotels/search", SearchHotels)
-	router.GET("/api/hotels/location", SearchHotels)
+
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/readyz", server.Readiness)
+
+	v1 := router.Group(apiVersionPrefix, server.authenticate)
+	server.registerHotelRoutes(v1.Group("/hotels"))
+	server.registerReviewRoutes(v1.Group("/reviews"))
+	server.registerModerationRoutes(v1.Group("/moderation", requireAdmin))
+	server.ratings.Register(v1.Group("/ratings"))
+	server.photos.Register(v1.Group("/photos"))
+	server.thumbnails.Register(v1.Group("/thumbnails"))
+	server.cuisines.Register(v1.Group("/cuisines"))
+	server.registerRestaurantRoutes(v1.Group("/restaurants"))
+	server.registerRentalRoutes(v1.Group("/vacation-rentals"))
+	server.registerBookingRoutes(v1.Group("/bookings"))
+	server.ratePlans.Register(v1.Group("/rate-plans"))
+	server.exchangeRates.RegisterReads(v1.Group("/exchange-rates"))
+	server.registerCarRoutes(v1)
+	server.registerAdminRoutes(v1.Group("/admin", requireAdmin))
+
+	server.registerLegacyRoutes(router)
+
+	server.router = router
+}
+
+func (server *Server) registerHotelRoutes(hotels *gin.RouterGroup) {
+	hotels.GET("", server.ListHotels)
+	hotels.GET("/filter", server.FilterHotels)
+	hotels.GET("/search", server.SearchHotels)
+	hotels.GET("/location", server.SearchHotelsNearby)
+	hotels.GET("/query", server.QueryHotels)
+	hotels.GET("/:id", server.GetHotelByID)
 	/*
 	*	MUTATIONS
 	 */
-	router.POST("/api/hotels", CreateHotel)
-	router.PUT("/api/hotels/:id", UpdateHotel)
-	router.DELETE("/api/hotels/:id", DeleteHotel)
-	router.POST("/movies/aggregations", AggregateHotels)
+	hotels.POST("", server.CreateHotel)
+	hotels.PUT("/:id", server.UpdateHotel)
+	hotels.DELETE("/:id", server.DeleteHotel)
+	hotels.POST("/aggregations", server.AggregateHotels)
+	hotels.PUT("/:id/inventory", server.UpdateHotelInventory)
 
-	server.router = router
+	/*
+	*	NESTED RESOURCES
+	 */
+	hotels.GET("/:id/reviews", server.reviews.ListBy(published(entityOfHotel)))
+	hotels.GET("/:id/reviews/average", server.GetAverageReviewForHotel)
+	hotels.GET("/:id/ratings", server.ratings.ListBy(ofHotel))
+	hotels.GET("/:id/ratings/summary", server.GetRatingSummary("hotel"))
+	hotels.GET("/:id/photos", server.photos.ListBy(entityOfHotel))
+	hotels.GET("/:id/thumbnails", server.thumbnails.ListBy(entityOfHotel))
+	hotels.GET("/:id/cuisines", server.cuisines.ListBy(ofHotel))
+	hotels.GET("/:id/restaurants", server.restaurants.ListBy(ofHotel))
+	hotels.GET("/:id/inventory", server.GetHotelInventory)
+	hotels.GET("/:id/availability", server.GetHotelAvailability)
+	hotels.GET("/:id/rate-plans", server.ratePlans.ListBy(ofHotel))
+	hotels.GET("/:id/quote", server.QuoteHotel)
+}
+
+// registerAdminRoutes mounts the operations only administrators may run
+func (server *Server) registerAdminRoutes(admin *gin.RouterGroup) {
+	admin.POST("/hotels/ratings/recompute", server.RecomputeHotelRatings)
+	admin.PUT("/hotels/:id/owner", server.SetHotelOwner)
+	admin.PUT("/restaurants/:id/owner", server.SetRestaurantOwner)
+	// Exchange rates convert every price and price filter
+	server.exchangeRates.RegisterWrites(admin.Group("/exchange-rates"))
+}
+
+// ofHotel selects the documents whose hotel_id is the hotel in the path
+func ofHotel(c *gin.Context) ([]repository.Condition, error) {
+	hotelID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, errors.New("invalid hotel ID format")
+	}
+	return []repository.Condition{repository.Eq("hotel_id", hotelID)}, nil
+}
+
+// entityOfHotel selects the documents attached to the hotel in the path
+// through their entity_type and entity_id
+func entityOfHotel(c *gin.Context) ([]repository.Condition, error) {
+	hotelID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, errors.New("invalid hotel ID format")
+	}
+	return []repository.Condition{
+		repository.Eq("entity_type", "hotel"),
+		repository.Eq("entity_id", hotelID),
+	}, nil
+}
 
-	// r.Run()
+// registerLegacyRoutes keeps the pre-v1 paths reachable. Every response is
+// marked with a Deprecation header pointing clients at the /api/v1 equivalent.
+func (server *Server) registerLegacyRoutes(router *gin.Engine) {
+	legacy := router.Group("/api", deprecated("/api", apiVersionPrefix))
+	legacy.GET("/hotels", server.ListHotels)
+	legacy.GET("/hotels/:id", server.GetHotelByID)
+	legacy.GET("/hotels/filter", server.FilterHotels)
+	legacy.GET("/hotels/search", server.SearchHotels)
+	legacy.GET("/hotels/location", server.SearchHotelsNearby)
+	legacy.POST("/hotels", server.CreateHotel)
+	legacy.PUT("/hotels/:id", server.UpdateHotel)
+	legacy.DELETE("/hotels/:id", server.DeleteHotel)
+
+	router.POST("/movies/aggregations", deprecated("/movies", apiVersionPrefix+"/hotels"), server.AggregateHotels)
+}
+
+// deprecated marks a response as coming from a deprecated route and links to
+// the successor path, obtained by swapping oldPrefix for newPrefix.
+func deprecated(oldPrefix, newPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := newPrefix + strings.TrimPrefix(c.Request.URL.Path, oldPrefix)
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
 }
 
+// Readiness reports whether the database connection can serve requests
+func (server *Server) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := util.Ping(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": util.State().String(), "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": util.State().String()})
+}
+
+// Start listens on address and serves requests until Shutdown is called,
+// at which point it returns http.ErrServerClosed.
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// Serve starts the background workers and serves requests on listener
+func (server *Server) Serve(listener net.Listener) error {
+	server.startWorkers()
+	return server.httpServer.Serve(listener)
 }
 
+func (server *Server) startWorkers() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.stopping {
+		return
+	}
+	for _, worker := range server.workers {
+		server.workerGroup.Add(1)
+		go func(worker Worker) {
+			defer server.workerGroup.Done()
+			worker.Run(server.workerCtx)
+		}(worker)
+	}
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish, then stops the background workers. Workers are stopped after the
+// drain because the requests being drained may still depend on them.
+func (server *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("could not drain HTTP connections: %w", err))
+	}
+
+	server.mu.Lock()
+	server.stopping = true
+	server.mu.Unlock()
+	server.stopWorkers()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.workerGroup.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background workers did not stop: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
+
+// errorResponse is the body of every error response. Validation failures
+// also list the offending fields.
 func errorResponse(err error) gin.H {
+	var fields model.FieldErrors
+	if errors.As(err, &fields) {
+		return gin.H{"error": "invalid request", "fields": fields}
+	}
 	return gin.H{"error": err.Error()}
 }
+
+// validateRequestMethod reports an error unless the request method is one of
+// the allowed methods. The comparison is case-insensitive.
+func validateRequestMethod(r *http.Request, allowedMethods ...string) error {
+	for _, method := range allowedMethods {
+		if strings.EqualFold(r.Method, method) {
+			return nil
+		}
+	}
+	return fmt.Errorf("method not allowed: %s", r.Method)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type blockingWorker struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func (w *blockingWorker) Run(ctx context.Context) {
+	close(w.started)
+	<-ctx.Done()
+	close(w.stopped)
+}
+
+func TestShutdownDrainsRequestsBeforeStoppingWorkers(t *testing.T) {
+	server := newTestServer(t)
+	worker := &blockingWorker{started: make(chan struct{}), stopped: make(chan struct{})}
+	server.AddWorker(worker)
+
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	server.router.GET("/slow", func(c *gin.Context) {
+		close(inFlight)
+		<-release
+		select {
+		case <-worker.stopped:
+			c.String(http.StatusInternalServerError, "worker stopped before the request finished")
+		default:
+			c.String(http.StatusOK, "done")
+		}
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+	<-worker.started
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	<-inFlight
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(context.Background()) }()
+
+	// Shutdown must wait for the in-flight request
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	res := <-responses
+	if res.err != nil {
+		t.Fatalf("request failed: %v", res.err)
+	}
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", res.status, res.body)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case <-worker.stopped:
+	default:
+		t.Fatal("worker was not stopped by Shutdown")
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("expected http.ErrServerClosed from Serve, got %v", err)
+	}
+}
+
+func TestShutdownTimesOutOnStuckRequest(t *testing.T) {
+	server := newTestServer(t)
+
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server.router.GET("/stuck", func(c *gin.Context) {
+		close(inFlight)
+		<-release
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go server.Serve(listener)
+	go http.Get("http://" + listener.Addr().String() + "/stuck")
+	<-inFlight
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
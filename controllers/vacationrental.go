@@ -2,247 +2,334 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/janto-pee/Horizon-Travels.git/model"
-	"github.com/janto-pee/Horizon-Travels.git/util"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type VacationRentalRequest struct {
-	Name          string            `json:"name" binding:"required"`
-	Description   string            `json:"description" binding:"required"`
-	Origin        string            `json:"origin" binding:"required"`
-	Category      string            `json:"category" binding:"required"`
-	Address       string            `json:"address" `
-	Location      string            `json:"location" `
-	PriceRange    string            `json:"price_range" `
-	Rating        float64           `json:"rating" json:"rating"`
-	ContactNumber string            `json:"contact_number"  `
-	Email         string            `json:"email" `
-	Website       string            `json:"website" json:"website"`
-	OpeningHours  map[string]string `json:"opening_hours" json:"opening_hours"`
-}
-
-// CreateVacationRental handles the creation of a new vacationRental
-func CreateVacationRental(c *gin.Context) {
-	var req VacationRentalRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (server *Server) vacationRentalResource() *Resource[model.VacationRental] {
+	return &Resource[model.VacationRental]{
+		Name:    "vacation rental",
+		Repo:    server.store.VacationRentals,
+		Present: pricesInCurrency[model.VacationRental](server),
 	}
+}
 
-	now := time.Now()
-	vacationRental := model.VacationRental{
-		ID:          primitive.NewObjectID(),
-		Name:        req.Name,
-		Description: req.Description,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
+func (server *Server) registerRentalRoutes(rentals *gin.RouterGroup) {
+	rentals.GET("/search", server.SearchRentals)
+	server.vacationRentals.Register(rentals)
+	rentals.GET("/:id/availability", server.GetRentalAvailability)
+	rentals.GET("/:id/rates", server.GetRentalRates)
+	rentals.GET("/:id/reviews", server.reviews.ListBy(published(entityOfRental)))
+	rentals.GET("/:id/ratings", server.ratings.ListBy(entityOfRental))
+	rentals.GET("/:id/ratings/summary", server.GetRatingSummary("vacation_rental"))
+}
 
-	// Save the vacationRental to the database
-	collection := util.MongoClient.Database(util.DbName).Collection(vacationRental.CollectionName())
-	result, err := collection.InsertOne(context.TODO(), vacationRental)
+// entityOfRental selects the documents attached to the vacation rental in
+// the path through their entity_type and entity_id
+func entityOfRental(c *gin.Context) ([]repository.Condition, error) {
+	rentalID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create vacationRental"})
-		return
+		return nil, errors.New("invalid vacation rental ID format")
 	}
+	return []repository.Condition{
+		repository.Eq("entity_type", "vacation_rental"),
+		repository.Eq("entity_id", rentalID),
+	}, nil
+}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "VacationRental created successfully",
-		"data":    vacationRental,
-		"id":      result.InsertedID,
-	})
+// rentalLocationField stores a vacation rental's [longitude, latitude]
+const rentalLocationField = "coordinates"
+
+// rentalSorts are the orders a RentalSearchRequest can ask for
+var rentalSorts = sortOptions{
+	"recommended": {repository.Desc("rating"), repository.Desc("review_count")},
+	"rating":      {repository.Desc("rating")},
+	"price_low":   {repository.Asc("price")},
+	"price_high":  {repository.Desc("price")},
+	"newest":      {repository.Desc("created_at")},
+	"distance":    {repository.ByDistance(rentalLocationField)},
 }
 
-// GetVacationRentalByID retrieves a vacationRental by its ID
-func GetVacationRentalByID(c *gin.Context) {
-	id := c.Param("id")
+// RentalSearchRequest takes the Rental Search parameters of
+// request-template.sql. List parameters accept repeated or comma-separated
+// values.
+type RentalSearchRequest struct {
+	Pagination
+	Location string `form:"location"`
+	StayRequest
+	PricedSearch             // prices per night
+	Type            []string `form:"type"` // property types
+	Amenity         []string `form:"amenity"`
+	Bedrooms        int      `form:"bedrooms"`  // at least
+	Bathrooms       float64  `form:"bathrooms"` // at least
+	Rating          float64  `form:"rating"`
+	DistFrom        string   `form:"distFrom"`
+	DistFromMaxDist float64  `form:"distFromMaxDistance"`
+
+	center *repository.Point // set by Validate
+}
 
-	vacationRentalID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
+// Validate checks the parameters against each other and parses the ones
+// that are not plain values. It returns model.FieldErrors.
+func (r *RentalSearchRequest) Validate(now time.Time) error {
+	fields := model.FieldErrors{}
+
+	r.StayRequest.validate(fields, now)
+	r.PricedSearch.validate(fields, rentalSorts)
+
+	for _, propertyType := range lowerList(r.Type) {
+		if !contains(model.PropertyTypes, propertyType) {
+			fields.Add("type", "must be one of "+strings.Join(model.PropertyTypes, ", "))
+		}
+	}
+	if r.Bedrooms < 0 {
+		fields.Add("bedrooms", "cannot be negative")
+	}
+	if r.Bathrooms < 0 {
+		fields.Add("bathrooms", "cannot be negative")
+	}
+	if r.Rating < 0 || r.Rating > 5 {
+		fields.Add("rating", "must be between 0 and 5")
 	}
 
-	var vacationRental model.VacationRental
-	collection := util.MongoClient.Database(util.DbName).Collection(model.VacationRental{}.CollectionName())
-	err = collection.FindOne(context.TODO(), bson.M{"_id": vacationRentalID}).Decode(&vacationRental)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			c.JSON(http.StatusNotFound, gin.H{"error": "VacationRental not found"})
-			return
+	if r.DistFrom != "" {
+		if center, ok := parsePoint(r.DistFrom); ok {
+			r.center = &center
+		} else {
+			fields.Add("distFrom", "must be a latitude,longitude pair")
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve vacationRental"})
-		return
+	}
+	switch {
+	case r.DistFromMaxDist < 0 || r.DistFromMaxDist > 20000:
+		fields.Add("distFromMaxDistance", "must be between 0 and 20000 kilometers")
+	case r.DistFromMaxDist > 0 && r.DistFrom == "":
+		fields.Add("distFromMaxDistance", "requires distFrom")
 	}
 
-	c.JSON(http.StatusOK, vacationRental)
+	if contains(splitList(r.Sort), "distance") && r.DistFrom == "" {
+		fields.Add("sort", "distance requires distFrom")
+	}
+	return fields.Err()
 }
 
-// GetVacationRentalsByHotel retrieves all vacationRentals for a specific hotel
-func GetVacationRentalsByHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
-
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
-		return
+// query translates the validated request into a repository query. Prices
+// are in currency, which has a rate.
+func (r *RentalSearchRequest) query(rates model.ExchangeRates, currency string) repository.Query {
+	var conditions []repository.Condition
+	if r.Location != "" {
+		conditions = append(conditions, repository.Contains("location", r.Location))
+	}
+	conditions = append(conditions, in("property_type", lowerList(r.Type))...)
+	for _, amenity := range splitList(r.Amenity) {
+		conditions = append(conditions, repository.Eq("amenities", amenity))
+	}
+	if r.Bedrooms > 0 {
+		conditions = append(conditions, repository.Gte("bedrooms", r.Bedrooms))
+	}
+	if r.Bathrooms > 0 {
+		conditions = append(conditions, repository.Gte("bathrooms", r.Bathrooms))
+	}
+	if r.Rating > 0 {
+		conditions = append(conditions, repository.Gte("rating", r.Rating))
+	}
+	if guests := r.guests(); guests > 0 {
+		conditions = append(conditions, repository.Gte("max_guests", guests))
 	}
 
-	var vacationRentals []model.VacationRental
-	collection := util.MongoClient.Database(util.DbName).Collection(model.VacationRental{}.CollectionName())
-
-	cursor, err := collection.Find(context.TODO(), bson.M{"hotel_id": objectID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve vacationRentals"})
-		return
+	// Every night must be free, and the stay long enough
+	if r.dated() {
+		nights := model.Nights(r.checkIn, r.checkOut)
+		values := make([]interface{}, len(nights))
+		for i, night := range nights {
+			values[i] = night
+		}
+		conditions = append(conditions,
+			repository.NotIn("unavailable_nights", values...),
+			repository.Lte("minimum_stay", len(nights)))
 	}
-	defer cursor.Close(context.TODO())
+	conditions = append(conditions, priceConditions(rates, currency, r.PriceMin, r.PriceMax)...)
 
-	if err = cursor.All(context.TODO(), &vacationRentals); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode vacationRentals"})
-		return
+	if r.center != nil {
+		radius := r.DistFromMaxDist
+		if radius == 0 {
+			radius = defaultDistFromMaxDistance
+		}
+		conditions = append(conditions, repository.Near(rentalLocationField, *r.center, radius*metersPer["km"]))
 	}
 
-	c.JSON(http.StatusOK, vacationRentals)
+	fallback := "recommended"
+	if r.center != nil {
+		fallback = "distance"
+	}
+	return repository.Query{Conditions: conditions, Sort: r.order(rentalSorts, fallback)}
 }
 
-// UpdateVacationRental updates an existing vacationRental
-func UpdateVacationRental(c *gin.Context) {
-	id := c.Param("id")
-
-	vacationRentalID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+// SearchRentals searches vacation rentals. With checkIn and checkOut, only
+// rentals free on every night that accept a stay that long are returned.
+func (server *Server) SearchRentals(c *gin.Context) {
+	var req RentalSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-
-	var req VacationRentalRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := req.Validate(time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	// Check if vacationRental exists
-	var existingVacationRental model.VacationRental
-	err = util.Db.FindOne(context.TODO(), bson.D{{"_id", vacationRentalID}}).Decode(&existingVacationRental)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "VacationRental not found"})
-		return
-	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
 
-	update := bson.M{
-		"$set": bson.M{
-			"origin":     req.Origin,
-			"name":       req.Name,
-			"updated_at": time.Now(),
-		},
+	field, code := req.currency()
+	currency, rates, ok := server.hotelCurrency(ctx, c, field, code)
+	if !ok {
+		return
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.VacationRental{}.CollectionName())
-	result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": vacationRentalID}, update)
+	query := req.query(rates, currency)
+	rentals, total, err := server.store.VacationRentals.List(ctx, query.Page(req.PageID, req.PageSize))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vacationRental"})
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	convertPrices(rentals, rates, currency)
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "VacationRental not found"})
-		return
-	}
+	c.JSON(http.StatusOK, successResponse(rentals, paginationResponse(req.PageID, req.PageSize, len(rentals), total)))
+}
 
-	// Get the updated vacationRental
-	var updatedVacationRental model.VacationRental
-	err = collection.FindOne(context.TODO(), bson.M{"_id": vacationRentalID}).Decode(&updatedVacationRental)
+// rentalFromPath loads the vacation rental named by the id path parameter,
+// writing the error response itself when it cannot
+func (server *Server) rentalFromPath(ctx context.Context, c *gin.Context) (*model.VacationRental, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated vacationRental"})
-		return
+		c.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid ID format")))
+		return nil, false
 	}
+	rental, err := server.store.VacationRentals.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, errorResponse(errors.New("vacation rental not found")))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	return rental, true
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "VacationRental updated successfully",
-		"data":    updatedVacationRental,
-	})
+// RentalAvailability tells whether a stay can be booked, and which nights
+// of it are free
+type RentalAvailability struct {
+	Available   bool                `json:"available"`
+	Problems    model.FieldErrors   `json:"problems,omitempty"`
+	MinimumStay int                 `json:"minimum_stay"`
+	MaxGuests   int                 `json:"max_guests"`
+	Nights      []NightAvailability `json:"nights"`
 }
 
-// DeleteVacationRental deletes a vacationRental
-func DeleteVacationRental(c *gin.Context) {
-	id := c.Param("id")
+// NightAvailability is whether one night is free
+type NightAvailability struct {
+	Date      time.Time `json:"date"`
+	Available bool      `json:"available"`
+}
 
-	vacationRentalID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+// GetRentalAvailability checks a stay at a vacation rental against its
+// calendar, minimum stay and capacity. Adults default to one.
+func (server *Server) GetRentalAvailability(c *gin.Context) {
+	var req AvailabilityRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.VacationRental{}.CollectionName())
-	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": vacationRentalID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vacationRental"})
+	if err := req.validateDated(time.Now().UTC()); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "VacationRental not found"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rental, ok := server.rentalFromPath(ctx, c)
+	if !ok {
 		return
 	}
+	availability := RentalAvailability{MinimumStay: rental.MinimumStay, MaxGuests: rental.MaxGuests}
+	for _, night := range model.Nights(req.checkIn, req.checkOut) {
+		availability.Nights = append(availability.Nights, NightAvailability{Date: night, Available: rental.Available(night)})
+	}
+	if problems := rental.StayProblems(req.checkIn, req.checkOut, max(req.guests(), 1)); len(problems) > 0 {
+		availability.Problems = problems
+	} else {
+		availability.Available = true
+	}
+	c.JSON(http.StatusOK, successResponse(availability, nil))
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "VacationRental deleted successfully"})
+// Rental rates
+type RentalRatesRequest struct {
+	StayRequest
+	Currency string `form:"currency"`
 }
 
-// GetAverageVacationRentalForHotel calculates the average vacationRental for a hotel
-func GetAverageVacationRentalForHotel(c *gin.Context) {
-	hotelID := c.Param("hotelId")
+// RentalRatesResponse is the rate card of a vacation rental, with the price
+// of a stay when one is asked for
+type RentalRatesResponse struct {
+	Price       float64           `json:"price"` // per night
+	Currency    string            `json:"currency"`
+	MinimumStay int               `json:"minimum_stay"`
+	Rates       model.RentalRates `json:"rates"`
+	Quote       *model.Quote      `json:"quote,omitempty"`
+}
 
-	objectID, err := primitive.ObjectIDFromHex(hotelID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotel ID format"})
+// GetRentalRates returns the rates of a vacation rental and, given checkIn
+// and checkOut, what the stay costs. Adults default to one.
+func (server *Server) GetRentalRates(c *gin.Context) {
+	var req RentalRatesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-
-	collection := util.MongoClient.Database(util.DbName).Collection(model.VacationRental{}.CollectionName())
-
-	// MongoDB aggregation pipeline to calculate average
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.M{"hotel_id": objectID}}},
-		{{"$group", bson.M{
-			"_id":          "$hotel_id",
-			"averageScore": bson.M{"$avg": "$score"},
-			"count":        bson.M{"$sum": 1},
-		}}},
+	fields := model.FieldErrors{}
+	req.validate(fields, time.Now().UTC())
+	if err := fields.Err(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
 	}
 
-	cursor, err := collection.Aggregate(context.TODO(), pipeline)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate average vacationRental"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	currency, rates, ok := server.hotelCurrency(ctx, c, "currency", req.Currency)
+	if !ok {
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	var results []bson.M
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode results"})
+	rental, ok := server.rentalFromPath(ctx, c)
+	if !ok {
 		return
 	}
+	if currency != "" {
+		rental.ConvertPrices(rates, currency)
+	}
 
-	if len(results) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"hotel_id":      hotelID,
-			"average_score": 0,
-			"count":         0,
-		})
-		return
+	response := RentalRatesResponse{Price: rental.Price, Currency: rental.Currency, MinimumStay: rental.MinimumStay, Rates: rental.Rates}
+	if req.dated() {
+		quote := rental.Quote(req.checkIn, req.checkOut, max(req.Adults, 1), req.childrenAges)
+		response.Quote = &quote
 	}
+	c.JSON(http.StatusOK, successResponse(response, nil))
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"hotel_id":      hotelID,
-		"average_score": results[0]["averageScore"],
-		"count":         results[0]["count"],
-	})
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestVacationRentals(t *testing.T) {
+	server := newTestServer(t)
+
+	// A Monday, so the first five nights are weeknights
+	checkIn := model.Night(time.Now().UTC().AddDate(0, 0, 14))
+	for checkIn.Weekday() != time.Monday {
+		checkIn = checkIn.AddDate(0, 0, 1)
+	}
+	day := func(offset int) string {
+		return checkIn.AddDate(0, 0, offset).Format("2006-01-02")
+	}
+
+	rentals := []model.VacationRental{
+		{
+			Name: "Lakeside Cabin", PropertyType: "Cabin", Location: "Lake District", Bedrooms: 2, Bathrooms: 1,
+			MaxGuests: 4, MinimumStay: 3, Price: 100, Rating: 4.8, Amenities: []string{"wifi", "fireplace"},
+			Rates: model.RentalRates{
+				WeekendUplift:         20,
+				LengthOfStayDiscounts: []model.StayDiscount{{MinNights: 4, Percent: 10}},
+				CleaningFee:           60,
+				SecurityDeposit:       200,
+			},
+			UnavailableNights: []time.Time{checkIn.AddDate(0, 0, 7)},
+		},
+		{Name: "Harbour Loft", PropertyType: "loft", Location: "Lake District", Bedrooms: 1, Bathrooms: 1, MaxGuests: 2, Price: 80, Rating: 4.5},
+		{Name: "Hillside Villa", PropertyType: "villa", Location: "Tuscany", Bedrooms: 4, Bathrooms: 2.5, MaxGuests: 8, Price: 300, Rating: 4.9},
+	}
+	for i := range rentals {
+		recorder := performRequest(server, http.MethodPost, "/api/v1/vacation-rentals", rentals[i])
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected %d, got %d: %s", rentals[i].Name, http.StatusCreated, recorder.Code, recorder.Body)
+		}
+		created := struct {
+			Data *model.VacationRental `json:"data"`
+		}{&rentals[i]}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rentals[0].PropertyType != "cabin" || rentals[1].MinimumStay != 1 || rentals[1].Currency != model.BaseCurrency {
+		t.Errorf("expected defaults to be set, got %+v", rentals[:2])
+	}
+	invalid := model.VacationRental{Name: "Houseboat", PropertyType: "boat"}
+	if recorder := performRequest(server, http.MethodPost, "/api/v1/vacation-rentals", invalid); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for an unknown property type, got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+
+	search := func(params string) []string {
+		t.Helper()
+		recorder := performRequest(server, http.MethodGet, "/api/v1/vacation-rentals/search?page_id=1&page_size=5&"+params, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d: %s", params, http.StatusOK, recorder.Code, recorder.Body)
+		}
+		var list struct {
+			Data []model.VacationRental `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, rental := range list.Data {
+			names = append(names, rental.Name)
+		}
+		return names
+	}
+	for _, test := range []struct {
+		params string
+		names  []string
+	}{
+		{"location=lake", []string{"Lakeside Cabin", "Harbour Loft"}},
+		{"type=cabin,villa&sort=price_low", []string{"Lakeside Cabin", "Hillside Villa"}},
+		{"adults=3&bedrooms=2&bathrooms=2", []string{"Hillside Villa"}},
+		{"amenity=fireplace", []string{"Lakeside Cabin"}},
+		{"priceMin=90&priceMax=150", []string{"Lakeside Cabin"}},
+		{"checkIn=" + day(0) + "&checkOut=" + day(2), []string{"Hillside Villa", "Harbour Loft"}},
+		{"checkIn=" + day(5) + "&checkOut=" + day(8), []string{"Hillside Villa", "Harbour Loft"}},
+		{"checkIn=" + day(0) + "&checkOut=" + day(3) + "&location=lake", []string{"Lakeside Cabin", "Harbour Loft"}},
+	} {
+		if names := search(test.params); !equalStrings(names, test.names) {
+			t.Errorf("%s: expected %v, got %v", test.params, test.names, names)
+		}
+	}
+	if recorder := performRequest(server, http.MethodGet, "/api/v1/vacation-rentals/search?page_id=1&page_size=5&type=boat", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for an unknown property type, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	cabin := "/api/v1/vacation-rentals/" + rentals[0].ID.Hex()
+	var availability struct {
+		Data RentalAvailability `json:"data"`
+	}
+	recorder := performRequest(server, http.MethodGet, cabin+"/availability?checkIn="+day(6)+"&checkOut="+day(8)+"&adults=5", nil)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &availability); err != nil {
+		t.Fatal(err)
+	}
+	if got := availability.Data; got.Available || len(got.Problems) != 3 || len(got.Nights) != 2 || !got.Nights[0].Available || got.Nights[1].Available {
+		t.Errorf("expected a short, full and partly unavailable stay, got %+v", got)
+	}
+	recorder = performRequest(server, http.MethodGet, cabin+"/availability?checkIn="+day(0)+"&checkOut="+day(3)+"&adults=2", nil)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &availability); err != nil {
+		t.Fatal(err)
+	}
+	if !availability.Data.Available {
+		t.Errorf("expected three weeknights to be available, got %+v", availability.Data)
+	}
+
+	// Four weeknights and a Friday: 4x100 + 120, 10% off, and cleaning
+	var rates struct {
+		Data RentalRatesResponse `json:"data"`
+	}
+	recorder = performRequest(server, http.MethodGet, cabin+"/rates?checkIn="+day(0)+"&checkOut="+day(5)+"&adults=2", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("rates: expected %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &rates); err != nil {
+		t.Fatal(err)
+	}
+	quote := rates.Data.Quote
+	if quote == nil || quote.Subtotal != 520 || quote.Discount != 52 || quote.Fees != 60 || quote.Total != 528 {
+		t.Errorf("expected 520 less 52 plus 60 cleaning, got %+v", quote)
+	}
+	if rates.Data.Rates.SecurityDeposit != 200 || rates.Data.MinimumStay != 3 {
+		t.Errorf("expected the rate card, got %+v", rates.Data)
+	}
+
+	review := createReview(t, server, model.Review{UserID: primitive.NewObjectID(), EntityType: "vacation_rental", EntityID: rentals[0].ID, Title: "Cosy", Content: "Lovely fire", Rating: 5})
+	publishReview(t, server, review.ID)
+	recorder = performRequest(server, http.MethodGet, cabin+"/reviews?page_id=1&page_size=5", nil)
+	var reviews struct {
+		Data []model.Review `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &reviews); err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews.Data) != 1 || reviews.Data[0].Title != "Cosy" {
+		t.Errorf("expected the cabin's review, got %+v", reviews.Data)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
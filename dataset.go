@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// batchSize is how many documents are read per query when walking a
+// whole collection
+const batchSize = 500
+
+// dataset is a collection that import, export and check work on. Its
+// functions hide the model type so the commands can treat every
+// collection alike.
+type dataset struct {
+	name string
+	// each calls fn with every stored document, as JSON
+	each func(ctx context.Context, fn func(doc json.RawMessage, invalid error) error) error
+	// put creates the document described by fields, or replaces the stored
+	// one with the same id. It reports whether the document was new.
+	put func(ctx context.Context, fields map[string]interface{}) (created bool, err error)
+	// columns are the CSV columns of the model
+	columns []column
+}
+
+func datasets(store *repository.Store) []dataset {
+	return []dataset{
+		newDataset("hotels", store.Hotels),
+		newDataset("reviews", store.Reviews),
+		newDataset("ratings", store.Ratings),
+		newDataset("photos", store.Photos),
+		newDataset("thumbnails", store.Thumbnails),
+		newDataset("cuisines", store.Cuisines),
+		newDataset("restaurants", store.Restaurants),
+		newDataset("vacation-rentals", store.VacationRentals),
+		newDataset("exchange-rates", store.ExchangeRates),
+		newDataset("car-suppliers", store.CarSuppliers),
+		newDataset("car-locations", store.CarLocations),
+		newDataset("rental-cars", store.RentalCars),
+		newDataset("menu-sections", store.MenuSections),
+		newDataset("menu-items", store.MenuItems),
+		newDataset("dining-tables", store.DiningTables),
+		newDataset("table-reservations", store.Reservations),
+	}
+}
+
+func findDataset(store *repository.Store, name string) (dataset, error) {
+	var names []string
+	for _, ds := range datasets(store) {
+		if ds.name == name {
+			return ds, nil
+		}
+		names = append(names, ds.name)
+	}
+	return dataset{}, fmt.Errorf("unknown collection %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+func newDataset[T any](name string, repo repository.Repository[T]) dataset {
+	return dataset{
+		name: name,
+		each: func(ctx context.Context, fn func(json.RawMessage, error) error) error {
+			return eachDocument(ctx, repo, func(doc *T) error {
+				raw, err := json.Marshal(doc)
+				if err != nil {
+					return err
+				}
+				return fn(raw, validate(doc))
+			})
+		},
+		put: func(ctx context.Context, fields map[string]interface{}) (bool, error) {
+			return putDocument(ctx, repo, fields)
+		},
+		columns: columnsOf(new(T)),
+	}
+}
+
+// eachDocument calls fn with every document of repo, in ID order
+func eachDocument[T any](ctx context.Context, repo repository.Repository[T], fn func(doc *T) error) error {
+	query := repository.Query{Sort: []repository.SortField{repository.Asc("_id")}}
+	for page := int64(1); ; page++ {
+		docs, _, err := repo.List(ctx, query.Page(page, batchSize))
+		if err != nil {
+			return err
+		}
+		for i := range docs {
+			if err := fn(&docs[i]); err != nil {
+				return err
+			}
+		}
+		if len(docs) < batchSize {
+			return nil
+		}
+	}
+}
+
+// putDocument validates the document described by fields and stores it.
+// A document whose id is already stored is replaced.
+func putDocument[T any](ctx context.Context, repo repository.Repository[T], fields map[string]interface{}) (bool, error) {
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return false, err
+	}
+	doc := new(T)
+	if err := json.Unmarshal(raw, doc); err != nil {
+		return false, err
+	}
+	if d, ok := any(doc).(interface{ SetDefaults() }); ok {
+		d.SetDefaults()
+	}
+	if err := validate(doc); err != nil {
+		return false, err
+	}
+
+	if hex, _ := fields["id"].(string); hex != "" {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return false, fmt.Errorf("invalid id %q", hex)
+		}
+		if _, err := repo.Get(ctx, id); err == nil {
+			return false, repo.Update(ctx, doc)
+		}
+	}
+	return true, repo.Create(ctx, doc)
+}
+
+// validate runs the model's Validate method, if it has one
+func validate(doc interface{}) error {
+	if v, ok := doc.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// column is a CSV column: the dotted JSON path of a field, and whether the
+// field is a string. Other fields are written as JSON.
+type column struct {
+	path     string
+	isString bool
+}
+
+// columnsOf lists the CSV columns of a model. Nested structs are spread
+// over one column per field; slices and maps stay in a single JSON column.
+func columnsOf(doc interface{}) []column {
+	raw, _ := json.Marshal(doc)
+	var fields map[string]interface{}
+	json.Unmarshal(raw, &fields)
+
+	var columns []column
+	var walk func(prefix string, fields map[string]interface{})
+	walk = func(prefix string, fields map[string]interface{}) {
+		for key, value := range fields {
+			if nested, ok := value.(map[string]interface{}); ok {
+				walk(prefix+key+".", nested)
+				continue
+			}
+			_, isString := value.(string)
+			columns = append(columns, column{path: prefix + key, isString: isString})
+		}
+	}
+	walk("", fields)
+
+	// id first, then alphabetical
+	sort.Slice(columns, func(i, j int) bool {
+		if (columns[i].path == "id") != (columns[j].path == "id") {
+			return columns[i].path == "id"
+		}
+		return columns[i].path < columns[j].path
+	})
+	return columns
+}
+
+// lookupPath returns the value at a dotted path of decoded JSON
+func lookupPath(fields map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = fields
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setPath sets the value at a dotted path, creating objects on the way
+func setPath(fields map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := fields[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			fields[key] = next
+		}
+		fields = next
+	}
+	fields[keys[len(keys)-1]] = value
+}
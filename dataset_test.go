@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+)
+
+func seededStore(t *testing.T) *repository.Store {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	s := seeder{store: store, rnd: rand.New(rand.NewSource(1))}
+	if err := s.run(context.Background(), 10); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return store
+}
+
+func TestSeededDataIsValid(t *testing.T) {
+	store := seededStore(t)
+	for _, ds := range datasets(store) {
+		err := ds.each(context.Background(), func(doc json.RawMessage, invalid error) error {
+			if invalid != nil {
+				t.Errorf("%s: invalid seeded document %s: %v", ds.name, doc, invalid)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hotels, total, err := store.Hotels.List(context.Background(), repository.Query{})
+	if err != nil || total != 10 {
+		t.Fatalf("expected 10 hotels, got %d (%v)", total, err)
+	}
+	for _, hotel := range hotels {
+		if hotel.ReviewCount == 0 {
+			continue
+		}
+		average, err := store.Reviews.AverageRating(context.Background(), "hotel", hotel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if average.Count != int64(hotel.ReviewCount) {
+			t.Errorf("%s counts %d reviews but has %d", hotel.Title, hotel.ReviewCount, average.Count)
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []string{formatNDJSON, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			source, err := findDataset(seededStore(t), "hotels")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var exported bytes.Buffer
+			if format == formatCSV {
+				_, err = exportCSV(ctx, source, &exported)
+			} else {
+				_, err = exportNDJSON(ctx, source, &exported)
+			}
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+
+			target := repository.NewMemoryStore()
+			ds, _ := findDataset(target, "hotels")
+			put := func(line int, fields map[string]interface{}) error {
+				if _, err := ds.put(ctx, fields); err != nil {
+					t.Errorf("record %d: %v", line, err)
+				}
+				return nil
+			}
+			if format == formatCSV {
+				err = readCSV(bytes.NewReader(exported.Bytes()), ds.columns, put)
+			} else {
+				err = readNDJSON(bytes.NewReader(exported.Bytes()), put)
+			}
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+
+			var reexported bytes.Buffer
+			if _, err := exportNDJSON(ctx, ds, &reexported); err != nil {
+				t.Fatal(err)
+			}
+			var original bytes.Buffer
+			exportNDJSON(ctx, source, &original)
+			if !sameHotels(t, original.Bytes(), reexported.Bytes()) {
+				t.Errorf("hotels changed in the round trip:\n%s\nvs\n%s", original.String(), reexported.String())
+			}
+		})
+	}
+}
+
+// sameHotels compares two NDJSON hotel exports, ignoring updated_at, which
+// an import refreshes
+func sameHotels(t *testing.T, a, b []byte) bool {
+	decode := func(data []byte) []model.Hotel {
+		var hotels []model.Hotel
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for decoder.More() {
+			var hotel model.Hotel
+			if err := decoder.Decode(&hotel); err != nil {
+				t.Fatal(err)
+			}
+			hotels = append(hotels, hotel)
+		}
+		return hotels
+	}
+	x, y := decode(a), decode(b)
+	if len(x) != len(y) || len(x) == 0 {
+		return false
+	}
+	for i := range x {
+		x[i].UpdatedAt = y[i].UpdatedAt
+		left, _ := json.Marshal(x[i])
+		right, _ := json.Marshal(y[i])
+		if !bytes.Equal(left, right) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestImportReplacesDocumentsWithKnownIDs(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	ds, _ := findDataset(store, "cuisines")
+
+	fields := map[string]interface{}{"name": "Jollof", "origin": "West Africa"}
+	if created, err := ds.put(ctx, fields); err != nil || !created {
+		t.Fatalf("expected a new cuisine, got created=%v err=%v", created, err)
+	}
+	cuisines, _, _ := store.Cuisines.List(ctx, repository.Query{})
+
+	fields = map[string]interface{}{"id": cuisines[0].ID.Hex(), "name": "Jollof rice"}
+	if created, err := ds.put(ctx, fields); err != nil || created {
+		t.Fatalf("expected the cuisine to be replaced, got created=%v err=%v", created, err)
+	}
+	if _, err := ds.put(ctx, map[string]interface{}{"origin": "Nowhere"}); err == nil {
+		t.Error("expected a cuisine without a name to be rejected")
+	}
+
+	cuisines, total, _ := store.Cuisines.List(ctx, repository.Query{})
+	if total != 1 || cuisines[0].Name != "Jollof rice" {
+		t.Errorf("unexpected cuisines after import: %+v", cuisines)
+	}
+}
@@ -4,18 +4,19 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/janto-pee/fintech-platform/model"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/util"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // UploadHandler handles image uploads
 type UploadHandler struct {
-	s3Uploader *utils.S3Uploader
+	s3Uploader *util.S3Uploader
 }
 
 // NewUploadHandler creates a new upload handler
 func NewUploadHandler(bucketName, region string) (*UploadHandler, error) {
-	uploader, err := utils.NewS3Uploader(bucketName, region)
+	uploader, err := util.NewS3Uploader(bucketName, region)
 	if err != nil {
 		return nil, err
 	}
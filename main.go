@@ -2,18 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/janto-pee/Horizon-Travels.git/controllers"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
 	"github.com/janto-pee/Horizon-Travels.git/util"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// command is a subcommand of the binary
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, config util.Config, args []string) error
+}
+
+// commands lists every subcommand. Running the binary without one serves
+// the API, so `go run .` keeps working.
+var commands = []command{
+	{"serve", "serve                          start the HTTP API", runServe},
+	{"migrate", "migrate up | down [steps] | status", runMigrate},
+	{"seed", "seed [-hotels n] [-seed n]    generate sample data", runSeed},
+	{"import", "import [-format ndjson|csv|tripadvisor] <collection> [file]", runImport},
+	{"export", "export [-format ndjson|csv] [-o file] <collection>", runExport},
+	{"check", "check [collection...]          validate every stored document", runCheck},
+	{"token", "token [-admin] [-ttl 24h] <user-id>  print an API access token", runToken},
+}
+
 func main() {
 	// Load configuration
 	config, err := util.LoadConfig(".")
@@ -21,77 +41,92 @@ func main() {
 		log.Fatal("Could not load configuration environment:", err)
 	}
 
-	// Validate required configuration
-	if err := validateConfig(config); err != nil {
-		log.Fatal("Invalid configuration:", err)
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		usage()
+		os.Exit(2)
 	}
 
-	// Setup graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Start server in goroutine
-	go func() {
-		if err := runGinServer(config); err != nil {
-			log.Printf("Server error: %v", err)
-			cancel()
-		}
-	}()
-
-	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// Every command stops cleanly on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	select {
-	case <-quit:
-		log.Println("Shutting down server...")
-	case <-ctx.Done():
-		log.Println("Server context cancelled")
+	if err := cmd.run(ctx, config, args); err != nil {
+		stop()
+		log.Fatalf("%s: %v", cmd.name, err)
 	}
+}
 
-	// Graceful shutdown with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
 
-	if err := gracefulShutdown(shutdownCtx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
-	} else {
-		log.Println("Server exited gracefully")
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: travels <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	for _, cmd := range commands {
+		fmt.Fprintln(os.Stderr, "  "+cmd.usage)
 	}
 }
 
-func validateConfig(config util.Config) error {
-	if config.HTTPServerAddress == "" {
-		return fmt.Errorf("HTTP server address is required")
+// connect opens the MongoDB database every command shares. Callers must
+// call util.Close when they are done.
+func connect(ctx context.Context, config util.Config) (*mongo.Database, error) {
+	if config.MongoURI == "" {
+		return nil, errors.New("MongoDB URI is required")
 	}
-	if config.DatabaseURL == "" {
-		return fmt.Errorf("database URL is required")
+	db, err := util.Connect(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to MongoDB: %w", err)
 	}
-	return nil
+	return db, nil
 }
 
-func runGinServer(config util.Config) error {
-	server, err := controllers.NewServer(config)
+// connectPostgres opens the PostgreSQL database selected by DB_DRIVER.
+// Callers must call util.Close when they are done.
+func connectPostgres(ctx context.Context, config util.Config) (*pgxpool.Pool, error) {
+	if config.DatabaseURL == "" {
+		return nil, errors.New("DB_SOURCE is required with the postgres driver")
+	}
+	pool, err := util.ConnectPostgres(ctx, config)
 	if err != nil {
-		return fmt.Errorf("could not create server: %w", err)
+		return nil, fmt.Errorf("could not connect to PostgreSQL: %w", err)
 	}
+	return pool, nil
+}
 
-	log.Printf("Starting server on %s", config.HTTPServerAddress)
-
-	if err := server.Start(config.HTTPServerAddress); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("could not start server: %w", err)
+// openStore connects to the database DB_DRIVER selects and returns the
+// repositories of every model
+func openStore(ctx context.Context, config util.Config) (*repository.Store, error) {
+	switch config.DBDriver {
+	case util.DriverMongo, "":
+		db, err := connect(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewMongoStore(db), nil
+	case util.DriverPostgres:
+		pool, err := connectPostgres(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewPostgresStore(pool), nil
 	}
-
-	return nil
+	return nil, fmt.Errorf("unknown DB_DRIVER %q, expected %s or %s", config.DBDriver, util.DriverMongo, util.DriverPostgres)
 }
 
-func gracefulShutdown(ctx context.Context) error {
-	// Close database connections
-	if err := util.CloseDB(ctx); err != nil {
-		log.Printf("Error closing database: %v", err)
+// closeStore closes the connection opened by openStore or connect
+func closeStore() {
+	if err := util.Close(context.Background()); err != nil {
+		log.Println(err)
 	}
-
-	// Add any other cleanup operations here
-	log.Println("Cleanup completed")
-	return nil
 }
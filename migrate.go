@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/janto-pee/Horizon-Travels.git/migrate"
+	"github.com/janto-pee/Horizon-Travels.git/util"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrate implements the migrate subcommand for the database DB_DRIVER
+// selects
+func runMigrate(ctx context.Context, config util.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if config.DBDriver == util.DriverPostgres {
+		pool, err := connectPostgres(ctx, config)
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+		migrations, err := migrate.Postgres()
+		if err != nil {
+			return err
+		}
+		migrator, err := migrate.NewPostgres(pool, migrations)
+		if err != nil {
+			return err
+		}
+		return runMigrator(ctx, migrator, args)
+	}
+
+	db, err := connect(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	migrator, err := migrate.New(db, migrate.All)
+	if err != nil {
+		return err
+	}
+	return runMigrator(ctx, migrator, args)
+}
+
+// runMigrator runs the up, down or status subcommand with migrator
+func runMigrator[DB any](ctx context.Context, migrator *migrate.Migrator[DB], args []string) error {
+	var err error
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d %s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return errors.New(migrateUsage)
+}
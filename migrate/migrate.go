@@ -0,0 +1,260 @@
+// Package migrate applies versioned changes to the database schema, such
+// as creating tables and indexes, backfilling fields and renaming
+// collections. Applied versions are recorded in schema_migrations: a
+// collection on MongoDB, a table on PostgreSQL.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collection records which migrations have been applied
+const collection = "schema_migrations"
+
+// Migration is one reversible schema change. DB is what its steps run
+// against: a *mongo.Database, or the pgx.Tx a PostgreSQL migration runs in.
+type Migration[DB any] struct {
+	// Version orders migrations; it must be unique and never reused
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db DB) error
+	Down    func(ctx context.Context, db DB) error
+}
+
+// Status describes whether a migration has been applied
+type Status[DB any] struct {
+	Migration[DB]
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// record is the schema_migrations entry of an applied migration
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// journal runs migration steps against a database and keeps track of the
+// versions it has applied
+type journal[DB any] interface {
+	applied(ctx context.Context) (map[int]record, error)
+	// apply runs m's up step and records m
+	apply(ctx context.Context, m Migration[DB]) error
+	// revert runs m's down step and forgets m
+	revert(ctx context.Context, m Migration[DB]) error
+}
+
+// Migrator applies migrations to a database
+type Migrator[DB any] struct {
+	journal    journal[DB]
+	migrations []Migration[DB]
+}
+
+// New returns a Migrator for a MongoDB database. The migrations are sorted
+// by version; it is an error for two of them to share a version.
+func New(db *mongo.Database, migrations []Migration[*mongo.Database]) (*Migrator[*mongo.Database], error) {
+	return newMigrator[*mongo.Database](mongoJournal{db}, migrations)
+}
+
+func newMigrator[DB any](j journal[DB], migrations []Migration[DB]) (*Migrator[DB], error) {
+	sorted, err := sortMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator[DB]{journal: j, migrations: sorted}, nil
+}
+
+func sortMigrations[DB any](migrations []Migration[DB]) ([]Migration[DB], error) {
+	sorted := append([]Migration[DB](nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Up == nil || m.Down == nil {
+			return nil, fmt.Errorf("migration %d (%s) needs both an up and a down step", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", sorted[i-1].Name, m.Name, m.Version)
+		}
+	}
+	return sorted, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator[DB]) Status(ctx context.Context) ([]Status[DB], error) {
+	applied, err := m.journal.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status[DB], len(m.migrations))
+	for i, migration := range m.migrations {
+		rec, ok := applied[migration.Version]
+		statuses[i] = Status[DB]{Migration: migration, Applied: ok, AppliedAt: rec.AppliedAt}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied. It stops at the first failure.
+func (m *Migrator[DB]) Up(ctx context.Context) ([]Migration[DB], error) {
+	applied, err := m.journal.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration[DB]
+	for _, migration := range pending(m.migrations, applied) {
+		if err := m.journal.apply(ctx, migration); err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones it reverted
+func (m *Migrator[DB]) Down(ctx context.Context, steps int) ([]Migration[DB], error) {
+	applied, err := m.journal.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration[DB]
+	for _, migration := range revertible(m.migrations, applied, steps) {
+		if err := m.journal.revert(ctx, migration); err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// mongoJournal records migrations in the schema_migrations collection
+type mongoJournal struct {
+	db *mongo.Database
+}
+
+func (j mongoJournal) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := j.db.Collection(collection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", collection, err)
+	}
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", collection, err)
+	}
+
+	applied := make(map[int]record, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+func (j mongoJournal) apply(ctx context.Context, m Migration[*mongo.Database]) error {
+	if err := m.Up(ctx, j.db); err != nil {
+		return err
+	}
+	rec := record{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+	if _, err := j.db.Collection(collection).InsertOne(ctx, rec); err != nil {
+		return fmt.Errorf("could not record migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+func (j mongoJournal) revert(ctx context.Context, m Migration[*mongo.Database]) error {
+	if err := m.Down(ctx, j.db); err != nil {
+		return err
+	}
+	if _, err := j.db.Collection(collection).DeleteOne(ctx, bson.M{"_id": m.Version}); err != nil {
+		return fmt.Errorf("could not unrecord migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// pending returns the migrations that have not been applied, oldest first
+func pending[DB any](migrations []Migration[DB], applied map[int]record) []Migration[DB] {
+	var todo []Migration[DB]
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			todo = append(todo, migration)
+		}
+	}
+	return todo
+}
+
+// revertible returns up to steps applied migrations, newest first
+func revertible[DB any](migrations []Migration[DB], applied map[int]record, steps int) []Migration[DB] {
+	var todo []Migration[DB]
+	for i := len(migrations) - 1; i >= 0 && len(todo) < steps; i-- {
+		if _, ok := applied[migrations[i].Version]; ok {
+			todo = append(todo, migrations[i])
+		}
+	}
+	return todo
+}
+
+// collectionExists reports whether db has a collection called name
+func collectionExists(ctx context.Context, db *mongo.Database, name string) (bool, error) {
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": name})
+	if err != nil {
+		return false, err
+	}
+	return len(names) > 0, nil
+}
+
+// renameCollection renames from to to within db. A missing from is not an
+// error, so the step can be re-run; an empty to is replaced, but one that
+// already holds documents is left alone and reported.
+func renameCollection(ctx context.Context, db *mongo.Database, from, to string) error {
+	exists, err := collectionExists(ctx, db, from)
+	if err != nil || !exists {
+		return err
+	}
+
+	count, err := db.Collection(to).EstimatedDocumentCount(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("both %q and %q hold documents; merge them by hand before migrating", from, to)
+	}
+
+	cmd := bson.D{
+		{Key: "renameCollection", Value: db.Name() + "." + from},
+		{Key: "to", Value: db.Name() + "." + to},
+		{Key: "dropTarget", Value: true},
+	}
+	return db.Client().Database("admin").RunCommand(ctx, cmd).Err()
+}
+
+// dropIndexes drops the named indexes of a collection, ignoring ones that
+// do not exist
+func dropIndexes(ctx context.Context, coll *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		_, err := coll.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && (cmdErr.Code == indexNotFound || cmdErr.Code == namespaceNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not drop index %s on %s: %w", name, coll.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Server error codes for dropping what is already gone
+const (
+	namespaceNotFound = 26
+	indexNotFound     = 27
+)
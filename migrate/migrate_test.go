@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func noop(context.Context, *mongo.Database) error { return nil }
+
+func TestAllMigrationsAreValid(t *testing.T) {
+	sorted, err := sortMigrations(All)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range sorted {
+		if m.Version != i+1 {
+			t.Errorf("expected version %d at position %d, got %d (%s)", i+1, i, m.Version, m.Name)
+		}
+	}
+}
+
+func TestSortMigrationsRejectsInvalidSets(t *testing.T) {
+	if _, err := sortMigrations([]Migration[*mongo.Database]{
+		{Version: 1, Name: "a", Up: noop, Down: noop},
+		{Version: 1, Name: "b", Up: noop, Down: noop},
+	}); err == nil {
+		t.Error("expected an error for a duplicate version")
+	}
+	if _, err := sortMigrations([]Migration[*mongo.Database]{{Version: 1, Name: "a", Up: noop}}); err == nil {
+		t.Error("expected an error for a missing down step")
+	}
+}
+
+func TestPendingAndRevertible(t *testing.T) {
+	migrations, err := sortMigrations([]Migration[*mongo.Database]{
+		{Version: 3, Name: "c", Up: noop, Down: noop},
+		{Version: 1, Name: "a", Up: noop, Down: noop},
+		{Version: 2, Name: "b", Up: noop, Down: noop},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := map[int]record{1: {Version: 1}, 2: {Version: 2}}
+
+	todo := pending(migrations, applied)
+	if len(todo) != 1 || todo[0].Version != 3 {
+		t.Errorf("expected only version 3 to be pending, got %+v", todo)
+	}
+
+	undo := revertible(migrations, applied, 5)
+	if len(undo) != 2 || undo[0].Version != 2 || undo[1].Version != 1 {
+		t.Errorf("expected versions 2 then 1 to be revertible, got %+v", undo)
+	}
+	if undo := revertible(migrations, applied, 1); len(undo) != 1 || undo[0].Version != 2 {
+		t.Errorf("expected one step to revert version 2, got %+v", undo)
+	}
+}
+
+func TestPostgresMigrationsAreValid(t *testing.T) {
+	migrations, err := Postgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted, err := sortMigrations(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range sorted {
+		if m.Version != i+1 {
+			t.Errorf("expected version %d at position %d, got %d (%s)", i+1, i, m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyHotelCollection is where hotels were written before migration 1
+const legacyHotelCollection = "hotel"
+
+// All is every migration of the application, in the order they apply.
+// Append new migrations with the next version; never edit applied ones.
+var All = []Migration[*mongo.Database]{
+	{
+		Version: 1,
+		Name:    "rename_hotel_collection",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return renameCollection(ctx, db, legacyHotelCollection, model.Hotel{}.CollectionName())
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return renameCollection(ctx, db, model.Hotel{}.CollectionName(), legacyHotelCollection)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "backfill_hotel_fields",
+		Up:      backfillHotelFields,
+		// The backfilled values cannot be told apart from ones set by
+		// clients, so there is nothing safe to undo
+		Down: func(ctx context.Context, db *mongo.Database) error { return nil },
+	},
+	{
+		Version: 3,
+		Name:    "hotel_search_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.Hotel{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys: bson.D{
+						{Key: "title", Value: "text"},
+						{Key: "content", Value: "text"},
+						{Key: "location.city", Value: "text"},
+						{Key: "tags", Value: "text"},
+					},
+					Options: options.Index().
+						SetName("hotels_text").
+						SetWeights(bson.D{{Key: "title", Value: 10}, {Key: "location.city", Value: 5}, {Key: "tags", Value: 3}}),
+				},
+				{
+					Keys:    bson.D{{Key: "location.coordinates", Value: "2dsphere"}},
+					Options: options.Index().SetName("hotels_location_2dsphere"),
+				},
+				{
+					Keys:    bson.D{{Key: "rating", Value: -1}, {Key: "price", Value: 1}},
+					Options: options.Index().SetName("hotels_rating_price"),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Hotel{}.CollectionName()),
+				"hotels_text", "hotels_location_2dsphere", "hotels_rating_price")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "nested_resource_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for name, keys := range nestedResourceIndexes() {
+				coll := db.Collection(name)
+				if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    keys,
+					Options: options.Index().SetName(name + "_owner"),
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for name := range nestedResourceIndexes() {
+				if err := dropIndexes(ctx, db.Collection(name), name+"_owner"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "external_id_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range importedCollections() {
+				if _, err := db.Collection(name).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "source", Value: 1}, {Key: "external_id", Value: 1}},
+					Options: options.Index().
+						SetName(name + "_external_id").
+						SetUnique(true).
+						SetPartialFilterExpression(bson.M{"external_id": bson.M{"$exists": true}}),
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range importedCollections() {
+				if err := dropIndexes(ctx, db.Collection(name), name+"_external_id"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "inventory_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.Inventory{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					// One document per room type and night
+					Keys:    bson.D{{Key: "hotel_id", Value: 1}, {Key: "room_type_id", Value: 1}, {Key: "date", Value: 1}},
+					Options: options.Index().SetName("inventory_night").SetUnique(true),
+				},
+				{
+					Keys:    bson.D{{Key: "date", Value: 1}},
+					Options: options.Index().SetName("inventory_date"),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Inventory{}.CollectionName()), "inventory_night", "inventory_date")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "booking_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.Booking{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				// Serves the search for expired holds
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "hold_expires_at", Value: 1}},
+				Options: options.Index().SetName("bookings_status_expiry"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Booking{}.CollectionName()), "bookings_status_expiry")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "rate_plan_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.RatePlan{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "hotel_id", Value: 1}, {Key: "room_type_id", Value: 1}},
+				Options: options.Index().SetName("ratePlans_owner"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.RatePlan{}.CollectionName()), "ratePlans_owner")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "exchange_rate_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.ExchangeRate{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				// One rate per currency
+				Keys:    bson.D{{Key: "currency", Value: 1}},
+				Options: options.Index().SetName("exchangeRates_currency").SetUnique(true),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.ExchangeRate{}.CollectionName()), "exchangeRates_currency")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "vacation_rental_model",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := reshapeVacationRentals(ctx, db); err != nil {
+				return err
+			}
+			_, err := db.Collection(model.VacationRental{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "coordinates", Value: "2dsphere"}},
+					Options: options.Index().SetName("vacationRentals_coordinates_2dsphere"),
+				},
+				{
+					Keys:    bson.D{{Key: "property_type", Value: 1}, {Key: "max_guests", Value: 1}},
+					Options: options.Index().SetName("vacationRentals_type_guests"),
+				},
+				{
+					Keys:    bson.D{{Key: "unavailable_nights", Value: 1}},
+					Options: options.Index().SetName("vacationRentals_unavailable_nights"),
+				},
+			})
+			return err
+		},
+		// The restaurant fields were never meaningful for rentals; only the
+		// indexes are undone
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.VacationRental{}.CollectionName()),
+				"vacationRentals_coordinates_2dsphere", "vacationRentals_type_guests", "vacationRentals_unavailable_nights")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "rental_car_indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection(model.CarLocation{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "coordinates", Value: "2dsphere"}},
+					Options: options.Index().SetName("carLocations_coordinates_2dsphere"),
+				},
+				{
+					Keys:    bson.D{{Key: "geo_id", Value: 1}},
+					Options: options.Index().SetName("carLocations_geo_id"),
+				},
+			}); err != nil {
+				return err
+			}
+			_, err := db.Collection(model.RentalCar{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					// Serves both searches, which start from the pick-up location
+					Keys:    bson.D{{Key: "location_id", Value: 1}, {Key: "price", Value: 1}},
+					Options: options.Index().SetName("rentalCars_location_price"),
+				},
+				{
+					Keys:    bson.D{{Key: "one_way_fees.location_id", Value: 1}},
+					Options: options.Index().SetName("rentalCars_one_way_fees"),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexes(ctx, db.Collection(model.CarLocation{}.CollectionName()),
+				"carLocations_coordinates_2dsphere", "carLocations_geo_id"); err != nil {
+				return err
+			}
+			return dropIndexes(ctx, db.Collection(model.RentalCar{}.CollectionName()),
+				"rentalCars_location_price", "rentalCars_one_way_fees")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "structured_opening_hours",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := structureOpeningHours(ctx, db); err != nil {
+				return err
+			}
+			_, err := db.Collection(model.Restaurant{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "opening_hours.weekly_slots", Value: 1}},
+					Options: options.Index().SetName("restaurants_weekly_slots"),
+				},
+				{
+					Keys:    bson.D{{Key: "opening_hours.exception_slots", Value: 1}},
+					Options: options.Index().SetName("restaurants_exception_slots"),
+				},
+			})
+			return err
+		},
+		// The structured hours keep every interval the old ones had, so
+		// only the indexes are undone
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Restaurant{}.CollectionName()),
+				"restaurants_weekly_slots", "restaurants_exception_slots")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "restaurant_menus",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection(model.MenuSection{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "restaurant_id", Value: 1}, {Key: "position", Value: 1}},
+				Options: options.Index().SetName("menuSections_restaurant_position"),
+			}); err != nil {
+				return err
+			}
+			if _, err := db.Collection(model.MenuItem{}.CollectionName()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "restaurant_id", Value: 1}, {Key: "position", Value: 1}},
+					Options: options.Index().SetName("menuItems_restaurant_position"),
+				},
+				{
+					Keys:    bson.D{{Key: "section_id", Value: 1}},
+					Options: options.Index().SetName("menuItems_section_id"),
+				},
+			}); err != nil {
+				return err
+			}
+			_, err := db.Collection(model.Restaurant{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "menu.dietary", Value: 1}},
+				Options: options.Index().SetName("restaurants_menu_dietary"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexes(ctx, db.Collection(model.MenuSection{}.CollectionName()),
+				"menuSections_restaurant_position"); err != nil {
+				return err
+			}
+			if err := dropIndexes(ctx, db.Collection(model.MenuItem{}.CollectionName()),
+				"menuItems_restaurant_position", "menuItems_section_id"); err != nil {
+				return err
+			}
+			return dropIndexes(ctx, db.Collection(model.Restaurant{}.CollectionName()), "restaurants_menu_dietary")
+		},
+	},
+	{
+		Version: 14,
+		Name:    "table_reservations",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection(model.DiningTable{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "restaurant_id", Value: 1}, {Key: "name", Value: 1}},
+				Options: options.Index().SetName("diningTables_restaurant_name"),
+			}); err != nil {
+				return err
+			}
+			_, err := db.Collection(model.TableReservation{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "restaurant_id", Value: 1}, {Key: "starts_at", Value: 1}},
+				Options: options.Index().SetName("tableReservations_restaurant_starts_at"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexes(ctx, db.Collection(model.DiningTable{}.CollectionName()),
+				"diningTables_restaurant_name"); err != nil {
+				return err
+			}
+			return dropIndexes(ctx, db.Collection(model.TableReservation{}.CollectionName()),
+				"tableReservations_restaurant_starts_at")
+		},
+	},
+	{
+		Version: 15,
+		Name:    "rating_entities",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			ratings := db.Collection(model.Rating{}.CollectionName())
+			// Every rating so far was of the hotel in its hotel_id
+			if _, err := ratings.UpdateMany(ctx,
+				bson.M{"entity_type": bson.M{"$exists": false}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{"entity_type": "hotel", "entity_id": "$hotel_id"}}}},
+			); err != nil {
+				return err
+			}
+			_, err := ratings.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1}},
+				Options: options.Index().SetName("ratings_entity"),
+			})
+			return err
+		},
+		// Hotel ratings are still found by hotel_id, so the backfilled
+		// entity fields can stay
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Rating{}.CollectionName()), "ratings_entity")
+		},
+	},
+	{
+		Version: 16,
+		Name:    "review_moderation",
+		// Reviews without a status stay published, so only the moderation
+		// queue needs an index
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.Review{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("reviews_status_created_at"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Review{}.CollectionName()), "reviews_status_created_at")
+		},
+	},
+	{
+		Version: 17,
+		Name:    "review_helpfulness",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(model.Review{}.CollectionName()).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1},
+					{Key: "helpful", Value: -1}, {Key: "not_helpful", Value: 1},
+				},
+				Options: options.Index().SetName("reviews_entity_helpful"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection(model.Review{}.CollectionName()), "reviews_entity_helpful")
+		},
+	},
+}
+
+// importedCollections hold documents an importer upserts by external ID
+func importedCollections() []string {
+	return []string{
+		model.Hotel{}.CollectionName(),
+		model.Restaurant{}.CollectionName(),
+		model.VacationRental{}.CollectionName(),
+	}
+}
+
+// nestedResourceIndexes are the keys the /hotels/:id/... lists filter on
+func nestedResourceIndexes() map[string]bson.D {
+	byEntity := bson.D{{Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1}}
+	return map[string]bson.D{
+		model.Review{}.CollectionName():    byEntity,
+		model.Photo{}.CollectionName():     byEntity,
+		model.Thumbnail{}.CollectionName(): byEntity,
+		model.Rating{}.CollectionName():    {{Key: "hotel_id", Value: 1}},
+	}
+}
+
+// backfillHotelFields gives hotels written before validation existed the
+// fields that are now required, and drops coordinates a 2dsphere index
+// would reject
+func backfillHotelFields(ctx context.Context, db *mongo.Database) error {
+	hotels := db.Collection(model.Hotel{}.CollectionName())
+
+	missing := func(field string) bson.M {
+		return bson.M{"$or": bson.A{
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{field: ""},
+		}}
+	}
+	if _, err := hotels.UpdateMany(ctx, missing("status"), bson.M{"$set": bson.M{"status": "active"}}); err != nil {
+		return err
+	}
+	if _, err := hotels.UpdateMany(ctx, missing("currency"), bson.M{"$set": bson.M{"currency": "USD"}}); err != nil {
+		return err
+	}
+
+	// A coordinate pair is [longitude, latitude]; empty arrays are ignored
+	// by the index and can stay
+	malformed := bson.M{
+		"location.coordinates.0": bson.M{"$exists": true},
+		"location.coordinates":   bson.M{"$not": bson.M{"$size": 2}},
+	}
+	_, err := hotels.UpdateMany(ctx, malformed, bson.M{"$unset": bson.M{"location.coordinates": ""}})
+	return err
+}
+
+// reshapeVacationRentals moves the contact details of vacation rentals
+// written with the restaurant-shaped model into their host, drops the fields
+// rentals never had and fills in the ones they now need
+func reshapeVacationRentals(ctx context.Context, db *mongo.Database) error {
+	rentals := db.Collection(model.VacationRental{}.CollectionName())
+
+	legacy := bson.M{"$or": bson.A{
+		bson.M{"contact_number": bson.M{"$exists": true}},
+		bson.M{"email": bson.M{"$exists": true}},
+		bson.M{"website": bson.M{"$exists": true}},
+	}}
+	if _, err := rentals.UpdateMany(ctx, legacy, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"host.phone":   bson.M{"$ifNull": bson.A{"$contact_number", ""}},
+			"host.email":   bson.M{"$ifNull": bson.A{"$email", ""}},
+			"host.website": bson.M{"$ifNull": bson.A{"$website", ""}},
+		}}},
+		{{Key: "$unset", Value: bson.A{"contact_number", "email", "website", "cuisines", "price_range", "opening_hours"}}},
+	}); err != nil {
+		return err
+	}
+
+	defaults := map[string]interface{}{"property_type": "other", "currency": model.BaseCurrency, "minimum_stay": 1}
+	for field, value := range defaults {
+		if _, err := rentals.UpdateMany(ctx, bson.M{field: bson.M{"$exists": false}}, bson.M{"$set": bson.M{field: value}}); err != nil {
+			return err
+		}
+	}
+	malformed := bson.M{
+		"coordinates.0": bson.M{"$exists": true},
+		"coordinates":   bson.M{"$not": bson.M{"$size": 2}},
+	}
+	_, err := rentals.UpdateMany(ctx, malformed, bson.M{"$unset": bson.M{"coordinates": ""}})
+	return err
+}
+
+// structureOpeningHours rewrites the opening hours restaurants kept as a map
+// of day names to ranges, e.g. {"monday": "12:00-15:00, 18:00-23:00"}, as
+// model.OpeningHours in UTC. Ranges that do not parse are dropped.
+func structureOpeningHours(ctx context.Context, db *mongo.Database) error {
+	restaurants := db.Collection(model.Restaurant{}.CollectionName())
+	cursor, err := restaurants.Find(ctx, bson.M{
+		"opening_hours":           bson.M{"$type": "object"},
+		"opening_hours.time_zone": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID           interface{}       `bson:"_id"`
+			OpeningHours map[string]string `bson:"opening_hours"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		hours := model.OpeningHours{}
+		for day, ranges := range doc.OpeningHours {
+			for _, r := range strings.Split(ranges, ",") {
+				opens, closes, _ := strings.Cut(strings.TrimSpace(r), "-")
+				period := model.OpeningPeriod{
+					Day:       strings.ToLower(strings.TrimSpace(day)),
+					TimeRange: model.TimeRange{Opens: strings.TrimSpace(opens), Closes: strings.TrimSpace(closes)},
+				}
+				if isWeekday(period.Day) && period.Valid() {
+					hours.Weekly = append(hours.Weekly, period)
+				}
+			}
+		}
+		hours.SetDefaults()
+		if _, err := restaurants.UpdateByID(ctx, doc.ID, bson.M{"$set": bson.M{"opening_hours": hours}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func isWeekday(day string) bool {
+	for _, weekday := range model.Weekdays {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
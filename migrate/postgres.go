@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresFiles holds the PostgreSQL migrations, one NNNN_name.up.sql and
+// NNNN_name.down.sql pair per version
+//
+//go:embed postgres/*.sql
+var postgresFiles embed.FS
+
+var sqlFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Postgres returns every PostgreSQL migration of the application. Each runs
+// in its own transaction, together with its schema_migrations entry.
+func Postgres() ([]Migration[pgx.Tx], error) {
+	return sqlMigrations(postgresFiles, "postgres")
+}
+
+// sqlMigrations pairs the up and down files of dir into migrations
+func sqlMigrations(fsys fs.FS, dir string) ([]Migration[pgx.Tx], error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration[pgx.Tx]{}
+	var migrations []*Migration[pgx.Tx]
+	for _, entry := range entries {
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration[pgx.Tx]{Version: version, Name: match[2]}
+			byVersion[version] = m
+			migrations = append(migrations, m)
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", m.Name, match[2], version)
+		}
+
+		step := execSQL(string(data))
+		if match[3] == "up" {
+			m.Up = step
+		} else {
+			m.Down = step
+		}
+	}
+
+	all := make([]Migration[pgx.Tx], len(migrations))
+	for i, m := range migrations {
+		all[i] = *m
+	}
+	return all, nil
+}
+
+func execSQL(sql string) func(ctx context.Context, tx pgx.Tx) error {
+	return func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, sql)
+		return err
+	}
+}
+
+// NewPostgres returns a Migrator for a PostgreSQL database
+func NewPostgres(pool *pgxpool.Pool, migrations []Migration[pgx.Tx]) (*Migrator[pgx.Tx], error) {
+	return newMigrator[pgx.Tx](postgresJournal{pool}, migrations)
+}
+
+// postgresJournal records migrations in the schema_migrations table
+type postgresJournal struct {
+	pool *pgxpool.Pool
+}
+
+func (j postgresJournal) applied(ctx context.Context) (map[int]record, error) {
+	_, err := j.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS "`+collection+`" (
+		"version" int PRIMARY KEY,
+		"name" varchar NOT NULL,
+		"applied_at" timestamptz NOT NULL DEFAULT (now())
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", collection, err)
+	}
+
+	rows, err := j.pool.Query(ctx, `SELECT "version", "name", "applied_at" FROM "`+collection+`"`)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", collection, err)
+	}
+	applied := map[int]record{}
+	for rows.Next() {
+		var rec record
+		if err := rows.Scan(&rec.Version, &rec.Name, &rec.AppliedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not read %s: %w", collection, err)
+		}
+		applied[rec.Version] = rec
+	}
+	return applied, rows.Err()
+}
+
+func (j postgresJournal) apply(ctx context.Context, m Migration[pgx.Tx]) error {
+	return pgx.BeginFunc(ctx, j.pool, func(tx pgx.Tx) error {
+		if err := m.Up(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO "`+collection+`" ("version", "name", "applied_at") VALUES ($1, $2, $3)`,
+			m.Version, m.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("could not record migration %d: %w", m.Version, err)
+		}
+		return nil
+	})
+}
+
+func (j postgresJournal) revert(ctx context.Context, m Migration[pgx.Tx]) error {
+	return pgx.BeginFunc(ctx, j.pool, func(tx pgx.Tx) error {
+		if err := m.Down(ctx, tx); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM "`+collection+`" WHERE "version" = $1`, m.Version); err != nil {
+			return fmt.Errorf("could not unrecord migration %d: %w", m.Version, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "ratings";
+DROP TABLE IF EXISTS "reviews";
+DROP TABLE IF EXISTS "cuisines";
+DROP TABLE IF EXISTS "photos";
+DROP TABLE IF EXISTS "thumbnails";
+DROP TABLE IF EXISTS "vacationrentals";
+DROP TABLE IF EXISTS "restaurants";
+DROP TABLE IF EXISTS "hotels";
@@ -0,0 +1,226 @@
+-- Every field of a model has a column of its own, named after its bson
+-- field. The fields of a sub-document are prefixed with its name, so a
+-- hotel's location.city is "location_city". Lists of scalars are arrays,
+-- lists of sub-documents and optional sub-documents are jsonb.
+--
+-- ObjectIDs are stored as hex in char(24) columns, and a reference that is
+-- not set as NULL. "seq" keeps insertion order, which ties sort in.
+
+CREATE TABLE "hotels" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "title" text,
+  "content" text,
+  "primary_info" text,
+  "secondary_info" text,
+  "accented_label" text,
+  "provider" text,
+  "price_details" text,
+  "price_summary" text,
+  "location_city" text,
+  "location_state" text,
+  "location_country" text,
+  "location_postal_code" text,
+  "location_coordinates" double precision[],
+  "address" text,
+  "price" double precision,
+  "currency" text,
+  "rating" double precision,
+  "review_count" bigint,
+  "amenities" text[],
+  "room_types" jsonb,
+  "contact_info_phone" text,
+  "contact_info_email" text,
+  "contact_info_website" text,
+  "policies_check_in" text,
+  "policies_check_out" text,
+  "policies_cancellation" text,
+  "policies_pet_policy" text,
+  "policies_smoking_policy" text,
+  "policies_age_restriction" bigint,
+  "policies_accepted_cards" text[],
+  "status" text,
+  "tags" text[],
+  "original_price" double precision,
+  "is_sponsored" boolean,
+  "owner_id" char(24),
+  "source" text,
+  "external_id" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "restaurants" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "name" text,
+  "description" text,
+  "address" text,
+  "location" text,
+  "cuisines" char(24)[],
+  "price_range" text,
+  "rating" double precision,
+  "contact_number" text,
+  "email" text,
+  "website" text,
+  "opening_hours_time_zone" text,
+  "opening_hours_weekly" jsonb,
+  "opening_hours_exceptions" jsonb,
+  "opening_hours_weekly_slots" bigint[],
+  "opening_hours_exception_dates" text[],
+  "opening_hours_exception_slots" text[],
+  "reservations_turn_minutes" bigint,
+  "reservations_turn_times" jsonb,
+  "reservations_interval_minutes" bigint,
+  "reservations_min_party_size" bigint,
+  "reservations_max_party_size" bigint,
+  "reservations_lead_minutes" bigint,
+  "reservations_max_days_ahead" bigint,
+  "review_count" bigint,
+  "menu_url" text,
+  "menu_items" bigint,
+  "menu_dietary" text[],
+  "menu_average_price" double precision,
+  "menu_min_price" double precision,
+  "menu_max_price" double precision,
+  "menu_currency" text,
+  "review_snippets" jsonb,
+  "offers" jsonb,
+  "owner_id" char(24),
+  "source" text,
+  "external_id" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "vacationrentals" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "name" text,
+  "description" text,
+  "property_type" text,
+  "address" text,
+  "location" text,
+  "coordinates" double precision[],
+  "bedrooms" bigint,
+  "beds" bigint,
+  "bathrooms" double precision,
+  "max_guests" bigint,
+  "minimum_stay" bigint,
+  "amenities" text[],
+  "house_rules_check_in" text,
+  "house_rules_check_out" text,
+  "house_rules_pets_allowed" boolean,
+  "house_rules_smoking_allowed" boolean,
+  "house_rules_parties_allowed" boolean,
+  "house_rules_quiet_hours" text,
+  "house_rules_other" text[],
+  "host_name" text,
+  "host_email" text,
+  "host_phone" text,
+  "host_website" text,
+  "host_superhost" boolean,
+  "host_languages" text[],
+  "host_hosting_since" timestamptz,
+  "price" double precision,
+  "currency" text,
+  "rates_seasons" jsonb,
+  "rates_weekend_uplift" double precision,
+  "rates_length_of_stay_discounts" jsonb,
+  "rates_cleaning_fee" double precision,
+  "rates_security_deposit" double precision,
+  "unavailable_nights" timestamptz[],
+  "rating" double precision,
+  "review_count" bigint,
+  "source" text,
+  "external_id" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "thumbnails" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "url" text,
+  "width" bigint,
+  "height" bigint,
+  "entity_id" char(24),
+  "entity_type" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "photos" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "url" text,
+  "title" text,
+  "description" text,
+  "alt" text,
+  "entity_id" char(24),
+  "entity_type" text,
+  "is_primary" boolean,
+  "is_active" boolean,
+  "order" bigint,
+  "metadata_width" bigint,
+  "metadata_height" bigint,
+  "metadata_size" bigint,
+  "metadata_format" text,
+  "metadata_color_space" text,
+  "metadata_orientation" bigint,
+  "metadata_quality" bigint,
+  "tags" text[],
+  "uploaded_by" char(24),
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "cuisines" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "name" text,
+  "description" text,
+  "origin" text,
+  "category" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "reviews" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "user_id" char(24),
+  "entity_id" char(24),
+  "entity_type" text,
+  "title" text,
+  "content" text,
+  "rating" double precision,
+  "helpful" bigint,
+  "not_helpful" bigint,
+  "votes" jsonb,
+  "status" text,
+  "moderation" jsonb,
+  "reports" jsonb,
+  "report_count" bigint,
+  "response" jsonb,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "ratings" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "user_id" char(24),
+  "hotel_id" char(24),
+  "entity_id" char(24),
+  "entity_type" text,
+  "score" double precision,
+  "sub_ratings_cleanliness" double precision,
+  "sub_ratings_service" double precision,
+  "sub_ratings_location" double precision,
+  "sub_ratings_value" double precision,
+  "sub_ratings_rooms" double precision,
+  "comment" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
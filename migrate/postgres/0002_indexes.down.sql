@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS "vacationrentals_external_id";
+DROP INDEX IF EXISTS "restaurants_external_id";
+DROP INDEX IF EXISTS "hotels_external_id";
+DROP INDEX IF EXISTS "thumbnails_entity";
+DROP INDEX IF EXISTS "photos_entity";
+DROP INDEX IF EXISTS "ratings_entity";
+DROP INDEX IF EXISTS "ratings_hotel";
+DROP INDEX IF EXISTS "reviews_entity";
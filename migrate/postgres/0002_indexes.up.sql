@@ -0,0 +1,11 @@
+-- Lookups of the reviews, ratings and media of a hotel or restaurant
+CREATE INDEX "reviews_entity" ON "reviews" ("entity_id", "status");
+CREATE INDEX "ratings_hotel" ON "ratings" ("hotel_id");
+CREATE INDEX "ratings_entity" ON "ratings" ("entity_id");
+CREATE INDEX "photos_entity" ON "photos" ("entity_id");
+CREATE INDEX "thumbnails_entity" ON "thumbnails" ("entity_id");
+
+-- Imported documents are upserted by their ID at the source
+CREATE UNIQUE INDEX "hotels_external_id" ON "hotels" ("source", "external_id") WHERE "external_id" IS NOT NULL;
+CREATE UNIQUE INDEX "restaurants_external_id" ON "restaurants" ("source", "external_id") WHERE "external_id" IS NOT NULL;
+CREATE UNIQUE INDEX "vacationrentals_external_id" ON "vacationrentals" ("source", "external_id") WHERE "external_id" IS NOT NULL;
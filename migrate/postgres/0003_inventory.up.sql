@@ -0,0 +1,14 @@
+-- Nightly room inventory, one row per hotel, room type and night
+CREATE TABLE "inventory" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "hotel_id" char(24),
+  "room_type_id" text,
+  "date" timestamptz,
+  "units" bigint,
+  "sold" bigint,
+  "closed_to_arrival" boolean,
+  "updated_at" timestamptz
+);
+
+CREATE UNIQUE INDEX "inventory_night" ON "inventory" ("hotel_id", "room_type_id", "date");
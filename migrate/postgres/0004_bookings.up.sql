@@ -0,0 +1,22 @@
+-- Bookings, which hold and sell inventory
+CREATE TABLE "bookings" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "hotel_id" char(24),
+  "room_type_id" text,
+  "check_in" timestamptz,
+  "check_out" timestamptz,
+  "rooms" bigint,
+  "adults" bigint,
+  "children_ages" bigint[],
+  "guest_name" text,
+  "guest_email" text,
+  "status" text,
+  "hold_expires_at" timestamptz,
+  "confirmed_at" timestamptz,
+  "cancellation" jsonb,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE INDEX "bookings_hotel" ON "bookings" ("hotel_id", "status");
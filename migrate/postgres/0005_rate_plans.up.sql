@@ -0,0 +1,22 @@
+-- Rate plans price the nights of hotel room types
+CREATE TABLE "rateplans" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "hotel_id" char(24),
+  "room_type_id" text,
+  "name" text,
+  "currency" text,
+  "base_rate" double precision,
+  "nightly_rates" jsonb,
+  "seasons" jsonb,
+  "weekend_uplift" double precision,
+  "length_of_stay_discounts" jsonb,
+  "included_adults" bigint,
+  "extra_adult_rate" double precision,
+  "child_rates" jsonb,
+  "stay_fee" double precision,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE INDEX "rateplans_room_type" ON "rateplans" ("hotel_id", "room_type_id");
@@ -0,0 +1,11 @@
+-- Exchange rates, one row per currency
+CREATE TABLE "exchangerates" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "currency" text,
+  "rate" double precision,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE UNIQUE INDEX "exchangerates_currency" ON "exchangerates" ("currency");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "rentalcars";
+DROP TABLE IF EXISTS "carlocations";
+DROP TABLE IF EXISTS "carsuppliers";
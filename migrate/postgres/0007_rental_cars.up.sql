@@ -0,0 +1,62 @@
+-- Car suppliers, their pick-up locations and the cars they rent out. A
+-- supplier or location cannot be deleted while it is still in use.
+CREATE TABLE "carsuppliers" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "name" text,
+  "code" text,
+  "logo_url" text,
+  "website" text,
+  "phone" text,
+  "rating" double precision,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "carlocations" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "supplier_id" char(24) REFERENCES "carsuppliers" ("id"),
+  "name" text,
+  "additional_names" text,
+  "geo_id" bigint,
+  "place_type" text,
+  "address" text,
+  "city" text,
+  "country" text,
+  "coordinates" double precision[],
+  "opening_hours" text,
+  "thumbnail" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "rentalcars" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "supplier_id" char(24) REFERENCES "carsuppliers" ("id"),
+  "location_id" char(24) REFERENCES "carlocations" ("id"),
+  "name" text,
+  "category" text,
+  "transmission" text,
+  "fuel" text,
+  "seats" bigint,
+  "doors" bigint,
+  "bags" bigint,
+  "air_conditioning" boolean,
+  "unlimited_mileage" boolean,
+  "min_driver_age" bigint,
+  "features" text[],
+  "image_url" text,
+  "price" double precision,
+  "currency" text,
+  "seasons" jsonb,
+  "rental_discounts" jsonb,
+  "one_way_fees" jsonb,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE INDEX "carlocations_supplier" ON "carlocations" ("supplier_id");
+CREATE INDEX "rentalcars_supplier" ON "rentalcars" ("supplier_id");
+CREATE INDEX "rentalcars_location" ON "rentalcars" ("location_id");
@@ -0,0 +1,32 @@
+-- The sections and dishes of restaurant menus
+CREATE TABLE "menusections" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "restaurant_id" char(24) REFERENCES "restaurants" ("id"),
+  "name" text,
+  "description" text,
+  "position" bigint,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "menuitems" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "restaurant_id" char(24) REFERENCES "restaurants" ("id"),
+  "section_id" char(24) REFERENCES "menusections" ("id"),
+  "cuisine_id" char(24),
+  "name" text,
+  "description" text,
+  "price" double precision,
+  "currency" text,
+  "dietary" text[],
+  "sold_out" boolean,
+  "position" bigint,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE INDEX "menusections_restaurant" ON "menusections" ("restaurant_id");
+CREATE INDEX "menuitems_restaurant" ON "menuitems" ("restaurant_id");
+CREATE INDEX "menuitems_section" ON "menuitems" ("section_id");
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "tablereservations";
+DROP TABLE IF EXISTS "diningtables";
@@ -0,0 +1,38 @@
+-- The tables of restaurants and the reservations made for them
+CREATE TABLE "diningtables" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "restaurant_id" char(24) REFERENCES "restaurants" ("id"),
+  "name" text,
+  "area" text,
+  "min_seats" bigint,
+  "max_seats" bigint,
+  "bookings" jsonb,
+  "version" bigint,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE TABLE "tablereservations" (
+  "id" char(24) PRIMARY KEY,
+  "seq" BIGSERIAL NOT NULL,
+  "restaurant_id" char(24),
+  "table_id" char(24),
+  "party_size" bigint,
+  "date" text,
+  "time" text,
+  "starts_at" timestamptz,
+  "ends_at" timestamptz,
+  "guest_name" text,
+  "guest_email" text,
+  "guest_phone" text,
+  "notes" text,
+  "status" text,
+  "cancellation" jsonb,
+  "created_at" timestamptz,
+  "updated_at" timestamptz
+);
+
+CREATE INDEX "diningtables_restaurant" ON "diningtables" ("restaurant_id");
+CREATE INDEX "tablereservations_restaurant" ON "tablereservations" ("restaurant_id", "date");
+CREATE INDEX "tablereservations_table" ON "tablereservations" ("table_id");
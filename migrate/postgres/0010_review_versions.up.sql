@@ -0,0 +1,3 @@
+-- Reviews count their changes, so votes and reports retry instead of
+-- overwriting each other. Reviews without a version have not changed since.
+ALTER TABLE "reviews" ADD COLUMN "version" bigint;
@@ -0,0 +1 @@
+ALTER TABLE "tablereservations" DROP COLUMN IF EXISTS "version";
@@ -0,0 +1,3 @@
+-- Table reservations count their changes, so concurrent modifications and
+-- cancellations cannot both be stored
+ALTER TABLE "tablereservations" ADD COLUMN "version" bigint;
@@ -0,0 +1,3 @@
+-- Hotels count their edits, so concurrent edits by the owner routes and
+-- the hotel API cannot drop each other's fields
+ALTER TABLE "hotels" ADD COLUMN "version" bigint;
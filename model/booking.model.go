@@ -0,0 +1,92 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Booking statuses. A booking starts as a hold on inventory, which is
+// either confirmed, cancelled or left to expire.
+const (
+	BookingHeld      = "held"
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+	BookingExpired   = "expired"
+)
+
+// Booking reserves rooms of one room type of a hotel for a stay
+type Booking struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	HotelID       primitive.ObjectID `bson:"hotel_id" json:"hotel_id"`
+	RoomTypeID    string             `bson:"room_type_id" json:"room_type_id"`
+	CheckIn       time.Time          `bson:"check_in" json:"check_in"`
+	CheckOut      time.Time          `bson:"check_out" json:"check_out"`
+	Rooms         int                `bson:"rooms" json:"rooms"`
+	Adults        int                `bson:"adults" json:"adults"`
+	ChildrenAges  []int              `bson:"children_ages" json:"children_ages"`
+	GuestName     string             `bson:"guest_name" json:"guest_name"`
+	GuestEmail    string             `bson:"guest_email" json:"guest_email"`
+	Status        string             `bson:"status" json:"status"`
+	HoldExpiresAt time.Time          `bson:"hold_expires_at" json:"hold_expires_at"`
+	ConfirmedAt   *time.Time         `bson:"confirmed_at,omitempty" json:"confirmed_at,omitempty"`
+	Cancellation  *Cancellation      `bson:"cancellation,omitempty" json:"cancellation,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Cancellation records when and why a booking was cancelled, and the
+// cancellation policy of the hotel at that time
+type Cancellation struct {
+	Reason      string    `bson:"reason" json:"reason"`
+	Policy      string    `bson:"policy" json:"policy"`
+	CancelledAt time.Time `bson:"cancelled_at" json:"cancelled_at"`
+}
+
+// BookingCollection returns the name of the MongoDB collection for bookings
+func (Booking) CollectionName() string {
+	return "bookings"
+}
+
+// Validate checks the fields a booking needs before it is stored
+func (b *Booking) Validate() error {
+	fields := FieldErrors{}
+	if b.HotelID.IsZero() {
+		fields.Add("hotel_id", "is required")
+	}
+	if strings.TrimSpace(b.RoomTypeID) == "" {
+		fields.Add("room_type_id", "is required")
+	}
+	if !b.CheckOut.After(b.CheckIn) {
+		fields.Add("check_out", "must be after check_in")
+	}
+	if b.Rooms < 1 {
+		fields.Add("rooms", "must be at least 1")
+	}
+	if b.Adults < b.Rooms {
+		fields.Add("adults", "must be at least one per room")
+	}
+	for _, age := range b.ChildrenAges {
+		if age < 0 || age > 17 {
+			fields.Add("children_ages", "must be ages between 0 and 17")
+		}
+	}
+	if strings.TrimSpace(b.GuestName) == "" {
+		fields.Add("guest_name", "is required")
+	}
+	if !strings.Contains(b.GuestEmail, "@") {
+		fields.Add("guest_email", "must be an email address")
+	}
+	return fields.Err()
+}
+
+// Guests counts the adults and children of the booking
+func (b *Booking) Guests() int {
+	return b.Adults + len(b.ChildrenAges)
+}
+
+// Expired reports whether the booking is a hold that has run out at now
+func (b *Booking) Expired(now time.Time) bool {
+	return b.Status == BookingHeld && !now.Before(b.HoldExpiresAt)
+}
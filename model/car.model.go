@@ -0,0 +1,354 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CarCategories are the ACRISS-style classes a rental car is sold as
+var CarCategories = []string{
+	"mini", "economy", "compact", "intermediate", "standard", "fullsize",
+	"premium", "luxury", "suv", "minivan", "van", "convertible", "pickup",
+}
+
+// CarTransmissions and CarFuels are the values of RentalCar.Transmission
+// and RentalCar.Fuel
+var (
+	CarTransmissions = []string{"automatic", "manual"}
+	CarFuels         = []string{"petrol", "diesel", "hybrid", "electric"}
+)
+
+// CarPlaceTypes are the kinds of place a car can be picked up at
+var CarPlaceTypes = []string{"airport", "city", "train_station", "port", "hotel", "other"}
+
+// CarSupplier is a car rental company
+type CarSupplier struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Code      string             `bson:"code" json:"code"` // e.g. "HZ"
+	LogoURL   string             `bson:"logo_url" json:"logo_url"`
+	Website   string             `bson:"website" json:"website"`
+	Phone     string             `bson:"phone" json:"phone"`
+	Rating    float64            `bson:"rating" json:"rating"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CarSupplierCollection returns the name of the MongoDB collection for car suppliers
+func (CarSupplier) CollectionName() string {
+	return "carSuppliers"
+}
+
+// SetDefaults sets default values for the car supplier
+func (s *CarSupplier) SetDefaults() {
+	s.Code = strings.ToUpper(strings.TrimSpace(s.Code))
+}
+
+// Validate checks the fields a car supplier needs before it is stored
+func (s *CarSupplier) Validate() error {
+	fields := FieldErrors{}
+	if strings.TrimSpace(s.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if s.Code == "" {
+		fields.Add("code", "is required")
+	}
+	if s.Rating < 0 || s.Rating > 5 {
+		fields.Add("rating", "must be between 0 and 5")
+	}
+	return fields.Err()
+}
+
+// CarLocation is a desk of a supplier where cars are picked up and dropped
+// off. GeoID is the geoId of the city or area the location is in, as in the
+// cars table of travel.sql.
+type CarLocation struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SupplierID      primitive.ObjectID `bson:"supplier_id" json:"supplier_id"`
+	Name            string             `bson:"name" json:"name"`
+	AdditionalNames string             `bson:"additional_names" json:"additional_names"` // e.g. the airport code
+	GeoID           int                `bson:"geo_id" json:"geo_id"`
+	PlaceType       string             `bson:"place_type" json:"place_type"`
+	Address         string             `bson:"address" json:"address"`
+	City            string             `bson:"city" json:"city"`
+	Country         string             `bson:"country" json:"country"`
+	Coordinates     []float64          `bson:"coordinates" json:"coordinates"` // [longitude, latitude]
+	OpeningHours    string             `bson:"opening_hours" json:"opening_hours"`
+	Thumbnail       string             `bson:"thumbnail" json:"thumbnail"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CarLocationCollection returns the name of the MongoDB collection for car rental locations
+func (CarLocation) CollectionName() string {
+	return "carLocations"
+}
+
+// SetDefaults sets default values for the car rental location
+func (l *CarLocation) SetDefaults() {
+	l.PlaceType = strings.ToLower(strings.TrimSpace(l.PlaceType))
+	if l.PlaceType == "" {
+		l.PlaceType = "other"
+	}
+}
+
+// Validate checks the fields a car rental location needs before it is stored
+func (l *CarLocation) Validate() error {
+	fields := FieldErrors{}
+	if l.SupplierID.IsZero() {
+		fields.Add("supplier_id", "is required")
+	}
+	if strings.TrimSpace(l.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if !contains(CarPlaceTypes, l.PlaceType) {
+		fields.Add("place_type", "must be one of "+strings.Join(CarPlaceTypes, ", "))
+	}
+	if len(l.Coordinates) != 0 && (len(l.Coordinates) != 2 || l.Coordinates[0] < -180 || l.Coordinates[0] > 180 || l.Coordinates[1] < -90 || l.Coordinates[1] > 90) {
+		fields.Add("coordinates", "must be a longitude and a latitude")
+	}
+	return fields.Err()
+}
+
+// RentalCar is a class of car a supplier rents out from one location, such
+// as "Toyota Corolla or similar". Price is the daily rate in Currency; a day
+// costs the rate of the season it falls in instead, if any.
+type RentalCar struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SupplierID       primitive.ObjectID `bson:"supplier_id" json:"supplier_id"`
+	LocationID       primitive.ObjectID `bson:"location_id" json:"location_id"` // where it is picked up
+	Name             string             `bson:"name" json:"name"`
+	Category         string             `bson:"category" json:"category"`
+	Transmission     string             `bson:"transmission" json:"transmission"`
+	Fuel             string             `bson:"fuel" json:"fuel"`
+	Seats            int                `bson:"seats" json:"seats"`
+	Doors            int                `bson:"doors" json:"doors"`
+	Bags             int                `bson:"bags" json:"bags"`
+	AirConditioning  bool               `bson:"air_conditioning" json:"air_conditioning"`
+	UnlimitedMileage bool               `bson:"unlimited_mileage" json:"unlimited_mileage"`
+	MinDriverAge     int                `bson:"min_driver_age" json:"min_driver_age"`
+	Features         []string           `bson:"features" json:"features"`
+	ImageURL         string             `bson:"image_url" json:"image_url"`
+	Price            float64            `bson:"price" json:"price"`
+	Currency         string             `bson:"currency" json:"currency"`
+	Seasons          []Season           `bson:"seasons" json:"seasons"` // daily rates
+	RentalDiscounts  []RentalDiscount   `bson:"rental_discounts" json:"rental_discounts"`
+	OneWayFees       []OneWayFee        `bson:"one_way_fees" json:"one_way_fees"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// RentalDiscount takes Percent off rentals of at least MinDays
+type RentalDiscount struct {
+	MinDays int     `bson:"min_days" json:"min_days"`
+	Percent float64 `bson:"percent" json:"percent"`
+}
+
+// OneWayFee is charged for dropping a car off at another location. A car
+// can only be dropped off at its own location or one it has a fee for.
+type OneWayFee struct {
+	LocationID primitive.ObjectID `bson:"location_id" json:"location_id"`
+	Fee        float64            `bson:"fee" json:"fee"`
+}
+
+// RentalCarCollection returns the name of the MongoDB collection for rental cars
+func (RentalCar) CollectionName() string {
+	return "rentalCars"
+}
+
+// SetDefaults sets default values for the rental car
+func (r *RentalCar) SetDefaults() {
+	r.Category = strings.ToLower(strings.TrimSpace(r.Category))
+	r.Transmission = strings.ToLower(strings.TrimSpace(r.Transmission))
+	r.Fuel = strings.ToLower(strings.TrimSpace(r.Fuel))
+	if r.Transmission == "" {
+		r.Transmission = "automatic"
+	}
+	if r.Fuel == "" {
+		r.Fuel = "petrol"
+	}
+	if r.Currency == "" {
+		r.Currency = BaseCurrency
+	}
+	r.Currency = strings.ToUpper(r.Currency)
+}
+
+// Validate checks the fields a rental car needs before it is stored
+func (r *RentalCar) Validate() error {
+	fields := FieldErrors{}
+	if r.SupplierID.IsZero() {
+		fields.Add("supplier_id", "is required")
+	}
+	if r.LocationID.IsZero() {
+		fields.Add("location_id", "is required")
+	}
+	if strings.TrimSpace(r.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if !contains(CarCategories, r.Category) {
+		fields.Add("category", "must be one of "+strings.Join(CarCategories, ", "))
+	}
+	if !contains(CarTransmissions, r.Transmission) {
+		fields.Add("transmission", "must be one of "+strings.Join(CarTransmissions, ", "))
+	}
+	if !contains(CarFuels, r.Fuel) {
+		fields.Add("fuel", "must be one of "+strings.Join(CarFuels, ", "))
+	}
+	if r.Seats < 1 {
+		fields.Add("seats", "must be at least 1")
+	}
+	if r.Doors < 0 {
+		fields.Add("doors", "cannot be negative")
+	}
+	if r.Bags < 0 {
+		fields.Add("bags", "cannot be negative")
+	}
+	if r.MinDriverAge < 0 {
+		fields.Add("min_driver_age", "cannot be negative")
+	}
+	if r.Price <= 0 {
+		fields.Add("price", "must be positive")
+	}
+	if len(r.Currency) != 3 {
+		fields.Add("currency", "must be a 3-letter code")
+	}
+	for _, season := range r.Seasons {
+		if season.Rate <= 0 || season.To.Before(season.From) {
+			fields.Add("seasons", "must have positive rates and end on or after they start")
+		}
+	}
+	for _, discount := range r.RentalDiscounts {
+		if discount.MinDays < 1 || discount.Percent <= 0 || discount.Percent >= 100 {
+			fields.Add("rental_discounts", "must apply from at least one day and take between 0 and 100 percent off")
+		}
+	}
+	for _, fee := range r.OneWayFees {
+		if fee.LocationID.IsZero() || fee.LocationID == r.LocationID || fee.Fee < 0 {
+			fields.Add("one_way_fees", "must name another location and have a fee of at least 0")
+		}
+	}
+	return fields.Err()
+}
+
+// RentalDays counts the days a rental from pickUp to dropOff is charged
+// for: every started 24 hours, and at least one
+func RentalDays(pickUp, dropOff time.Time) int {
+	days := int(dropOff.Sub(pickUp) / (24 * time.Hour))
+	if pickUp.Add(time.Duration(days) * 24 * time.Hour).Before(dropOff) {
+		days++
+	}
+	return max(days, 1)
+}
+
+// OneWayFee is the fee for dropping the car off at locationID. It reports
+// false when the car cannot be dropped off there.
+func (r *RentalCar) OneWayFee(locationID primitive.ObjectID) (float64, bool) {
+	if locationID == r.LocationID {
+		return 0, true
+	}
+	for _, fee := range r.OneWayFees {
+		if fee.LocationID == locationID {
+			return fee.Fee, true
+		}
+	}
+	return 0, false
+}
+
+// CarQuote is the price of renting a car for some days
+type CarQuote struct {
+	CarID              primitive.ObjectID `json:"car_id"`
+	Currency           string             `json:"currency"`
+	Days               []DayPrice         `json:"days"`
+	Subtotal           float64            `json:"subtotal"`
+	Discount           float64            `json:"discount"`
+	OneWayFee          float64            `json:"one_way_fee"` // not discounted
+	Total              float64            `json:"total"`
+	PricePerDay        string             `json:"price_per_day"` // after the discount
+	StrikethroughPrice string             `json:"strikethrough_price,omitempty"`
+	PriceSummary       string             `json:"price_summary"`
+}
+
+// DayPrice is the rate of one rental day, starting on Date
+type DayPrice struct {
+	Date time.Time `json:"date"`
+	Rate float64   `json:"rate"`
+}
+
+// Quote prices renting the car from pickUp to dropOff at dropOffLocationID.
+// It reports false when the car cannot be dropped off there.
+func (r *RentalCar) Quote(pickUp, dropOff time.Time, dropOffLocationID primitive.ObjectID) (CarQuote, bool) {
+	oneWayFee, ok := r.OneWayFee(dropOffLocationID)
+	if !ok {
+		return CarQuote{}, false
+	}
+	round := func(amount float64) float64 { return RoundPrice(r.Currency, amount) }
+
+	quote := CarQuote{CarID: r.ID, Currency: r.Currency}
+	days := RentalDays(pickUp, dropOff)
+	for i := 0; i < days; i++ {
+		date := Night(pickUp).AddDate(0, 0, i)
+		day := DayPrice{Date: date, Rate: round(r.rate(date))}
+		quote.Days = append(quote.Days, day)
+		quote.Subtotal += day.Rate
+	}
+	quote.Subtotal = round(quote.Subtotal)
+	percent := r.rentalDiscount(days)
+	quote.Discount = round(quote.Subtotal * percent / 100)
+	quote.OneWayFee = round(oneWayFee)
+	quote.Total = round(quote.Subtotal - quote.Discount + quote.OneWayFee)
+
+	quote.PricePerDay = FormatPrice(r.Currency, (quote.Subtotal-quote.Discount)/float64(days))
+	if quote.Discount > 0 {
+		quote.StrikethroughPrice = FormatPrice(r.Currency, quote.Subtotal/float64(days))
+	}
+	summary := fmt.Sprintf("%s total for %s", FormatPrice(r.Currency, quote.Total), plural(days, "day"))
+	if quote.OneWayFee > 0 {
+		summary += fmt.Sprintf(", including a %s one-way fee", FormatPrice(r.Currency, quote.OneWayFee))
+	}
+	if percent > 0 {
+		summary += fmt.Sprintf(" (%s%% off)", trimZeros(fmt.Sprintf("%.2f", percent)))
+	}
+	quote.PriceSummary = summary
+	return quote, true
+}
+
+// ConvertPrices converts the rates and fees of the car to currency to
+func (r *RentalCar) ConvertPrices(rates ExchangeRates, to string) bool {
+	convert, ok := rates.Converter(r.Currency, to)
+	if !ok {
+		return false
+	}
+	r.Price = convert(r.Price)
+	for i := range r.Seasons {
+		r.Seasons[i].Rate = convert(r.Seasons[i].Rate)
+	}
+	for i := range r.OneWayFees {
+		r.OneWayFees[i].Fee = convert(r.OneWayFees[i].Fee)
+	}
+	r.Currency = strings.ToUpper(to)
+	return true
+}
+
+// rate is the price of the rental day starting on date
+func (r *RentalCar) rate(date time.Time) float64 {
+	for _, season := range r.Seasons {
+		if !date.Before(Night(season.From)) && !date.After(Night(season.To)) {
+			return season.Rate
+		}
+	}
+	return r.Price
+}
+
+// rentalDiscount is the percentage taken off a rental of days
+func (r *RentalCar) rentalDiscount(days int) float64 {
+	var percent float64
+	for _, discount := range r.RentalDiscounts {
+		if days >= discount.MinDays && discount.Percent > percent {
+			percent = discount.Percent
+		}
+	}
+	return percent
+}
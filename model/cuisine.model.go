@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -21,3 +22,12 @@ type Cuisine struct {
 func (Cuisine) CollectionName() string {
 	return "cuisines"
 }
+
+// Validate checks the fields a cuisine needs before it is stored
+func (c *Cuisine) Validate() error {
+	fields := FieldErrors{}
+	if strings.TrimSpace(c.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	return fields.Err()
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BaseCurrency is the currency exchange rates are quoted against. Prices in
+// different currencies are compared by converting them to it.
+const BaseCurrency = "USD"
+
+// ExchangeRate is how many units of Currency one unit of BaseCurrency buys
+type ExchangeRate struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Currency  string             `bson:"currency" json:"currency"`
+	Rate      float64            `bson:"rate" json:"rate"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ExchangeRateCollection returns the name of the MongoDB collection for exchange rates
+func (ExchangeRate) CollectionName() string {
+	return "exchangeRates"
+}
+
+// SetDefaults sets default values for the exchange rate
+func (e *ExchangeRate) SetDefaults() {
+	e.Currency = strings.ToUpper(strings.TrimSpace(e.Currency))
+}
+
+// Validate checks the fields an exchange rate needs before it is stored
+func (e *ExchangeRate) Validate() error {
+	fields := FieldErrors{}
+	if len(e.Currency) != 3 || strings.Trim(e.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		fields.Add("currency", "must be a 3-letter currency code such as EUR")
+	}
+	switch {
+	case e.Rate <= 0:
+		fields.Add("rate", "must be positive")
+	case e.Currency == BaseCurrency && e.Rate != 1:
+		fields.Add("rate", "must be 1 for "+BaseCurrency)
+	}
+	return fields.Err()
+}
+
+// ExchangeRates maps currency codes to their ExchangeRate.Rate
+type ExchangeRates map[string]float64
+
+// NewExchangeRates collects stored rates into a table. BaseCurrency is
+// always in it.
+func NewExchangeRates(rates []ExchangeRate) ExchangeRates {
+	table := ExchangeRates{BaseCurrency: 1}
+	for _, rate := range rates {
+		table[strings.ToUpper(rate.Currency)] = rate.Rate
+	}
+	return table
+}
+
+// Has reports whether the table has a rate for currency
+func (r ExchangeRates) Has(currency string) bool {
+	return r[strings.ToUpper(currency)] > 0
+}
+
+// Currencies lists the currencies of the table in alphabetical order
+func (r ExchangeRates) Currencies() []string {
+	currencies := make([]string, 0, len(r))
+	for currency := range r {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// Convert converts amount from one currency to another without rounding.
+// It reports false when either currency has no rate.
+func (r ExchangeRates) Convert(amount float64, from, to string) (float64, bool) {
+	fromRate, toRate := r[strings.ToUpper(from)], r[strings.ToUpper(to)]
+	if fromRate <= 0 || toRate <= 0 {
+		return 0, false
+	}
+	return amount / fromRate * toRate, true
+}
+
+// Converter returns a function converting prices from one currency to
+// another, rounded to the minor unit of the target currency. It reports
+// false when either currency has no rate.
+func (r ExchangeRates) Converter(from, to string) (func(amount float64) float64, bool) {
+	if !r.Has(from) || !r.Has(to) {
+		return nil, false
+	}
+	return func(amount float64) float64 {
+		converted, _ := r.Convert(amount, from, to)
+		return RoundPrice(to, converted)
+	}, true
+}
+
+// Priced is a model whose prices can be shown in another currency
+type Priced interface {
+	// ConvertPrices converts every price of the model to currency to. It
+	// reports false, changing nothing, when rates cannot convert them.
+	ConvertPrices(rates ExchangeRates, to string) bool
+}
+
+// minorUnits are the decimal places of the ISO 4217 currencies that do not
+// use two
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnits is the number of decimal places prices in currency have
+func MinorUnits(currency string) int {
+	if units, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return units
+	}
+	return 2
+}
+
+// RoundPrice rounds amount to the minor unit of currency
+func RoundPrice(currency string, amount float64) float64 {
+	scale := math.Pow10(MinorUnits(currency))
+	return math.Round(amount*scale) / scale
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"math"
 	"strings"
 	"time"
 
@@ -30,8 +31,14 @@ type Hotel struct {
 	Policies      HotelPolicies      `bson:"policies" json:"policies"`
 	Status        string             `bson:"status" json:"status" validate:"required,oneof=active inactive pending"`
 	Tags          []string           `bson:"tags" json:"tags"`
+	OriginalPrice float64            `bson:"original_price" json:"original_price"` // price before a discount, if any
+	IsSponsored   bool               `bson:"is_sponsored" json:"is_sponsored"`
+	OwnerID       primitive.ObjectID `bson:"owner_id,omitempty" json:"-"`              // set by administrators, never shown
+	Source        string             `bson:"source,omitempty" json:"source"`           // where an imported hotel came from
+	ExternalID    string             `bson:"external_id,omitempty" json:"external_id"` // its ID at that source
 	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	Version       int                `bson:"version" json:"-"` // changes with every edit and score
 }
 
 type Location struct {
@@ -101,9 +108,30 @@ func (h *Hotel) SetDefaults() {
 	if h.CreatedAt.IsZero() {
 		h.CreatedAt = time.Now()
 	}
+	h.AssignRoomTypeIDs()
 	h.UpdatedAt = time.Now()
 }
 
+// AssignRoomTypeIDs gives room types without an ID a new one, so their
+// inventory can refer to them
+func (h *Hotel) AssignRoomTypeIDs() {
+	for i := range h.RoomTypes {
+		if h.RoomTypes[i].ID == "" {
+			h.RoomTypes[i].ID = primitive.NewObjectID().Hex()
+		}
+	}
+}
+
+// RoomType returns the room type with the given ID
+func (h *Hotel) RoomType(id string) (*RoomType, bool) {
+	for i := range h.RoomTypes {
+		if h.RoomTypes[i].ID == id {
+			return &h.RoomTypes[i], true
+		}
+	}
+	return nil, false
+}
+
 // UpdateRating updates the hotel's average rating based on new review
 func (h *Hotel) UpdateRating(newRating float64) {
 	if h.ReviewCount == 0 {
@@ -117,6 +145,41 @@ func (h *Hotel) UpdateRating(newRating float64) {
 	h.UpdatedAt = time.Now()
 }
 
+// RemoveRating takes the rating of a deleted review back out of the hotel's
+// average rating
+func (h *Hotel) RemoveRating(oldRating float64) {
+	if h.ReviewCount <= 1 {
+		h.Rating = 0
+		h.ReviewCount = 0
+	} else {
+		totalRating := h.Rating * float64(h.ReviewCount)
+		h.ReviewCount--
+		// Rounding errors must not push the average out of range
+		h.Rating = math.Min(math.Max((totalRating-oldRating)/float64(h.ReviewCount), 0), 5)
+	}
+	h.UpdatedAt = time.Now()
+}
+
+// ConvertPrices converts the price of the hotel and its room types to
+// currency to. A hotel without a currency is priced in BaseCurrency.
+func (h *Hotel) ConvertPrices(rates ExchangeRates, to string) bool {
+	from := h.Currency
+	if from == "" {
+		from = BaseCurrency
+	}
+	convert, ok := rates.Converter(from, to)
+	if !ok {
+		return false
+	}
+	h.Price = convert(h.Price)
+	h.OriginalPrice = convert(h.OriginalPrice)
+	for i := range h.RoomTypes {
+		h.RoomTypes[i].Price = convert(h.RoomTypes[i].Price)
+	}
+	h.Currency = strings.ToUpper(to)
+	return true
+}
+
 // GetAveragePrice returns the average price across all room types
 func (h *Hotel) GetAveragePrice() float64 {
 	if len(h.RoomTypes) == 0 {
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Inventory is the stock of one room type of a hotel for one night
+type Inventory struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	HotelID         primitive.ObjectID `bson:"hotel_id" json:"hotel_id"`
+	RoomTypeID      string             `bson:"room_type_id" json:"room_type_id"`
+	Date            time.Time          `bson:"date" json:"date"` // midnight UTC of the night
+	Units           int                `bson:"units" json:"units"`
+	Sold            int                `bson:"sold" json:"sold"`
+	ClosedToArrival bool               `bson:"closed_to_arrival" json:"closed_to_arrival"` // stays cannot start on this night
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// InventoryCollection returns the name of the MongoDB collection for inventory
+func (Inventory) CollectionName() string {
+	return "inventory"
+}
+
+// Validate checks the fields a night of inventory needs before it is stored
+func (i *Inventory) Validate() error {
+	fields := FieldErrors{}
+	if i.HotelID.IsZero() {
+		fields.Add("hotel_id", "is required")
+	}
+	if strings.TrimSpace(i.RoomTypeID) == "" {
+		fields.Add("room_type_id", "is required")
+	}
+	if i.Date.IsZero() || !i.Date.Equal(Night(i.Date)) {
+		fields.Add("date", "must be a date at midnight UTC")
+	}
+	if i.Units < 0 {
+		fields.Add("units", "cannot be negative")
+	}
+	if i.Sold < 0 {
+		fields.Add("sold", "cannot be negative")
+	}
+	return fields.Err()
+}
+
+// Available returns the units left to sell
+func (i *Inventory) Available() int {
+	return max(i.Units-i.Sold, 0)
+}
+
+// Night returns the night t falls on, as midnight UTC of its date
+func Night(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Nights lists the nights of a stay, from checkIn up to the night before
+// checkOut
+func Nights(checkIn, checkOut time.Time) []time.Time {
+	var nights []time.Time
+	for night := Night(checkIn); night.Before(Night(checkOut)); night = night.AddDate(0, 0, 1) {
+		nights = append(nights, night)
+	}
+	return nights
+}
+
+// RoomAvailability is a room type that can be booked for a whole stay
+type RoomAvailability struct {
+	RoomType  RoomType `json:"room_type"`
+	Available int      `json:"available"` // units free on every night of the stay
+}
+
+// AvailableRooms returns the room types of hotel that can host guests in
+// each of rooms units on every night from checkIn to checkOut. Only room
+// types with inventory for every night are bookable, and a stay cannot
+// start on a night closed to arrival.
+func AvailableRooms(hotel *Hotel, inventory []Inventory, checkIn, checkOut time.Time, guests, rooms int) []RoomAvailability {
+	nights := Nights(checkIn, checkOut)
+	rooms = max(rooms, 1)
+	perRoom := (guests + rooms - 1) / rooms
+
+	byNight := map[string]map[time.Time]Inventory{}
+	for _, night := range inventory {
+		if byNight[night.RoomTypeID] == nil {
+			byNight[night.RoomTypeID] = map[time.Time]Inventory{}
+		}
+		byNight[night.RoomTypeID][Night(night.Date)] = night
+	}
+
+	var available []RoomAvailability
+	for _, roomType := range hotel.RoomTypes {
+		if roomType.ID == "" || roomType.MaxGuests < perRoom || len(nights) == 0 {
+			continue
+		}
+		free := -1
+		for i, night := range nights {
+			stock, ok := byNight[roomType.ID][night]
+			if !ok || (i == 0 && stock.ClosedToArrival) {
+				free = 0
+				break
+			}
+			if free < 0 || stock.Available() < free {
+				free = stock.Available()
+			}
+		}
+		if free >= rooms {
+			available = append(available, RoomAvailability{RoomType: roomType, Available: free})
+		}
+	}
+	return available
+}
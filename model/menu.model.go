@@ -0,0 +1,221 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DietaryTags are the dietary needs a dish can be marked as suitable for
+var DietaryTags = []string{"vegetarian", "vegan", "halal", "kosher", "gluten_free", "dairy_free", "nut_free"}
+
+// PriceLevels are the price ranges a restaurant is shown with, cheapest
+// first. A menu gets the level of its average dish price in BaseCurrency:
+// the first one whose limit in priceLevelLimits it is below.
+var (
+	PriceLevels      = []string{"$", "$$", "$$$", "$$$$"}
+	priceLevelLimits = []float64{15, 30, 60}
+)
+
+// MenuSection groups the dishes of a restaurant's menu, such as "Starters"
+type MenuSection struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RestaurantID primitive.ObjectID `bson:"restaurant_id" json:"restaurant_id"`
+	Name         string             `bson:"name" json:"name"`
+	Description  string             `bson:"description" json:"description"`
+	Position     int                `bson:"position" json:"position"` // order on the menu
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// MenuSectionCollection returns the name of the MongoDB collection for menu sections
+func (MenuSection) CollectionName() string {
+	return "menuSections"
+}
+
+// Validate checks the fields a menu section needs before it is stored
+func (s *MenuSection) Validate() error {
+	fields := FieldErrors{}
+	if s.RestaurantID.IsZero() {
+		fields.Add("restaurant_id", "is required")
+	}
+	if strings.TrimSpace(s.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if s.Position < 0 {
+		fields.Add("position", "cannot be negative")
+	}
+	return fields.Err()
+}
+
+// MenuItem is a dish or drink on a restaurant's menu. Price is in Currency.
+type MenuItem struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	RestaurantID primitive.ObjectID  `bson:"restaurant_id" json:"restaurant_id"`
+	SectionID    primitive.ObjectID  `bson:"section_id" json:"section_id"`
+	CuisineID    *primitive.ObjectID `bson:"cuisine_id,omitempty" json:"cuisine_id,omitempty"`
+	Name         string              `bson:"name" json:"name"`
+	Description  string              `bson:"description" json:"description"`
+	Price        float64             `bson:"price" json:"price"`
+	Currency     string              `bson:"currency" json:"currency"`
+	Dietary      []string            `bson:"dietary" json:"dietary"` // DietaryTags
+	SoldOut      bool                `bson:"sold_out" json:"sold_out"`
+	Position     int                 `bson:"position" json:"position"` // order in the section
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// MenuItemCollection returns the name of the MongoDB collection for menu items
+func (MenuItem) CollectionName() string {
+	return "menuItems"
+}
+
+// SetDefaults sets default values for the menu item. Dietary tags are
+// normalized and sorted, and a vegan dish is vegetarian too.
+func (m *MenuItem) SetDefaults() {
+	if m.Currency == "" {
+		m.Currency = BaseCurrency
+	}
+	m.Currency = strings.ToUpper(m.Currency)
+
+	tags := make([]string, 0, len(m.Dietary))
+	for _, tag := range m.Dietary {
+		tag = DietaryTag(tag)
+		if tag != "" && !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if contains(tags, "vegan") && !contains(tags, "vegetarian") {
+		tags = append(tags, "vegetarian")
+	}
+	sort.Strings(tags)
+	m.Dietary = tags
+}
+
+// DietaryTag normalizes the spelling of a dietary tag, so "Gluten-free"
+// becomes gluten_free
+func DietaryTag(tag string) string {
+	return strings.NewReplacer("-", "_", " ", "_").Replace(strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// Validate checks the fields a menu item needs before it is stored
+func (m *MenuItem) Validate() error {
+	fields := FieldErrors{}
+	if m.RestaurantID.IsZero() {
+		fields.Add("restaurant_id", "is required")
+	}
+	if m.SectionID.IsZero() {
+		fields.Add("section_id", "is required")
+	}
+	if strings.TrimSpace(m.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if m.Price < 0 {
+		fields.Add("price", "cannot be negative")
+	}
+	if len(m.Currency) != 3 {
+		fields.Add("currency", "must be a 3-letter code")
+	}
+	for _, tag := range m.Dietary {
+		if !contains(DietaryTags, tag) {
+			fields.Add("dietary", "must be among "+strings.Join(DietaryTags, ", "))
+		}
+	}
+	if m.Position < 0 {
+		fields.Add("position", "cannot be negative")
+	}
+	return fields.Err()
+}
+
+// ConvertPrices converts the price of the item to currency to
+func (m *MenuItem) ConvertPrices(rates ExchangeRates, to string) bool {
+	convert, ok := rates.Converter(m.Currency, to)
+	if !ok {
+		return false
+	}
+	m.Price = convert(m.Price)
+	m.Currency = strings.ToUpper(to)
+	return true
+}
+
+// MenuSummary is what a restaurant's listing shows of its menu. It is
+// derived from the menu items by Restaurant.SetMenu.
+type MenuSummary struct {
+	Items   int      `bson:"items" json:"items"`
+	Dietary []string `bson:"dietary" json:"dietary"` // tags at least one dish has
+	// The prices of the dishes that have one, in Currency: the currency
+	// every dish is priced in, or BaseCurrency when they differ
+	AveragePrice float64 `bson:"average_price" json:"average_price"`
+	MinPrice     float64 `bson:"min_price" json:"min_price"`
+	MaxPrice     float64 `bson:"max_price" json:"max_price"`
+	Currency     string  `bson:"currency,omitempty" json:"currency,omitempty"`
+}
+
+// SetMenu summarizes the restaurant's menu items and derives its
+// PriceRange from them. Dishes in a currency rates cannot convert are left
+// out of the prices. A restaurant without priced dishes keeps the price
+// range it was given, such as an imported one.
+func (r *Restaurant) SetMenu(items []MenuItem, rates ExchangeRates) {
+	summary := MenuSummary{Items: len(items), Dietary: []string{}}
+	for _, item := range items {
+		if summary.Currency == "" {
+			summary.Currency = item.Currency
+		} else if summary.Currency != item.Currency {
+			summary.Currency = BaseCurrency
+		}
+		for _, tag := range item.Dietary {
+			if !contains(summary.Dietary, tag) {
+				summary.Dietary = append(summary.Dietary, tag)
+			}
+		}
+	}
+	sort.Strings(summary.Dietary)
+
+	var total float64
+	priced := 0
+	for _, item := range items {
+		if item.Price <= 0 {
+			continue
+		}
+		price := item.Price
+		if item.Currency != summary.Currency {
+			var ok bool
+			if price, ok = rates.Convert(price, item.Currency, summary.Currency); !ok {
+				continue
+			}
+		}
+		if priced == 0 || price < summary.MinPrice {
+			summary.MinPrice = price
+		}
+		summary.MaxPrice = max(summary.MaxPrice, price)
+		total += price
+		priced++
+	}
+	if priced > 0 {
+		summary.AveragePrice = RoundPrice(summary.Currency, total/float64(priced))
+		summary.MinPrice = RoundPrice(summary.Currency, summary.MinPrice)
+		summary.MaxPrice = RoundPrice(summary.Currency, summary.MaxPrice)
+
+		average, ok := total/float64(priced), true
+		if summary.Currency != BaseCurrency {
+			average, ok = rates.Convert(average, summary.Currency, BaseCurrency)
+		}
+		if ok {
+			r.PriceRange = PriceLevel(average)
+		}
+	}
+	r.Menu = summary
+}
+
+// PriceLevel is the entry of PriceLevels for an average dish price in
+// BaseCurrency
+func PriceLevel(averagePrice float64) string {
+	for i, limit := range priceLevelLimits {
+		if averagePrice < limit {
+			return PriceLevels[i]
+		}
+	}
+	return PriceLevels[len(PriceLevels)-1]
+}
@@ -0,0 +1,343 @@
+package model
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Opening hours are kept in the time zone of the place, which must load
+	// even where the host has no zoneinfo
+	_ "time/tzdata"
+)
+
+// Weekdays are the values of OpeningPeriod.Day, in time.Weekday order
+var Weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// The values of OpenStatus.Category, as in the currentOpenStatusCategory of
+// the upstream data
+const (
+	OpenStatusOpen    = "OPEN"
+	OpenStatusClosing = "CLOSING"
+	OpenStatusClosed  = "CLOSED"
+)
+
+// ClosingSoon is how long before it closes a place shows as closing soon
+const ClosingSoon = time.Hour
+
+// SlotMinutes is the length of the slots the open_at filter matches. A
+// slot counts as open when the place is open at its start.
+const SlotMinutes = 15
+
+const (
+	minutesPerDay = 24 * 60
+	slotsPerDay   = minutesPerDay / SlotMinutes
+	dateLayout    = "2006-01-02"
+	slotLayout    = "2006-01-02T15:04"
+)
+
+// OpeningHours is when a place is open: periods of a week in the local
+// time of TimeZone, and exceptions replacing them on particular dates such
+// as holidays. A day can have several periods, for split shifts.
+type OpeningHours struct {
+	TimeZone   string           `bson:"time_zone" json:"time_zone"` // IANA name, e.g. "Europe/London"
+	Weekly     []OpeningPeriod  `bson:"weekly" json:"weekly"`
+	Exceptions []HoursException `bson:"exceptions" json:"exceptions"`
+
+	// Derived by SetDefaults so stores can match the open_at filter:
+	// the open slots of the week counted from Sunday midnight, the dates
+	// whose hours differ from the week because of an exception, and the
+	// open slots of those dates as "YYYY-MM-DDTHH:MM"
+	WeeklySlots    []int    `bson:"weekly_slots" json:"-"`
+	ExceptionDates []string `bson:"exception_dates" json:"-"`
+	ExceptionSlots []string `bson:"exception_slots" json:"-"`
+}
+
+// TimeRange runs from Opens to Closes, both "HH:MM". When Closes is not
+// after Opens the range ends on the next day, so "18:00"-"02:00" is a late
+// evening and "00:00"-"00:00" a whole day.
+type TimeRange struct {
+	Opens  string `bson:"opens" json:"opens"`
+	Closes string `bson:"closes" json:"closes"` // "24:00" is midnight
+}
+
+// OpeningPeriod is a time range starting on a day of every week
+type OpeningPeriod struct {
+	Day       string `bson:"day" json:"day"`
+	TimeRange `bson:",inline"`
+}
+
+// HoursException replaces the weekly periods starting on Date, a local
+// "YYYY-MM-DD". Without periods the place is closed that day.
+type HoursException struct {
+	Date    string      `bson:"date" json:"date"`
+	Name    string      `bson:"name" json:"name"` // e.g. "Christmas Day"
+	Periods []TimeRange `bson:"periods" json:"periods"`
+}
+
+// OpenStatus tells whether a place is open at an instant, and when that
+// changes
+type OpenStatus struct {
+	Category string     `json:"category"`
+	Text     string     `json:"text"`
+	OpensAt  *time.Time `json:"opens_at,omitempty"`
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+}
+
+// span is a time range in minutes from the local midnight of the day it
+// starts; To passes minutesPerDay when it ends on the next day
+type span struct{ from, to int }
+
+// Known reports whether any hours are set
+func (h *OpeningHours) Known() bool {
+	return len(h.Weekly) > 0 || len(h.Exceptions) > 0
+}
+
+// SetDefaults normalizes the hours and derives the fields the open_at
+// filter matches
+func (h *OpeningHours) SetDefaults() {
+	h.TimeZone = strings.TrimSpace(h.TimeZone)
+	if h.TimeZone == "" {
+		h.TimeZone = "UTC"
+	}
+	for i := range h.Weekly {
+		h.Weekly[i].Day = strings.ToLower(strings.TrimSpace(h.Weekly[i].Day))
+	}
+	sort.SliceStable(h.Exceptions, func(i, j int) bool { return h.Exceptions[i].Date < h.Exceptions[j].Date })
+	h.index()
+}
+
+// validate adds the problems of the hours to fields, under field
+func (h *OpeningHours) validate(fields FieldErrors, field string) {
+	if _, err := time.LoadLocation(h.TimeZone); err != nil {
+		fields.Add(field+".time_zone", "must be an IANA time zone such as Europe/London")
+	}
+	for _, period := range h.Weekly {
+		if !contains(Weekdays, period.Day) {
+			fields.Add(field+".weekly", "must have days from sunday to saturday")
+		}
+		if _, ok := period.span(); !ok {
+			fields.Add(field+".weekly", "must have times in HH:MM format")
+		}
+	}
+	seen := map[string]bool{}
+	for _, exception := range h.Exceptions {
+		if _, err := time.Parse(dateLayout, exception.Date); err != nil {
+			fields.Add(field+".exceptions", "must have dates in YYYY-MM-DD format")
+		} else if seen[exception.Date] {
+			fields.Add(field+".exceptions", "cannot repeat "+exception.Date)
+		}
+		seen[exception.Date] = true
+		for _, period := range exception.Periods {
+			if _, ok := period.span(); !ok {
+				fields.Add(field+".exceptions", "must have times in HH:MM format")
+			}
+		}
+	}
+}
+
+// IsOpenAt reports whether the place is open at the instant at
+func (h *OpeningHours) IsOpenAt(at time.Time) bool {
+	return h.Status(at).Category != OpenStatusClosed
+}
+
+// Status tells whether the place is open at the instant at. Hours that are
+// not Known are reported closed.
+func (h *OpeningHours) Status(at time.Time) OpenStatus {
+	loc := h.location()
+	local := at.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	minute := local.Hour()*60 + local.Minute()
+
+	if closes, ok := h.closesAfter(day, minute); ok {
+		closesAt := wallTime(day, closes, loc)
+		status := OpenStatus{Category: OpenStatusOpen, Text: "Open now", ClosesAt: &closesAt}
+		if closesAt.Sub(at) <= ClosingSoon {
+			status.Category, status.Text = OpenStatusClosing, "Closes soon"
+		}
+		return status
+	}
+
+	status := OpenStatus{Category: OpenStatusClosed, Text: "Closed now"}
+	for offset := 0; offset <= 7; offset++ {
+		date := day.AddDate(0, 0, offset)
+		for _, s := range h.spans(date) {
+			if offset > 0 || s.from > minute {
+				opensAt := wallTime(date, s.from, loc)
+				status.OpensAt = &opensAt
+				return status
+			}
+		}
+	}
+	return status
+}
+
+// LocalTime is the instant the wall-clock time of wall, whatever its
+// location, happens in the time zone of the hours
+func (h *OpeningHours) LocalTime(wall time.Time) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, h.location())
+}
+
+// OpenSlot returns the conditions' view of a local wall-clock time: the
+// weekly slot it falls in, its date and the exception slot it falls in
+func OpenSlot(local time.Time) (weekly int, date, exception string) {
+	minute := local.Hour()*60 + local.Minute()
+	slotStart := time.Date(local.Year(), local.Month(), local.Day(), 0, minute-minute%SlotMinutes, 0, 0, time.UTC)
+	return int(local.Weekday())*slotsPerDay + minute/SlotMinutes, local.Format(dateLayout), slotStart.Format(slotLayout)
+}
+
+// location is the time zone of the hours, UTC when it does not load
+func (h *OpeningHours) location() *time.Location {
+	if loc, err := time.LoadLocation(h.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// spans lists the ranges starting on the local date day, earliest first:
+// those of its exception if it has one, or else of its weekday
+func (h *OpeningHours) spans(day time.Time) []span {
+	var spans []span
+	date := day.Format(dateLayout)
+	if exception, ok := h.exception(date); ok {
+		for _, period := range exception.Periods {
+			if s, ok := period.span(); ok {
+				spans = append(spans, s)
+			}
+		}
+	} else {
+		weekday := Weekdays[day.Weekday()]
+		for _, period := range h.Weekly {
+			if s, ok := period.span(); ok && period.Day == weekday {
+				spans = append(spans, s)
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].from < spans[j].from })
+	return spans
+}
+
+func (h *OpeningHours) exception(date string) (HoursException, bool) {
+	for _, exception := range h.Exceptions {
+		if exception.Date == date {
+			return exception, true
+		}
+	}
+	return HoursException{}, false
+}
+
+// closesAfter reports whether the place is open at minute of the local date
+// day and, if so, when it closes in minutes from that day's midnight.
+// Ranges that follow on without a break count as one.
+func (h *OpeningHours) closesAfter(day time.Time, minute int) (int, bool) {
+	// Ranges of the day before and of the day, in minutes from the day
+	var spans []span
+	for _, s := range h.spans(day.AddDate(0, 0, -1)) {
+		spans = append(spans, span{s.from - minutesPerDay, s.to - minutesPerDay})
+	}
+	spans = append(spans, h.spans(day)...)
+	for _, s := range h.spans(day.AddDate(0, 0, 1)) {
+		spans = append(spans, span{s.from + minutesPerDay, s.to + minutesPerDay})
+	}
+
+	closes, open := minute, false
+	for extended := true; extended; {
+		extended = false
+		for _, s := range spans {
+			if s.from <= closes && closes < s.to && (open || s.from <= minute) {
+				closes, open, extended = s.to, true, true
+			}
+		}
+	}
+	return closes, open
+}
+
+// index derives WeeklySlots, ExceptionDates and ExceptionSlots
+func (h *OpeningHours) index() {
+	open := map[int]bool{}
+	for _, period := range h.Weekly {
+		s, ok := period.span()
+		day := indexOf(Weekdays, period.Day)
+		if !ok || day < 0 {
+			continue
+		}
+		start := day*minutesPerDay + s.from
+		for slot := (start + SlotMinutes - 1) / SlotMinutes; slot*SlotMinutes < start+s.to-s.from; slot++ {
+			open[slot%(7*slotsPerDay)] = true
+		}
+	}
+	h.WeeklySlots = []int{}
+	for slot := range open {
+		h.WeeklySlots = append(h.WeeklySlots, slot)
+	}
+	sort.Ints(h.WeeklySlots)
+
+	// An exception also changes the early hours of the next date, which
+	// ranges of its own date may run into
+	dates := map[string]time.Time{}
+	for _, exception := range h.Exceptions {
+		if day, err := time.Parse(dateLayout, exception.Date); err == nil {
+			dates[exception.Date] = day
+			dates[day.AddDate(0, 0, 1).Format(dateLayout)] = day.AddDate(0, 0, 1)
+		}
+	}
+	h.ExceptionDates, h.ExceptionSlots = []string{}, []string{}
+	for date, day := range dates {
+		h.ExceptionDates = append(h.ExceptionDates, date)
+		for minute := 0; minute < minutesPerDay; minute += SlotMinutes {
+			if _, ok := h.closesAfter(day, minute); ok {
+				h.ExceptionSlots = append(h.ExceptionSlots, day.Add(time.Duration(minute)*time.Minute).Format(slotLayout))
+			}
+		}
+	}
+	sort.Strings(h.ExceptionDates)
+	sort.Strings(h.ExceptionSlots)
+}
+
+// Valid reports whether both times are "HH:MM"
+func (r TimeRange) Valid() bool {
+	_, ok := r.span()
+	return ok
+}
+
+// span converts the range to minutes, reporting false when a time is not
+// "HH:MM"
+func (r TimeRange) span() (span, bool) {
+	from, ok1 := clockMinutes(r.Opens)
+	to, ok2 := clockMinutes(r.Closes)
+	if !ok1 || !ok2 || from == minutesPerDay {
+		return span{}, false
+	}
+	if to <= from {
+		to += minutesPerDay
+	}
+	return span{from, to}, true
+}
+
+// clockMinutes reads "HH:MM", from "00:00" to "24:00", as minutes from
+// midnight
+func clockMinutes(clock string) (int, bool) {
+	if len(clock) != 5 || clock[2] != ':' {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(clock[:2])
+	minutes, err2 := strconv.Atoi(clock[3:])
+	if err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > minutesPerDay {
+		return 0, false
+	}
+	return hours*60 + minutes, true
+}
+
+// wallTime is the instant minutes after the local midnight of day
+func wallTime(day time.Time, minutes int, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, loc)
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
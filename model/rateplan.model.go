@@ -0,0 +1,316 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RatePlan prices the nights of one room type of a hotel. A night costs its
+// NightlyRates entry if it has one; otherwise the rate of the season it
+// falls in, or BaseRate, raised by WeekendUplift on Friday and Saturday
+// nights. Rates cover IncludedAdults in a room; extra adults and children
+// are charged per night on top, and StayFee once per room.
+type RatePlan struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	HotelID               primitive.ObjectID `bson:"hotel_id" json:"hotel_id"`
+	RoomTypeID            string             `bson:"room_type_id" json:"room_type_id"`
+	Name                  string             `bson:"name" json:"name"`
+	Currency              string             `bson:"currency" json:"currency"`
+	BaseRate              float64            `bson:"base_rate" json:"base_rate"`
+	NightlyRates          []NightlyRate      `bson:"nightly_rates" json:"nightly_rates"`
+	Seasons               []Season           `bson:"seasons" json:"seasons"`
+	WeekendUplift         float64            `bson:"weekend_uplift" json:"weekend_uplift"` // percent
+	LengthOfStayDiscounts []StayDiscount     `bson:"length_of_stay_discounts" json:"length_of_stay_discounts"`
+	IncludedAdults        int                `bson:"included_adults" json:"included_adults"`
+	ExtraAdultRate        float64            `bson:"extra_adult_rate" json:"extra_adult_rate"`
+	ChildRates            []ChildRate        `bson:"child_rates" json:"child_rates"`
+	StayFee               float64            `bson:"stay_fee" json:"stay_fee"` // e.g. cleaning, not discounted
+	CreatedAt             time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt             time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// NightlyRate fixes the rate of a single night
+type NightlyRate struct {
+	Date time.Time `bson:"date" json:"date"`
+	Rate float64   `bson:"rate" json:"rate"`
+}
+
+// Season sets the rate of the nights from From to To, inclusive
+type Season struct {
+	Name string    `bson:"name" json:"name"`
+	From time.Time `bson:"from" json:"from"`
+	To   time.Time `bson:"to" json:"to"`
+	Rate float64   `bson:"rate" json:"rate"`
+}
+
+// StayDiscount takes Percent off stays of at least MinNights
+type StayDiscount struct {
+	MinNights int     `bson:"min_nights" json:"min_nights"`
+	Percent   float64 `bson:"percent" json:"percent"`
+}
+
+// ChildRate is the nightly charge for a child up to MaxAge. A child older
+// than every band pays the extra adult rate.
+type ChildRate struct {
+	MaxAge int     `bson:"max_age" json:"max_age"`
+	Rate   float64 `bson:"rate" json:"rate"`
+}
+
+// RatePlanCollection returns the name of the MongoDB collection for rate plans
+func (RatePlan) CollectionName() string {
+	return "ratePlans"
+}
+
+// SetDefaults sets default values for the rate plan
+func (p *RatePlan) SetDefaults() {
+	if p.Currency == "" {
+		p.Currency = "USD"
+	}
+	p.Currency = strings.ToUpper(p.Currency)
+	if p.IncludedAdults == 0 {
+		p.IncludedAdults = 2
+	}
+}
+
+// Validate checks the fields a rate plan needs before it is stored
+func (p *RatePlan) Validate() error {
+	fields := FieldErrors{}
+	if p.HotelID.IsZero() {
+		fields.Add("hotel_id", "is required")
+	}
+	if strings.TrimSpace(p.RoomTypeID) == "" {
+		fields.Add("room_type_id", "is required")
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if len(p.Currency) != 3 {
+		fields.Add("currency", "must be a 3-letter code")
+	}
+	if p.BaseRate <= 0 {
+		fields.Add("base_rate", "must be positive")
+	}
+	for _, night := range p.NightlyRates {
+		if night.Rate <= 0 || !night.Date.Equal(Night(night.Date)) {
+			fields.Add("nightly_rates", "must have positive rates on dates at midnight UTC")
+		}
+	}
+	for _, season := range p.Seasons {
+		if season.Rate <= 0 || season.To.Before(season.From) {
+			fields.Add("seasons", "must have positive rates and end on or after they start")
+		}
+	}
+	if p.WeekendUplift < 0 {
+		fields.Add("weekend_uplift", "cannot be negative")
+	}
+	for _, discount := range p.LengthOfStayDiscounts {
+		if discount.MinNights < 1 || discount.Percent <= 0 || discount.Percent >= 100 {
+			fields.Add("length_of_stay_discounts", "must apply from at least one night and take between 0 and 100 percent off")
+		}
+	}
+	if p.IncludedAdults < 1 {
+		fields.Add("included_adults", "must be at least 1")
+	}
+	if p.ExtraAdultRate < 0 {
+		fields.Add("extra_adult_rate", "cannot be negative")
+	}
+	for _, child := range p.ChildRates {
+		if child.MaxAge < 0 || child.MaxAge > 17 || child.Rate < 0 {
+			fields.Add("child_rates", "must be for ages up to 17 and cannot be negative")
+		}
+	}
+	if p.StayFee < 0 {
+		fields.Add("stay_fee", "cannot be negative")
+	}
+	return fields.Err()
+}
+
+// Quote is the price of a stay under one rate plan
+type Quote struct {
+	RatePlanID         primitive.ObjectID `json:"rate_plan_id"`
+	RatePlan           string             `json:"rate_plan"`
+	RoomTypeID         string             `json:"room_type_id"`
+	Currency           string             `json:"currency"`
+	Nights             []NightPrice       `json:"nights"`
+	Subtotal           float64            `json:"subtotal"`
+	Discount           float64            `json:"discount"`
+	Fees               float64            `json:"fees"`
+	Total              float64            `json:"total"`
+	PriceForDisplay    string             `json:"price_for_display"`             // average per night, without fees
+	StrikethroughPrice string             `json:"strikethrough_price,omitempty"` // average per night before the discount
+	PriceSummary       string             `json:"price_summary"`
+}
+
+// NightPrice is what one night of a stay costs across every room
+type NightPrice struct {
+	Date   time.Time `json:"date"`
+	Rate   float64   `json:"rate"`   // room rates
+	Extras float64   `json:"extras"` // extra adults and children
+	Total  float64   `json:"total"`
+}
+
+// Quote prices a stay from checkIn to checkOut for adults and children
+// spread as evenly as possible over rooms
+func (p *RatePlan) Quote(checkIn, checkOut time.Time, rooms, adults int, childrenAges []int) Quote {
+	rooms = max(rooms, 1)
+	quote := Quote{RatePlanID: p.ID, RatePlan: p.Name, RoomTypeID: p.RoomTypeID, Currency: p.Currency}
+
+	// Occupants beyond what the rate covers pay the same every night
+	var extras float64
+	for room := 0; room < rooms; room++ {
+		inRoom := adults / rooms
+		if room < adults%rooms {
+			inRoom++
+		}
+		extras += float64(max(inRoom-p.IncludedAdults, 0)) * p.ExtraAdultRate
+	}
+	for _, age := range childrenAges {
+		extras += p.childRate(age)
+	}
+
+	round := func(amount float64) float64 { return RoundPrice(p.Currency, amount) }
+	nights := Nights(checkIn, checkOut)
+	for _, date := range nights {
+		night := NightPrice{Date: date, Rate: round(p.rate(date) * float64(rooms)), Extras: round(extras)}
+		night.Total = round(night.Rate + night.Extras)
+		quote.Nights = append(quote.Nights, night)
+		quote.Subtotal += night.Total
+	}
+	quote.Subtotal = round(quote.Subtotal)
+	percent := p.stayDiscount(len(nights))
+	quote.Discount = round(quote.Subtotal * percent / 100)
+	if len(nights) > 0 {
+		quote.Fees = round(p.StayFee * float64(rooms))
+	}
+	quote.Total = round(quote.Subtotal - quote.Discount + quote.Fees)
+
+	if len(nights) == 0 {
+		return quote
+	}
+	perNight := float64(len(nights))
+	quote.PriceForDisplay = FormatPrice(p.Currency, (quote.Subtotal-quote.Discount)/perNight)
+	if quote.Discount > 0 {
+		quote.StrikethroughPrice = FormatPrice(p.Currency, quote.Subtotal/perNight)
+	}
+
+	summary := fmt.Sprintf("%s total for %s, %s, %s", FormatPrice(p.Currency, quote.Total),
+		plural(len(nights), "night"), plural(rooms, "room"), plural(adults, "adult"))
+	if len(childrenAges) > 0 {
+		summary += " and " + plural(len(childrenAges), "child")
+	}
+	if percent > 0 {
+		summary += fmt.Sprintf(" (%s%% off)", trimZeros(fmt.Sprintf("%.2f", percent)))
+	}
+	quote.PriceSummary = summary
+	return quote
+}
+
+// ConvertPrices converts the rates of the plan to currency to
+func (p *RatePlan) ConvertPrices(rates ExchangeRates, to string) bool {
+	convert, ok := rates.Converter(p.Currency, to)
+	if !ok {
+		return false
+	}
+	p.BaseRate = convert(p.BaseRate)
+	for i := range p.NightlyRates {
+		p.NightlyRates[i].Rate = convert(p.NightlyRates[i].Rate)
+	}
+	for i := range p.Seasons {
+		p.Seasons[i].Rate = convert(p.Seasons[i].Rate)
+	}
+	p.ExtraAdultRate = convert(p.ExtraAdultRate)
+	for i := range p.ChildRates {
+		p.ChildRates[i].Rate = convert(p.ChildRates[i].Rate)
+	}
+	p.StayFee = convert(p.StayFee)
+	p.Currency = strings.ToUpper(to)
+	return true
+}
+
+// rate is the price of one room on the night of date
+func (p *RatePlan) rate(date time.Time) float64 {
+	for _, night := range p.NightlyRates {
+		if Night(night.Date).Equal(date) {
+			return night.Rate
+		}
+	}
+	rate := p.BaseRate
+	for _, season := range p.Seasons {
+		if !date.Before(Night(season.From)) && !date.After(Night(season.To)) {
+			rate = season.Rate
+			break
+		}
+	}
+	if weekday := date.Weekday(); weekday == time.Friday || weekday == time.Saturday {
+		rate *= 1 + p.WeekendUplift/100
+	}
+	return rate
+}
+
+// childRate is the nightly charge for a child of age
+func (p *RatePlan) childRate(age int) float64 {
+	bands := append([]ChildRate(nil), p.ChildRates...)
+	sort.Slice(bands, func(i, j int) bool { return bands[i].MaxAge < bands[j].MaxAge })
+	for _, band := range bands {
+		if age <= band.MaxAge {
+			return band.Rate
+		}
+	}
+	return p.ExtraAdultRate
+}
+
+// stayDiscount is the percentage taken off a stay of nights
+func (p *RatePlan) stayDiscount(nights int) float64 {
+	var percent float64
+	for _, discount := range p.LengthOfStayDiscounts {
+		if nights >= discount.MinNights && discount.Percent > percent {
+			percent = discount.Percent
+		}
+	}
+	return percent
+}
+
+var currencySymbols = map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "NGN": "₦", "JPY": "¥"}
+
+// FormatPrice writes amount the way prices are displayed: with the
+// currency's symbol or code, thousands separators, and the minor unit only
+// when it is not zero
+func FormatPrice(currency string, amount float64) string {
+	s := strconv.FormatFloat(math.Abs(RoundPrice(currency, amount)), 'f', MinorUnits(currency), 64)
+	whole, fraction, _ := strings.Cut(s, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if strings.Trim(fraction, "0") != "" {
+		whole += "." + fraction
+	}
+
+	prefix := currency + " "
+	if symbol, ok := currencySymbols[strings.ToUpper(currency)]; ok {
+		prefix = symbol
+	}
+	if amount < 0 {
+		prefix = "-" + prefix
+	}
+	return prefix + whole
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if noun == "child" {
+		return fmt.Sprintf("%d children", n)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func trimZeros(s string) string {
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
@@ -1,23 +1,99 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// RatedEntityTypes are the kinds of entity travelers can rate
+var RatedEntityTypes = []string{"hotel", "restaurant", "vacation_rental"}
+
+// RatingCategories are the parts of a stay or visit SubRatings score
+var RatingCategories = []string{"cleanliness", "service", "location", "value", "rooms"}
+
 // Rating represents the structure for a rating in the database
 type Rating struct {
-	ID        primitive.ObjectID `bson:"_id" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	HotelID   primitive.ObjectID `bson:"hotel_id" json:"hotel_id"`
-	Score     float64            `bson:"score" json:"score"`
-	Comment   string             `bson:"comment" json:"comment"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	HotelID    primitive.ObjectID `bson:"hotel_id" json:"hotel_id"` // set for ratings of hotels only
+	EntityID   primitive.ObjectID `bson:"entity_id" json:"entity_id"`
+	EntityType string             `bson:"entity_type" json:"entity_type"` // one of RatedEntityTypes
+	Score      float64            `bson:"score" json:"score"`
+	SubRatings SubRatings         `bson:"sub_ratings" json:"sub_ratings"`
+	Comment    string             `bson:"comment" json:"comment"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// SubRatings are the optional scores of a rating by category, from 1 to 5.
+// A category left at zero was not rated.
+type SubRatings struct {
+	Cleanliness float64 `bson:"cleanliness,omitempty" json:"cleanliness,omitempty"`
+	Service     float64 `bson:"service,omitempty" json:"service,omitempty"`
+	Location    float64 `bson:"location,omitempty" json:"location,omitempty"`
+	Value       float64 `bson:"value,omitempty" json:"value,omitempty"`
+	Rooms       float64 `bson:"rooms,omitempty" json:"rooms,omitempty"`
+}
+
+// Scores returns the sub-ratings by category
+func (s SubRatings) Scores() map[string]float64 {
+	return map[string]float64{
+		"cleanliness": s.Cleanliness,
+		"service":     s.Service,
+		"location":    s.Location,
+		"value":       s.Value,
+		"rooms":       s.Rooms,
+	}
 }
 
 // RatingCollection returns the name of the MongoDB collection for ratings
 func (Rating) CollectionName() string {
 	return "ratings"
 }
+
+// SetDefaults makes a rating that only names a hotel a rating of that
+// hotel. The hotel_id of a hotel's rating is its entity_id; ratings of
+// anything else have none.
+func (r *Rating) SetDefaults() {
+	r.EntityType = strings.ToLower(strings.TrimSpace(r.EntityType))
+	if r.EntityType == "" && !r.HotelID.IsZero() {
+		r.EntityType = "hotel"
+	}
+	switch {
+	case r.EntityType != "hotel":
+		r.HotelID = primitive.NilObjectID
+	case r.HotelID.IsZero():
+		r.HotelID = r.EntityID
+	default:
+		r.EntityID = r.HotelID
+	}
+}
+
+// Validate checks the fields a rating needs before it is stored
+func (r *Rating) Validate() error {
+	fields := FieldErrors{}
+	if r.UserID.IsZero() {
+		fields.Add("user_id", "is required")
+	}
+	if r.EntityID.IsZero() {
+		fields.Add("entity_id", "is required")
+	}
+	if !contains(RatedEntityTypes, r.EntityType) {
+		fields.Add("entity_type", "must be one of "+strings.Join(RatedEntityTypes, ", "))
+	}
+	if r.Score < 1 || r.Score > 5 {
+		fields.Add("score", "must be between 1 and 5")
+	}
+	for category, score := range r.SubRatings.Scores() {
+		switch {
+		case score == 0:
+		case category == "rooms" && r.EntityType == "restaurant":
+			fields.Add("sub_ratings.rooms", "is not rated for restaurants")
+		case score < 1 || score > 5:
+			fields.Add("sub_ratings."+category, "must be between 1 and 5")
+		}
+	}
+	return fields.Err()
+}
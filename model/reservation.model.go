@@ -0,0 +1,349 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Table reservation statuses. A reservation is confirmed as soon as a table
+// is found for it.
+const (
+	ReservationConfirmed = "confirmed"
+	ReservationCancelled = "cancelled"
+)
+
+// Defaults for the zero values of ReservationRules
+const (
+	DefaultTurnMinutes     = 90
+	DefaultIntervalMinutes = 15
+	DefaultMaxDaysAhead    = 60
+)
+
+// ReservationRules are how a restaurant takes table reservations. A party
+// keeps its table for the turn time, and the restaurant must stay open for
+// all of it. Zero values take the defaults.
+type ReservationRules struct {
+	TurnMinutes     int             `bson:"turn_minutes" json:"turn_minutes"`
+	TurnTimes       []PartyTurnTime `bson:"turn_times" json:"turn_times"`             // for larger parties
+	IntervalMinutes int             `bson:"interval_minutes" json:"interval_minutes"` // between the times offered
+	MinPartySize    int             `bson:"min_party_size" json:"min_party_size"`
+	MaxPartySize    int             `bson:"max_party_size" json:"max_party_size"` // 0 for any party a table fits
+	LeadMinutes     int             `bson:"lead_minutes" json:"lead_minutes"`     // notice needed
+	MaxDaysAhead    int             `bson:"max_days_ahead" json:"max_days_ahead"`
+}
+
+// PartyTurnTime is the turn time of parties of at least MinPartySize
+type PartyTurnTime struct {
+	MinPartySize int `bson:"min_party_size" json:"min_party_size"`
+	Minutes      int `bson:"minutes" json:"minutes"`
+}
+
+// validate adds the problems of the rules to fields, under field
+func (r *ReservationRules) validate(fields FieldErrors, field string) {
+	if r.TurnMinutes != 0 && (r.TurnMinutes < 15 || r.TurnMinutes > 12*60) {
+		fields.Add(field+".turn_minutes", "must be between 15 and 720")
+	}
+	for _, turn := range r.TurnTimes {
+		if turn.MinPartySize < 1 || turn.Minutes < 15 || turn.Minutes > 12*60 {
+			fields.Add(field+".turn_times", "must have party sizes of at least 1 and between 15 and 720 minutes")
+		}
+	}
+	if r.IntervalMinutes != 0 && (r.IntervalMinutes < 5 || r.IntervalMinutes%5 != 0 || 60%r.IntervalMinutes != 0) {
+		fields.Add(field+".interval_minutes", "must be 5, 10, 15, 20, 30 or 60")
+	}
+	if r.MinPartySize < 0 {
+		fields.Add(field+".min_party_size", "cannot be negative")
+	}
+	if r.MaxPartySize < 0 || r.MaxPartySize != 0 && r.MaxPartySize < r.MinPartySize {
+		fields.Add(field+".max_party_size", "must be 0 or at least min_party_size")
+	}
+	if r.LeadMinutes < 0 {
+		fields.Add(field+".lead_minutes", "cannot be negative")
+	}
+	if r.MaxDaysAhead < 0 {
+		fields.Add(field+".max_days_ahead", "cannot be negative")
+	}
+}
+
+// TurnTime is how long a party of partySize keeps its table: the turn time
+// for the largest MinPartySize it reaches, or else TurnMinutes
+func (r *ReservationRules) TurnTime(partySize int) time.Duration {
+	minutes, from := r.TurnMinutes, 0
+	if minutes == 0 {
+		minutes = DefaultTurnMinutes
+	}
+	for _, turn := range r.TurnTimes {
+		if partySize >= turn.MinPartySize && turn.MinPartySize > from {
+			minutes, from = turn.Minutes, turn.MinPartySize
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func (r *ReservationRules) interval() int {
+	if r.IntervalMinutes == 0 {
+		return DefaultIntervalMinutes
+	}
+	return r.IntervalMinutes
+}
+
+func (r *ReservationRules) maxDaysAhead() int {
+	if r.MaxDaysAhead == 0 {
+		return DefaultMaxDaysAhead
+	}
+	return r.MaxDaysAhead
+}
+
+// CheckPartySize reports a field error unless the rules take parties of
+// partySize
+func (r *ReservationRules) CheckPartySize(partySize int) error {
+	switch {
+	case partySize < max(r.MinPartySize, 1):
+		return FieldErrors{"party_size": fmt.Sprintf("must be at least %d", max(r.MinPartySize, 1))}
+	case r.MaxPartySize > 0 && partySize > r.MaxPartySize:
+		return FieldErrors{"party_size": fmt.Sprintf("must be at most %d; larger parties should contact the restaurant", r.MaxPartySize)}
+	}
+	return nil
+}
+
+// CheckSeating reports field errors unless a party of partySize can be
+// seated at startsAt, as seen at now: far enough ahead, on one of the times
+// offered and while the restaurant stays open for the whole turn
+func (r *Restaurant) CheckSeating(startsAt time.Time, partySize int, now time.Time) error {
+	rules, hours := &r.Reservations, &r.OpeningHours
+	if err := rules.CheckPartySize(partySize); err != nil {
+		return err
+	}
+	local := startsAt.In(hours.location())
+	switch {
+	case startsAt.Before(now):
+		return FieldErrors{"time": "cannot be in the past"}
+	case startsAt.Before(now.Add(time.Duration(rules.LeadMinutes) * time.Minute)):
+		return FieldErrors{"time": fmt.Sprintf("must be at least %s from now", plural(rules.LeadMinutes, "minute"))}
+	case startsAt.After(now.AddDate(0, 0, rules.maxDaysAhead())):
+		return FieldErrors{"date": fmt.Sprintf("must be within %s", plural(rules.maxDaysAhead(), "day"))}
+	case local.Minute()%rules.interval() != 0 || local.Second() != 0:
+		return FieldErrors{"time": fmt.Sprintf("must be a multiple of %d minutes past the hour", rules.interval())}
+	}
+	status := hours.Status(startsAt)
+	switch {
+	case status.Category == OpenStatusClosed:
+		return FieldErrors{"time": "the restaurant is closed then"}
+	case status.ClosesAt.Before(startsAt.Add(rules.TurnTime(partySize))):
+		return FieldErrors{"time": "is too close to closing time"}
+	}
+	return nil
+}
+
+// DiningTable is a table of a restaurant. Bookings holds its upcoming
+// reservations, so claiming a time is a single write to the table.
+type DiningTable struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RestaurantID primitive.ObjectID `bson:"restaurant_id" json:"restaurant_id"`
+	Name         string             `bson:"name" json:"name"` // e.g. "12" or "Window booth"
+	Area         string             `bson:"area" json:"area"` // e.g. "terrace"
+	MinSeats     int                `bson:"min_seats" json:"min_seats"`
+	MaxSeats     int                `bson:"max_seats" json:"max_seats"`
+	Bookings     []TableBooking     `bson:"bookings" json:"bookings"` // maintained by the reservations
+	Version      int                `bson:"version" json:"-"`         // changes with every booking
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// TableBooking is the time a reservation keeps a table
+type TableBooking struct {
+	ReservationID primitive.ObjectID `bson:"reservation_id" json:"reservation_id"`
+	StartsAt      time.Time          `bson:"starts_at" json:"starts_at"`
+	EndsAt        time.Time          `bson:"ends_at" json:"ends_at"`
+}
+
+// DiningTableCollection returns the name of the MongoDB collection for dining tables
+func (DiningTable) CollectionName() string {
+	return "diningTables"
+}
+
+// SetDefaults sets default values for the table
+func (t *DiningTable) SetDefaults() {
+	if t.MinSeats == 0 {
+		t.MinSeats = 1
+	}
+	t.Area = strings.ToLower(strings.TrimSpace(t.Area))
+}
+
+// Validate checks the fields a table needs before it is stored
+func (t *DiningTable) Validate() error {
+	fields := FieldErrors{}
+	if t.RestaurantID.IsZero() {
+		fields.Add("restaurant_id", "is required")
+	}
+	if strings.TrimSpace(t.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if t.MaxSeats < 1 {
+		fields.Add("max_seats", "must be at least 1")
+	}
+	if t.MinSeats < 1 || t.MinSeats > t.MaxSeats {
+		fields.Add("min_seats", "must be between 1 and max_seats")
+	}
+	return fields.Err()
+}
+
+// Fits reports whether the table seats a party of partySize
+func (t *DiningTable) Fits(partySize int) bool {
+	return t.MinSeats <= partySize && partySize <= t.MaxSeats
+}
+
+// Free reports whether no booking but that of the reservation except
+// overlaps the time from from to to
+func (t *DiningTable) Free(from, to time.Time, except primitive.ObjectID) bool {
+	for _, booking := range t.Bookings {
+		if booking.ReservationID != except && booking.StartsAt.Before(to) && from.Before(booking.EndsAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// Upcoming reports whether the table has bookings that have not ended at now
+func (t *DiningTable) Upcoming(now time.Time) bool {
+	for _, booking := range t.Bookings {
+		if booking.EndsAt.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Book replaces the booking of the reservation, if any, with booking.
+// Bookings that ended before now are dropped.
+func (t *DiningTable) Book(booking TableBooking, now time.Time) {
+	t.Unbook(booking.ReservationID, now)
+	t.Bookings = append(t.Bookings, booking)
+	sort.Slice(t.Bookings, func(i, j int) bool { return t.Bookings[i].StartsAt.Before(t.Bookings[j].StartsAt) })
+}
+
+// Unbook removes the booking of a reservation, and the bookings that ended
+// before now
+func (t *DiningTable) Unbook(reservationID primitive.ObjectID, now time.Time) {
+	kept := []TableBooking{}
+	for _, booking := range t.Bookings {
+		if booking.ReservationID != reservationID && booking.EndsAt.After(now) {
+			kept = append(kept, booking)
+		}
+	}
+	t.Bookings = kept
+}
+
+// PickTable returns the table to seat a party of partySize at from to to:
+// preferred if it fits and is free, or else the smallest such table. The
+// booking of the reservation except does not count. It reports false when
+// no table is free.
+func PickTable(tables []DiningTable, partySize int, from, to time.Time, except, preferred primitive.ObjectID) (*DiningTable, bool) {
+	var best *DiningTable
+	for i := range tables {
+		table := &tables[i]
+		if !table.Fits(partySize) || !table.Free(from, to, except) {
+			continue
+		}
+		if table.ID == preferred {
+			return table, true
+		}
+		if best == nil || table.MaxSeats < best.MaxSeats || table.MaxSeats == best.MaxSeats && table.Name < best.Name {
+			best = table
+		}
+	}
+	return best, best != nil
+}
+
+// TableSlot is a time a party can be seated at
+type TableSlot struct {
+	Time     string    `json:"time"` // "HH:MM" at the restaurant
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"` // when the table is needed back
+	Tables   int       `json:"tables"`  // free for the party
+}
+
+// TableSlots lists the times of the local date day a party of partySize
+// can be seated at one of tables, as seen at now
+func (r *Restaurant) TableSlots(tables []DiningTable, day time.Time, partySize int, now time.Time) []TableSlot {
+	loc := r.OpeningHours.location()
+	turn := r.Reservations.TurnTime(partySize)
+	slots := []TableSlot{}
+	for minute := 0; minute < minutesPerDay; minute += r.Reservations.interval() {
+		startsAt := wallTime(day, minute, loc)
+		if r.CheckSeating(startsAt, partySize, now) != nil {
+			continue
+		}
+		slot := TableSlot{Time: startsAt.In(loc).Format("15:04"), StartsAt: startsAt, EndsAt: startsAt.Add(turn)}
+		for i := range tables {
+			if tables[i].Fits(partySize) && tables[i].Free(slot.StartsAt, slot.EndsAt, primitive.NilObjectID) {
+				slot.Tables++
+			}
+		}
+		if slot.Tables > 0 {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// TableReservation books a table of a restaurant for a party. Date and
+// Time are the local date and time at the restaurant, StartsAt the same
+// instant; the table is kept until EndsAt, after the turn time.
+type TableReservation struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RestaurantID primitive.ObjectID `bson:"restaurant_id" json:"restaurant_id"`
+	TableID      primitive.ObjectID `bson:"table_id" json:"table_id"`
+	PartySize    int                `bson:"party_size" json:"party_size"`
+	Date         string             `bson:"date" json:"date"` // YYYY-MM-DD
+	Time         string             `bson:"time" json:"time"` // HH:MM
+	StartsAt     time.Time          `bson:"starts_at" json:"starts_at"`
+	EndsAt       time.Time          `bson:"ends_at" json:"ends_at"`
+	GuestName    string             `bson:"guest_name" json:"guest_name"`
+	GuestEmail   string             `bson:"guest_email" json:"guest_email"`
+	GuestPhone   string             `bson:"guest_phone" json:"guest_phone"`
+	Notes        string             `bson:"notes" json:"notes"` // e.g. allergies or a birthday
+	Status       string             `bson:"status" json:"status"`
+	Cancellation *Cancellation      `bson:"cancellation,omitempty" json:"cancellation,omitempty"`
+	Version      int                `bson:"version" json:"-"` // changes with every modification and cancellation
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// TableReservationCollection returns the name of the MongoDB collection for table reservations
+func (TableReservation) CollectionName() string {
+	return "tableReservations"
+}
+
+// Validate checks the fields a table reservation needs before it is stored
+func (r *TableReservation) Validate() error {
+	fields := FieldErrors{}
+	if r.RestaurantID.IsZero() {
+		fields.Add("restaurant_id", "is required")
+	}
+	if r.PartySize < 1 {
+		fields.Add("party_size", "must be at least 1")
+	}
+	if _, err := time.Parse(dateLayout, r.Date); err != nil {
+		fields.Add("date", "must be a date in YYYY-MM-DD format")
+	}
+	if _, ok := clockMinutes(r.Time); !ok {
+		fields.Add("time", "must be a time in HH:MM format")
+	}
+	if strings.TrimSpace(r.GuestName) == "" {
+		fields.Add("guest_name", "is required")
+	}
+	if !strings.Contains(r.GuestEmail, "@") {
+		fields.Add("guest_email", "must be an email address")
+	}
+	return fields.Err()
+}
+
+// Booking is the time the reservation keeps its table
+func (r *TableReservation) Booking() TableBooking {
+	return TableBooking{ReservationID: r.ID, StartsAt: r.StartsAt, EndsAt: r.EndsAt}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -8,23 +9,82 @@ import (
 
 // Restaurant represents the structure for a restaurant in the database
 type Restaurant struct {
-	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
-	Name          string               `bson:"name" json:"name"`
-	Description   string               `bson:"description" json:"description"`
-	Address       string               `bson:"address" json:"address"`
-	Location      string               `bson:"location" json:"location"`
-	Cuisines      []primitive.ObjectID `bson:"cuisines" json:"cuisines"`
-	PriceRange    string               `bson:"price_range" json:"price_range"`
-	Rating        float64              `bson:"rating" json:"rating"`
-	ContactNumber string               `bson:"contact_number" json:"contact_number"`
-	Email         string               `bson:"email" json:"email"`
-	Website       string               `bson:"website" json:"website"`
-	OpeningHours  map[string]string    `bson:"opening_hours" json:"opening_hours"`
-	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
-	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name           string               `bson:"name" json:"name"`
+	Description    string               `bson:"description" json:"description"`
+	Address        string               `bson:"address" json:"address"`
+	Location       string               `bson:"location" json:"location"`
+	Cuisines       []primitive.ObjectID `bson:"cuisines" json:"cuisines"`
+	PriceRange     string               `bson:"price_range" json:"price_range"`
+	Rating         float64              `bson:"rating" json:"rating"`
+	ContactNumber  string               `bson:"contact_number" json:"contact_number"`
+	Email          string               `bson:"email" json:"email"`
+	Website        string               `bson:"website" json:"website"`
+	OpeningHours   OpeningHours         `bson:"opening_hours" json:"opening_hours"`
+	OpenStatus     *OpenStatus          `bson:"-" json:"open_status,omitempty"` // set for responses
+	Reservations   ReservationRules     `bson:"reservations" json:"reservations"`
+	ReviewCount    int                  `bson:"review_count" json:"review_count"`
+	MenuURL        string               `bson:"menu_url" json:"menu_url"`
+	Menu           MenuSummary          `bson:"menu" json:"menu"` // kept in sync with the menu items
+	ReviewSnippets []ReviewSnippet      `bson:"review_snippets" json:"review_snippets"`
+	Offers         []Offer              `bson:"offers" json:"offers"`
+	OwnerID        primitive.ObjectID   `bson:"owner_id,omitempty" json:"-"` // who answers its reviews, set by administrators
+	Source         string               `bson:"source,omitempty" json:"source"`
+	ExternalID     string               `bson:"external_id,omitempty" json:"external_id"`
+	CreatedAt      time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// ReviewSnippet is an excerpt of a review published elsewhere
+type ReviewSnippet struct {
+	Text string `bson:"text" json:"text"`
+	URL  string `bson:"url" json:"url"`
+}
+
+// Offer is a booking or delivery option offered by a partner
+type Offer struct {
+	Type     string `bson:"type" json:"type"` // e.g. "RESERVATION" or "DELIVERY"
+	Provider string `bson:"provider" json:"provider"`
+	Label    string `bson:"label" json:"label"`
+	URL      string `bson:"url" json:"url"`
 }
 
 // RestaurantCollection returns the name of the MongoDB collection for restaurants
 func (Restaurant) CollectionName() string {
 	return "restaurants"
 }
+
+// SetDefaults sets default values for the restaurant
+func (r *Restaurant) SetDefaults() {
+	r.OpeningHours.SetDefaults()
+}
+
+// Validate checks the fields a restaurant needs before it is stored
+func (r *Restaurant) Validate() error {
+	fields := FieldErrors{}
+	if strings.TrimSpace(r.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if r.Rating < 0 || r.Rating > 5 {
+		fields.Add("rating", "must be between 0 and 5")
+	}
+	r.OpeningHours.validate(fields, "opening_hours")
+	r.Reservations.validate(fields, "reservations")
+	return fields.Err()
+}
+
+// ConvertPrices converts the menu prices of the restaurant to currency to
+func (r *Restaurant) ConvertPrices(rates ExchangeRates, to string) bool {
+	if r.Menu.Currency == "" {
+		return false
+	}
+	convert, ok := rates.Converter(r.Menu.Currency, to)
+	if !ok {
+		return false
+	}
+	r.Menu.AveragePrice = convert(r.Menu.AveragePrice)
+	r.Menu.MinPrice = convert(r.Menu.MinPrice)
+	r.Menu.MaxPrice = convert(r.Menu.MaxPrice)
+	r.Menu.Currency = strings.ToUpper(to)
+	return true
+}
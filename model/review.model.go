@@ -1,27 +1,239 @@
 package model
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Moderation statuses of a review. Only published reviews are shown to the
+// public and count towards ratings.
+const (
+	ReviewPending   = "pending"   // waiting for a moderator
+	ReviewPublished = "published" // approved by a moderator
+	ReviewRejected  = "rejected"  // turned down by a moderator
+	ReviewFlagged   = "flagged"   // taken down after reports, waiting for a moderator
+)
+
+// ReviewStatuses are the moderation statuses of a review
+var ReviewStatuses = []string{ReviewPending, ReviewPublished, ReviewRejected, ReviewFlagged}
+
+// ReviewReportsToFlag is how many travelers must report a published review
+// before it is taken down until a moderator looks at it
+const ReviewReportsToFlag = 3
+
+// ErrAlreadyReported is returned when a traveler reports a review twice
+var ErrAlreadyReported = errors.New("the review was already reported by this user")
+
+// ErrOwnReview is returned when a traveler votes on their own review
+var ErrOwnReview = errors.New("travelers cannot vote on their own reviews")
+
+// ErrNoVote is returned when a traveler retracts a vote they did not cast
+var ErrNoVote = errors.New("the user has not voted on this review")
+
+// ErrResponded is returned when an owner responds to a review twice
+var ErrResponded = errors.New("the review already has a response")
+
+// ErrNoResponse is returned when an owner edits a response not yet written
+var ErrNoResponse = errors.New("the review has no response")
+
 // Review represents the structure for a review in the database
 type Review struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	EntityID    primitive.ObjectID `bson:"entity_id" json:"entity_id"`
+	EntityType  string             `bson:"entity_type" json:"entity_type"` // "hotel", "restaurant", etc.
+	Title       string             `bson:"title" json:"title"`
+	Content     string             `bson:"content" json:"content"`
+	Rating      float64            `bson:"rating" json:"rating"`
+	Helpful     int                `bson:"helpful" json:"helpful"`
+	NotHelpful  int                `bson:"not_helpful" json:"not_helpful"`
+	Votes       []ReviewVote       `bson:"votes,omitempty" json:"-"` // who Helpful and NotHelpful count
+	Status      string             `bson:"status" json:"status"`     // one of ReviewStatuses
+	Moderation  *ReviewModeration  `bson:"moderation,omitempty" json:"moderation,omitempty"`
+	Reports     []ReviewReport     `bson:"reports,omitempty" json:"reports,omitempty"`
+	ReportCount int                `bson:"report_count" json:"report_count"`
+	Response    *ReviewResponse    `bson:"response,omitempty" json:"response,omitempty"`
+	Version     int                `bson:"version" json:"-"` // changes with every vote, report, response and decision
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ReviewModeration is the last decision a moderator made about a review
+type ReviewModeration struct {
+	ModeratorID primitive.ObjectID `bson:"moderator_id" json:"moderator_id"`
+	Status      string             `bson:"status" json:"status"` // the status the review was given
+	Reason      string             `bson:"reason" json:"reason"`
+	ModeratedAt time.Time          `bson:"moderated_at" json:"moderated_at"`
+}
+
+// ReviewReport is a traveler's complaint about a review
+type ReviewReport struct {
 	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
-	EntityID   primitive.ObjectID `bson:"entity_id" json:"entity_id"`
-	EntityType string             `bson:"entity_type" json:"entity_type"` // "hotel", "restaurant", etc.
-	Title      string             `bson:"title" json:"title"`
-	Content    string             `bson:"content" json:"content"`
-	Rating     float64            `bson:"rating" json:"rating"`
-	Helpful    int                `bson:"helpful" json:"helpful"`
-	NotHelpful int                `bson:"not_helpful" json:"not_helpful"`
-	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	Reason     string             `bson:"reason" json:"reason"`
+	ReportedAt time.Time          `bson:"reported_at" json:"reported_at"`
+}
+
+// ReviewVote is a traveler's verdict on whether a review helped them
+type ReviewVote struct {
+	UserID  primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Helpful bool               `bson:"helpful" json:"helpful"`
+	VotedAt time.Time          `bson:"voted_at" json:"voted_at"`
+}
+
+// ReviewResponse is the public reply of the owner of the reviewed hotel or
+// restaurant
+type ReviewResponse struct {
+	OwnerID   primitive.ObjectID   `bson:"owner_id" json:"-"` // kept private, like the listing's owner
+	Text      string               `bson:"text" json:"text"`
+	History   []ReviewResponseEdit `bson:"history,omitempty" json:"history,omitempty"` // earlier texts, oldest first
+	CreatedAt time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// ReviewResponseEdit is a text a response had before it was edited
+type ReviewResponseEdit struct {
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"-"`
+	Text      string             `bson:"text" json:"text"`
+	WrittenAt time.Time          `bson:"written_at" json:"written_at"`
 }
 
 // ReviewCollection returns the name of the MongoDB collection for reviews
 func (Review) CollectionName() string {
 	return "reviews"
 }
+
+// Validate checks the fields a review needs before it is stored
+func (r *Review) Validate() error {
+	fields := FieldErrors{}
+	if r.UserID.IsZero() {
+		fields.Add("user_id", "is required")
+	}
+	if r.EntityID.IsZero() {
+		fields.Add("entity_id", "is required")
+	}
+	if strings.TrimSpace(r.EntityType) == "" {
+		fields.Add("entity_type", "is required")
+	}
+	if strings.TrimSpace(r.Title) == "" {
+		fields.Add("title", "is required")
+	}
+	if strings.TrimSpace(r.Content) == "" {
+		fields.Add("content", "is required")
+	}
+	if r.Rating < 1 || r.Rating > 5 {
+		fields.Add("rating", "must be between 1 and 5")
+	}
+	if r.Status != "" && !contains(ReviewStatuses, r.Status) {
+		fields.Add("status", "must be one of "+strings.Join(ReviewStatuses, ", "))
+	}
+	return fields.Err()
+}
+
+// CurrentStatus is the review's status. Reviews written before moderation
+// have none and count as published.
+func (r *Review) CurrentStatus() string {
+	if r.Status == "" {
+		return ReviewPublished
+	}
+	return r.Status
+}
+
+// Published reports whether the public can see the review
+func (r *Review) Published() bool {
+	return r.CurrentStatus() == ReviewPublished
+}
+
+// SameText reports whether other says what the review says, about the same
+// entity
+func (r *Review) SameText(other *Review) bool {
+	return r.EntityType == other.EntityType && r.EntityID == other.EntityID &&
+		r.Title == other.Title && r.Content == other.Content && r.Rating == other.Rating
+}
+
+// AddReport records a traveler's report of the review, flagging a
+// published review once ReviewReportsToFlag travelers reported it
+func (r *Review) AddReport(report ReviewReport) error {
+	for _, existing := range r.Reports {
+		if existing.UserID == report.UserID {
+			return ErrAlreadyReported
+		}
+	}
+	r.Reports = append(r.Reports, report)
+	r.ReportCount = len(r.Reports)
+	if r.ReportCount >= ReviewReportsToFlag && r.Published() {
+		r.Status = ReviewFlagged
+	}
+	r.UpdatedAt = report.ReportedAt
+	return nil
+}
+
+// Moderate gives the review the status a moderator decided on. Publishing
+// a review dismisses the reports against it.
+func (r *Review) Moderate(decision ReviewModeration) {
+	r.Status = decision.Status
+	r.Moderation = &decision
+	if decision.Status == ReviewPublished {
+		r.Reports, r.ReportCount = nil, 0
+	}
+	r.UpdatedAt = decision.ModeratedAt
+}
+
+// Vote counts whether a traveler found the review helpful, replacing the
+// vote they cast before
+func (r *Review) Vote(vote ReviewVote) error {
+	if vote.UserID == r.UserID {
+		return ErrOwnReview
+	}
+	r.Unvote(vote.UserID)
+	r.Votes = append(r.Votes, vote)
+	if vote.Helpful {
+		r.Helpful++
+	} else {
+		r.NotHelpful++
+	}
+	return nil
+}
+
+// Unvote retracts a traveler's vote, or returns ErrNoVote
+func (r *Review) Unvote(userID primitive.ObjectID) error {
+	for i, vote := range r.Votes {
+		if vote.UserID != userID {
+			continue
+		}
+		if vote.Helpful {
+			r.Helpful--
+		} else {
+			r.NotHelpful--
+		}
+		r.Votes = append(r.Votes[:i:i], r.Votes[i+1:]...)
+		return nil
+	}
+	return ErrNoVote
+}
+
+// Respond adds the owner's response, or returns ErrResponded
+func (r *Review) Respond(response ReviewResponse) error {
+	if r.Response != nil {
+		return ErrResponded
+	}
+	response.History = nil
+	r.Response = &response
+	return nil
+}
+
+// EditResponse replaces the text of the owner's response, keeping the one
+// it replaces in its history, or returns ErrNoResponse
+func (r *Review) EditResponse(ownerID primitive.ObjectID, text string, at time.Time) error {
+	if r.Response == nil {
+		return ErrNoResponse
+	}
+	edited := *r.Response
+	edited.History = append(append([]ReviewResponseEdit(nil), edited.History...),
+		ReviewResponseEdit{OwnerID: edited.OwnerID, Text: edited.Text, WrittenAt: edited.UpdatedAt})
+	edited.OwnerID, edited.Text, edited.UpdatedAt = ownerID, text, at
+	r.Response = &edited
+	return nil
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -22,3 +23,24 @@ type Thumbnail struct {
 func (Thumbnail) CollectionName() string {
 	return "thumbnails"
 }
+
+// Validate checks the fields a thumbnail needs before it is stored
+func (t *Thumbnail) Validate() error {
+	fields := FieldErrors{}
+	if strings.TrimSpace(t.URL) == "" {
+		fields.Add("url", "is required")
+	}
+	if t.EntityID.IsZero() {
+		fields.Add("entity_id", "is required")
+	}
+	if strings.TrimSpace(t.EntityType) == "" {
+		fields.Add("entity_type", "is required")
+	}
+	if t.Width < 0 {
+		fields.Add("width", "cannot be negative")
+	}
+	if t.Height < 0 {
+		fields.Add("height", "cannot be negative")
+	}
+	return fields.Err()
+}
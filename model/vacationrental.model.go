@@ -1,30 +1,253 @@
 package model
 
 import (
+	"sort"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// vacationRental represents the structure for a vacationRental in the database
+// PropertyTypes are the kinds of property a vacation rental can be
+var PropertyTypes = []string{
+	"apartment", "house", "villa", "cottage", "cabin", "condo", "loft",
+	"townhouse", "penthouse", "bungalow", "chalet", "guesthouse", "other",
+}
+
+// VacationRental is a whole property let by a host. Price is the nightly
+// rate in Currency before the adjustments in Rates.
 type VacationRental struct {
-	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
-	Name          string               `bson:"name" json:"name"`
-	Description   string               `bson:"description" json:"description"`
-	Address       string               `bson:"address" json:"address"`
-	Location      string               `bson:"location" json:"location"`
-	Cuisines      []primitive.ObjectID `bson:"cuisines" json:"cuisines"`
-	PriceRange    string               `bson:"price_range" json:"price_range"`
-	Rating        float64              `bson:"rating" json:"rating"`
-	ContactNumber string               `bson:"contact_number" json:"contact_number"`
-	Email         string               `bson:"email" json:"email"`
-	Website       string               `bson:"website" json:"website"`
-	OpeningHours  map[string]string    `bson:"opening_hours" json:"opening_hours"`
-	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
-	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name              string             `bson:"name" json:"name"`
+	Description       string             `bson:"description" json:"description"`
+	PropertyType      string             `bson:"property_type" json:"property_type"`
+	Address           string             `bson:"address" json:"address"`
+	Location          string             `bson:"location" json:"location"`
+	Coordinates       []float64          `bson:"coordinates" json:"coordinates"` // [longitude, latitude]
+	Bedrooms          int                `bson:"bedrooms" json:"bedrooms"`
+	Beds              int                `bson:"beds" json:"beds"`
+	Bathrooms         float64            `bson:"bathrooms" json:"bathrooms"` // 1.5 is a bathroom and a toilet
+	MaxGuests         int                `bson:"max_guests" json:"max_guests"`
+	MinimumStay       int                `bson:"minimum_stay" json:"minimum_stay"` // nights
+	Amenities         []string           `bson:"amenities" json:"amenities"`
+	HouseRules        HouseRules         `bson:"house_rules" json:"house_rules"`
+	Host              Host               `bson:"host" json:"host"`
+	Price             float64            `bson:"price" json:"price"`
+	Currency          string             `bson:"currency" json:"currency"`
+	Rates             RentalRates        `bson:"rates" json:"rates"`
+	UnavailableNights []time.Time        `bson:"unavailable_nights" json:"unavailable_nights"` // booked or blocked, at midnight UTC
+	Rating            float64            `bson:"rating" json:"rating"`
+	ReviewCount       int                `bson:"review_count" json:"review_count"`
+	Source            string             `bson:"source,omitempty" json:"source"`
+	ExternalID        string             `bson:"external_id,omitempty" json:"external_id"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// HouseRules are the conditions guests accept when they book
+type HouseRules struct {
+	CheckIn        string   `bson:"check_in" json:"check_in"`   // e.g. "15:00"
+	CheckOut       string   `bson:"check_out" json:"check_out"` // e.g. "11:00"
+	PetsAllowed    bool     `bson:"pets_allowed" json:"pets_allowed"`
+	SmokingAllowed bool     `bson:"smoking_allowed" json:"smoking_allowed"`
+	PartiesAllowed bool     `bson:"parties_allowed" json:"parties_allowed"`
+	QuietHours     string   `bson:"quiet_hours" json:"quiet_hours"`
+	Other          []string `bson:"other" json:"other"`
+}
+
+// Host is the person or company letting a vacation rental
+type Host struct {
+	Name         string     `bson:"name" json:"name"`
+	Email        string     `bson:"email" json:"email"`
+	Phone        string     `bson:"phone" json:"phone"`
+	Website      string     `bson:"website" json:"website"`
+	Superhost    bool       `bson:"superhost" json:"superhost"`
+	Languages    []string   `bson:"languages" json:"languages"`
+	HostingSince *time.Time `bson:"hosting_since,omitempty" json:"hosting_since,omitempty"`
+}
+
+// RentalRates adjust the nightly price of a vacation rental the way a
+// RatePlan does, and add the charges of the stay
+type RentalRates struct {
+	Seasons               []Season       `bson:"seasons" json:"seasons"`
+	WeekendUplift         float64        `bson:"weekend_uplift" json:"weekend_uplift"` // percent
+	LengthOfStayDiscounts []StayDiscount `bson:"length_of_stay_discounts" json:"length_of_stay_discounts"`
+	CleaningFee           float64        `bson:"cleaning_fee" json:"cleaning_fee"`         // once per stay
+	SecurityDeposit       float64        `bson:"security_deposit" json:"security_deposit"` // refundable, not part of the price
 }
 
 // vacationRentalCollection returns the name of the MongoDB collection for vacationRentals
 func (VacationRental) CollectionName() string {
 	return "vacationRentals"
 }
+
+// SetDefaults sets default values for the vacation rental
+func (v *VacationRental) SetDefaults() {
+	v.PropertyType = strings.ToLower(strings.TrimSpace(v.PropertyType))
+	if v.PropertyType == "" {
+		v.PropertyType = "other"
+	}
+	if v.Currency == "" {
+		v.Currency = BaseCurrency
+	}
+	v.Currency = strings.ToUpper(v.Currency)
+	if v.MinimumStay == 0 {
+		v.MinimumStay = 1
+	}
+	v.SetUnavailable(v.UnavailableNights)
+}
+
+// Validate checks the fields a vacation rental needs before it is stored
+func (v *VacationRental) Validate() error {
+	fields := FieldErrors{}
+	if strings.TrimSpace(v.Name) == "" {
+		fields.Add("name", "is required")
+	}
+	if !isPropertyType(v.PropertyType) {
+		fields.Add("property_type", "must be one of "+strings.Join(PropertyTypes, ", "))
+	}
+	if len(v.Coordinates) != 0 && (len(v.Coordinates) != 2 || v.Coordinates[0] < -180 || v.Coordinates[0] > 180 || v.Coordinates[1] < -90 || v.Coordinates[1] > 90) {
+		fields.Add("coordinates", "must be a longitude and a latitude")
+	}
+	if v.Bedrooms < 0 {
+		fields.Add("bedrooms", "cannot be negative")
+	}
+	if v.Beds < 0 {
+		fields.Add("beds", "cannot be negative")
+	}
+	if v.Bathrooms < 0 {
+		fields.Add("bathrooms", "cannot be negative")
+	}
+	if v.MaxGuests < 0 {
+		fields.Add("max_guests", "cannot be negative")
+	}
+	if v.MinimumStay < 1 {
+		fields.Add("minimum_stay", "must be at least 1 night")
+	}
+	if v.Price < 0 {
+		fields.Add("price", "cannot be negative")
+	}
+	if len(v.Currency) != 3 {
+		fields.Add("currency", "must be a 3-letter code")
+	}
+	for _, season := range v.Rates.Seasons {
+		if season.Rate <= 0 || season.To.Before(season.From) {
+			fields.Add("rates.seasons", "must have positive rates and end on or after they start")
+		}
+	}
+	if v.Rates.WeekendUplift < 0 {
+		fields.Add("rates.weekend_uplift", "cannot be negative")
+	}
+	for _, discount := range v.Rates.LengthOfStayDiscounts {
+		if discount.MinNights < 1 || discount.Percent <= 0 || discount.Percent >= 100 {
+			fields.Add("rates.length_of_stay_discounts", "must apply from at least one night and take between 0 and 100 percent off")
+		}
+	}
+	if v.Rates.CleaningFee < 0 {
+		fields.Add("rates.cleaning_fee", "cannot be negative")
+	}
+	if v.Rates.SecurityDeposit < 0 {
+		fields.Add("rates.security_deposit", "cannot be negative")
+	}
+	if v.Rating < 0 || v.Rating > 5 {
+		fields.Add("rating", "must be between 0 and 5")
+	}
+	return fields.Err()
+}
+
+func isPropertyType(s string) bool {
+	for _, propertyType := range PropertyTypes {
+		if s == propertyType {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUnavailable replaces the unavailable nights with nights, moved to
+// midnight UTC, sorted and without duplicates
+func (v *VacationRental) SetUnavailable(nights []time.Time) {
+	seen := map[time.Time]bool{}
+	unique := []time.Time{}
+	for _, night := range nights {
+		if night = Night(night); !seen[night] {
+			seen[night] = true
+			unique = append(unique, night)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].Before(unique[j]) })
+	v.UnavailableNights = unique
+}
+
+// Available reports whether the night of date is free
+func (v *VacationRental) Available(date time.Time) bool {
+	date = Night(date)
+	for _, night := range v.UnavailableNights {
+		if night.Equal(date) {
+			return false
+		}
+	}
+	return true
+}
+
+// StayProblems lists why the rental cannot be booked from checkIn to
+// checkOut for guests, by field. It is empty when the stay is possible.
+func (v *VacationRental) StayProblems(checkIn, checkOut time.Time, guests int) FieldErrors {
+	problems := FieldErrors{}
+	nights := Nights(checkIn, checkOut)
+	if len(nights) < v.MinimumStay {
+		problems.Add("checkOut", "the minimum stay is "+plural(v.MinimumStay, "night"))
+	}
+	if v.MaxGuests > 0 && guests > v.MaxGuests {
+		problems.Add("adults", "the rental sleeps at most "+plural(v.MaxGuests, "guest"))
+	}
+	for _, night := range nights {
+		if !v.Available(night) {
+			problems.Add("checkIn", "some nights of the stay are unavailable")
+			break
+		}
+	}
+	return problems
+}
+
+// ratePlan prices the rental like a room whose rate covers every guest
+func (v *VacationRental) ratePlan() RatePlan {
+	return RatePlan{
+		Name:                  v.Name,
+		Currency:              v.Currency,
+		BaseRate:              v.Price,
+		Seasons:               v.Rates.Seasons,
+		WeekendUplift:         v.Rates.WeekendUplift,
+		LengthOfStayDiscounts: v.Rates.LengthOfStayDiscounts,
+		IncludedAdults:        max(v.MaxGuests, 1),
+		StayFee:               v.Rates.CleaningFee,
+	}
+}
+
+// Quote prices a stay at the rental, cleaning included. Children stay
+// free, as do adults up to MaxGuests.
+func (v *VacationRental) Quote(checkIn, checkOut time.Time, adults int, childrenAges []int) Quote {
+	plan := v.ratePlan()
+	quote := plan.Quote(checkIn, checkOut, 1, adults, childrenAges)
+	quote.RatePlan = ""
+	return quote
+}
+
+// ConvertPrices converts the price and rates of the rental to currency to
+func (v *VacationRental) ConvertPrices(rates ExchangeRates, to string) bool {
+	convert, ok := rates.Converter(v.Currency, to)
+	if !ok {
+		return false
+	}
+	v.Price = convert(v.Price)
+	seasons := make([]Season, len(v.Rates.Seasons))
+	for i, season := range v.Rates.Seasons {
+		season.Rate = convert(season.Rate)
+		seasons[i] = season
+	}
+	v.Rates.Seasons = seasons
+	v.Rates.CleaningFee = convert(v.Rates.CleaningFee)
+	v.Rates.SecurityDeposit = convert(v.Rates.SecurityDeposit)
+	v.Currency = strings.ToUpper(to)
+	return true
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldErrors maps JSON field names to what is wrong with them. It is an
+// error so Validate methods can return it directly.
+type FieldErrors map[string]string
+
+// Add records a problem with field, keeping the first one reported
+func (f FieldErrors) Add(field, message string) {
+	if _, ok := f[field]; !ok {
+		f[field] = message
+	}
+}
+
+// Err returns f as an error, or nil when no field is invalid
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return f
+}
+
+func (f FieldErrors) Error() string {
+	fields := make([]string, 0, len(f))
+	for field := range f {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field + " " + f[field]
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
@@ -0,0 +1,61 @@
+package repository
+
+import "math"
+
+// EarthRadius is the radius in meters MongoDB uses for spherical geometry.
+// Every backend uses it so they agree on distances.
+const EarthRadius = 6378100.0
+
+// Point is a position in degrees
+type Point struct {
+	Lng float64
+	Lat float64
+}
+
+// PointOf reads a [longitude, latitude] pair, the way coordinates are stored
+func PointOf(coordinates []float64) (Point, bool) {
+	if len(coordinates) != 2 {
+		return Point{}, false
+	}
+	return Point{Lng: coordinates[0], Lat: coordinates[1]}, true
+}
+
+// Circle is the value of a Near condition
+type Circle struct {
+	Center Point
+	Radius float64 // meters
+}
+
+// Near matches documents whose [longitude, latitude] field lies within
+// radius meters of center
+func Near(field string, center Point, radius float64) Condition {
+	return Condition{field, OpNear, Circle{Center: center, Radius: radius}}
+}
+
+// ByDistance orders results by their distance from the center of the Near
+// condition on field, nearest first
+func ByDistance(field string) SortField {
+	return SortField{Field: field, Distance: true}
+}
+
+// Distance returns the great-circle distance between two points in meters
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLng := lat2-lat1, radians(b.Lng-a.Lng)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// nearCondition returns the Near condition on field, if q has one
+func nearCondition(conditions []Condition, field string) (Circle, bool) {
+	for _, cond := range conditions {
+		if circle, ok := cond.Value.(Circle); ok && cond.Op == OpNear && cond.Field == field {
+			return circle, true
+		}
+	}
+	return Circle{}, false
+}
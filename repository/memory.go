@@ -0,0 +1,509 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemoryStore returns a Store that keeps every document in process
+// memory. It is safe for concurrent use and is meant for tests and local
+// development.
+func NewMemoryStore() *Store {
+	return &Store{
+		Hotels:          hotelRepository{newMemoryRepository[model.Hotel]()},
+		Reviews:         reviewRepository{newMemoryRepository[model.Review]()},
+		Ratings:         ratingRepository{newMemoryRepository[model.Rating]()},
+		Photos:          newMemoryRepository[model.Photo](),
+		Thumbnails:      newMemoryRepository[model.Thumbnail](),
+		Cuisines:        newMemoryRepository[model.Cuisine](),
+		Restaurants:     newMemoryRepository[model.Restaurant](),
+		VacationRentals: newMemoryRepository[model.VacationRental](),
+		Inventory:       inventoryRepository{newMemoryRepository[model.Inventory]()},
+		Bookings:        bookingRepository{newMemoryRepository[model.Booking]()},
+		RatePlans:       newMemoryRepository[model.RatePlan](),
+		ExchangeRates:   newMemoryRepository[model.ExchangeRate](),
+		CarSuppliers:    newMemoryRepository[model.CarSupplier](),
+		CarLocations:    newMemoryRepository[model.CarLocation](),
+		RentalCars:      newMemoryRepository[model.RentalCar](),
+		MenuSections:    newMemoryRepository[model.MenuSection](),
+		MenuItems:       newMemoryRepository[model.MenuItem](),
+		DiningTables:    diningTableRepository{newMemoryRepository[model.DiningTable]()},
+		Reservations:    tableReservationRepository{newMemoryRepository[model.TableReservation]()},
+	}
+}
+
+// memoryRepository implements Repository for one model. Documents are kept
+// BSON-encoded so callers never share memory with the stored copy, and so
+// queries can be evaluated against the same field names MongoDB uses.
+type memoryRepository[T any] struct {
+	mu    sync.RWMutex
+	docs  map[primitive.ObjectID]bson.Raw
+	order []primitive.ObjectID
+}
+
+func newMemoryRepository[T any]() *memoryRepository[T] {
+	return &memoryRepository[T]{docs: make(map[primitive.ObjectID]bson.Raw)}
+}
+
+func (r *memoryRepository[T]) Create(ctx context.Context, doc *T) error {
+	id, err := ensureID(doc)
+	if err != nil {
+		return err
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.docs[id]; exists {
+		return fmt.Errorf("duplicate key: %s", id.Hex())
+	}
+	r.docs[id] = raw
+	r.order = append(r.order, id)
+	return nil
+}
+
+func (r *memoryRepository[T]) Get(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	r.mu.RLock()
+	raw, ok := r.docs[id]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (r *memoryRepository[T]) List(ctx context.Context, q Query) ([]T, int64, error) {
+	matched, err := r.match(q.Conditions, q.Text)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(q.Sort) > 0 {
+		sortDocuments(matched, q.Sort, q.Conditions)
+	}
+
+	total := int64(len(matched))
+	start := min(q.Skip, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+
+	docs := make([]T, 0, end-start)
+	for _, m := range matched[start:end] {
+		var doc T
+		if err := bson.Unmarshal(m.raw, &doc); err != nil {
+			return nil, 0, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, total, nil
+}
+
+func (r *memoryRepository[T]) Update(ctx context.Context, doc *T) error {
+	id, err := documentID(doc)
+	if err != nil {
+		return err
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.docs[id]; !ok {
+		return ErrNotFound
+	}
+	r.docs[id] = raw
+	return nil
+}
+
+func (r *memoryRepository[T]) replaceIf(ctx context.Context, doc *T, conditions []Condition) (bool, error) {
+	id, err := documentID(doc)
+	if err != nil {
+		return false, err
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.docs[id]
+	if !ok {
+		return false, nil
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(stored, &fields); err != nil {
+		return false, err
+	}
+	if !matchesAll(fields, conditions) {
+		return false, nil
+	}
+	r.docs[id] = raw
+	return true, nil
+}
+
+func (r *memoryRepository[T]) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.docs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.docs, id)
+	for i, existing := range r.order {
+		if existing == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository[T]) average(ctx context.Context, conditions []Condition, field string) (Average, error) {
+	matched, err := r.match(conditions, "")
+	if err != nil {
+		return Average{}, err
+	}
+
+	var result Average
+	var sum float64
+	for _, m := range matched {
+		value, ok := lookup(m.fields, field)
+		if n, isNumber := toFloat(value); ok && isNumber {
+			sum += n
+			result.Count++
+		}
+	}
+	if result.Count > 0 {
+		result.Value = sum / float64(result.Count)
+	}
+	return result, nil
+}
+
+func (r *memoryRepository[T]) aggregate(ctx context.Context, pipeline []bson.M) ([]bson.M, error) {
+	conditions, pipeline, err := splitMatch(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	matched, err := r.match(conditions, "")
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]bson.M, len(matched))
+	for i, m := range matched {
+		docs[i] = m.fields
+	}
+	return runPipeline(docs, pipeline)
+}
+
+// memoryDocument is a stored document together with its decoded fields
+type memoryDocument struct {
+	raw    bson.Raw
+	fields bson.M
+}
+
+// match returns the stored documents satisfying every condition and the
+// text search, in insertion order.
+func (r *memoryRepository[T]) match(conditions []Condition, text string) ([]memoryDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []memoryDocument
+	for _, id := range r.order {
+		raw := r.docs[id]
+		var fields bson.M
+		if err := bson.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		if matchesAll(fields, conditions) && matchesText(fields, text) {
+			matched = append(matched, memoryDocument{raw: raw, fields: fields})
+		}
+	}
+	return matched, nil
+}
+
+func matchesAll(fields bson.M, conditions []Condition) bool {
+	for _, cond := range conditions {
+		if cond.Op == OpOr {
+			if !matchesAny(fields, cond) {
+				return false
+			}
+			continue
+		}
+		value, found := lookup(fields, cond.Field)
+		if !matchesCondition(value, found, cond) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether fields satisfy one of the alternatives of an
+// Or condition
+func matchesAny(fields bson.M, cond Condition) bool {
+	alternatives, _ := cond.Value.([][]Condition)
+	for _, conditions := range alternatives {
+		if matchesAll(fields, conditions) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesCondition(value interface{}, found bool, cond Condition) bool {
+	switch cond.Op {
+	case OpNe:
+		return !matchesCondition(value, found, Condition{cond.Field, OpEq, cond.Value})
+	case OpNin:
+		return !matchesCondition(value, found, Condition{cond.Field, OpIn, cond.Value})
+	}
+	if !found {
+		return cond.Op == OpEq && cond.Value == nil
+	}
+	if cond.Op == OpNear {
+		circle, _ := cond.Value.(Circle)
+		distance, ok := distanceFrom(value, circle.Center)
+		return ok && distance <= circle.Radius
+	}
+
+	// Like MongoDB, a condition on an array matches if any element matches
+	if values, isArray := value.(bson.A); isArray {
+		for _, element := range values {
+			if matchesCondition(element, true, cond) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch cond.Op {
+	case OpEq:
+		c, ok := compare(value, cond.Value)
+		return ok && c == 0
+	case OpGt, OpGte, OpLt, OpLte:
+		c, ok := compare(value, cond.Value)
+		if !ok {
+			return false
+		}
+		switch cond.Op {
+		case OpGt:
+			return c > 0
+		case OpGte:
+			return c >= 0
+		case OpLt:
+			return c < 0
+		default:
+			return c <= 0
+		}
+	case OpIn:
+		candidates, _ := cond.Value.([]interface{})
+		for _, candidate := range candidates {
+			if c, ok := compare(value, candidate); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	case OpContains:
+		s, isString := value.(string)
+		substr, _ := cond.Value.(string)
+		return isString && strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+	}
+	return false
+}
+
+// matchesText approximates a MongoDB $text search: a document matches when
+// any top-level string field contains any of the search terms.
+func matchesText(fields bson.M, text string) bool {
+	terms := strings.Fields(strings.ToLower(text))
+	if len(terms) == 0 {
+		return true
+	}
+	for _, value := range fields {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		s = strings.ToLower(s)
+		for _, term := range terms {
+			if strings.Contains(s, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookup resolves a dotted field path. Paths that cross an array of
+// sub-documents yield an array of the nested values.
+func lookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+	key, rest, _ := strings.Cut(path, ".")
+
+	switch v := value.(type) {
+	case bson.M:
+		next, ok := v[key]
+		if !ok {
+			return nil, false
+		}
+		return lookup(next, rest)
+	case bson.D:
+		return lookup(v.Map(), path)
+	case bson.A:
+		var values bson.A
+		for _, element := range v {
+			if nested, ok := lookup(element, path); ok {
+				values = append(values, nested)
+			}
+		}
+		return values, len(values) > 0
+	}
+	return nil, false
+}
+
+func sortDocuments(docs []memoryDocument, fields []SortField, conditions []Condition) {
+	key := func(doc memoryDocument, field SortField) interface{} {
+		value, _ := lookup(doc.fields, field.Field)
+		if !field.Distance {
+			return value
+		}
+		circle, _ := nearCondition(conditions, field.Field)
+		if distance, ok := distanceFrom(value, circle.Center); ok {
+			return distance
+		}
+		return math.Inf(1)
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, field := range fields {
+			c, ok := compare(key(docs[i], field), key(docs[j], field))
+			if !ok || c == 0 {
+				continue
+			}
+			if field.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// compare orders two scalar values. Numbers compare across integer and float
+// types, times compare with BSON datetimes, and ok is false for values of
+// unrelated types.
+func compare(a, b interface{}) (c int, ok bool) {
+	if x, isNumber := toFloat(a); isNumber {
+		y, isNumber := toFloat(b)
+		if !isNumber {
+			return 0, false
+		}
+		return threeWay(x < y, x > y), true
+	}
+
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		return threeWay(!x && y, x && !y), true
+	case primitive.ObjectID:
+		y, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x.Hex(), y.Hex()), true
+	case primitive.DateTime, time.Time:
+		x1, ok1 := toTime(a)
+		y1, ok2 := toTime(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return x1.Compare(y1), true
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// distanceFrom returns the distance in meters from center to a stored
+// [longitude, latitude] pair
+func distanceFrom(value interface{}, center Point) (float64, bool) {
+	pair, ok := value.(bson.A)
+	if !ok || len(pair) != 2 {
+		return 0, false
+	}
+	lng, ok1 := toFloat(pair[0])
+	lat, ok2 := toFloat(pair[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return Distance(center, Point{Lng: lng, Lat: lat}), true
+}
+
+func threeWay(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toTime(value interface{}) (time.Time, bool) {
+	switch t := value.(type) {
+	case time.Time:
+		// Stored times have millisecond precision, so compare at that precision
+		return t.Truncate(time.Millisecond), true
+	case primitive.DateTime:
+		return t.Time(), true
+	}
+	return time.Time{}, false
+}
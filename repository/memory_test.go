@@ -0,0 +1,564 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func seedHotels(t *testing.T, repo HotelRepository) []model.Hotel {
+	t.Helper()
+	hotels := []model.Hotel{
+		{Title: "Harbour View", Provider: "Expedia", Price: 120, Rating: 4.5, Location: model.Location{City: "Lagos", Country: "NG"}, Amenities: []string{"pool", "wifi"}},
+		{Title: "City Lodge", Provider: "Booking.com", Price: 80, Rating: 3.9, Location: model.Location{City: "Abuja", Country: "NG"}, Amenities: []string{"wifi"}},
+		{Title: "Palm Suites", Provider: "expedia", Price: 200, Rating: 4.8, Location: model.Location{City: "Lagos", Country: "NG"}},
+	}
+	for i := range hotels {
+		if err := repo.Create(context.Background(), &hotels[i]); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if hotels[i].ID.IsZero() {
+			t.Fatalf("create did not assign an ID")
+		}
+	}
+	return hotels
+}
+
+func TestMemoryRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Hotels
+	hotels := seedHotels(t, repo)
+
+	got, err := repo.Get(ctx, hotels[0].ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Title != "Harbour View" || got.Location.City != "Lagos" {
+		t.Errorf("unexpected hotel: %+v", got)
+	}
+
+	// The stored copy must not change when the caller mutates its value
+	got.Amenities[0] = "spa"
+	again, _ := repo.Get(ctx, hotels[0].ID)
+	if again.Amenities[0] != "pool" {
+		t.Errorf("stored document was mutated through a returned value")
+	}
+
+	got.Title = "Harbour View Hotel"
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	again, _ = repo.Get(ctx, hotels[0].ID)
+	if again.Title != "Harbour View Hotel" {
+		t.Errorf("update not applied: %q", again.Title)
+	}
+
+	if err := repo.Delete(ctx, hotels[0].ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, hotels[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := repo.Delete(ctx, hotels[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+	missing := model.Hotel{ID: primitive.NewObjectID()}
+	if err := repo.Update(ctx, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating a missing hotel, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryQuery(t *testing.T) {
+	repo := NewMemoryStore().Hotels
+	seedHotels(t, repo)
+	testQueries(t, repo)
+}
+
+// testQueries runs queries every backend must answer alike against the
+// hotels of seedHotels
+func testQueries(t *testing.T, repo HotelRepository) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		query  Query
+		titles []string
+		total  int64
+	}{
+		{
+			name:   "nested field",
+			query:  Query{Conditions: []Condition{Eq("location.city", "Lagos")}},
+			titles: []string{"Harbour View", "Palm Suites"},
+			total:  2,
+		},
+		{
+			name:   "numeric range across int and float",
+			query:  Query{Conditions: []Condition{Gte("price", 100), Lte("price", 150.0)}},
+			titles: []string{"Harbour View"},
+			total:  1,
+		},
+		{
+			name:   "case-insensitive contains",
+			query:  Query{Conditions: []Condition{Contains("provider", "EXPEDIA")}},
+			titles: []string{"Harbour View", "Palm Suites"},
+			total:  2,
+		},
+		{
+			name:   "array element",
+			query:  Query{Conditions: []Condition{Eq("amenities", "wifi")}},
+			titles: []string{"Harbour View", "City Lodge"},
+			total:  2,
+		},
+		{
+			name:   "in",
+			query:  Query{Conditions: []Condition{In("location.city", "Abuja", "Kano")}},
+			titles: []string{"City Lodge"},
+			total:  1,
+		},
+		{
+			name:   "not in",
+			query:  Query{Conditions: []Condition{NotIn("location.city", "Abuja", "Kano")}},
+			titles: []string{"Harbour View", "Palm Suites"},
+			total:  2,
+		},
+		{
+			name: "or",
+			query: Query{Conditions: []Condition{
+				Contains("provider", "expedia"),
+				Or([]Condition{Eq("location.city", "Abuja")}, []Condition{Gte("price", 150), Eq("location.country", "NG")}),
+			}},
+			titles: []string{"Palm Suites"},
+			total:  1,
+		},
+		{
+			name:   "sorted and paged",
+			query:  Query{Sort: []SortField{Desc("rating")}}.Page(1, 2),
+			titles: []string{"Palm Suites", "Harbour View"},
+			total:  3,
+		},
+		{
+			name:   "second page",
+			query:  Query{Sort: []SortField{Desc("rating")}}.Page(2, 2),
+			titles: []string{"City Lodge"},
+			total:  3,
+		},
+		{
+			name:   "text search",
+			query:  Query{Text: "palm lodge"},
+			titles: []string{"City Lodge", "Palm Suites"},
+			total:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hotels, total, err := repo.List(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if total != tt.total {
+				t.Errorf("expected total %d, got %d", tt.total, total)
+			}
+			if len(hotels) != len(tt.titles) {
+				t.Fatalf("expected %d hotels, got %d", len(tt.titles), len(hotels))
+			}
+			for i, hotel := range hotels {
+				if hotel.Title != tt.titles[i] {
+					t.Errorf("position %d: expected %q, got %q", i, tt.titles[i], hotel.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryAggregate(t *testing.T) {
+	repo := NewMemoryStore().Hotels
+	seedHotels(t, repo)
+	testAggregation(t, repo)
+}
+
+// testAggregation runs pipelines, decoded from JSON as the API receives
+// them, over the hotels of seedHotels
+func testAggregation(t *testing.T, repo HotelRepository) {
+	t.Helper()
+	ctx := context.Background()
+	var pipeline []bson.M
+	if err := json.Unmarshal([]byte(`[
+		{"$match": {"location.country": "NG", "price": {"$gte": 50}}},
+		{"$group": {"_id": "$location.city", "hotels": {"$sum": 1}, "average_price": {"$avg": "$price"}, "best": {"$max": "$rating"}}},
+		{"$sort": {"_id": 1}}
+	]`), &pipeline); err != nil {
+		t.Fatal(err)
+	}
+	results, err := repo.Aggregate(ctx, pipeline)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	expected := []bson.M{
+		{"_id": "Abuja", "hotels": int64(1), "average_price": 80.0, "best": 3.9},
+		{"_id": "Lagos", "hotels": int64(2), "average_price": 160.0, "best": 4.8},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+
+	pipeline = nil
+	if err := json.Unmarshal([]byte(`[
+		{"$unwind": "$amenities"},
+		{"$group": {"_id": "$amenities", "hotels": {"$push": "$title"}}},
+		{"$sort": {"_id": -1}},
+		{"$limit": 1},
+		{"$project": {"_id": 0, "amenity": "$_id", "hotels": 1}}
+	]`), &pipeline); err != nil {
+		t.Fatal(err)
+	}
+	results, err = repo.Aggregate(ctx, pipeline)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	expected = []bson.M{{"amenity": "wifi", "hotels": bson.A{"Harbour View", "City Lodge"}}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+
+	_, err = repo.Aggregate(ctx, []bson.M{{"$lookup": bson.M{"from": "reviews"}}})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for an unknown stage, got %v", err)
+	}
+}
+
+func TestMemoryRatingAverage(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Ratings
+	hotelID := primitive.NewObjectID()
+
+	for _, score := range []float64{4, 5, 3} {
+		if err := repo.Create(ctx, &model.Rating{HotelID: hotelID, Score: score}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if err := repo.Create(ctx, &model.Rating{HotelID: primitive.NewObjectID(), Score: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	avg, err := repo.AverageScore(ctx, hotelID)
+	if err != nil {
+		t.Fatalf("average: %v", err)
+	}
+	if avg.Count != 3 || avg.Value != 4 {
+		t.Errorf("expected average 4 over 3 ratings, got %+v", avg)
+	}
+}
+
+func TestMemoryRepositoryConcurrentCreate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Cuisines
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Create(ctx, &model.Cuisine{Name: "Jollof"}); err != nil {
+				t.Errorf("create: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, total, err := repo.List(ctx, Query{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 50 {
+		t.Errorf("expected 50 cuisines, got %d", total)
+	}
+}
+
+func TestMemoryNearSortsByDistance(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Hotels
+	for _, hotel := range []model.Hotel{
+		{Title: "Far", Location: model.Location{Coordinates: []float64{3.60, 6.45}}},
+		{Title: "Near", Location: model.Location{Coordinates: []float64{3.39, 6.45}}},
+		{Title: "Out of range", Location: model.Location{Coordinates: []float64{7.49, 9.05}}},
+		{Title: "Unplaced"},
+	} {
+		if err := repo.Create(ctx, &hotel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	center := Point{Lng: 3.38, Lat: 6.45}
+	hotels, total, err := repo.List(ctx, Query{
+		Conditions: []Condition{Near("location.coordinates", center, 50000)},
+		Sort:       []SortField{ByDistance("location.coordinates")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || hotels[0].Title != "Near" || hotels[1].Title != "Far" {
+		t.Errorf("expected Near then Far, got %+v", hotels)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Lagos to Abuja is about 534 km as the crow flies
+	d := Distance(Point{Lng: 3.3792, Lat: 6.5244}, Point{Lng: 7.4951, Lat: 9.0579})
+	if d < 530000 || d > 540000 {
+		t.Errorf("unexpected distance %v", d)
+	}
+}
+
+func TestMemoryInventoryReserveNeverOversells(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Inventory
+	hotelID := primitive.NewObjectID()
+	checkIn := time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+	checkOut := checkIn.AddDate(0, 0, 2)
+	for _, date := range model.Nights(checkIn, checkOut) {
+		if err := repo.Create(ctx, &model.Inventory{HotelID: hotelID, RoomTypeID: "double", Date: date, Units: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.Reserve(ctx, hotelID, "double", checkIn, checkOut, 1)
+			switch {
+			case err == nil:
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			case !errors.Is(err, ErrUnavailable) && !errors.Is(err, ErrConflict):
+				t.Errorf("reserve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	nights, err := repo.Nights(ctx, hotelID, "double", checkIn, checkOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, night := range nights {
+		if night.Sold != reserved || night.Sold > night.Units {
+			t.Errorf("%s: sold %d of %d units with %d reservations", night.Date.Format("2006-01-02"), night.Sold, night.Units, reserved)
+		}
+	}
+	if reserved == 0 {
+		t.Fatal("expected some reservations to succeed")
+	}
+
+	if err := repo.Release(ctx, hotelID, "double", checkIn, checkOut, reserved); err != nil {
+		t.Fatal(err)
+	}
+	// A stay running past the inventory takes nothing
+	if err := repo.Reserve(ctx, hotelID, "double", checkIn, checkOut.AddDate(0, 0, 1), 1); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+	nights, _ = repo.Nights(ctx, hotelID, "double", checkIn, checkOut)
+	for _, night := range nights {
+		if night.Sold != 0 {
+			t.Errorf("%s: expected every unit back, %d still sold", night.Date.Format("2006-01-02"), night.Sold)
+		}
+	}
+}
+
+// intercepted runs before on every conditional write of a document
+type intercepted[T any] struct {
+	backend[T]
+	before func(doc *T) error
+}
+
+func (b intercepted[T]) replaceIf(ctx context.Context, doc *T, conditions []Condition) (bool, error) {
+	if err := b.before(doc); err != nil {
+		return false, err
+	}
+	return b.backend.replaceIf(ctx, doc, conditions)
+}
+
+func TestMemoryInventorySellSeesCalendarChanges(t *testing.T) {
+	ctx := context.Background()
+	stored := newMemoryRepository[model.Inventory]()
+	night := model.Inventory{HotelID: primitive.NewObjectID(), RoomTypeID: "double", Date: time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC), Units: 1}
+	if err := stored.Create(ctx, &night); err != nil {
+		t.Fatal(err)
+	}
+
+	// The night is closed while the sale is between reading and writing it
+	closed := false
+	repo := inventoryRepository{intercepted[model.Inventory]{stored, func(*model.Inventory) error {
+		if !closed {
+			closed = true
+			update := night
+			update.Units = 0
+			return inventoryRepository{stored}.UpdateCalendar(ctx, &update)
+		}
+		return nil
+	}}}
+	err := repo.Reserve(ctx, night.HotelID, "double", night.Date, night.Date.AddDate(0, 0, 1), 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+	got, _ := stored.Get(ctx, night.ID)
+	if got.Units != 0 || got.Sold != 0 {
+		t.Errorf("expected the closed night to stay unsold, got %d of %d units sold", got.Sold, got.Units)
+	}
+}
+
+func TestMemoryInventoryReserveReportsFailedRollback(t *testing.T) {
+	ctx := context.Background()
+	stored := newMemoryRepository[model.Inventory]()
+	hotelID := primitive.NewObjectID()
+	checkIn := time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+	nights := model.Nights(checkIn, checkIn.AddDate(0, 0, 3))
+	for i, date := range nights {
+		units := 2
+		if i == 2 {
+			units = 0 // sold out
+		}
+		if err := stored.Create(ctx, &model.Inventory{HotelID: hotelID, RoomTypeID: "double", Date: date, Units: units}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Giving back the first night fails
+	errDown := errors.New("database is down")
+	repo := inventoryRepository{intercepted[model.Inventory]{stored, func(night *model.Inventory) error {
+		if night.Sold == 0 && night.Date.Equal(nights[0]) {
+			return errDown
+		}
+		return nil
+	}}}
+	err := repo.Reserve(ctx, hotelID, "double", checkIn, checkIn.AddDate(0, 0, 3), 1)
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, ErrNotReleased) || !errors.Is(err, errDown) {
+		t.Errorf("expected ErrUnavailable along with the failed rollback, got %v", err)
+	}
+
+	got, err := inventoryRepository{stored}.Nights(ctx, hotelID, "double", checkIn, checkIn.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{1, 0, 0} {
+		if got[i].Sold != want {
+			t.Errorf("night %d: expected %d sold, got %d", i+1, want, got[i].Sold)
+		}
+	}
+}
+
+func TestMemoryReviewChangeSeesVotesInBetween(t *testing.T) {
+	ctx := context.Background()
+	stored := newMemoryRepository[model.Review]()
+	first, second, last := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	review := model.Review{UserID: primitive.NewObjectID(), Status: model.ReviewPublished}
+	if err := stored.Create(ctx, &review); err != nil {
+		t.Fatal(err)
+	}
+	direct := reviewRepository{stored}
+	if _, err := direct.Vote(ctx, review.ID, model.ReviewVote{UserID: first, Helpful: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the last vote is being written, the first traveler takes theirs
+	// back and another casts the same vote, which leaves the counts as read
+	swapped := false
+	repo := reviewRepository{intercepted[model.Review]{stored, func(*model.Review) error {
+		if !swapped {
+			swapped = true
+			if _, err := direct.Unvote(ctx, review.ID, first); err != nil {
+				return err
+			}
+			_, err := direct.Vote(ctx, review.ID, model.ReviewVote{UserID: second, Helpful: true})
+			return err
+		}
+		return nil
+	}}}
+	if _, err := repo.Vote(ctx, review.ID, model.ReviewVote{UserID: last, Helpful: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := stored.Get(ctx, review.ID)
+	var voters []string
+	for _, vote := range got.Votes {
+		voters = append(voters, vote.UserID.Hex())
+	}
+	if got.Helpful != 2 || len(voters) != 2 || voters[0] != second.Hex() || voters[1] != last.Hex() {
+		t.Errorf("expected the second and last votes, got %d helpful from %v", got.Helpful, voters)
+	}
+	if got.Version != 4 {
+		t.Errorf("expected version 4 after four changes, got %d", got.Version)
+	}
+}
+
+func TestMemoryReviewUpdateSeesChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := reviewRepository{newMemoryRepository[model.Review]()}
+	review := model.Review{UserID: primitive.NewObjectID(), Title: "Lovely", Status: model.ReviewPublished}
+	if err := repo.Create(ctx, &review); err != nil {
+		t.Fatal(err)
+	}
+
+	// The author's edit was read before the vote
+	edit := review
+	edit.Title = "Lovely view"
+	if _, err := repo.Vote(ctx, review.ID, model.ReviewVote{UserID: primitive.NewObjectID(), Helpful: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, &edit); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	got, _ := repo.Get(ctx, review.ID)
+	if got.Title != "Lovely" || got.Helpful != 1 {
+		t.Errorf("expected the vote to be kept, got %q with %d helpful", got.Title, got.Helpful)
+	}
+
+	// Read again, the edit goes through
+	got.Title = "Lovely view"
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.Get(ctx, review.ID); got.Title != "Lovely view" || got.Helpful != 1 || got.Version != 2 {
+		t.Errorf("expected the edit on top of the vote, got %+v", got)
+	}
+}
+
+func TestMemoryHotelEditSeesEditsInBetween(t *testing.T) {
+	ctx := context.Background()
+	stored := newMemoryRepository[model.Hotel]()
+	hotel := model.Hotel{Title: "Harbour View", Price: 120}
+	if err := stored.Create(ctx, &hotel); err != nil {
+		t.Fatal(err)
+	}
+
+	// An owner is named while the price change is between reading and
+	// writing the hotel, leaving the rating as read
+	owner := primitive.NewObjectID()
+	named := false
+	repo := hotelRepository{intercepted[model.Hotel]{stored, func(*model.Hotel) error {
+		if !named {
+			named = true
+			_, err := hotelRepository{stored}.Edit(ctx, hotel.ID, func(hotel *model.Hotel) { hotel.OwnerID = owner })
+			return err
+		}
+		return nil
+	}}}
+	if _, err := repo.Edit(ctx, hotel.ID, func(hotel *model.Hotel) { hotel.Price = 140 }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := stored.Get(ctx, hotel.ID); got.Price != 140 || got.OwnerID != owner || got.Version != 2 {
+		t.Errorf("expected both edits to be kept, got price %v, owner %v, version %d", got.Price, got.OwnerID, got.Version)
+	}
+}
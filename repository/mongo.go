@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoStore returns a Store backed by the collections of db
+func NewMongoStore(db *mongo.Database) *Store {
+	return &Store{
+		Hotels:          hotelRepository{newMongoRepository[model.Hotel](db.Collection(model.Hotel{}.CollectionName()))},
+		Reviews:         reviewRepository{newMongoRepository[model.Review](db.Collection(model.Review{}.CollectionName()))},
+		Ratings:         ratingRepository{newMongoRepository[model.Rating](db.Collection(model.Rating{}.CollectionName()))},
+		Photos:          newMongoRepository[model.Photo](db.Collection(model.Photo{}.CollectionName())),
+		Thumbnails:      newMongoRepository[model.Thumbnail](db.Collection(model.Thumbnail{}.CollectionName())),
+		Cuisines:        newMongoRepository[model.Cuisine](db.Collection(model.Cuisine{}.CollectionName())),
+		Restaurants:     newMongoRepository[model.Restaurant](db.Collection(model.Restaurant{}.CollectionName())),
+		VacationRentals: newMongoRepository[model.VacationRental](db.Collection(model.VacationRental{}.CollectionName())),
+		Inventory:       inventoryRepository{newMongoRepository[model.Inventory](db.Collection(model.Inventory{}.CollectionName()))},
+		Bookings:        bookingRepository{newMongoRepository[model.Booking](db.Collection(model.Booking{}.CollectionName()))},
+		RatePlans:       newMongoRepository[model.RatePlan](db.Collection(model.RatePlan{}.CollectionName())),
+		ExchangeRates:   newMongoRepository[model.ExchangeRate](db.Collection(model.ExchangeRate{}.CollectionName())),
+		CarSuppliers:    newMongoRepository[model.CarSupplier](db.Collection(model.CarSupplier{}.CollectionName())),
+		CarLocations:    newMongoRepository[model.CarLocation](db.Collection(model.CarLocation{}.CollectionName())),
+		RentalCars:      newMongoRepository[model.RentalCar](db.Collection(model.RentalCar{}.CollectionName())),
+		MenuSections:    newMongoRepository[model.MenuSection](db.Collection(model.MenuSection{}.CollectionName())),
+		MenuItems:       newMongoRepository[model.MenuItem](db.Collection(model.MenuItem{}.CollectionName())),
+		DiningTables:    diningTableRepository{newMongoRepository[model.DiningTable](db.Collection(model.DiningTable{}.CollectionName()))},
+		Reservations:    tableReservationRepository{newMongoRepository[model.TableReservation](db.Collection(model.TableReservation{}.CollectionName()))},
+	}
+}
+
+// mongoRepository implements Repository for one model on one collection
+type mongoRepository[T any] struct {
+	collection *mongo.Collection
+}
+
+func newMongoRepository[T any](collection *mongo.Collection) *mongoRepository[T] {
+	return &mongoRepository[T]{collection: collection}
+}
+
+func (r *mongoRepository[T]) Create(ctx context.Context, doc *T) error {
+	if _, err := ensureID(doc); err != nil {
+		return err
+	}
+	_, err := r.collection.InsertOne(ctx, doc)
+	return err
+}
+
+func (r *mongoRepository[T]) Get(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	var doc T
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (r *mongoRepository[T]) List(ctx context.Context, q Query) ([]T, int64, error) {
+	filter := mongoFilter(q.Conditions)
+	if q.Text != "" {
+		filter["$text"] = bson.M{"$search": q.Text}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find()
+	if q.Limit > 0 {
+		opts.SetLimit(q.Limit)
+	}
+	if q.Skip > 0 {
+		opts.SetSkip(q.Skip)
+	}
+	if circle, ok := sortsByDistance(q); ok {
+		// $nearSphere returns the nearest first but cannot be counted, so
+		// only the find uses it. It replaces any later sort fields.
+		near := bson.M{}
+		for key, value := range filter {
+			near[key] = value
+		}
+		near[q.Sort[0].Field] = bson.M{"$nearSphere": bson.M{
+			"$geometry":    bson.M{"type": "Point", "coordinates": bson.A{circle.Center.Lng, circle.Center.Lat}},
+			"$maxDistance": circle.Radius,
+		}}
+		filter = near
+	} else if len(q.Sort) > 0 {
+		opts.SetSort(mongoSort(q.Sort))
+	} else if q.Text != "" {
+		opts.SetSort(bson.M{"score": bson.M{"$meta": "textScore"}})
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []T{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+	return docs, total, nil
+}
+
+func (r *mongoRepository[T]) Update(ctx context.Context, doc *T) error {
+	id, err := documentID(doc)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": id}, doc)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRepository[T]) replaceIf(ctx context.Context, doc *T, conditions []Condition) (bool, error) {
+	id, err := documentID(doc)
+	if err != nil {
+		return false, err
+	}
+	result, err := r.collection.ReplaceOne(ctx, mongoFilter(append([]Condition{Eq("_id", id)}, conditions...)), doc)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *mongoRepository[T]) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRepository[T]) average(ctx context.Context, conditions []Condition, field string) (Average, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: mongoFilter(conditions)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"average": bson.M{"$avg": "$" + field},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return Average{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Average float64 `bson:"average"`
+		Count   int64   `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return Average{}, err
+	}
+	if len(results) == 0 {
+		return Average{}, nil
+	}
+	return Average{Value: results[0].Average, Count: results[0].Count}, nil
+}
+
+func (r *mongoRepository[T]) aggregate(ctx context.Context, pipeline []bson.M) ([]bson.M, error) {
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []bson.M{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// mongoFilter translates conditions into a MongoDB query document
+func mongoFilter(conditions []Condition) bson.M {
+	filter := bson.M{}
+	var and []bson.M
+	for _, cond := range conditions {
+		if cond.Op == OpOr {
+			alternatives, _ := cond.Value.([][]Condition)
+			if len(alternatives) == 0 {
+				// $or rejects an empty list; no alternative matches nothing
+				and = append(and, bson.M{"_id": bson.M{"$in": bson.A{}}})
+				continue
+			}
+			or := make(bson.A, len(alternatives))
+			for i, alternative := range alternatives {
+				or[i] = mongoFilter(alternative)
+			}
+			and = append(and, bson.M{"$or": or})
+			continue
+		}
+		var expr interface{}
+		switch cond.Op {
+		case OpEq:
+			expr = cond.Value
+		case OpNe:
+			expr = bson.M{"$ne": cond.Value}
+		case OpGt:
+			expr = bson.M{"$gt": cond.Value}
+		case OpGte:
+			expr = bson.M{"$gte": cond.Value}
+		case OpLt:
+			expr = bson.M{"$lt": cond.Value}
+		case OpLte:
+			expr = bson.M{"$lte": cond.Value}
+		case OpIn:
+			expr = bson.M{"$in": cond.Value}
+		case OpNin:
+			expr = bson.M{"$nin": cond.Value}
+		case OpContains:
+			pattern, _ := cond.Value.(string)
+			expr = bson.M{"$regex": regexp.QuoteMeta(pattern), "$options": "i"}
+		case OpNear:
+			circle, _ := cond.Value.(Circle)
+			expr = bson.M{"$geoWithin": bson.M{"$centerSphere": bson.A{
+				bson.A{circle.Center.Lng, circle.Center.Lat},
+				circle.Radius / EarthRadius,
+			}}}
+		}
+		// Several conditions on one field must not overwrite each other
+		if _, exists := filter[cond.Field]; exists {
+			and = append(and, bson.M{cond.Field: expr})
+			continue
+		}
+		filter[cond.Field] = expr
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// sortsByDistance returns the Near condition q is ordered by, when its
+// first sort field is a distance
+func sortsByDistance(q Query) (Circle, bool) {
+	if len(q.Sort) == 0 || !q.Sort[0].Distance {
+		return Circle{}, false
+	}
+	return nearCondition(q.Conditions, q.Sort[0].Field)
+}
+
+func mongoSort(fields []SortField) bson.D {
+	sort := bson.D{}
+	for _, field := range fields {
+		if field.Distance {
+			// Only $nearSphere orders by distance, see List
+			continue
+		}
+		direction := 1
+		if field.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: field.Field, Value: direction})
+	}
+	return sort
+}
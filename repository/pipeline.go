@@ -0,0 +1,654 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Backends without an aggregation engine of their own evaluate pipelines
+// here. They cover the stages and operators reporting queries use: $match,
+// $group, $sort, $skip, $limit, $count, $unwind, $project and $addFields,
+// with field paths and literals as expressions. Anything else fails with
+// ErrUnsupported.
+
+// splitMatch turns the $match stages a pipeline starts with into conditions,
+// so a backend can select the documents the rest of the pipeline runs on
+// with its own query engine.
+func splitMatch(pipeline []bson.M) ([]Condition, []bson.M, error) {
+	var conditions []Condition
+	for len(pipeline) > 0 {
+		filter, ok := pipeline[0]["$match"]
+		if !ok || len(pipeline[0]) != 1 {
+			break
+		}
+		matched, err := filterConditions(filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, matched...)
+		pipeline = pipeline[1:]
+	}
+	return conditions, pipeline, nil
+}
+
+// filterConditions translates a MongoDB query document into conditions
+func filterConditions(filter interface{}) ([]Condition, error) {
+	fields, ok := asDocument(filter)
+	if !ok {
+		return nil, fmt.Errorf("$match needs a document, got %T", filter)
+	}
+
+	var conditions []Condition
+	for _, key := range sortedKeys(fields) {
+		value := fields[key]
+		switch key {
+		case "$and", "$or":
+			clauses, ok := asArray(value)
+			if !ok {
+				return nil, fmt.Errorf("%s needs an array", key)
+			}
+			alternatives := make([][]Condition, len(clauses))
+			for i, clause := range clauses {
+				var err error
+				if alternatives[i], err = filterConditions(clause); err != nil {
+					return nil, err
+				}
+			}
+			if key == "$or" {
+				conditions = append(conditions, Or(alternatives...))
+				continue
+			}
+			for _, and := range alternatives {
+				conditions = append(conditions, and...)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			return nil, fmt.Errorf("%w: query operator %s", ErrUnsupported, key)
+		}
+
+		operators, isDocument := asDocument(value)
+		if !isDocument || !hasOperators(operators) {
+			conditions = append(conditions, Eq(key, value))
+			continue
+		}
+		for _, op := range sortedKeys(operators) {
+			cond, err := operatorCondition(key, op, operators[op])
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, cond)
+		}
+	}
+	return conditions, nil
+}
+
+func operatorCondition(field, op string, value interface{}) (Condition, error) {
+	switch op {
+	case "$eq":
+		return Eq(field, value), nil
+	case "$ne":
+		return Ne(field, value), nil
+	case "$gt":
+		return Gt(field, value), nil
+	case "$gte":
+		return Gte(field, value), nil
+	case "$lt":
+		return Lt(field, value), nil
+	case "$lte":
+		return Lte(field, value), nil
+	case "$in", "$nin":
+		values, ok := asArray(value)
+		if !ok {
+			return Condition{}, fmt.Errorf("%s needs an array", op)
+		}
+		if op == "$in" {
+			return In(field, values...), nil
+		}
+		return NotIn(field, values...), nil
+	case "$exists":
+		if exists, _ := value.(bool); exists {
+			return Ne(field, nil), nil
+		}
+		return Eq(field, nil), nil
+	}
+	return Condition{}, fmt.Errorf("%w: query operator %s", ErrUnsupported, op)
+}
+
+func hasOperators(doc bson.M) bool {
+	for key := range doc {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// runPipeline applies the stages of pipeline to docs in turn
+func runPipeline(docs []bson.M, pipeline []bson.M) ([]bson.M, error) {
+	for _, stage := range pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage needs exactly one field, got %d", len(stage))
+		}
+		for name, spec := range stage {
+			var err error
+			if docs, err = runStage(docs, name, spec); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	if docs == nil {
+		docs = []bson.M{}
+	}
+	return docs, nil
+}
+
+func runStage(docs []bson.M, name string, spec interface{}) ([]bson.M, error) {
+	switch name {
+	case "$match":
+		conditions, err := filterConditions(spec)
+		if err != nil {
+			return nil, err
+		}
+		var matched []bson.M
+		for _, doc := range docs {
+			if matchesAll(doc, conditions) {
+				matched = append(matched, doc)
+			}
+		}
+		return matched, nil
+	case "$group":
+		return group(docs, spec)
+	case "$sort":
+		return sortStage(docs, spec)
+	case "$skip", "$limit":
+		n, ok := toFloat(spec)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("needs a non-negative number")
+		}
+		count := min(int(n), len(docs))
+		if name == "$skip" {
+			return docs[count:], nil
+		}
+		return docs[:count], nil
+	case "$count":
+		field, ok := spec.(string)
+		if !ok || field == "" {
+			return nil, fmt.Errorf("needs a field name")
+		}
+		if len(docs) == 0 {
+			return nil, nil
+		}
+		return []bson.M{{field: int64(len(docs))}}, nil
+	case "$unwind":
+		return unwind(docs, spec)
+	case "$project":
+		return project(docs, spec)
+	case "$addFields", "$set":
+		fields, ok := asDocument(spec)
+		if !ok {
+			return nil, fmt.Errorf("needs a document")
+		}
+		result := make([]bson.M, len(docs))
+		for i, doc := range docs {
+			result[i] = cloneValue(doc).(bson.M)
+			for _, key := range sortedKeys(fields) {
+				value, err := evaluate(fields[key], doc)
+				if err != nil {
+					return nil, err
+				}
+				setPath(result[i], key, value)
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%w: pipeline stage %s", ErrUnsupported, name)
+}
+
+// accumulator folds the values of one $group output field
+type accumulator struct {
+	op     string
+	values []interface{}
+}
+
+func (a accumulator) result() interface{} {
+	switch a.op {
+	case "$sum", "$avg":
+		sum, count, integral := 0.0, 0, true
+		for _, value := range a.values {
+			if n, ok := toFloat(value); ok {
+				sum += n
+				count++
+				integral = integral && n == float64(int64(n))
+			}
+		}
+		if a.op == "$avg" {
+			if count == 0 {
+				return nil
+			}
+			return sum / float64(count)
+		}
+		if integral {
+			return int64(sum)
+		}
+		return sum
+	case "$count":
+		return int64(len(a.values))
+	case "$min", "$max":
+		var best interface{}
+		for _, value := range a.values {
+			if value == nil {
+				continue
+			}
+			c := orderValues(value, best)
+			if best == nil || a.op == "$min" && c < 0 || a.op == "$max" && c > 0 {
+				best = value
+			}
+		}
+		return best
+	case "$first", "$last":
+		if len(a.values) == 0 {
+			return nil
+		}
+		if a.op == "$first" {
+			return a.values[0]
+		}
+		return a.values[len(a.values)-1]
+	case "$push":
+		return bson.A(append([]interface{}{}, a.values...))
+	default: // $addToSet
+		set := bson.A{}
+		seen := map[string]bool{}
+		for _, value := range a.values {
+			if key := groupKey(value); !seen[key] {
+				seen[key] = true
+				set = append(set, value)
+			}
+		}
+		return set
+	}
+}
+
+var accumulators = map[string]bool{
+	"$sum": true, "$avg": true, "$count": true, "$min": true, "$max": true,
+	"$first": true, "$last": true, "$push": true, "$addToSet": true,
+}
+
+func group(docs []bson.M, spec interface{}) ([]bson.M, error) {
+	fields, ok := asDocument(spec)
+	if !ok {
+		return nil, fmt.Errorf("needs a document")
+	}
+	idExpr, ok := fields["_id"]
+	if !ok {
+		return nil, fmt.Errorf("needs an _id")
+	}
+
+	type outputField struct {
+		name, op string
+		expr     interface{}
+	}
+	var outputs []outputField
+	for _, name := range sortedKeys(fields) {
+		if name == "_id" {
+			continue
+		}
+		accumulatorSpec, ok := asDocument(fields[name])
+		if !ok || len(accumulatorSpec) != 1 {
+			return nil, fmt.Errorf("field %s needs a single accumulator", name)
+		}
+		for op, expr := range accumulatorSpec {
+			if !accumulators[op] {
+				return nil, fmt.Errorf("%w: accumulator %s", ErrUnsupported, op)
+			}
+			outputs = append(outputs, outputField{name, op, expr})
+		}
+	}
+
+	type bucket struct {
+		id     interface{}
+		fields []accumulator
+	}
+	var order []string
+	buckets := map[string]*bucket{}
+	for _, doc := range docs {
+		id, err := evaluate(idExpr, doc)
+		if err != nil {
+			return nil, err
+		}
+		key := groupKey(id)
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{id: id, fields: make([]accumulator, len(outputs))}
+			for i, output := range outputs {
+				b.fields[i].op = output.op
+			}
+			buckets[key] = b
+			order = append(order, key)
+		}
+		for i, output := range outputs {
+			value, err := evaluate(output.expr, doc)
+			if err != nil {
+				return nil, err
+			}
+			if output.op == "$count" {
+				value = true
+			}
+			b.fields[i].values = append(b.fields[i].values, value)
+		}
+	}
+
+	result := make([]bson.M, 0, len(order))
+	for _, key := range order {
+		b := buckets[key]
+		doc := bson.M{"_id": b.id}
+		for i, output := range outputs {
+			doc[output.name] = b.fields[i].result()
+		}
+		result = append(result, doc)
+	}
+	return result, nil
+}
+
+// groupKey identifies a value for grouping. Numbers of every type group
+// together, as on MongoDB.
+func groupKey(value interface{}) string {
+	if n, ok := toFloat(value); ok {
+		value = n
+	}
+	data, err := json.Marshal(jsonValue(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return fmt.Sprintf("%T:%s", value, data)
+}
+
+// sortStage orders documents by the fields of a $sort stage. Stages arrive
+// as unordered documents, so several keys are applied in name order.
+func sortStage(docs []bson.M, spec interface{}) ([]bson.M, error) {
+	fields, ok := asDocument(spec)
+	if !ok || len(fields) == 0 {
+		return nil, fmt.Errorf("needs a document of fields")
+	}
+	keys := sortedKeys(fields)
+	directions := make([]float64, len(keys))
+	for i, key := range keys {
+		direction, ok := toFloat(fields[key])
+		if !ok || direction != 1 && direction != -1 {
+			return nil, fmt.Errorf("%w: sort order for %s", ErrUnsupported, key)
+		}
+		directions[i] = direction
+	}
+
+	sorted := append([]bson.M{}, docs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for k, key := range keys {
+			a, _ := lookup(sorted[i], key)
+			b, _ := lookup(sorted[j], key)
+			if c := orderValues(a, b); c != 0 {
+				return c*int(directions[k]) < 0
+			}
+		}
+		return false
+	})
+	return sorted, nil
+}
+
+// orderValues orders any two values, placing values of different types in
+// MongoDB's order: null, numbers, strings, documents, arrays, ObjectIDs,
+// booleans and dates
+func orderValues(a, b interface{}) int {
+	if c, ok := compare(a, b); ok {
+		return c
+	}
+	return threeWay(typeRank(a) < typeRank(b), typeRank(a) > typeRank(b))
+}
+
+func typeRank(value interface{}) int {
+	if _, ok := toFloat(value); ok {
+		return 1
+	}
+	switch value.(type) {
+	case nil:
+		return 0
+	case string:
+		return 2
+	case bson.M, bson.D, map[string]interface{}:
+		return 3
+	case bson.A, []interface{}:
+		return 4
+	case primitive.ObjectID:
+		return 5
+	case bool:
+		return 6
+	}
+	return 7
+}
+
+func unwind(docs []bson.M, spec interface{}) ([]bson.M, error) {
+	path, preserve := spec, false
+	if options, ok := asDocument(spec); ok {
+		path = options["path"]
+		preserve, _ = options["preserveNullAndEmptyArrays"].(bool)
+	}
+	field, ok := path.(string)
+	if !ok || !strings.HasPrefix(field, "$") {
+		return nil, fmt.Errorf("needs a field path")
+	}
+	field = field[1:]
+
+	var result []bson.M
+	for _, doc := range docs {
+		value, found := lookup(doc, field)
+		elements, isArray := asArray(value)
+		switch {
+		case !found || value == nil || isArray && len(elements) == 0:
+			if preserve {
+				result = append(result, doc)
+			}
+		case !isArray:
+			result = append(result, doc)
+		default:
+			for _, element := range elements {
+				unwound := cloneValue(doc).(bson.M)
+				setPath(unwound, field, element)
+				result = append(result, unwound)
+			}
+		}
+	}
+	return result, nil
+}
+
+func project(docs []bson.M, spec interface{}) ([]bson.M, error) {
+	fields, ok := asDocument(spec)
+	if !ok || len(fields) == 0 {
+		return nil, fmt.Errorf("needs a document of fields")
+	}
+
+	// A projection either lists the fields to keep, and computed fields, or
+	// the fields to drop. _id is kept unless dropped.
+	excluding, including := false, false
+	for key, value := range fields {
+		if isExclusion(value) {
+			excluding = excluding || key != "_id"
+		} else {
+			including = true
+		}
+	}
+	if excluding && including {
+		return nil, fmt.Errorf("cannot mix kept and dropped fields")
+	}
+
+	result := make([]bson.M, len(docs))
+	for i, doc := range docs {
+		if excluding || !including {
+			result[i] = cloneValue(doc).(bson.M)
+			for key := range fields {
+				deletePath(result[i], key)
+			}
+			continue
+		}
+
+		projected := bson.M{}
+		if _, ok := fields["_id"]; !ok {
+			if id, found := doc["_id"]; found {
+				projected["_id"] = id
+			}
+		}
+		for _, key := range sortedKeys(fields) {
+			value := fields[key]
+			if isExclusion(value) {
+				continue
+			}
+			if b, isBool := value.(bool); isBool && b || isOne(value) {
+				if kept, found := lookup(doc, key); found {
+					setPath(projected, key, cloneValue(kept))
+				}
+				continue
+			}
+			computed, err := evaluate(value, doc)
+			if err != nil {
+				return nil, err
+			}
+			setPath(projected, key, computed)
+		}
+		result[i] = projected
+	}
+	return result, nil
+}
+
+func isExclusion(value interface{}) bool {
+	if b, ok := value.(bool); ok {
+		return !b
+	}
+	n, ok := toFloat(value)
+	return ok && n == 0
+}
+
+func isOne(value interface{}) bool {
+	n, ok := toFloat(value)
+	return ok && n == 1
+}
+
+// evaluate computes an expression against doc: a "$field" path, a document
+// of expressions or a literal
+func evaluate(expr interface{}, doc bson.M) (interface{}, error) {
+	if path, ok := expr.(string); ok && strings.HasPrefix(path, "$") {
+		value, _ := lookup(doc, path[1:])
+		return value, nil
+	}
+	if fields, ok := asDocument(expr); ok {
+		if literal, ok := fields["$literal"]; ok && len(fields) == 1 {
+			return literal, nil
+		}
+		result := bson.M{}
+		for key, value := range fields {
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("%w: expression operator %s", ErrUnsupported, key)
+			}
+			computed, err := evaluate(value, doc)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = computed
+		}
+		return result, nil
+	}
+	if elements, ok := asArray(expr); ok {
+		result := make(bson.A, len(elements))
+		for i, element := range elements {
+			computed, err := evaluate(element, doc)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = computed
+		}
+		return result, nil
+	}
+	return expr, nil
+}
+
+// asDocument accepts the document types a pipeline decoded from BSON or
+// JSON holds
+func asDocument(value interface{}) (bson.M, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return bson.M(v), true
+	case bson.D:
+		return v.Map(), true
+	}
+	return nil, false
+}
+
+func asArray(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.A:
+		return v, true
+	case []interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+func sortedKeys(doc bson.M) []string {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// cloneValue copies the documents and arrays within value, so a stage can
+// change its output without touching its input
+func cloneValue(value interface{}) interface{} {
+	if doc, ok := asDocument(value); ok {
+		clone := make(bson.M, len(doc))
+		for key, field := range doc {
+			clone[key] = cloneValue(field)
+		}
+		return clone
+	}
+	if elements, ok := asArray(value); ok {
+		clone := make(bson.A, len(elements))
+		for i, element := range elements {
+			clone[i] = cloneValue(element)
+		}
+		return clone
+	}
+	return value
+}
+
+// setPath sets a dotted field path in doc, creating documents on the way
+func setPath(doc bson.M, path string, value interface{}) {
+	key, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		doc[key] = value
+		return
+	}
+	next, ok := asDocument(doc[key])
+	if !ok {
+		next = bson.M{}
+	}
+	doc[key] = next
+	setPath(next, rest, value)
+}
+
+// deletePath removes a dotted field path from doc
+func deletePath(doc bson.M, path string) {
+	key, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		delete(doc, key)
+		return
+	}
+	if next, ok := asDocument(doc[key]); ok {
+		deletePath(next, rest)
+	}
+}
@@ -0,0 +1,616 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewPostgresStore returns a Store backed by the tables of a PostgreSQL
+// database, created by the migrations in migrate/postgres. Each model's
+// table is named after its collection, in lower case.
+func NewPostgresStore(pool *pgxpool.Pool) *Store {
+	return &Store{
+		Hotels:          hotelRepository{newPostgresRepository[model.Hotel](pool, model.Hotel{}.CollectionName())},
+		Reviews:         reviewRepository{newPostgresRepository[model.Review](pool, model.Review{}.CollectionName())},
+		Ratings:         ratingRepository{newPostgresRepository[model.Rating](pool, model.Rating{}.CollectionName())},
+		Photos:          newPostgresRepository[model.Photo](pool, model.Photo{}.CollectionName()),
+		Thumbnails:      newPostgresRepository[model.Thumbnail](pool, model.Thumbnail{}.CollectionName()),
+		Cuisines:        newPostgresRepository[model.Cuisine](pool, model.Cuisine{}.CollectionName()),
+		Restaurants:     newPostgresRepository[model.Restaurant](pool, model.Restaurant{}.CollectionName()),
+		VacationRentals: newPostgresRepository[model.VacationRental](pool, model.VacationRental{}.CollectionName()),
+		Inventory:       inventoryRepository{newPostgresRepository[model.Inventory](pool, model.Inventory{}.CollectionName())},
+		Bookings:        bookingRepository{newPostgresRepository[model.Booking](pool, model.Booking{}.CollectionName())},
+		RatePlans:       newPostgresRepository[model.RatePlan](pool, model.RatePlan{}.CollectionName()),
+		ExchangeRates:   newPostgresRepository[model.ExchangeRate](pool, model.ExchangeRate{}.CollectionName()),
+		CarSuppliers:    newPostgresRepository[model.CarSupplier](pool, model.CarSupplier{}.CollectionName()),
+		CarLocations:    newPostgresRepository[model.CarLocation](pool, model.CarLocation{}.CollectionName()),
+		RentalCars:      newPostgresRepository[model.RentalCar](pool, model.RentalCar{}.CollectionName()),
+		MenuSections:    newPostgresRepository[model.MenuSection](pool, model.MenuSection{}.CollectionName()),
+		MenuItems:       newPostgresRepository[model.MenuItem](pool, model.MenuItem{}.CollectionName()),
+		DiningTables:    diningTableRepository{newPostgresRepository[model.DiningTable](pool, model.DiningTable{}.CollectionName())},
+		Reservations:    tableReservationRepository{newPostgresRepository[model.TableReservation](pool, model.TableReservation{}.CollectionName())},
+	}
+}
+
+// postgresRepository implements Repository for one model on one table.
+// Each field of the model has a column of its own, derived from the model
+// by schemaOf; lists of sub-documents and optional sub-documents are stored
+// as JSONB. Conditions on list columns, like on MongoDB, match a document
+// when any element does.
+type postgresRepository[T any] struct {
+	pool    *pgxpool.Pool
+	table   string // quoted
+	schema  postgresSchema
+	columns string // the quoted column names, in schema order
+}
+
+func newPostgresRepository[T any](pool *pgxpool.Pool, collection string) *postgresRepository[T] {
+	schema := schemaOf(reflect.TypeOf((*T)(nil)).Elem())
+	names := make([]string, len(schema.Columns))
+	for i, column := range schema.Columns {
+		names[i] = column.quoted()
+	}
+	return &postgresRepository[T]{
+		pool:    pool,
+		table:   pgx.Identifier{strings.ToLower(collection)}.Sanitize(),
+		schema:  schema,
+		columns: strings.Join(names, ", "),
+	}
+}
+
+func (r *postgresRepository[T]) Create(ctx context.Context, doc *T) error {
+	if _, err := ensureID(doc); err != nil {
+		return err
+	}
+	values, err := r.schema.encodeRow(doc)
+	if err != nil {
+		return err
+	}
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	_, err = r.pool.Exec(ctx, `INSERT INTO `+r.table+` (`+r.columns+`) VALUES (`+strings.Join(placeholders, ", ")+`)`, values...)
+	return err
+}
+
+func (r *postgresRepository[T]) Get(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+r.columns+` FROM `+r.table+` WHERE "id" = $1`, id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	docs, err := r.decode(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &docs[0], nil
+}
+
+func (r *postgresRepository[T]) List(ctx context.Context, q Query) ([]T, int64, error) {
+	where, args, err := r.where(q.Conditions, q.Text)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, `SELECT count(*) FROM `+r.table+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sql := `SELECT ` + r.columns + ` FROM ` + r.table + where + r.order(q.Sort, q.Conditions, &args)
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		sql += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if q.Skip > 0 {
+		args = append(args, q.Skip)
+		sql += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	docs, err := r.decode(rows)
+	return docs, total, err
+}
+
+// decode reads every row of rows, which select the schema's columns
+func (r *postgresRepository[T]) decode(rows pgx.Rows) ([]T, error) {
+	defer rows.Close()
+	docs := []T{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		var doc T
+		if err := r.schema.decodeRow(values, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+func (r *postgresRepository[T]) Update(ctx context.Context, doc *T) error {
+	replaced, err := r.replaceIf(ctx, doc, nil)
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresRepository[T]) replaceIf(ctx context.Context, doc *T, conditions []Condition) (bool, error) {
+	id, err := documentID(doc)
+	if err != nil {
+		return false, err
+	}
+	values, err := r.schema.encodeRow(doc)
+	if err != nil {
+		return false, err
+	}
+	// A concurrent update makes PostgreSQL evaluate the conditions again
+	// against the row it wrote, so the check and the write are atomic
+	where, args, err := r.where(conditions, "")
+	if err != nil {
+		return false, err
+	}
+	args = append(args, id.Hex())
+	sql := fmt.Sprintf(`UPDATE %s SET `, r.table)
+	for i, column := range r.schema.Columns {
+		if i > 0 {
+			sql += ", "
+		}
+		args = append(args, values[i])
+		sql += fmt.Sprintf("%s = $%d", column.quoted(), len(args))
+	}
+	sql += fmt.Sprintf(` WHERE "id" = $%d`, len(args)-len(values))
+	if where != "" {
+		sql += " AND " + strings.TrimPrefix(where, " WHERE ")
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *postgresRepository[T]) Delete(ctx context.Context, id primitive.ObjectID) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM `+r.table+` WHERE "id" = $1`, id.Hex())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresRepository[T]) average(ctx context.Context, conditions []Condition, field string) (Average, error) {
+	column, rest, ok := r.schema.column(field)
+	if !ok || column.Array || column.Kind != kindJSON && column.Kind != kindInt && column.Kind != kindFloat {
+		return Average{}, nil
+	}
+	where, args, err := r.where(conditions, "")
+	if err != nil {
+		return Average{}, err
+	}
+
+	value := column.quoted() + "::float8"
+	if column.Kind == kindJSON {
+		args = append(args, jsonPath(rest))
+		value = fmt.Sprintf(`(SELECT v::float8 FROM jsonb_path_query_first(%s, $%d::jsonpath) AS v WHERE jsonb_typeof(v) = 'number')`, column.quoted(), len(args))
+	}
+	sql := fmt.Sprintf(`SELECT coalesce(avg(v), 0), count(v) FROM (SELECT %s AS v FROM %s%s) AS selected`, value, r.table, where)
+
+	var result Average
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&result.Value, &result.Count)
+	return result, err
+}
+
+// aggregate selects the documents matched by the $match stages the pipeline
+// starts with in SQL and evaluates the rest of it in process
+func (r *postgresRepository[T]) aggregate(ctx context.Context, pipeline []bson.M) ([]bson.M, error) {
+	conditions, pipeline, err := splitMatch(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	selected, _, err := r.List(ctx, Query{Conditions: conditions})
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]bson.M, len(selected))
+	for i := range selected {
+		raw, err := bson.Marshal(&selected[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(raw, &docs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return runPipeline(docs, pipeline)
+}
+
+// jsonTimeLayout has a fixed width, so formatted UTC times sort as strings
+const jsonTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// jsonValue converts a BSON value, or a condition's value, to what it is
+// stored as in the JSON copy of a document
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int32, int64, int:
+		return v
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+		return v
+	case float32:
+		return jsonValue(float64(v))
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC().Format(jsonTimeLayout)
+	case time.Time:
+		return v.UTC().Format(jsonTimeLayout)
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.Binary:
+		return base64.StdEncoding.EncodeToString(v.Data)
+	case bson.M:
+		object := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			object[key] = jsonValue(field)
+		}
+		return object
+	case bson.D:
+		return jsonValue(v.Map())
+	case bson.A:
+		return jsonValue([]interface{}(v))
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, element := range v {
+			array[i] = jsonValue(element)
+		}
+		return array
+	}
+
+	// Anything else, such as a named string type, goes through its JSON form
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	var decoded interface{}
+	json.Unmarshal(data, &decoded)
+	return decoded
+}
+
+// jsonLiteral writes a condition value as a jsonpath literal
+func jsonLiteral(value interface{}) (string, error) {
+	switch v := jsonValue(value).(type) {
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("cannot compare with %T", value)
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
+
+// jsonPath converts a dotted field path within a JSON column to a lax
+// jsonpath such as $."moderation"."status"
+func jsonPath(field string) string {
+	var path strings.Builder
+	path.WriteString("$")
+	if field == "" {
+		return path.String()
+	}
+	for _, key := range strings.Split(field, ".") {
+		data, _ := json.Marshal(key)
+		path.WriteString(".")
+		path.Write(data)
+	}
+	return path.String()
+}
+
+var (
+	sqlOperators = map[Operator]string{
+		OpEq: "=", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<=",
+	}
+	jsonpathOperators = map[Operator]string{
+		OpEq: "==", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<=",
+	}
+)
+
+// where translates conditions and a text search into a WHERE clause and its
+// arguments
+func (r *postgresRepository[T]) where(conditions []Condition, text string) (string, []interface{}, error) {
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	clauses, err := r.schema.clauses(conditions, arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Like the memory store, a text search matches documents with a
+	// top-level string field containing any of the terms
+	if terms := strings.Fields(text); len(terms) > 0 {
+		patterns := make([]string, len(terms))
+		for i, term := range terms {
+			patterns[i] = "%" + likeEscaper.Replace(term) + "%"
+		}
+		placeholder := arg(patterns)
+		var matches []string
+		for _, column := range r.schema.Columns {
+			if len(column.Path) == 1 && column.Kind == kindText && !column.Array {
+				matches = append(matches, fmt.Sprintf("%s ILIKE ANY(%s::text[])", column.quoted(), placeholder))
+			}
+		}
+		if len(matches) == 0 {
+			matches = []string{"false"}
+		}
+		clauses = append(clauses, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	if len(clauses) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args, nil
+}
+
+// clauses translates each condition into an SQL predicate, adding its
+// arguments through arg
+func (s postgresSchema) clauses(conditions []Condition, arg func(interface{}) string) ([]string, error) {
+	var clauses []string
+	for _, cond := range conditions {
+		if cond.Op == OpOr {
+			alternatives, _ := cond.Value.([][]Condition)
+			or := make([]string, len(alternatives))
+			for i, alternative := range alternatives {
+				and, err := s.clauses(alternative, arg)
+				if err != nil {
+					return nil, err
+				}
+				or[i] = "true"
+				if len(and) > 0 {
+					or[i] = "(" + strings.Join(and, " AND ") + ")"
+				}
+			}
+			if len(or) == 0 {
+				or = []string{"false"}
+			}
+			clauses = append(clauses, "("+strings.Join(or, " OR ")+")")
+			continue
+		}
+
+		// Negations hold wherever the condition they negate does not,
+		// including when the field is missing
+		positive := cond
+		switch cond.Op {
+		case OpNe:
+			positive.Op = OpEq
+		case OpNin:
+			positive.Op = OpIn
+		}
+		clause, err := s.clause(positive, arg)
+		if err != nil {
+			return nil, fmt.Errorf("condition on %s: %w", cond.Field, err)
+		}
+		if positive.Op != cond.Op {
+			clause = "NOT " + clause
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+// clause translates a condition other than a negation into a predicate that
+// is never NULL
+func (s postgresSchema) clause(cond Condition, arg func(interface{}) string) (string, error) {
+	column, rest, ok := s.column(cond.Field)
+	if !ok {
+		// Like MongoDB, a field the model does not have is missing
+		if cond.Op == OpEq && cond.Value == nil {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	if column.Kind == kindJSON {
+		return jsonClause(column, rest, cond, arg)
+	}
+
+	name := column.quoted()
+	if id, isID := cond.Value.(primitive.ObjectID); isID && id.IsZero() && column.Kind == kindID && !column.Array {
+		cond.Value = nil
+	}
+	if cond.Op == OpEq && cond.Value == nil {
+		// Like MongoDB, null matches a missing field too
+		return name + " IS NULL", nil
+	}
+
+	switch cond.Op {
+	case OpNear:
+		if column.Kind != kindFloat || !column.Array {
+			return "", fmt.Errorf("%s is not a [longitude, latitude] pair", cond.Field)
+		}
+		circle, _ := cond.Value.(Circle)
+		return fmt.Sprintf("coalesce(%s <= %s::float8, false)", postgresDistance(column, circle.Center, arg), arg(circle.Radius)), nil
+	case OpContains:
+		if column.Kind != kindText {
+			return "false", nil
+		}
+		substr, _ := cond.Value.(string)
+		pattern := arg("%" + likeEscaper.Replace(substr) + "%")
+		if column.Array {
+			return fmt.Sprintf("coalesce(EXISTS (SELECT 1 FROM unnest(%s) AS e WHERE e ILIKE %s), false)", name, pattern), nil
+		}
+		return fmt.Sprintf("coalesce(%s ILIKE %s, false)", name, pattern), nil
+	case OpIn:
+		candidates, _ := cond.Value.([]interface{})
+		var alternatives []string
+		for _, candidate := range candidates {
+			alternative, err := s.clause(Condition{cond.Field, OpEq, candidate}, arg)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		if len(alternatives) == 0 {
+			return "false", nil
+		}
+		return "(" + strings.Join(alternatives, " OR ") + ")", nil
+	}
+
+	operator, ok := sqlOperators[cond.Op]
+	if !ok {
+		return "", fmt.Errorf("unsupported operator %q", cond.Op)
+	}
+	var placeholder string
+	if n, isNumber := toFloat(cond.Value); isNumber && (column.Kind == kindInt || column.Kind == kindFloat) {
+		// Integer columns compare with fractions, as on MongoDB
+		placeholder = arg(n) + "::float8"
+	} else {
+		value, err := scalarValue(column.Kind, cond.Value)
+		if err != nil {
+			// Like MongoDB, values of another type never compare
+			return "false", nil
+		}
+		placeholder = arg(value)
+	}
+	if column.Array {
+		return fmt.Sprintf("coalesce(EXISTS (SELECT 1 FROM unnest(%s) AS e WHERE e %s %s), false)", name, operator, placeholder), nil
+	}
+	return fmt.Sprintf("coalesce(%s %s %s, false)", name, operator, placeholder), nil
+}
+
+// jsonClause translates a condition on a field stored in a JSON column into
+// a jsonpath predicate, which like MongoDB matches an array when any of its
+// elements does
+func jsonClause(column postgresColumn, rest string, cond Condition, arg func(interface{}) string) (string, error) {
+	name := column.quoted()
+	path := jsonPath(rest)
+	var predicate string
+	switch cond.Op {
+	case OpEq:
+		if cond.Value == nil {
+			// Like MongoDB, null matches a missing field too
+			return fmt.Sprintf(`(%[1]s IS NULL OR NOT %[1]s @? %[2]s::jsonpath OR %[1]s @? %[3]s::jsonpath)`,
+				name, arg(path), arg(path+" ? (@ == null)")), nil
+		}
+		literal, err := jsonLiteral(cond.Value)
+		if err != nil {
+			return "", err
+		}
+		predicate = "@ == " + literal
+	case OpGt, OpGte, OpLt, OpLte:
+		literal, err := jsonLiteral(cond.Value)
+		if err != nil {
+			return "", err
+		}
+		predicate = "@ " + jsonpathOperators[cond.Op] + " " + literal
+	case OpIn:
+		values, _ := cond.Value.([]interface{})
+		if len(values) == 0 {
+			return "false", nil
+		}
+		alternatives := make([]string, len(values))
+		for i, value := range values {
+			literal, err := jsonLiteral(value)
+			if err != nil {
+				return "", err
+			}
+			alternatives[i] = "@ == " + literal
+		}
+		predicate = strings.Join(alternatives, " || ")
+	case OpContains:
+		substr, _ := cond.Value.(string)
+		pattern, _ := json.Marshal(regexp.QuoteMeta(substr))
+		predicate = fmt.Sprintf(`@ like_regex %s flag "i"`, pattern)
+	default:
+		return "", fmt.Errorf("unsupported operator %q on a JSON column", cond.Op)
+	}
+	return fmt.Sprintf(`coalesce(%s @? %s::jsonpath, false)`, name, arg(path+" ? ("+predicate+")")), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// order translates sort fields into an ORDER BY clause. Missing fields sort
+// first, as on MongoDB, lists sort by their least element ascending and
+// their greatest descending, and ties keep insertion order.
+func (r *postgresRepository[T]) order(fields []SortField, conditions []Condition, args *[]interface{}) string {
+	arg := func(value interface{}) string {
+		*args = append(*args, value)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+
+	order := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		column, rest, ok := r.schema.column(field.Field)
+		if !ok {
+			continue
+		}
+		if field.Distance {
+			if circle, ok := nearCondition(conditions, field.Field); ok && column.Kind == kindFloat && column.Array {
+				order = append(order, postgresDistance(column, circle.Center, arg)+" ASC NULLS LAST")
+			}
+			continue
+		}
+
+		direction := "ASC NULLS FIRST"
+		if field.Desc {
+			direction = "DESC NULLS LAST"
+		}
+		key := column.quoted()
+		switch {
+		case column.Kind == kindJSON:
+			key = fmt.Sprintf(`jsonb_path_query_first(%s, %s::jsonpath)`, key, arg(jsonPath(rest)))
+		case column.Array && field.Desc:
+			key = fmt.Sprintf(`(SELECT max(e) FROM unnest(%s) AS e)`, key)
+		case column.Array:
+			key = fmt.Sprintf(`(SELECT min(e) FROM unnest(%s) AS e)`, key)
+		}
+		order = append(order, key+" "+direction)
+	}
+	order = append(order, `"seq"`)
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// postgresDistance is the SQL for the distance in meters from center to the
+// [longitude, latitude] pair in column, computed like Distance. It is NULL
+// when the column does not hold such a pair.
+func postgresDistance(column postgresColumn, center Point, arg func(interface{}) string) string {
+	pair := column.quoted()
+	lng, lat := pair+"[1]", pair+"[2]"
+	centerLng, centerLat := arg(center.Lng)+"::float8", arg(center.Lat)+"::float8"
+	return fmt.Sprintf(`(CASE WHEN cardinality(%[1]s) = 2 THEN
+		2 * %[2]s * asin(least(1, sqrt(
+			power(sin(radians(%[3]s - %[5]s) / 2), 2) +
+			cos(radians(%[5]s)) * cos(radians(%[3]s)) * power(sin(radians(%[4]s - %[6]s) / 2), 2)
+		)))
+	END)`, pair, arg(EarthRadius)+"::float8", lat, lng, centerLat, centerLng)
+}
@@ -0,0 +1,475 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// columnKind is the type a field is stored as in PostgreSQL
+type columnKind int
+
+const (
+	kindText  columnKind = iota // text
+	kindID                      // char(24), an ObjectID in hex
+	kindInt                     // bigint
+	kindFloat                   // double precision
+	kindBool                    // boolean
+	kindTime                    // timestamptz
+	kindJSON                    // jsonb, for lists of sub-documents, maps and optional sub-documents
+)
+
+var columnTypes = map[columnKind]string{
+	kindText:  "text",
+	kindID:    "char(24)",
+	kindInt:   "bigint",
+	kindFloat: "double precision",
+	kindBool:  "boolean",
+	kindTime:  "timestamptz",
+	kindJSON:  "jsonb",
+}
+
+// postgresColumn is the column a field of a model is stored in. The fields
+// of a sub-document get columns of their own, named after their path, such
+// as location_city for location.city.
+type postgresColumn struct {
+	Name  string
+	Path  []string // the bson field path
+	Kind  columnKind
+	Array bool         // a PostgreSQL array of Kind
+	Type  reflect.Type // the Go type of a JSON column, to decode it with
+}
+
+// SQLType is the column's type in a CREATE TABLE statement
+func (c postgresColumn) SQLType() string {
+	if c.Array {
+		return columnTypes[c.Kind] + "[]"
+	}
+	return columnTypes[c.Kind]
+}
+
+// postgresSchema is the table layout of a model
+type postgresSchema struct {
+	Columns []postgresColumn
+	byPath  map[string]int // dotted bson path to column
+}
+
+var (
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// schemaOf derives the table layout of the model t from its bson tags
+func schemaOf(t reflect.Type) postgresSchema {
+	schema := postgresSchema{byPath: map[string]int{}}
+	schema.addFields(t, nil)
+	return schema
+}
+
+func (s *postgresSchema) addFields(t reflect.Type, prefix []string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline := bsonName(field)
+		if name == "-" {
+			continue
+		}
+		if inline {
+			s.addFields(field.Type, prefix)
+			continue
+		}
+		s.addField(field.Type, append(prefix[:len(prefix):len(prefix)], name))
+	}
+}
+
+func (s *postgresSchema) addField(t reflect.Type, path []string) {
+	column := postgresColumn{Name: columnName(path), Path: path}
+	if kind, ok := scalarKind(t); ok {
+		column.Kind = kind
+	} else if t.Kind() == reflect.Struct {
+		s.addFields(t, path)
+		return
+	} else if elem, ok := sliceElem(t); ok {
+		if kind, ok := scalarKind(elem); ok {
+			column.Kind, column.Array = kind, true
+		} else {
+			column.Kind, column.Type = kindJSON, t
+		}
+	} else {
+		column.Kind, column.Type = kindJSON, t
+	}
+	s.byPath[strings.Join(path, ".")] = len(s.Columns)
+	s.Columns = append(s.Columns, column)
+}
+
+// bsonName returns the bson key of field and whether it is inlined
+func bsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("bson")
+	name, options, _ := strings.Cut(tag, ",")
+	inline := strings.Contains(","+options+",", ",inline,")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, inline
+}
+
+func columnName(path []string) string {
+	if len(path) == 1 && path[0] == "_id" {
+		return "id"
+	}
+	return strings.Join(path, "_")
+}
+
+// scalarKind is the column kind of a single value of type t. Pointers to
+// scalars are stored like the scalar, as NULL when nil.
+func scalarKind(t reflect.Type) (columnKind, bool) {
+	if t.Kind() == reflect.Pointer && t.Elem().Kind() != reflect.Struct || t.Kind() == reflect.Pointer && t.Elem() == timeType {
+		t = t.Elem()
+	}
+	switch {
+	case t == objectIDType:
+		return kindID, true
+	case t == timeType:
+		return kindTime, true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return kindText, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint16, reflect.Uint32:
+		return kindInt, true
+	case reflect.Float32, reflect.Float64:
+		return kindFloat, true
+	case reflect.Bool:
+		return kindBool, true
+	}
+	return 0, false
+}
+
+// sliceElem returns the element type of a slice or array type other than
+// an ObjectID
+func sliceElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array && t != objectIDType {
+		return t.Elem(), true
+	}
+	return nil, false
+}
+
+// column returns the column storing path and the path within it, which is
+// only set for JSON columns. ok is false for a path the model does not have.
+func (s postgresSchema) column(path string) (column postgresColumn, rest string, ok bool) {
+	if path == "id" {
+		path = "_id"
+	}
+	for prefix := path; ; {
+		if i, found := s.byPath[prefix]; found {
+			column := s.Columns[i]
+			rest := strings.TrimPrefix(strings.TrimPrefix(path, prefix), ".")
+			if rest != "" && column.Kind != kindJSON {
+				return column, "", false
+			}
+			return column, rest, true
+		}
+		cut := strings.LastIndex(prefix, ".")
+		if cut < 0 {
+			return postgresColumn{}, "", false
+		}
+		prefix = prefix[:cut]
+	}
+}
+
+// quoted is the column name as an SQL identifier
+func (c postgresColumn) quoted() string {
+	return `"` + strings.ReplaceAll(c.Name, `"`, `""`) + `"`
+}
+
+// encodeRow returns the column values of doc, in the order of the schema's
+// columns. Fields the document leaves out are NULL.
+func (s postgresSchema) encodeRow(doc interface{}) ([]interface{}, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(s.Columns))
+	for i, column := range s.Columns {
+		value, found := lookup(fields, strings.Join(column.Path, "."))
+		if !found || value == nil {
+			continue
+		}
+		if values[i], err = column.encode(value); err != nil {
+			return nil, fmt.Errorf("column %s: %w", column.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// encode converts a BSON value to what pgx writes to the column
+func (c postgresColumn) encode(value interface{}) (interface{}, error) {
+	if c.Kind == kindJSON {
+		return json.Marshal(jsonValue(value))
+	}
+	if !c.Array {
+		return scalarValue(c.Kind, value)
+	}
+
+	elements, _ := value.(bson.A)
+	switch c.Kind {
+	case kindID:
+		array := make([]string, len(elements))
+		for i, element := range elements {
+			id, _ := element.(primitive.ObjectID)
+			array[i] = id.Hex()
+		}
+		return array, nil
+	case kindText:
+		array := make([]string, len(elements))
+		for i, element := range elements {
+			v, err := scalarValue(c.Kind, element)
+			if err != nil {
+				return nil, err
+			}
+			array[i], _ = v.(string)
+		}
+		return array, nil
+	case kindInt:
+		array := make([]int64, len(elements))
+		for i, element := range elements {
+			n, _ := toFloat(element)
+			array[i] = int64(n)
+		}
+		return array, nil
+	case kindFloat:
+		array := make([]float64, len(elements))
+		for i, element := range elements {
+			array[i], _ = toFloat(element)
+		}
+		return array, nil
+	case kindBool:
+		array := make([]bool, len(elements))
+		for i, element := range elements {
+			array[i], _ = element.(bool)
+		}
+		return array, nil
+	default:
+		array := make([]time.Time, len(elements))
+		for i, element := range elements {
+			array[i], _ = toTime(element)
+		}
+		return array, nil
+	}
+}
+
+// scalarValue converts a BSON or condition value to what pgx writes to a
+// column of kind
+func scalarValue(kind columnKind, value interface{}) (interface{}, error) {
+	switch kind {
+	case kindID:
+		// A zero ObjectID, a reference that is not set, is stored as NULL
+		// so references can be foreign keys
+		if id, ok := value.(primitive.ObjectID); ok && id.IsZero() {
+			return nil, nil
+		} else if ok {
+			return id.Hex(), nil
+		}
+	case kindText:
+		// Named string types, such as statuses, are stored as strings
+		if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+	case kindInt:
+		if n, ok := toFloat(value); ok {
+			return int64(n), nil
+		}
+	case kindFloat:
+		if n, ok := toFloat(value); ok {
+			return n, nil
+		}
+	case kindBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case kindTime:
+		if t, ok := toTime(value); ok {
+			return t.UTC(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot store %T as %s", value, columnTypes[kind])
+}
+
+// decodeRow rebuilds the document stored in a row, whose values are in
+// the order of the schema's columns, and decodes it into doc
+func (s postgresSchema) decodeRow(values []interface{}, doc interface{}) error {
+	fields := bson.M{}
+	for i, column := range s.Columns {
+		if values[i] == nil {
+			continue
+		}
+		value, err := column.decode(values[i])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		parent := fields
+		for _, key := range column.Path[:len(column.Path)-1] {
+			next, ok := parent[key].(bson.M)
+			if !ok {
+				next = bson.M{}
+				parent[key] = next
+			}
+			parent = next
+		}
+		parent[column.Path[len(column.Path)-1]] = value
+	}
+
+	raw, err := bson.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, doc)
+}
+
+// decode converts what pgx read from the column back to a BSON value
+func (c postgresColumn) decode(value interface{}) (interface{}, error) {
+	if c.Kind == kindJSON {
+		return fromJSON(value, c.Type)
+	}
+	if !c.Array {
+		return fromColumn(c.Kind, value)
+	}
+	elements, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected %T for an array", value)
+	}
+	array := make(bson.A, len(elements))
+	for i, element := range elements {
+		var err error
+		if array[i], err = fromColumn(c.Kind, element); err != nil {
+			return nil, err
+		}
+	}
+	return array, nil
+}
+
+func fromColumn(kind columnKind, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch kind {
+	case kindID:
+		s, _ := value.(string)
+		return primitive.ObjectIDFromHex(strings.TrimSpace(s))
+	case kindTime:
+		if t, ok := value.(time.Time); ok {
+			return primitive.NewDateTimeFromTime(t), nil
+		}
+		return nil, fmt.Errorf("unexpected %T for a time", value)
+	}
+	return value, nil
+}
+
+// fromJSON converts a decoded JSON value back to the BSON the Go type t
+// marshals to: ObjectIDs and times are stored as strings in JSON columns
+func fromJSON(value interface{}, t reflect.Type) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == objectIDType:
+		s, _ := value.(string)
+		return primitive.ObjectIDFromHex(s)
+	case t == timeType:
+		s, _ := value.(string)
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		return primitive.NewDateTimeFromTime(parsed), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected %T for %s", value, t)
+		}
+		doc := bson.M{}
+		for key, v := range object {
+			doc[key] = v
+		}
+		return doc, structFromJSON(doc, t)
+	case reflect.Slice, reflect.Array:
+		elements, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected %T for %s", value, t)
+		}
+		array := make(bson.A, len(elements))
+		for i, element := range elements {
+			var err error
+			if array[i], err = fromJSON(element, t.Elem()); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected %T for %s", value, t)
+		}
+		doc := bson.M{}
+		for key, v := range object {
+			var err error
+			if doc[key], err = fromJSON(v, t.Elem()); err != nil {
+				return nil, err
+			}
+		}
+		return doc, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint16, reflect.Uint32:
+		if n, ok := value.(float64); ok {
+			return int64(n), nil
+		}
+	}
+	return value, nil
+}
+
+// structFromJSON converts the fields of doc, a JSON object of the struct
+// type t, in place
+func structFromJSON(doc bson.M, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline := bsonName(field)
+		if inline {
+			if err := structFromJSON(doc, field.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := doc[name]
+		if !ok || name == "-" {
+			continue
+		}
+		converted, err := fromJSON(value, field.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		doc[name] = converted
+	}
+	return nil
+}
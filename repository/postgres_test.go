@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/janto-pee/Horizon-Travels.git/migrate"
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPostgresWhere(t *testing.T) {
+	hotels := newPostgresRepository[model.Hotel](nil, model.Hotel{}.CollectionName())
+	owner := primitive.NewObjectID()
+	where, args, err := hotels.where([]Condition{
+		Eq("location.city", "Lagos"),
+		Gte("price", 100),
+		Ne("owner_id", owner),
+		In("status", "active", "pending"),
+		Contains("provider", "a_b"),
+		Eq("amenities", "pool"),
+		Eq("room_types.name", "Suite"),
+		Eq("source", 3),
+		Eq("unknown", "x"),
+		Or([]Condition{Eq("currency", "USD")}, nil),
+	}, "palm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clauses := []string{
+		`coalesce("location_city" = $1, false)`,
+		`coalesce("price" >= $2::float8, false)`,
+		`NOT coalesce("owner_id" = $3, false)`,
+		`(coalesce("status" = $4, false) OR coalesce("status" = $5, false))`,
+		`coalesce("provider" ILIKE $6, false)`,
+		`coalesce(EXISTS (SELECT 1 FROM unnest("amenities") AS e WHERE e = $7), false)`,
+		`coalesce("room_types" @? $8::jsonpath, false)`,
+		`false AND false`,
+		`((coalesce("currency" = $9, false)) OR true)`,
+		`"title" ILIKE ANY($10::text[])`,
+	}
+	for _, clause := range clauses {
+		if !strings.Contains(where, clause) {
+			t.Errorf("expected %s in %s", clause, where)
+		}
+	}
+
+	expected := []interface{}{"Lagos", 100.0, owner.Hex(), "active", "pending", `%a\_b%`, "pool", `$."name" ? (@ == "Suite")`, "USD"}
+	if len(args) != len(expected)+1 {
+		t.Fatalf("expected %d arguments, got %d: %v", len(expected)+1, len(args), args)
+	}
+	for i, want := range expected {
+		if args[i] != want {
+			t.Errorf("argument %d: expected %v, got %v", i+1, want, args[i])
+		}
+	}
+	if patterns, _ := args[len(args)-1].([]string); len(patterns) != 1 || patterns[0] != "%palm%" {
+		t.Errorf("unexpected text patterns %v", args[len(args)-1])
+	}
+
+	if where, args, _ := hotels.where([]Condition{Eq("owner_id", primitive.NilObjectID)}, ""); where != ` WHERE "owner_id" IS NULL` || len(args) != 0 {
+		t.Errorf("expected an unset reference to match NULL, got %q %v", where, args)
+	}
+	if where, args, _ := hotels.where(nil, ""); where != "" || len(args) != 0 {
+		t.Errorf("expected no clause without conditions, got %q %v", where, args)
+	}
+}
+
+// TestPostgresRowRoundTrip checks that a document survives being written to
+// its columns and read back, given the values pgx reads from them
+func TestPostgresRowRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	review := model.Review{
+		ID:         primitive.NewObjectID(),
+		UserID:     primitive.NewObjectID(),
+		EntityID:   primitive.NewObjectID(),
+		EntityType: "hotel",
+		Title:      "Lovely",
+		Rating:     4,
+		Votes:      []model.ReviewVote{{UserID: primitive.NewObjectID(), Helpful: true, VotedAt: created}},
+		Status:     model.ReviewPublished,
+		Moderation: &model.ReviewModeration{ModeratorID: primitive.NewObjectID(), Status: model.ReviewPublished, Reason: "fine", ModeratedAt: created},
+		CreatedAt:  created,
+	}
+	hotel := model.Hotel{
+		ID:        primitive.NewObjectID(),
+		Title:     "Palm",
+		Location:  model.Location{City: "Lagos", Coordinates: []float64{3.4, 6.5}},
+		Amenities: []string{"pool", "wifi"},
+		RoomTypes: []model.RoomType{{ID: "deluxe", Name: "Deluxe", MaxGuests: 2, Price: 120, Amenities: []string{}}},
+		CreatedAt: created,
+	}
+
+	testRoundTrip(t, &review)
+	testRoundTrip(t, &hotel)
+}
+
+func testRoundTrip[T any](t *testing.T, doc *T) {
+	t.Helper()
+	schema := schemaOf(reflect.TypeOf(doc).Elem())
+	values, err := schema.encodeRow(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pgx reads arrays as []interface{} and decodes JSON
+	for i, value := range values {
+		switch v := value.(type) {
+		case []byte:
+			json.Unmarshal(v, &values[i])
+		case []string:
+			values[i] = toInterfaces(v)
+		case []float64:
+			values[i] = toInterfaces(v)
+		}
+	}
+
+	var decoded T
+	if err := schema.decodeRow(values, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, doc) {
+		t.Errorf("expected %+v back, got %+v", *doc, decoded)
+	}
+}
+
+func toInterfaces[E any](values []E) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+// TestPostgresMigrationsMatchModels checks that the tables the migrations
+// create have the columns the models are stored in
+func TestPostgresMigrationsMatchModels(t *testing.T) {
+	tables := map[string]map[string]string{}
+	files, err := filepath.Glob("../migrate/postgres/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range createTable.FindAllStringSubmatch(string(data), -1) {
+			columns := map[string]string{}
+			for _, line := range strings.Split(match[2], "\n") {
+				name, definition, ok := strings.Cut(strings.TrimSpace(line), " ")
+				if ok && strings.HasPrefix(name, `"`) {
+					columns[strings.Trim(name, `"`)] = strings.TrimSuffix(definition, ",")
+				}
+			}
+			tables[match[1]] = columns
+		}
+		for _, match := range addColumn.FindAllStringSubmatch(string(data), -1) {
+			if columns, ok := tables[match[1]]; ok {
+				columns[match[2]] = match[3]
+			}
+		}
+	}
+
+	for collection, doc := range map[string]interface{}{
+		model.Hotel{}.CollectionName():            model.Hotel{},
+		model.Review{}.CollectionName():           model.Review{},
+		model.Rating{}.CollectionName():           model.Rating{},
+		model.Photo{}.CollectionName():            model.Photo{},
+		model.Thumbnail{}.CollectionName():        model.Thumbnail{},
+		model.Cuisine{}.CollectionName():          model.Cuisine{},
+		model.Restaurant{}.CollectionName():       model.Restaurant{},
+		model.VacationRental{}.CollectionName():   model.VacationRental{},
+		model.Inventory{}.CollectionName():        model.Inventory{},
+		model.Booking{}.CollectionName():          model.Booking{},
+		model.RatePlan{}.CollectionName():         model.RatePlan{},
+		model.ExchangeRate{}.CollectionName():     model.ExchangeRate{},
+		model.CarSupplier{}.CollectionName():      model.CarSupplier{},
+		model.CarLocation{}.CollectionName():      model.CarLocation{},
+		model.RentalCar{}.CollectionName():        model.RentalCar{},
+		model.MenuSection{}.CollectionName():      model.MenuSection{},
+		model.MenuItem{}.CollectionName():         model.MenuItem{},
+		model.DiningTable{}.CollectionName():      model.DiningTable{},
+		model.TableReservation{}.CollectionName(): model.TableReservation{},
+	} {
+		table := strings.ToLower(collection)
+		columns, ok := tables[table]
+		if !ok {
+			t.Errorf("no migration creates %s", table)
+			continue
+		}
+		schema := schemaOf(reflect.TypeOf(doc))
+		for _, column := range schema.Columns {
+			definition, ok := columns[column.Name]
+			if !ok {
+				t.Errorf("%s has no column %s", table, column.Name)
+				continue
+			}
+			if rest, ok := strings.CutPrefix(definition, column.SQLType()); !ok || strings.HasPrefix(rest, "[") {
+				t.Errorf("%s.%s: expected %s, got %s", table, column.Name, column.SQLType(), definition)
+			}
+			delete(columns, column.Name)
+		}
+		delete(columns, "seq")
+		for name := range columns {
+			t.Errorf("%s has column %s, which %T does not store", table, name, doc)
+		}
+	}
+}
+
+var (
+	createTable = regexp.MustCompile(`(?s)CREATE TABLE "(\w+)" \((.*?)\n\);`)
+	addColumn   = regexp.MustCompile(`ALTER TABLE "(\w+)" ADD COLUMN "(\w+)" ([^;]+);`)
+)
+
+// TestPostgresRepository runs the shared repository tests against the
+// database in POSTGRES_TEST_URL, which it migrates and empties
+func TestPostgresRepository(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	migrations, err := migrate.Postgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.NewPostgres(pool, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, `TRUNCATE "hotels", "ratings"`); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewPostgresStore(pool)
+	seedHotels(t, store.Hotels)
+	testQueries(t, store.Hotels)
+	testAggregation(t, store.Hotels)
+
+	hotelID := primitive.NewObjectID()
+	for _, score := range []float64{4, 5, 3} {
+		if err := store.Ratings.Create(ctx, &model.Rating{HotelID: hotelID, Score: score}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	avg, err := store.Ratings.AverageScore(ctx, hotelID)
+	if err != nil || avg.Count != 3 || avg.Value != 4 {
+		t.Errorf("expected average 4 over 3 ratings, got %+v (%v)", avg, err)
+	}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Operator is a comparison applied by a Condition
+type Operator string
+
+const (
+	OpEq       Operator = "eq"
+	OpNe       Operator = "ne"
+	OpGt       Operator = "gt"
+	OpGte      Operator = "gte"
+	OpLt       Operator = "lt"
+	OpLte      Operator = "lte"
+	OpIn       Operator = "in"
+	OpNin      Operator = "nin"
+	OpContains Operator = "contains" // case-insensitive substring match
+	OpNear     Operator = "near"     // within a Circle, see Near
+	OpOr       Operator = "or"       // any of several groups of conditions, see Or
+)
+
+// Condition restricts a query to documents whose field satisfies Op against
+// Value. Field uses the bson field name and may be a dotted path such as
+// "location.city". Conditions on array fields match when any element does.
+type Condition struct {
+	Field string
+	Op    Operator
+	Value interface{}
+}
+
+func Eq(field string, value interface{}) Condition  { return Condition{field, OpEq, value} }
+func Ne(field string, value interface{}) Condition  { return Condition{field, OpNe, value} }
+func Gt(field string, value interface{}) Condition  { return Condition{field, OpGt, value} }
+func Gte(field string, value interface{}) Condition { return Condition{field, OpGte, value} }
+func Lt(field string, value interface{}) Condition  { return Condition{field, OpLt, value} }
+func Lte(field string, value interface{}) Condition { return Condition{field, OpLte, value} }
+
+// In matches documents whose field equals one of values
+func In(field string, values ...interface{}) Condition {
+	return Condition{field, OpIn, values}
+}
+
+// NotIn matches documents whose field equals none of values
+func NotIn(field string, values ...interface{}) Condition {
+	return Condition{field, OpNin, values}
+}
+
+// Contains matches documents whose field contains substr, ignoring case
+func Contains(field string, substr string) Condition {
+	return Condition{field, OpContains, substr}
+}
+
+// Or matches documents that satisfy every condition of at least one of
+// alternatives. It has no field of its own.
+func Or(alternatives ...[]Condition) Condition {
+	return Condition{Op: OpOr, Value: alternatives}
+}
+
+// SortField orders query results by a single field
+type SortField struct {
+	Field string
+	Desc  bool
+	// Distance orders by distance from a Near condition instead of by the
+	// field's value, see ByDistance
+	Distance bool
+}
+
+func Asc(field string) SortField  { return SortField{Field: field} }
+func Desc(field string) SortField { return SortField{Field: field, Desc: true} }
+
+// Query selects, orders and pages the documents returned by List. The zero
+// Query returns every document.
+type Query struct {
+	Conditions []Condition
+	// Text is a free-text search over the model's text index. When set and
+	// Sort is empty, results are ordered by relevance.
+	Text  string
+	Sort  []SortField
+	Skip  int64
+	Limit int64
+}
+
+// Page sets Skip and Limit for a 1-based page number
+func (q Query) Page(pageID, pageSize int64) Query {
+	q.Skip = (pageID - 1) * pageSize
+	q.Limit = pageSize
+	return q
+}
+
+// ensureID assigns a new ObjectID to doc when its _id is missing or zero and
+// returns the document's ID.
+func ensureID[T any](doc *T) (primitive.ObjectID, error) {
+	id, err := documentID(doc)
+	if err != nil || !id.IsZero() {
+		return id, err
+	}
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return id, err
+	}
+	var fields bson.D
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return id, err
+	}
+
+	id = primitive.NewObjectID()
+	withID := bson.D{{Key: "_id", Value: id}}
+	for _, field := range fields {
+		if field.Key != "_id" {
+			withID = append(withID, field)
+		}
+	}
+	if raw, err = bson.Marshal(withID); err != nil {
+		return id, err
+	}
+	return id, bson.Unmarshal(raw, doc)
+}
+
+// documentID returns the _id of doc, or the zero ObjectID when it has none
+func documentID[T any](doc *T) (primitive.ObjectID, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	id, _ := bson.Raw(raw).Lookup("_id").ObjectIDOK()
+	return id, nil
+}
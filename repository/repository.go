@@ -0,0 +1,673 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no document matches the requested ID
+	ErrNotFound = errors.New("document not found")
+	// ErrUnsupported is returned when a backend cannot perform an operation
+	ErrUnsupported = errors.New("operation not supported by this storage backend")
+	// ErrUnavailable is returned when there is not enough inventory left
+	ErrUnavailable = errors.New("not enough inventory available")
+	// ErrConflict is returned when a document changed while it was being
+	// updated, or is no longer in the state an update expects
+	ErrConflict = errors.New("document was changed by another request")
+	// ErrNotReleased is returned along with the reason a reservation failed
+	// when inventory it had already taken could not be given back
+	ErrNotReleased = errors.New("inventory held by a failed reservation was not released")
+)
+
+// Repository is the storage contract shared by every model
+type Repository[T any] interface {
+	// Create stores doc, assigning it a new ID when it has none
+	Create(ctx context.Context, doc *T) error
+	// Get returns the document with the given ID or ErrNotFound
+	Get(ctx context.Context, id primitive.ObjectID) (*T, error)
+	// List returns the page of documents selected by q along with the
+	// total number of documents matching q's conditions
+	List(ctx context.Context, q Query) ([]T, int64, error)
+	// Update replaces the stored document that has doc's ID
+	Update(ctx context.Context, doc *T) error
+	// Delete removes the document with the given ID
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Average is the result of averaging a numeric field over a set of documents
+type Average struct {
+	Value float64
+	Count int64
+}
+
+// Stars counts scores from 1 to 5 by the star they round to, one star first
+type Stars [5]int64
+
+// HotelRepository stores hotels
+type HotelRepository interface {
+	Repository[model.Hotel]
+	// Aggregate runs a MongoDB aggregation pipeline. Backends other than
+	// MongoDB support the stages listed in pipeline.go and fail with
+	// ErrUnsupported on others.
+	Aggregate(ctx context.Context, pipeline []bson.M) ([]bson.M, error)
+	// Rate folds the scores in add into a hotel's rating and review count
+	// and takes those in remove back out, as one atomic step
+	Rate(ctx context.Context, hotelID primitive.ObjectID, add, remove []float64) error
+	// SetRating replaces a hotel's rating and review count with average
+	SetRating(ctx context.Context, hotelID primitive.ObjectID, average Average) error
+	// Edit applies edit to the stored hotel and returns the result. Ratings
+	// folded in meanwhile are kept, as edit runs again on the new rating.
+	Edit(ctx context.Context, hotelID primitive.ObjectID, edit func(hotel *model.Hotel)) (*model.Hotel, error)
+}
+
+// ReviewRepository stores reviews of hotels, restaurants and rentals
+type ReviewRepository interface {
+	Repository[model.Review]
+	// AverageRating averages the rating of every published review of an
+	// entity
+	AverageRating(ctx context.Context, entityType string, entityID primitive.ObjectID) (Average, error)
+	// Stars counts the published reviews of an entity by their rating
+	Stars(ctx context.Context, entityType string, entityID primitive.ObjectID) (Stars, error)
+	// Moderate gives a review a moderator's decision if its status is one
+	// of from, and fails with ErrConflict otherwise. It returns the review
+	// before and after the decision.
+	Moderate(ctx context.Context, reviewID primitive.ObjectID, decision model.ReviewModeration, from ...string) (before, after *model.Review, err error)
+	// Report adds a traveler's report to a published or flagged review,
+	// and fails with ErrConflict for any other status. It returns the
+	// review before and after the report.
+	Report(ctx context.Context, reviewID primitive.ObjectID, report model.ReviewReport) (before, after *model.Review, err error)
+	// Vote counts a traveler's vote on a published review in place of
+	// their earlier one, and fails with ErrConflict for any other status
+	Vote(ctx context.Context, reviewID primitive.ObjectID, vote model.ReviewVote) (*model.Review, error)
+	// Unvote retracts a traveler's vote, or fails with model.ErrNoVote
+	Unvote(ctx context.Context, reviewID, userID primitive.ObjectID) (*model.Review, error)
+	// Respond adds an owner's response to a published review. It fails
+	// with ErrConflict for any other status, or with model.ErrResponded.
+	Respond(ctx context.Context, reviewID primitive.ObjectID, response model.ReviewResponse) (*model.Review, error)
+	// EditResponse replaces the text of a review's response, or fails with
+	// model.ErrNoResponse
+	EditResponse(ctx context.Context, reviewID, ownerID primitive.ObjectID, text string, at time.Time) (*model.Review, error)
+}
+
+// PublishedReview selects the reviews the public can see. Reviews written
+// before moderation have no status and count as published.
+var PublishedReview = Or([]Condition{Eq("status", model.ReviewPublished)}, []Condition{Eq("status", "")}, []Condition{Eq("status", nil)})
+
+// RatingRepository stores the ratings of hotels, restaurants and rentals
+type RatingRepository interface {
+	Repository[model.Rating]
+	// AverageScore averages the score of every rating of a hotel
+	AverageScore(ctx context.Context, hotelID primitive.ObjectID) (Average, error)
+	// AverageOf averages a field of the ratings of an entity, such as
+	// "score" or "sub_ratings.service". Ratings without it are left out.
+	AverageOf(ctx context.Context, entityType string, entityID primitive.ObjectID, field string) (Average, error)
+	// Stars counts the ratings of an entity by their score
+	Stars(ctx context.Context, entityType string, entityID primitive.ObjectID) (Stars, error)
+}
+
+// PhotoRepository stores photos
+type PhotoRepository interface {
+	Repository[model.Photo]
+}
+
+// ThumbnailRepository stores thumbnails
+type ThumbnailRepository interface {
+	Repository[model.Thumbnail]
+}
+
+// CuisineRepository stores cuisines
+type CuisineRepository interface {
+	Repository[model.Cuisine]
+}
+
+// RestaurantRepository stores restaurants
+type RestaurantRepository interface {
+	Repository[model.Restaurant]
+}
+
+// VacationRentalRepository stores vacation rentals
+type VacationRentalRepository interface {
+	Repository[model.VacationRental]
+}
+
+// RatePlanRepository stores the rate plans of hotel room types
+type RatePlanRepository interface {
+	Repository[model.RatePlan]
+}
+
+// ExchangeRateRepository stores the exchange rate of each currency
+type ExchangeRateRepository interface {
+	Repository[model.ExchangeRate]
+}
+
+// CarSupplierRepository stores car rental companies
+type CarSupplierRepository interface {
+	Repository[model.CarSupplier]
+}
+
+// CarLocationRepository stores the pick-up locations of car suppliers
+type CarLocationRepository interface {
+	Repository[model.CarLocation]
+}
+
+// RentalCarRepository stores the cars suppliers rent out
+type RentalCarRepository interface {
+	Repository[model.RentalCar]
+}
+
+// MenuSectionRepository stores the sections of restaurant menus
+type MenuSectionRepository interface {
+	Repository[model.MenuSection]
+}
+
+// MenuItemRepository stores the dishes on restaurant menus
+type MenuItemRepository interface {
+	Repository[model.MenuItem]
+}
+
+// DiningTableRepository stores the tables of restaurants along with their
+// upcoming bookings. Update keeps the stored bookings, which only Claim and
+// Unclaim change.
+type DiningTableRepository interface {
+	Repository[model.DiningTable]
+	// Claim books a table for the time of a reservation, replacing the
+	// reservation's earlier booking of the table. It fails with
+	// ErrUnavailable when the time overlaps another booking.
+	Claim(ctx context.Context, tableID primitive.ObjectID, booking model.TableBooking) error
+	// Unclaim removes the booking of a reservation from a table
+	Unclaim(ctx context.Context, tableID, reservationID primitive.ObjectID) error
+}
+
+// TableReservationRepository stores the table reservations of restaurants
+type TableReservationRepository interface {
+	Repository[model.TableReservation]
+	// Transition stores reservation if the stored copy has one of the
+	// statuses from and was not changed since reservation was read, and
+	// fails with ErrConflict otherwise
+	Transition(ctx context.Context, reservation *model.TableReservation, from ...string) error
+}
+
+// InventoryRepository stores the nightly room inventory of hotels
+type InventoryRepository interface {
+	Repository[model.Inventory]
+	// Nights returns a hotel's inventory for the nights from from up to,
+	// but not including, to, by room type and date. An empty roomTypeID
+	// selects every room type.
+	Nights(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, from, to time.Time) ([]model.Inventory, error)
+	// Reserve sells units of a room type on every night of a stay, or on
+	// none of them. It fails with ErrUnavailable when a night is missing,
+	// has too few units left or, for the first night, is closed to arrival.
+	Reserve(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, checkIn, checkOut time.Time, units int) error
+	// Release returns units sold by Reserve
+	Release(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, checkIn, checkOut time.Time, units int) error
+	// UpdateCalendar stores the units and arrival restriction of a night,
+	// keeping the units sold. It fails with ErrUnavailable when units would
+	// fall below those sold.
+	UpdateCalendar(ctx context.Context, night *model.Inventory) error
+}
+
+// BookingRepository stores bookings
+type BookingRepository interface {
+	Repository[model.Booking]
+	// Transition stores booking if the stored copy has one of the statuses
+	// from, and fails with ErrConflict otherwise
+	Transition(ctx context.Context, booking *model.Booking, from ...string) error
+}
+
+// Store groups the repositories of every model behind one value
+type Store struct {
+	Hotels          HotelRepository
+	Reviews         ReviewRepository
+	Ratings         RatingRepository
+	Photos          PhotoRepository
+	Thumbnails      ThumbnailRepository
+	Cuisines        CuisineRepository
+	Restaurants     RestaurantRepository
+	VacationRentals VacationRentalRepository
+	Inventory       InventoryRepository
+	Bookings        BookingRepository
+	RatePlans       RatePlanRepository
+	ExchangeRates   ExchangeRateRepository
+	CarSuppliers    CarSupplierRepository
+	CarLocations    CarLocationRepository
+	RentalCars      RentalCarRepository
+	MenuSections    MenuSectionRepository
+	MenuItems       MenuItemRepository
+	DiningTables    DiningTableRepository
+	Reservations    TableReservationRepository
+}
+
+// backend is what a storage implementation provides for a single model. The
+// typed repositories below build their model-specific methods on top of it so
+// each backend only has to implement the generic operations once.
+type backend[T any] interface {
+	Repository[T]
+	average(ctx context.Context, conditions []Condition, field string) (Average, error)
+	aggregate(ctx context.Context, pipeline []bson.M) ([]bson.M, error)
+	// replaceIf replaces the stored document with doc's ID if it satisfies
+	// conditions, as one atomic step, and reports whether it did
+	replaceIf(ctx context.Context, doc *T, conditions []Condition) (bool, error)
+}
+
+type hotelRepository struct{ backend[model.Hotel] }
+
+func (r hotelRepository) Aggregate(ctx context.Context, pipeline []bson.M) ([]bson.M, error) {
+	return r.aggregate(ctx, pipeline)
+}
+
+func (r hotelRepository) Rate(ctx context.Context, hotelID primitive.ObjectID, add, remove []float64) error {
+	_, err := r.rerate(ctx, hotelID, func(hotel *model.Hotel) {
+		for _, score := range remove {
+			hotel.RemoveRating(score)
+		}
+		for _, score := range add {
+			hotel.UpdateRating(score)
+		}
+	})
+	return err
+}
+
+func (r hotelRepository) SetRating(ctx context.Context, hotelID primitive.ObjectID, average Average) error {
+	_, err := r.rerate(ctx, hotelID, func(hotel *model.Hotel) {
+		hotel.Rating, hotel.ReviewCount = average.Value, int(average.Count)
+		hotel.UpdatedAt = time.Now()
+	})
+	return err
+}
+
+func (r hotelRepository) Edit(ctx context.Context, hotelID primitive.ObjectID, edit func(hotel *model.Hotel)) (*model.Hotel, error) {
+	return r.rerate(ctx, hotelID, edit)
+}
+
+// rerate applies edit to the stored hotel. The write only succeeds if the
+// hotel's version, rating and review count are still the ones read, so
+// concurrent edits and scores retry instead of dropping each other's fields.
+func (r hotelRepository) rerate(ctx context.Context, hotelID primitive.ObjectID, edit func(hotel *model.Hotel)) (*model.Hotel, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		hotel, err := r.Get(ctx, hotelID)
+		if err != nil {
+			return nil, err
+		}
+		version, rating, count := hotel.Version, hotel.Rating, hotel.ReviewCount
+		edit(hotel)
+		hotel.Version = version + 1
+		ok, err := r.replaceIf(ctx, hotel, []Condition{
+			orMissing(Eq("version", version), version == 0),
+			Eq("rating", rating),
+			Eq("review_count", count),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return hotel, nil
+		}
+	}
+	return nil, ErrConflict
+}
+
+type reviewRepository struct{ backend[model.Review] }
+
+func (r reviewRepository) AverageRating(ctx context.Context, entityType string, entityID primitive.ObjectID) (Average, error) {
+	return r.average(ctx, []Condition{Eq("entity_type", entityType), Eq("entity_id", entityID), PublishedReview}, "rating")
+}
+
+func (r reviewRepository) Stars(ctx context.Context, entityType string, entityID primitive.ObjectID) (Stars, error) {
+	return countStars(ctx, r.backend, []Condition{Eq("entity_type", entityType), Eq("entity_id", entityID), PublishedReview}, "rating")
+}
+
+// Update replaces the review only if its version is still the one it was
+// read with, so a vote, report, decision or response made meanwhile is not
+// undone. It returns ErrConflict otherwise.
+func (r reviewRepository) Update(ctx context.Context, review *model.Review) error {
+	version := review.Version
+	review.Version = version + 1
+	ok, err := r.replaceIf(ctx, review, []Condition{orMissing(Eq("version", version), version == 0)})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r reviewRepository) Moderate(ctx context.Context, reviewID primitive.ObjectID, decision model.ReviewModeration, from ...string) (*model.Review, *model.Review, error) {
+	return r.change(ctx, reviewID, func(review *model.Review) error {
+		for _, status := range from {
+			if review.CurrentStatus() == status {
+				review.Moderate(decision)
+				return nil
+			}
+		}
+		return ErrConflict
+	})
+}
+
+func (r reviewRepository) Report(ctx context.Context, reviewID primitive.ObjectID, report model.ReviewReport) (*model.Review, *model.Review, error) {
+	return r.change(ctx, reviewID, func(review *model.Review) error {
+		if status := review.CurrentStatus(); status != model.ReviewPublished && status != model.ReviewFlagged {
+			return ErrConflict
+		}
+		return review.AddReport(report)
+	})
+}
+
+func (r reviewRepository) Vote(ctx context.Context, reviewID primitive.ObjectID, vote model.ReviewVote) (*model.Review, error) {
+	_, after, err := r.change(ctx, reviewID, func(review *model.Review) error {
+		if !review.Published() {
+			return ErrConflict
+		}
+		return review.Vote(vote)
+	})
+	return after, err
+}
+
+func (r reviewRepository) Unvote(ctx context.Context, reviewID, userID primitive.ObjectID) (*model.Review, error) {
+	_, after, err := r.change(ctx, reviewID, func(review *model.Review) error {
+		return review.Unvote(userID)
+	})
+	return after, err
+}
+
+func (r reviewRepository) Respond(ctx context.Context, reviewID primitive.ObjectID, response model.ReviewResponse) (*model.Review, error) {
+	_, after, err := r.change(ctx, reviewID, func(review *model.Review) error {
+		if !review.Published() {
+			return ErrConflict
+		}
+		return review.Respond(response)
+	})
+	return after, err
+}
+
+func (r reviewRepository) EditResponse(ctx context.Context, reviewID, ownerID primitive.ObjectID, text string, at time.Time) (*model.Review, error) {
+	_, after, err := r.change(ctx, reviewID, func(review *model.Review) error {
+		return review.EditResponse(ownerID, text, at)
+	})
+	return after, err
+}
+
+// change applies edit to the stored review. The write only succeeds if the
+// review's version is still the one read, so a report made during a
+// moderator's decision, or a vote or edit made at the same time as another,
+// is not lost. Counts that happen to come back to the values read do not
+// hide the votes cast in between.
+func (r reviewRepository) change(ctx context.Context, reviewID primitive.ObjectID, edit func(review *model.Review) error) (*model.Review, *model.Review, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		before, err := r.Get(ctx, reviewID)
+		if err != nil {
+			return nil, nil, err
+		}
+		after := *before
+		after.Reports = append([]model.ReviewReport(nil), before.Reports...)
+		after.Votes = append([]model.ReviewVote(nil), before.Votes...)
+		if err := edit(&after); err != nil {
+			return nil, nil, err
+		}
+		after.Version = before.Version + 1
+		// Reviews written before versions were added have none
+		ok, err := r.replaceIf(ctx, &after, []Condition{orMissing(Eq("version", before.Version), before.Version == 0)})
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return before, &after, nil
+		}
+	}
+	return nil, nil, ErrConflict
+}
+
+// orMissing also matches documents without the field of cond when missing
+// is true, as documents written before the field existed are
+func orMissing(cond Condition, missing bool) Condition {
+	if !missing {
+		return cond
+	}
+	return Or([]Condition{cond}, []Condition{Eq(cond.Field, nil)})
+}
+
+type ratingRepository struct{ backend[model.Rating] }
+
+func (r ratingRepository) AverageScore(ctx context.Context, hotelID primitive.ObjectID) (Average, error) {
+	return r.average(ctx, []Condition{Eq("hotel_id", hotelID)}, "score")
+}
+
+func (r ratingRepository) AverageOf(ctx context.Context, entityType string, entityID primitive.ObjectID, field string) (Average, error) {
+	return r.average(ctx, append(ratingsOf(entityType, entityID), Gt(field, 0)), field)
+}
+
+func (r ratingRepository) Stars(ctx context.Context, entityType string, entityID primitive.ObjectID) (Stars, error) {
+	return countStars(ctx, r.backend, ratingsOf(entityType, entityID), "score")
+}
+
+// ratingsOf selects the ratings of an entity. Ratings of hotels are found
+// by hotel_id, which they had before ratings could be of anything else.
+func ratingsOf(entityType string, entityID primitive.ObjectID) []Condition {
+	if entityType == "hotel" {
+		return []Condition{Eq("hotel_id", entityID)}
+	}
+	return []Condition{Eq("entity_type", entityType), Eq("entity_id", entityID)}
+}
+
+// countStars counts the documents matching conditions by the star their
+// score in field rounds to
+func countStars[T any](ctx context.Context, b backend[T], conditions []Condition, field string) (Stars, error) {
+	var stars Stars
+	for i := range stars {
+		star := float64(i + 1)
+		bucket := append(conditions[:len(conditions):len(conditions)], Gte(field, star-0.5), Lt(field, star+0.5))
+		average, err := b.average(ctx, bucket, field)
+		if err != nil {
+			return stars, err
+		}
+		stars[i] = average.Count
+	}
+	return stars, nil
+}
+
+type inventoryRepository struct{ backend[model.Inventory] }
+
+func (r inventoryRepository) Nights(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, from, to time.Time) ([]model.Inventory, error) {
+	conditions := []Condition{Eq("hotel_id", hotelID), Gte("date", from), Lt("date", to)}
+	if roomTypeID != "" {
+		conditions = append(conditions, Eq("room_type_id", roomTypeID))
+	}
+	nights, _, err := r.List(ctx, Query{Conditions: conditions, Sort: []SortField{Asc("room_type_id"), Asc("date")}})
+	return nights, err
+}
+
+// maxAttempts bounds the retries of an update that lost a race
+const maxAttempts = 10
+
+func (r inventoryRepository) Reserve(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, checkIn, checkOut time.Time, units int) error {
+	var sold []time.Time
+	for i, date := range model.Nights(checkIn, checkOut) {
+		if err := r.sell(ctx, hotelID, roomTypeID, date, units, i == 0); err != nil {
+			// Give back the nights already sold, even when the request was
+			// cancelled. Nights that cannot be given back stay held, so
+			// they are reported along with the error.
+			errs := []error{err}
+			rollback := context.WithoutCancel(ctx)
+			for _, date := range sold {
+				if err := r.sell(rollback, hotelID, roomTypeID, date, -units, false); err != nil {
+					errs = append(errs, fmt.Errorf("%w: %d units of %s: %w", ErrNotReleased, units, date.Format("2006-01-02"), err))
+				}
+			}
+			return errors.Join(errs...)
+		}
+		sold = append(sold, date)
+	}
+	return nil
+}
+
+func (r inventoryRepository) Release(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, checkIn, checkOut time.Time, units int) error {
+	var errs []error
+	for _, date := range model.Nights(checkIn, checkOut) {
+		if err := r.sell(ctx, hotelID, roomTypeID, date, -units, false); err != nil && !errors.Is(err, ErrUnavailable) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sell adds units to the units sold of one night. The write only succeeds
+// if no other request sold units of the night or changed its calendar since
+// it was read, so concurrent sales retry instead of overselling.
+func (r inventoryRepository) sell(ctx context.Context, hotelID primitive.ObjectID, roomTypeID string, date time.Time, units int, arrival bool) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		nights, _, err := r.List(ctx, Query{Conditions: []Condition{
+			Eq("hotel_id", hotelID), Eq("room_type_id", roomTypeID), Eq("date", date),
+		}})
+		if err != nil {
+			return err
+		}
+		if len(nights) == 0 {
+			return ErrUnavailable
+		}
+		night := nights[0]
+		if units > 0 && (night.Available() < units || (arrival && night.ClosedToArrival)) {
+			return ErrUnavailable
+		}
+
+		night.Sold = max(night.Sold+units, 0)
+		night.UpdatedAt = time.Now()
+		ok, err := r.replaceIf(ctx, &night, unchangedNight(nights[0]))
+		if err != nil || ok {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+func (r inventoryRepository) UpdateCalendar(ctx context.Context, night *model.Inventory) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		stored, err := r.Get(ctx, night.ID)
+		if err != nil {
+			return err
+		}
+		if night.Units < stored.Sold {
+			return ErrUnavailable
+		}
+
+		updated := *stored
+		updated.Units, updated.ClosedToArrival, updated.UpdatedAt = night.Units, night.ClosedToArrival, night.UpdatedAt
+		ok, err := r.replaceIf(ctx, &updated, unchangedNight(*stored))
+		if err != nil {
+			return err
+		}
+		if ok {
+			*night = updated
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+// unchangedNight selects a night that still has the sales and calendar it
+// had when it was read as night
+func unchangedNight(night model.Inventory) []Condition {
+	return []Condition{
+		Eq("sold", night.Sold),
+		Eq("units", night.Units),
+		orMissing(Eq("closed_to_arrival", night.ClosedToArrival), !night.ClosedToArrival),
+	}
+}
+
+type bookingRepository struct{ backend[model.Booking] }
+
+func (r bookingRepository) Transition(ctx context.Context, booking *model.Booking, from ...string) error {
+	statuses := make([]interface{}, len(from))
+	for i, status := range from {
+		statuses[i] = status
+	}
+	ok, err := r.replaceIf(ctx, booking, []Condition{In("status", statuses...)})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict
+	}
+	return nil
+}
+
+type diningTableRepository struct{ backend[model.DiningTable] }
+
+func (r diningTableRepository) Update(ctx context.Context, table *model.DiningTable) error {
+	return r.change(ctx, table.ID, func(stored *model.DiningTable) error {
+		table.Bookings, table.Version = stored.Bookings, stored.Version
+		*stored = *table
+		return nil
+	})
+}
+
+func (r diningTableRepository) Claim(ctx context.Context, tableID primitive.ObjectID, booking model.TableBooking) error {
+	return r.change(ctx, tableID, func(table *model.DiningTable) error {
+		if !table.Free(booking.StartsAt, booking.EndsAt, booking.ReservationID) {
+			return ErrUnavailable
+		}
+		table.Book(booking, time.Now())
+		return nil
+	})
+}
+
+func (r diningTableRepository) Unclaim(ctx context.Context, tableID, reservationID primitive.ObjectID) error {
+	return r.change(ctx, tableID, func(table *model.DiningTable) error {
+		table.Unbook(reservationID, time.Now())
+		return nil
+	})
+}
+
+// change applies edit to the stored table. The write only succeeds if the
+// table's version is still the one read, so concurrent bookings retry
+// instead of overlapping.
+func (r diningTableRepository) change(ctx context.Context, tableID primitive.ObjectID, edit func(table *model.DiningTable) error) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		table, err := r.Get(ctx, tableID)
+		if err != nil {
+			return err
+		}
+		version := table.Version
+		if err := edit(table); err != nil {
+			return err
+		}
+		table.Version = version + 1
+		ok, err := r.replaceIf(ctx, table, []Condition{Eq("version", version)})
+		if err != nil || ok {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+type tableReservationRepository struct {
+	backend[model.TableReservation]
+}
+
+func (r tableReservationRepository) Transition(ctx context.Context, reservation *model.TableReservation, from ...string) error {
+	statuses := make([]interface{}, len(from))
+	for i, status := range from {
+		statuses[i] = status
+	}
+	// Two modifications of the same confirmed reservation both pass the
+	// status check, so the version decides which one is stored
+	version := reservation.Version
+	reservation.Version = version + 1
+	ok, err := r.replaceIf(ctx, reservation, []Condition{
+		In("status", statuses...),
+		orMissing(Eq("version", version), version == 0),
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict
+	}
+	return nil
+}
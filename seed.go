@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"github.com/janto-pee/Horizon-Travels.git/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedCity is a place sample data is generated around
+type seedCity struct {
+	name, state, country, postalCode, currency string
+	lng, lat                                   float64
+	timeZone                                   string
+}
+
+var (
+	seedCities = []seedCity{
+		{"Lagos", "Lagos", "NG", "101241", "NGN", 3.3792, 6.5244, "Africa/Lagos"},
+		{"Abuja", "FCT", "NG", "900211", "NGN", 7.4951, 9.0579, "Africa/Lagos"},
+		{"Nairobi", "Nairobi", "KE", "00100", "KES", 36.8219, -1.2921, "Africa/Nairobi"},
+		{"Cape Town", "Western Cape", "ZA", "8001", "ZAR", 18.4241, -33.9249, "Africa/Johannesburg"},
+		{"Marrakesh", "Marrakesh-Safi", "MA", "40000", "MAD", -7.9811, 31.6295, "Africa/Casablanca"},
+		{"Lisbon", "Lisbon", "PT", "1100-148", "EUR", -9.1393, 38.7223, "Europe/Lisbon"},
+		{"Barcelona", "Catalonia", "ES", "08002", "EUR", 2.1734, 41.3851, "Europe/Madrid"},
+		{"London", "England", "GB", "WC2N 5DU", "GBP", -0.1276, 51.5072, "Europe/London"},
+		{"New York", "NY", "US", "10036", "USD", -73.9857, 40.7484, "America/New_York"},
+		{"Bangkok", "Bangkok", "TH", "10200", "THB", 100.5018, 13.7563, "Asia/Bangkok"},
+	}
+	seedHotelPrefixes = []string{"Harbour", "Palm", "Grand", "Royal", "Old Town", "Riverside", "Skyline", "Garden", "Baobab", "Atlantic"}
+	seedHotelSuffixes = []string{"Hotel", "Suites", "Lodge", "Inn", "Resort", "House", "Residences"}
+	seedStreets       = []string{"Marina Road", "Independence Avenue", "Church Street", "Harbour Drive", "Market Lane", "Kingsway", "Beach Road"}
+	seedProviders     = []string{"Booking.com", "Expedia", "Hotels.com", "Agoda", "Trip.com"}
+	seedAmenities     = []string{"Free WiFi", "Pool", "Spa", "Gym", "Airport shuttle", "Restaurant", "Bar", "Parking", "Air conditioning", "Room service", "Beach access"}
+	seedTags          = []string{"family", "business", "romantic", "budget", "luxury", "beachfront", "city centre", "pet friendly"}
+	seedRooms         = []struct {
+		name   string
+		guests int
+		factor float64
+	}{
+		{"Standard Room", 2, 1}, {"Deluxe Room", 2, 1.4}, {"Family Room", 4, 1.8}, {"Junior Suite", 3, 2.2}, {"Presidential Suite", 4, 4},
+	}
+	seedCuisines = []struct{ name, origin, category string }{
+		{"Jollof", "West Africa", "Rice"}, {"Suya", "Nigeria", "Grill"}, {"Tagine", "Morocco", "Stew"},
+		{"Nyama Choma", "Kenya", "Grill"}, {"Pad Thai", "Thailand", "Noodles"}, {"Tapas", "Spain", "Small plates"},
+		{"Bacalhau", "Portugal", "Seafood"}, {"Bobotie", "South Africa", "Baked"}, {"Pizza", "Italy", "Baked"},
+	}
+	seedRestaurantNames = []string{"Bistro", "Kitchen", "Grill", "Brasserie", "Eatery", "Canteen", "Table"}
+	seedRentalKinds     = []string{"Apartment", "Villa", "Loft", "Cottage", "Townhouse", "Penthouse"}
+	seedHostNames       = []string{"Amara Okafor", "Tunde Bello", "Wanjiru Kamau", "Sipho Dlamini", "Ines Costa", "Oliver Hughes"}
+	seedReviewTitles    = map[int][]string{
+		1: {"Would not return", "Very disappointing"},
+		2: {"Below expectations", "Needs work"},
+		3: {"Decent stay", "Average but fine"},
+		4: {"Really enjoyed it", "Great value"},
+		5: {"Absolutely perfect", "Best stay this year"},
+	}
+	seedReviewBodies = map[int][]string{
+		1: {"The room was not clean and nobody at the front desk could help.", "Noisy all night and the air conditioning did not work."},
+		2: {"The location is good but the room felt tired and the breakfast was cold.", "Staff were friendly, but check-in took over an hour."},
+		3: {"Nothing special, nothing wrong. Comfortable beds and a convenient location.", "Good for a night or two. The pool was smaller than in the photos."},
+		4: {"Lovely staff and a great breakfast. The room was spotless.", "Easy walk to the sights and very quiet at night."},
+		5: {"Everything was perfect from arrival to checkout. The view was stunning.", "The staff went out of their way to make our anniversary special."},
+	}
+)
+
+// runSeed fills the database with generated but realistic sample data
+func runSeed(ctx context.Context, config util.Config, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	hotels := flags.Int("hotels", 20, "number of hotels to generate")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed, for reproducible data")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *hotels < 1 {
+		return fmt.Errorf("-hotels must be at least 1")
+	}
+
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	s := seeder{store: store, rnd: rand.New(rand.NewSource(*seed))}
+	if err := s.run(ctx, *hotels); err != nil {
+		return err
+	}
+	log.Printf("seeded %d hotels, %d reviews, %d cuisines, %d restaurants and %d vacation rentals (seed %d)",
+		s.counts.hotels, s.counts.reviews, s.counts.cuisines, s.counts.restaurants, s.counts.rentals, *seed)
+	return nil
+}
+
+type seeder struct {
+	store  *repository.Store
+	rnd    *rand.Rand
+	counts struct{ hotels, reviews, cuisines, restaurants, rentals int }
+}
+
+func (s *seeder) run(ctx context.Context, hotels int) error {
+	var cuisineIDs []primitive.ObjectID
+	for _, c := range seedCuisines {
+		cuisine := model.Cuisine{Name: c.name, Origin: c.origin, Category: c.category, Description: c.name + " from " + c.origin}
+		s.stamp(&cuisine.CreatedAt, &cuisine.UpdatedAt)
+		if err := s.store.Cuisines.Create(ctx, &cuisine); err != nil {
+			return err
+		}
+		cuisineIDs = append(cuisineIDs, cuisine.ID)
+		s.counts.cuisines++
+	}
+
+	for i := 0; i < hotels; i++ {
+		city := seedCities[s.rnd.Intn(len(seedCities))]
+		hotel := s.hotel(city)
+		if err := s.store.Hotels.Create(ctx, &hotel); err != nil {
+			return err
+		}
+		s.counts.hotels++
+
+		if err := s.reviews(ctx, &hotel); err != nil {
+			return err
+		}
+		if err := s.store.Hotels.Update(ctx, &hotel); err != nil {
+			return err
+		}
+
+		if s.rnd.Intn(2) == 0 {
+			restaurant := s.restaurant(city, cuisineIDs)
+			if err := s.store.Restaurants.Create(ctx, &restaurant); err != nil {
+				return err
+			}
+			s.counts.restaurants++
+		}
+		if s.rnd.Intn(3) == 0 {
+			rental := s.rental(city)
+			if err := s.store.VacationRentals.Create(ctx, &rental); err != nil {
+				return err
+			}
+			s.counts.rentals++
+		}
+	}
+	return nil
+}
+
+func (s *seeder) hotel(city seedCity) model.Hotel {
+	name := pick(s.rnd, seedHotelPrefixes) + " " + pick(s.rnd, seedHotelSuffixes)
+	price := math.Round(40+s.rnd.Float64()*360) + 0.99
+
+	rooms := s.rnd.Intn(len(seedRooms)) + 1
+	roomTypes := make([]model.RoomType, rooms)
+	for i := range roomTypes {
+		room := seedRooms[i]
+		roomTypes[i] = model.RoomType{
+			ID:        fmt.Sprintf("room-%d", i+1),
+			Name:      room.name,
+			Price:     math.Round(price*room.factor*100) / 100,
+			MaxGuests: room.guests,
+			Amenities: pickN(s.rnd, seedAmenities, 3),
+			Available: s.rnd.Intn(5) > 0,
+		}
+	}
+
+	hotel := model.Hotel{
+		Title:         name + " " + city.name,
+		Content:       fmt.Sprintf("%s is a %d-room property in %s with %s.", name, 20+s.rnd.Intn(200), city.name, strings.ToLower(strings.Join(pickN(s.rnd, seedAmenities, 2), " and "))),
+		PrimaryInfo:   fmt.Sprintf("%.1f km from the centre", 0.2+s.rnd.Float64()*8),
+		SecondaryInfo: pick(s.rnd, []string{"Free cancellation", "Breakfast included", "Pay at the property", ""}),
+		Provider:      pick(s.rnd, seedProviders),
+		PriceDetails:  "per night, taxes included",
+		PriceSummary:  fmt.Sprintf("from %.2f %s", price, city.currency),
+		Location: model.Location{
+			City:       city.name,
+			State:      city.state,
+			Country:    city.country,
+			PostalCode: city.postalCode,
+			// Spread hotels over roughly 10 km around the city centre
+			Coordinates: []float64{
+				math.Round((city.lng+(s.rnd.Float64()-0.5)*0.1)*1e6) / 1e6,
+				math.Round((city.lat+(s.rnd.Float64()-0.5)*0.1)*1e6) / 1e6,
+			},
+		},
+		Address:   fmt.Sprintf("%d %s, %s", 1+s.rnd.Intn(250), pick(s.rnd, seedStreets), city.name),
+		Price:     price,
+		Currency:  city.currency,
+		Amenities: pickN(s.rnd, seedAmenities, 4+s.rnd.Intn(4)),
+		RoomTypes: roomTypes,
+		ContactInfo: model.ContactInfo{
+			Phone:   fmt.Sprintf("+%d %d", 100+s.rnd.Intn(900), 1000000+s.rnd.Intn(9000000)),
+			Email:   "stay@" + slug(name) + ".example.com",
+			Website: "https://" + slug(name) + ".example.com",
+		},
+		Policies: model.HotelPolicies{
+			CheckIn:       "14:00",
+			CheckOut:      "11:00",
+			Cancellation:  pick(s.rnd, []string{"Free cancellation up to 24 hours before arrival", "Non-refundable", "Free cancellation up to 7 days before arrival"}),
+			PetPolicy:     pick(s.rnd, []string{"Pets allowed", "No pets"}),
+			SmokingPolicy: "Non-smoking",
+			AcceptedCards: []string{"Visa", "Mastercard"},
+		},
+		Status: "active",
+		Tags:   pickN(s.rnd, seedTags, 2),
+	}
+	s.stamp(&hotel.CreatedAt, &hotel.UpdatedAt)
+	return hotel
+}
+
+// reviews writes a handful of reviews of hotel and folds them into its rating
+func (s *seeder) reviews(ctx context.Context, hotel *model.Hotel) error {
+	// Each hotel gets a typical score that its reviews scatter around
+	typical := 2 + s.rnd.Intn(4)
+	for i := s.rnd.Intn(9); i > 0; i-- {
+		score := min(max(typical+s.rnd.Intn(3)-1, 1), 5)
+		review := model.Review{
+			UserID:     primitive.NewObjectID(),
+			EntityID:   hotel.ID,
+			EntityType: "hotel",
+			Title:      pick(s.rnd, seedReviewTitles[score]),
+			Content:    pick(s.rnd, seedReviewBodies[score]),
+			Rating:     float64(score),
+			Helpful:    s.rnd.Intn(20),
+			Status:     model.ReviewPublished,
+		}
+		s.stamp(&review.CreatedAt, &review.UpdatedAt)
+		if err := s.store.Reviews.Create(ctx, &review); err != nil {
+			return err
+		}
+		hotel.UpdateRating(review.Rating)
+		s.counts.reviews++
+	}
+	hotel.Rating = math.Round(hotel.Rating*10) / 10
+	return nil
+}
+
+func (s *seeder) restaurant(city seedCity, cuisineIDs []primitive.ObjectID) model.Restaurant {
+	name := pick(s.rnd, seedHotelPrefixes) + " " + pick(s.rnd, seedRestaurantNames)
+	restaurant := model.Restaurant{
+		Name:          name,
+		Description:   "A neighbourhood favourite in " + city.name,
+		Address:       fmt.Sprintf("%d %s, %s", 1+s.rnd.Intn(250), pick(s.rnd, seedStreets), city.name),
+		Location:      city.name + ", " + city.country,
+		Cuisines:      pickN(s.rnd, cuisineIDs, 1+s.rnd.Intn(3)),
+		PriceRange:    strings.Repeat("$", 1+s.rnd.Intn(4)),
+		Rating:        float64(25+s.rnd.Intn(26)) / 10,
+		ContactNumber: fmt.Sprintf("+%d %d", 100+s.rnd.Intn(900), 1000000+s.rnd.Intn(9000000)),
+		Email:         "hello@" + slug(name) + ".example.com",
+		Website:       "https://" + slug(name) + ".example.com",
+		OpeningHours:  s.openingHours(city),
+	}
+	restaurant.SetDefaults()
+	s.stamp(&restaurant.CreatedAt, &restaurant.UpdatedAt)
+	return restaurant
+}
+
+// openingHours opens for lunch and dinner on weekdays, all day at the
+// weekend, and closes on Mondays at some restaurants
+func (s *seeder) openingHours(city seedCity) model.OpeningHours {
+	hours := model.OpeningHours{TimeZone: city.timeZone}
+	closedMondays := s.rnd.Intn(3) == 0
+	for _, day := range model.Weekdays {
+		switch {
+		case day == "monday" && closedMondays:
+		case day == "saturday" || day == "sunday":
+			hours.Weekly = append(hours.Weekly, model.OpeningPeriod{Day: day, TimeRange: model.TimeRange{Opens: "10:00", Closes: "23:30"}})
+		default:
+			hours.Weekly = append(hours.Weekly,
+				model.OpeningPeriod{Day: day, TimeRange: model.TimeRange{Opens: "12:00", Closes: "15:00"}},
+				model.OpeningPeriod{Day: day, TimeRange: model.TimeRange{Opens: "18:00", Closes: "23:00"}})
+		}
+	}
+	return hours
+}
+
+func (s *seeder) rental(city seedCity) model.VacationRental {
+	kind := pick(s.rnd, seedRentalKinds)
+	bedrooms := 1 + s.rnd.Intn(4)
+	price := math.Round(40 + float64(bedrooms)*40 + s.rnd.Float64()*80)
+	rental := model.VacationRental{
+		Name:         fmt.Sprintf("%d-bedroom %s in %s", bedrooms, kind, city.name),
+		Description:  fmt.Sprintf("Self-catering %s close to %s.", strings.ToLower(kind), pick(s.rnd, seedStreets)),
+		PropertyType: strings.ToLower(kind),
+		Address:      fmt.Sprintf("%d %s, %s", 1+s.rnd.Intn(250), pick(s.rnd, seedStreets), city.name),
+		Location:     city.name + ", " + city.country,
+		Coordinates: []float64{
+			math.Round((city.lng+(s.rnd.Float64()-0.5)*0.1)*1e6) / 1e6,
+			math.Round((city.lat+(s.rnd.Float64()-0.5)*0.1)*1e6) / 1e6,
+		},
+		Bedrooms:    bedrooms,
+		Beds:        bedrooms + s.rnd.Intn(2),
+		Bathrooms:   float64(1 + s.rnd.Intn(bedrooms)),
+		MaxGuests:   bedrooms * 2,
+		MinimumStay: 1 + s.rnd.Intn(3),
+		Amenities:   pickN(s.rnd, seedAmenities, 3+s.rnd.Intn(4)),
+		HouseRules:  model.HouseRules{CheckIn: "15:00", CheckOut: "11:00", PetsAllowed: s.rnd.Intn(3) == 0, QuietHours: "22:00-07:00"},
+		Host:        model.Host{Name: pick(s.rnd, seedHostNames), Email: "host@" + slug(kind+" "+city.name) + ".example.com", Superhost: s.rnd.Intn(4) == 0},
+		Price:       price,
+		Currency:    city.currency,
+		Rates: model.RentalRates{
+			WeekendUplift:         float64(5 * s.rnd.Intn(4)),
+			LengthOfStayDiscounts: []model.StayDiscount{{MinNights: 7, Percent: 10}},
+			CleaningFee:           math.Round(price * 0.3),
+		},
+		Rating: float64(30+s.rnd.Intn(21)) / 10,
+	}
+	s.stamp(&rental.CreatedAt, &rental.UpdatedAt)
+	return rental
+}
+
+// stamp sets created and updated to a time within the last year
+func (s *seeder) stamp(created, updated *time.Time) {
+	*created = time.Now().Add(-time.Duration(s.rnd.Int63n(int64(365 * 24 * time.Hour)))).UTC().Truncate(time.Second)
+	*updated = *created
+}
+
+func pick[T any](rnd *rand.Rand, items []T) T {
+	return items[rnd.Intn(len(items))]
+}
+
+// pickN returns n distinct items in random order
+func pickN[T any](rnd *rand.Rand, items []T, n int) []T {
+	n = min(n, len(items))
+	picked := make([]T, n)
+	for i, j := range rnd.Perm(len(items))[:n] {
+		picked[i] = items[j]
+	}
+	return picked
+}
+
+func slug(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "")
+}
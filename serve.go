@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/janto-pee/Horizon-Travels.git/controllers"
+	"github.com/janto-pee/Horizon-Travels.git/util"
+)
+
+// runServe serves the API until ctx is cancelled, then shuts down gracefully
+func runServe(ctx context.Context, config util.Config, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+	// Validate required configuration
+	if err := validateConfig(config); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Connect to the database before accepting requests
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	// Buffer application and request logs; they are flushed on shutdown
+	logs := util.NewLogWriter(os.Stderr, time.Second)
+	log.SetOutput(logs)
+	gin.DefaultWriter = logs
+	gin.DefaultErrorWriter = logs
+	defer func() {
+		// Flush logs last so every shutdown message is written
+		log.SetOutput(os.Stderr)
+		if err := logs.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "could not flush logs: %v\n", err)
+		}
+	}()
+
+	server, err := controllers.NewServer(config, store)
+	if err != nil {
+		closeStore()
+		return fmt.Errorf("could not create server: %w", err)
+	}
+
+	// Start server in goroutine
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- runGinServer(server, config.HTTPServerAddress)
+	}()
+
+	// A server that failed, such as on a port already in use, still gets
+	// cleaned up, but its error is what runServe returns
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down server...")
+	case runErr = <-serverErr:
+		if runErr != nil {
+			log.Printf("Server error: %v", runErr)
+		}
+	}
+
+	// Graceful shutdown with timeout
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer shutdownCancel()
+
+	if err := gracefulShutdown(shutdownCtx, server); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+		return errors.Join(runErr, err)
+	}
+	if runErr != nil {
+		return runErr
+	}
+	log.Println("Server exited gracefully")
+	return nil
+}
+
+func validateConfig(config util.Config) error {
+	if config.HTTPServerAddress == "" {
+		return fmt.Errorf("HTTP server address is required")
+	}
+	switch config.DBDriver {
+	case util.DriverMongo, "":
+		if config.MongoURI == "" {
+			return fmt.Errorf("MongoDB URI is required")
+		}
+	case util.DriverPostgres:
+		if config.DatabaseURL == "" {
+			return fmt.Errorf("DB_SOURCE is required with the postgres driver")
+		}
+	default:
+		return fmt.Errorf("unknown DB_DRIVER %q", config.DBDriver)
+	}
+	return nil
+}
+
+func runGinServer(server *controllers.Server, address string) error {
+	log.Printf("Starting server on %s", address)
+
+	if err := server.Start(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not start server: %w", err)
+	}
+
+	return nil
+}
+
+// gracefulShutdown drains in-flight requests and stops background workers
+// before closing the database they depend on.
+func gracefulShutdown(ctx context.Context, server *controllers.Server) error {
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Close database connections
+	if err := util.Close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	log.Println("Cleanup completed")
+	return errors.Join(errs...)
+}
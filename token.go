@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// runToken prints an access token for a user, signed with TOKEN_SECRET
+func runToken(ctx context.Context, config util.Config, args []string) error {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	admin := flags.Bool("admin", false, "allow the admin routes")
+	ttl := flags.Duration("ttl", 24*time.Hour, "how long the token is valid")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected a user ID")
+	}
+	userID, err := primitive.ObjectIDFromHex(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid user ID %q", flags.Arg(0))
+	}
+	if *ttl <= 0 {
+		return fmt.Errorf("-ttl must be positive")
+	}
+
+	token, err := util.NewToken(config.TokenSecret, util.TokenClaims{
+		Subject:   userID.Hex(),
+		Admin:     *admin,
+		ExpiresAt: time.Now().Add(*ttl).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
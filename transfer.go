@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"github.com/janto-pee/Horizon-Travels.git/tripadvisor"
+	"github.com/janto-pee/Horizon-Travels.git/util"
+)
+
+const (
+	formatNDJSON      = "ndjson"
+	formatCSV         = "csv"
+	formatTripAdvisor = "tripadvisor"
+)
+
+func checkFormat(format string) error {
+	if format != formatNDJSON && format != formatCSV {
+		return fmt.Errorf("unknown format %q, expected %s or %s", format, formatNDJSON, formatCSV)
+	}
+	return nil
+}
+
+// runExport writes every document of a collection as NDJSON or CSV
+func runExport(ctx context.Context, config util.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", formatNDJSON, "output format: ndjson or csv")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("export needs exactly one collection")
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	ds, err := findDataset(store, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+
+	var count int
+	if *format == formatCSV {
+		count, err = exportCSV(ctx, ds, w)
+	} else {
+		count, err = exportNDJSON(ctx, ds, w)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	log.Printf("exported %d %s", count, ds.name)
+	return nil
+}
+
+func exportNDJSON(ctx context.Context, ds dataset, w io.Writer) (int, error) {
+	count := 0
+	err := ds.each(ctx, func(doc json.RawMessage, _ error) error {
+		count++
+		_, err := fmt.Fprintf(w, "%s\n", doc)
+		return err
+	})
+	return count, err
+}
+
+func exportCSV(ctx context.Context, ds dataset, w io.Writer) (int, error) {
+	out := csv.NewWriter(w)
+	header := make([]string, len(ds.columns))
+	for i, col := range ds.columns {
+		header[i] = col.path
+	}
+	if err := out.Write(header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := ds.each(ctx, func(doc json.RawMessage, _ error) error {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(doc, &fields); err != nil {
+			return err
+		}
+		row := make([]string, len(ds.columns))
+		for i, col := range ds.columns {
+			value, ok := lookupPath(fields, col.path)
+			if !ok || value == nil {
+				continue
+			}
+			if s, ok := value.(string); ok {
+				row[i] = s
+				continue
+			}
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			row[i] = string(raw)
+		}
+		count++
+		return out.Write(row)
+	})
+	out.Flush()
+	if err == nil {
+		err = out.Error()
+	}
+	return count, err
+}
+
+// importReport counts what an import did
+type importReport struct {
+	created, updated, failed int
+}
+
+// runImport loads a collection from an NDJSON or CSV file or stdin.
+// Documents whose id is already stored replace it; invalid documents are
+// reported and skipped. With -format tripadvisor the input is a search
+// payload instead, see importTripAdvisor.
+func runImport(ctx context.Context, config util.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", formatNDJSON, "input format: ndjson, csv or tripadvisor")
+	var opts tripadvisor.Options
+	flags.StringVar(&opts.City, "city", "", "city of the hotels in a tripadvisor hotel search")
+	flags.StringVar(&opts.Country, "country", "", "country of the hotels in a tripadvisor hotel search")
+	flags.StringVar(&opts.Currency, "currency", "USD", "currency of tripadvisor prices without a known symbol")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return errors.New("import needs a collection and an optional file")
+	}
+	if *format != formatTripAdvisor {
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if flags.NArg() == 2 {
+		file, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	if *format == formatTripAdvisor {
+		return importTripAdvisor(ctx, store, opts, flags.Arg(0), in)
+	}
+	ds, err := findDataset(store, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var report importReport
+	put := func(line int, fields map[string]interface{}) error {
+		created, err := ds.put(ctx, fields)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			report.failed++
+			log.Printf("record %d: %v", line, err)
+		case created:
+			report.created++
+		default:
+			report.updated++
+		}
+		return nil
+	}
+	if *format == formatCSV {
+		err = readCSV(in, ds.columns, put)
+	} else {
+		err = readNDJSON(in, put)
+	}
+	log.Printf("%s: %d created, %d updated, %d failed", ds.name, report.created, report.updated, report.failed)
+	if err != nil {
+		return err
+	}
+	if report.failed > 0 {
+		return fmt.Errorf("%d records could not be imported", report.failed)
+	}
+	return nil
+}
+
+// importTripAdvisor upserts the results of a TripAdvisor search payload.
+// kind is hotels, restaurants or vacation-rentals.
+func importTripAdvisor(ctx context.Context, store *repository.Store, opts tripadvisor.Options, kind string, in io.Reader) error {
+	report, err := tripadvisor.NewImporter(store, opts).Import(ctx, kind, in)
+	if report != nil {
+		log.Printf("tripadvisor %s import:\n%s", kind, report)
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Rejected) > 0 {
+		return fmt.Errorf("%d records could not be imported", len(report.Rejected))
+	}
+	return nil
+}
+
+// readNDJSON calls fn with each JSON object of r, numbered from 1
+func readNDJSON(r io.Reader, fn func(line int, fields map[string]interface{}) error) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var fields map[string]interface{}
+		err := decoder.Decode(&fields)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+		if err := fn(line, fields); err != nil {
+			return err
+		}
+	}
+}
+
+// readCSV calls fn with each row of r as nested fields. The header names
+// the columns; string columns are taken as is and the others parsed as
+// JSON. Empty cells are left out.
+func readCSV(r io.Reader, columns []column, fn func(line int, fields map[string]interface{}) error) error {
+	in := csv.NewReader(r)
+	header, err := in.Read()
+	if err != nil {
+		return fmt.Errorf("could not read the CSV header: %w", err)
+	}
+
+	known := make(map[string]column, len(columns))
+	for _, col := range columns {
+		known[col.path] = col
+	}
+	for _, name := range header {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown CSV column %q", name)
+		}
+	}
+
+	for line := 2; ; line++ {
+		row, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]interface{}{}
+		for i, cell := range row {
+			if cell == "" {
+				continue
+			}
+			col := known[header[i]]
+			var value interface{} = cell
+			if !col.isString {
+				if err := json.Unmarshal([]byte(cell), &value); err != nil {
+					return fmt.Errorf("line %d, column %s: %w", line, col.path, err)
+				}
+			}
+			setPath(fields, col.path, value)
+		}
+		if err := fn(line, fields); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,549 @@
+// Package tripadvisor imports TripAdvisor-style search payloads, the shape
+// described in travel.sql, into the application's models. Records are
+// upserted by their upstream ID, so importing the same payload twice
+// updates what the first import created.
+package tripadvisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/janto-pee/Horizon-Travels.git/model"
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Source is stored on every imported document
+const Source = "tripadvisor"
+
+// Kinds of payload the importer understands
+const (
+	Hotels          = "hotels"
+	Restaurants     = "restaurants"
+	VacationRentals = "vacation-rentals"
+)
+
+// maxImageSize caps the width and height requested from image templates
+const maxImageSize = 1200
+
+// Options fill in what search payloads do not carry
+type Options struct {
+	// City and Country locate the hotels of a hotel search, which is always
+	// made for a single place
+	City    string
+	Country string
+	// Currency is used when a display price has no recognisable symbol
+	Currency string
+}
+
+// Rejection explains why a record was not imported
+type Rejection struct {
+	Kind       string `json:"kind"`
+	Index      int    `json:"index"` // position in the payload, from 0
+	ExternalID string `json:"external_id,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// Report counts what an import did, per kind of document
+type Report struct {
+	Created  map[string]int `json:"created"`
+	Updated  map[string]int `json:"updated"`
+	Rejected []Rejection    `json:"rejected"`
+}
+
+func newReport() *Report {
+	return &Report{Created: map[string]int{}, Updated: map[string]int{}}
+}
+
+func (r *Report) count(kind string, created bool) {
+	if created {
+		r.Created[kind]++
+	} else {
+		r.Updated[kind]++
+	}
+}
+
+func (r *Report) reject(kind string, index int, externalID string, err error) {
+	r.Rejected = append(r.Rejected, Rejection{Kind: kind, Index: index, ExternalID: externalID, Reason: err.Error()})
+}
+
+// String summarises the report in one line per kind
+func (r *Report) String() string {
+	kinds := []string{Hotels, Restaurants, VacationRentals, "cuisines", "photos", "thumbnails"}
+	var lines []string
+	for _, kind := range kinds {
+		if r.Created[kind] > 0 || r.Updated[kind] > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %d created, %d updated", kind, r.Created[kind], r.Updated[kind]))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("rejected: %d", len(r.Rejected)))
+	for _, rejection := range r.Rejected {
+		lines = append(lines, fmt.Sprintf("  %s #%d %s: %s", rejection.Kind, rejection.Index, rejection.ExternalID, rejection.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Importer writes payloads to a store
+type Importer struct {
+	store *repository.Store
+	opts  Options
+}
+
+// NewImporter returns an Importer writing to store
+func NewImporter(store *repository.Store, opts Options) *Importer {
+	if opts.Currency == "" {
+		opts.Currency = "USD"
+	}
+	return &Importer{store: store, opts: opts}
+}
+
+// Import reads a search payload of the given kind from r. Records that
+// cannot be mapped or fail validation are rejected and listed in the
+// report; a storage error stops the import.
+func (im *Importer) Import(ctx context.Context, kind string, r io.Reader) (*Report, error) {
+	var importItem func(ctx context.Context, report *Report, raw json.RawMessage) (string, error)
+	switch kind {
+	case Hotels:
+		importItem = im.importHotel
+	case Restaurants:
+		importItem = im.importRestaurant
+	case VacationRentals:
+		importItem = im.importRental
+	default:
+		return nil, fmt.Errorf("unknown payload kind %q", kind)
+	}
+
+	items, err := decodeItems(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport()
+	for i, raw := range items {
+		externalID, err := importItem(ctx, report, raw)
+		var rejected *rejectedError
+		if errors.As(err, &rejected) {
+			report.reject(kind, i, externalID, rejected.err)
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("%s #%d: %w", kind, i, err)
+		}
+	}
+	return report, nil
+}
+
+// rejectedError marks a record as invalid rather than the import as failed
+type rejectedError struct{ err error }
+
+func (e *rejectedError) Error() string { return e.err.Error() }
+
+func reject(format string, args ...interface{}) error {
+	return &rejectedError{fmt.Errorf(format, args...)}
+}
+
+// validated rejects doc when its model's Validate fails
+func validated(doc interface{ Validate() error }) error {
+	if err := doc.Validate(); err != nil {
+		return &rejectedError{err}
+	}
+	return nil
+}
+
+// rankPrefix matches the "12. " position search results put before titles
+var rankPrefix = regexp.MustCompile(`^\d+\.\s+`)
+
+func (im *Importer) importHotel(ctx context.Context, report *Report, raw json.RawMessage) (string, error) {
+	var item hotelItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return "", reject("malformed hotel: %v", err)
+	}
+	if item.ID == "" {
+		return "", reject("hotel has no id")
+	}
+
+	hotel := model.Hotel{
+		Title:         rankPrefix.ReplaceAllString(strings.TrimSpace(item.Title), ""),
+		Content:       strings.Trim(strings.Join([]string{item.PrimaryInfo, item.SecondaryInfo}, ". "), ". "),
+		PrimaryInfo:   item.PrimaryInfo,
+		SecondaryInfo: item.SecondaryInfo,
+		Provider:      item.Provider,
+		PriceDetails:  item.PriceDetails,
+		PriceSummary:  item.PriceSummary,
+		Location:      model.Location{City: im.opts.City, Country: im.opts.Country},
+		Currency:      im.opts.Currency,
+		IsSponsored:   item.IsSponsored,
+		Status:        "active",
+		Source:        Source,
+		ExternalID:    item.ID,
+	}
+	if item.AccentedLabel {
+		hotel.AccentedLabel = "true"
+	}
+	if item.BubbleRating != nil {
+		hotel.Rating = item.BubbleRating.Rating.Value
+		var count number
+		if json.Unmarshal([]byte(`"`+item.BubbleRating.Count+`"`), &count) == nil {
+			hotel.ReviewCount = int(count.Value)
+		}
+	}
+	if amount, currency, ok := parsePrice(item.PriceForDisplay); ok {
+		hotel.Price = amount
+		if currency != "" {
+			hotel.Currency = currency
+		}
+	}
+	if amount, _, ok := parsePrice(item.StrikethroughPrice); ok {
+		hotel.OriginalPrice = amount
+	}
+	if err := validated(&hotel); err != nil {
+		return item.ID, err
+	}
+
+	created, err := upsert(ctx, im.store.Hotels, &hotel, item.ID, func(stored *model.Hotel) {
+		hotel.ID, hotel.CreatedAt = stored.ID, stored.CreatedAt
+		// Keep what was entered by hand that search results do not carry
+		hotel.Content = keep(hotel.Content, stored.Content)
+		hotel.Location = keepLocation(hotel.Location, stored.Location)
+		hotel.Address = stored.Address
+		hotel.Amenities, hotel.RoomTypes = stored.Amenities, stored.RoomTypes
+		hotel.ContactInfo, hotel.Policies, hotel.Tags = stored.ContactInfo, stored.Policies, stored.Tags
+	})
+	if err != nil {
+		return item.ID, err
+	}
+	report.count(Hotels, created)
+
+	for i, photo := range item.CardPhotos {
+		if err := im.importPhoto(ctx, report, "hotel", hotel.ID, fmt.Sprintf("%s photo %d", hotel.Title, i+1), photo.Sizes); err != nil {
+			return item.ID, err
+		}
+	}
+	return item.ID, nil
+}
+
+func (im *Importer) importRestaurant(ctx context.Context, report *Report, raw json.RawMessage) (string, error) {
+	var item restaurantItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return "", reject("malformed restaurant: %v", err)
+	}
+	externalID := item.LocationID.String()
+	if externalID == "" {
+		externalID = item.RestaurantsID.String()
+	}
+	if externalID == "" {
+		return "", reject("restaurant has no locationId")
+	}
+
+	restaurant := model.Restaurant{
+		Name:        strings.TrimSpace(item.Name),
+		Description: strings.Join(item.EstablishmentTypeAndCuisineTags, ", "),
+		Location:    item.ParentGeoName,
+		PriceRange:  item.PriceTag,
+		Rating:      item.AverageRating.Value,
+		ReviewCount: int(item.UserReviewCount.Value),
+		Source:      Source,
+		ExternalID:  externalID,
+	}
+	if item.HasMenu {
+		restaurant.MenuURL = item.MenuURL
+	}
+	if item.ReviewSnippets != nil {
+		for _, snippet := range item.ReviewSnippets.ReviewSnippetsList {
+			restaurant.ReviewSnippets = append(restaurant.ReviewSnippets, model.ReviewSnippet{Text: snippet.ReviewText, URL: snippet.ReviewURL})
+		}
+	}
+	if item.Offers != nil {
+		for _, o := range []*offer{item.Offers.Slot1Offer, item.Offers.Slot2Offer} {
+			if o == nil {
+				continue
+			}
+			restaurant.Offers = append(restaurant.Offers, model.Offer{
+				Type:     o.OfferType,
+				Provider: o.ProviderName,
+				Label:    o.ButtonText,
+				URL:      keep(o.OfferURL, o.Href),
+			})
+		}
+	}
+	restaurant.SetDefaults()
+	if err := validated(&restaurant); err != nil {
+		return externalID, err
+	}
+
+	for _, tag := range item.EstablishmentTypeAndCuisineTags {
+		id, err := im.cuisine(ctx, report, tag)
+		if err != nil {
+			return externalID, err
+		}
+		restaurant.Cuisines = append(restaurant.Cuisines, id)
+	}
+
+	created, err := upsert(ctx, im.store.Restaurants, &restaurant, externalID, func(stored *model.Restaurant) {
+		restaurant.ID, restaurant.CreatedAt = stored.ID, stored.CreatedAt
+		restaurant.Address = stored.Address
+		restaurant.ContactNumber, restaurant.Email, restaurant.Website = stored.ContactNumber, stored.Email, stored.Website
+		restaurant.OpeningHours = stored.OpeningHours
+		restaurant.Menu = stored.Menu
+		if stored.Menu.AveragePrice > 0 {
+			restaurant.PriceRange = stored.PriceRange
+		}
+	})
+	if err != nil {
+		return externalID, err
+	}
+	report.count(Restaurants, created)
+
+	if item.HeroImgURL != "" {
+		sizes := photoSizes{URLTemplate: item.HeroImgURL, MaxWidth: item.HeroImgRawWidth, MaxHeight: item.HeroImgRawHeight}
+		if err := im.importPhoto(ctx, report, "restaurant", restaurant.ID, restaurant.Name, sizes); err != nil {
+			return externalID, err
+		}
+	}
+	if item.SquareImgURL != "" {
+		sizes := photoSizes{URLTemplate: item.SquareImgURL, MaxWidth: item.SquareImgRawLength, MaxHeight: item.SquareImgRawLength}
+		if err := im.importThumbnail(ctx, report, "restaurant", restaurant.ID, sizes); err != nil {
+			return externalID, err
+		}
+	}
+	return externalID, nil
+}
+
+func (im *Importer) importRental(ctx context.Context, report *Report, raw json.RawMessage) (string, error) {
+	var item rentalItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return "", reject("malformed vacation rental: %v", err)
+	}
+	externalID := item.LocationID.String()
+	if externalID == "" {
+		externalID = item.GeoID.String()
+	}
+	if externalID == "" {
+		return "", reject("vacation rental has no locationId")
+	}
+
+	rental := model.VacationRental{
+		Name:        strings.TrimSpace(item.LocalizedName),
+		Description: item.PlaceType,
+		Location:    string(item.LocalizedAdditionalNames),
+		Source:      Source,
+		ExternalID:  externalID,
+	}
+	if item.Latitude.Valid && item.Longitude.Valid {
+		if item.Latitude.Value < -90 || item.Latitude.Value > 90 || item.Longitude.Value < -180 || item.Longitude.Value > 180 {
+			return externalID, reject("coordinates %v, %v are out of range", item.Latitude.Value, item.Longitude.Value)
+		}
+		rental.Coordinates = []float64{item.Longitude.Value, item.Latitude.Value}
+	}
+	rental.SetDefaults()
+	if err := validated(&rental); err != nil {
+		return externalID, err
+	}
+
+	created, err := upsert(ctx, im.store.VacationRentals, &rental, externalID, func(stored *model.VacationRental) {
+		// Search results only carry the name, place and coordinates; the
+		// property, its host, rates and calendar are kept
+		imported := rental
+		rental = *stored
+		rental.Name, rental.Description, rental.Location = imported.Name, imported.Description, imported.Location
+		rental.Coordinates, rental.UpdatedAt = imported.Coordinates, imported.UpdatedAt
+	})
+	if err != nil {
+		return externalID, err
+	}
+	report.count(VacationRentals, created)
+
+	if item.Thumbnail != nil && item.Thumbnail.URLTemplate != "" {
+		if err := im.importThumbnail(ctx, report, "vacation_rental", rental.ID, *item.Thumbnail); err != nil {
+			return externalID, err
+		}
+	}
+	return externalID, nil
+}
+
+// importPhoto upserts the photo of an entity, matching on its URL
+func (im *Importer) importPhoto(ctx context.Context, report *Report, entityType string, entityID primitive.ObjectID, title string, sizes photoSizes) error {
+	width, height := imageSize(sizes)
+	url := expandTemplate(sizes.URLTemplate, width, height)
+	photo := model.Photo{
+		URL:        url,
+		Title:      title,
+		EntityID:   entityID,
+		EntityType: entityType,
+		Metadata:   model.PhotoMetadata{Width: width, Height: height, Format: imageFormat(url)},
+	}
+
+	existing, _, err := im.store.Photos.List(ctx, repository.Query{Conditions: []repository.Condition{
+		repository.Eq("entity_type", entityType),
+		repository.Eq("entity_id", entityID),
+		repository.Eq("url", url),
+	}, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		stored := existing[0]
+		photo.ID, photo.CreatedAt = stored.ID, stored.CreatedAt
+		photo.IsPrimary, photo.Order, photo.Tags = stored.IsPrimary, stored.Order, stored.Tags
+	}
+	photo.SetDefaults()
+	if err := validated(&photo); err != nil {
+		// A bad photo does not invalidate the record it belongs to
+		report.reject("photos", -1, url, err.(*rejectedError).err)
+		return nil
+	}
+
+	if len(existing) > 0 {
+		err = im.store.Photos.Update(ctx, &photo)
+	} else {
+		err = im.store.Photos.Create(ctx, &photo)
+	}
+	if err == nil {
+		report.count("photos", len(existing) == 0)
+	}
+	return err
+}
+
+// importThumbnail upserts the thumbnail of an entity, matching on its URL
+func (im *Importer) importThumbnail(ctx context.Context, report *Report, entityType string, entityID primitive.ObjectID, sizes photoSizes) error {
+	width, height := imageSize(sizes)
+	thumbnail := model.Thumbnail{
+		URL:        expandTemplate(sizes.URLTemplate, width, height),
+		Width:      width,
+		Height:     height,
+		EntityID:   entityID,
+		EntityType: entityType,
+		UpdatedAt:  time.Now(),
+	}
+
+	existing, _, err := im.store.Thumbnails.List(ctx, repository.Query{Conditions: []repository.Condition{
+		repository.Eq("entity_type", entityType),
+		repository.Eq("entity_id", entityID),
+		repository.Eq("url", thumbnail.URL),
+	}, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if err := validated(&thumbnail); err != nil {
+		report.reject("thumbnails", -1, thumbnail.URL, err.(*rejectedError).err)
+		return nil
+	}
+
+	if len(existing) > 0 {
+		thumbnail.ID, thumbnail.CreatedAt = existing[0].ID, existing[0].CreatedAt
+		err = im.store.Thumbnails.Update(ctx, &thumbnail)
+	} else {
+		thumbnail.CreatedAt = thumbnail.UpdatedAt
+		err = im.store.Thumbnails.Create(ctx, &thumbnail)
+	}
+	if err == nil {
+		report.count("thumbnails", len(existing) == 0)
+	}
+	return err
+}
+
+// cuisine returns the ID of the cuisine called name, creating it if needed
+func (im *Importer) cuisine(ctx context.Context, report *Report, name string) (primitive.ObjectID, error) {
+	existing, _, err := im.store.Cuisines.List(ctx, repository.Query{
+		Conditions: []repository.Condition{repository.Eq("name", name)},
+		Limit:      1,
+	})
+	if err != nil || len(existing) > 0 {
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		return existing[0].ID, nil
+	}
+
+	now := time.Now()
+	cuisine := model.Cuisine{Name: name, CreatedAt: now, UpdatedAt: now}
+	if err := im.store.Cuisines.Create(ctx, &cuisine); err != nil {
+		return primitive.NilObjectID, err
+	}
+	report.count("cuisines", true)
+	return cuisine.ID, nil
+}
+
+// upsert stores doc, replacing the document previously imported with the
+// same external ID. merge copies what doc should keep from the stored
+// version. It reports whether doc was new.
+func upsert[T any](ctx context.Context, repo repository.Repository[T], doc *T, externalID string, merge func(stored *T)) (bool, error) {
+	existing, _, err := repo.List(ctx, repository.Query{Conditions: []repository.Condition{
+		repository.Eq("source", Source),
+		repository.Eq("external_id", externalID),
+	}, Limit: 1})
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	stamp(doc, now)
+	if len(existing) == 0 {
+		return true, repo.Create(ctx, doc)
+	}
+	merge(&existing[0])
+	return false, repo.Update(ctx, doc)
+}
+
+// stamp sets the timestamps every imported model has
+func stamp(doc interface{}, now time.Time) {
+	switch d := doc.(type) {
+	case *model.Hotel:
+		d.CreatedAt, d.UpdatedAt = now, now
+	case *model.Restaurant:
+		d.CreatedAt, d.UpdatedAt = now, now
+	case *model.VacationRental:
+		d.CreatedAt, d.UpdatedAt = now, now
+	}
+}
+
+// imageSize picks the size to request from an image template
+func imageSize(sizes photoSizes) (width, height int) {
+	width, height = sizes.MaxWidth, sizes.MaxHeight
+	if width <= 0 || height <= 0 {
+		return maxImageSize, maxImageSize
+	}
+	if width > maxImageSize || height > maxImageSize {
+		scale := float64(maxImageSize) / float64(max(width, height))
+		width, height = int(float64(width)*scale), int(float64(height)*scale)
+	}
+	return width, height
+}
+
+// imageFormat guesses an image's format from its URL's extension
+func imageFormat(url string) string {
+	url, _, _ = strings.Cut(url, "?")
+	switch ext := strings.TrimPrefix(strings.ToLower(path.Ext(url)), "."); ext {
+	case "jpg", "jpeg", "png", "webp":
+		return ext
+	}
+	return "jpg"
+}
+
+// keep returns value, or fallback when value is empty
+func keep(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+// keepLocation fills the parts of an imported location that are empty from
+// the stored one
+func keepLocation(imported, stored model.Location) model.Location {
+	if imported.City == "" {
+		imported.City = stored.City
+	}
+	if imported.Country == "" {
+		imported.Country = stored.Country
+	}
+	imported.State, imported.PostalCode, imported.Coordinates = stored.State, stored.PostalCode, stored.Coordinates
+	return imported
+}
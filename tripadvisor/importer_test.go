@@ -0,0 +1,173 @@
+package tripadvisor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/janto-pee/Horizon-Travels.git/repository"
+)
+
+const hotelPayload = `{
+  "status": true,
+  "data": {
+    "data": [
+      {
+        "id": "1234",
+        "title": "1. Grand Palace Hotel",
+        "primaryInfo": "Breakfast included",
+        "secondaryInfo": "Victoria Island",
+        "bubbleRating": {"count": "1,024", "rating": 4.5},
+        "isSponsored": true,
+        "provider": "Booking.com",
+        "priceForDisplay": "€120",
+        "strikethroughPrice": "€150",
+        "cardPhotos": [
+          {"sizes": {"maxHeight": 2000, "maxWidth": 3000, "urlTemplate": "https://img.example/a.jpg?w={width}&h={height}"}}
+        ]
+      },
+      {"id": "", "title": "No id"},
+      {"id": "99", "title": "", "priceForDisplay": "$10"}
+    ]
+  }
+}`
+
+func TestImportHotelsUpsertsByExternalID(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	importer := NewImporter(store, Options{City: "Lagos", Country: "Nigeria"})
+
+	report, err := importer.Import(ctx, Hotels, strings.NewReader(hotelPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created[Hotels] != 1 || report.Created["photos"] != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(report.Rejected) != 2 || report.Rejected[0].Index != 1 || report.Rejected[1].ExternalID != "99" {
+		t.Errorf("expected the hotels without an id or title to be rejected, got %+v", report.Rejected)
+	}
+
+	hotels, _, _ := store.Hotels.List(ctx, repository.Query{})
+	if len(hotels) != 1 {
+		t.Fatalf("expected 1 hotel, got %d", len(hotels))
+	}
+	hotel := hotels[0]
+	if hotel.Title != "Grand Palace Hotel" || hotel.Price != 120 || hotel.OriginalPrice != 150 ||
+		hotel.Currency != "EUR" || hotel.ReviewCount != 1024 || hotel.Rating != 4.5 || !hotel.IsSponsored ||
+		hotel.Location.City != "Lagos" || hotel.Source != Source || hotel.ExternalID != "1234" {
+		t.Errorf("hotel mapped wrongly: %+v", hotel)
+	}
+
+	photos, _, _ := store.Photos.List(ctx, repository.Query{})
+	if len(photos) != 1 || photos[0].URL != "https://img.example/a.jpg?w=1200&h=800" || photos[0].EntityID != hotel.ID {
+		t.Errorf("photo mapped wrongly: %+v", photos)
+	}
+
+	report, err = importer.Import(ctx, Hotels, strings.NewReader(hotelPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created[Hotels] != 0 || report.Updated[Hotels] != 1 || report.Updated["photos"] != 1 {
+		t.Errorf("expected the second import to update, got %+v", report)
+	}
+	if _, total, _ := store.Hotels.List(ctx, repository.Query{}); total != 1 {
+		t.Errorf("expected 1 hotel after reimporting, got %d", total)
+	}
+}
+
+const restaurantPayload = `{
+  "data": [
+    {
+      "restaurantsId": "Restaurant_Review-g1-d42",
+      "locationId": 42,
+      "name": "Mama Put",
+      "averageRating": 4.2,
+      "userReviewCount": 87,
+      "establishmentTypeAndCuisineTags": ["Nigerian", "African"],
+      "priceTag": "$$ - $$$",
+      "hasMenu": true,
+      "menuUrl": "https://menu.example/42",
+      "parentGeoName": "Lagos",
+      "reviewSnippets": {"reviewSnippetsList": [{"reviewText": "Great jollof", "reviewUrl": "/r/1"}]},
+      "offers": {"slot1Offer": {"buttonText": "Reserve", "offerURL": "https://book.example", "providerName": "TheFork", "offerType": "RESERVATION"}},
+      "heroImgUrl": "https://img.example/hero.jpg",
+      "heroImgRawHeight": 600,
+      "heroImgRawWidth": 800,
+      "squareImgUrl": "https://img.example/square.jpg",
+      "squareImgRawLength": 250
+    },
+    {"locationId": 43, "name": "Suya Spot", "establishmentTypeAndCuisineTags": ["Nigerian"]}
+  ]
+}`
+
+func TestImportRestaurantsSharesCuisines(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+
+	report, err := NewImporter(store, Options{}).Import(ctx, Restaurants, strings.NewReader(restaurantPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created[Restaurants] != 2 || report.Created["cuisines"] != 2 || report.Created["photos"] != 1 ||
+		report.Created["thumbnails"] != 1 || len(report.Rejected) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	restaurants, _, _ := store.Restaurants.List(ctx, repository.Query{
+		Conditions: []repository.Condition{repository.Eq("external_id", "42")},
+	})
+	if len(restaurants) != 1 {
+		t.Fatalf("expected restaurant 42, got %+v", restaurants)
+	}
+	restaurant := restaurants[0]
+	if restaurant.ReviewCount != 87 || restaurant.MenuURL == "" || len(restaurant.Cuisines) != 2 ||
+		len(restaurant.ReviewSnippets) != 1 || len(restaurant.Offers) != 1 || restaurant.Offers[0].URL != "https://book.example" {
+		t.Errorf("restaurant mapped wrongly: %+v", restaurant)
+	}
+}
+
+func TestImportVacationRentals(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	payload := `[
+	  {"geoId": 7, "localizedName": "Lekki Beach House", "localizedAdditionalNames": {"longOnlyHierarchy": "Lekki, Lagos, Nigeria"},
+	   "latitude": 6.44, "longitude": 3.47, "thumbnail": {"photoSizeDynamic": {"maxHeight": 400, "maxWidth": 600, "urlTemplate": "https://img.example/t.jpg?w={width}"}}},
+	  {"geoId": 8, "localizedName": "Nowhere", "latitude": 120, "longitude": 3}
+	]`
+
+	report, err := NewImporter(store, Options{}).Import(ctx, VacationRentals, strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created[VacationRentals] != 1 || report.Created["thumbnails"] != 1 || len(report.Rejected) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	rentals, _, _ := store.VacationRentals.List(ctx, repository.Query{})
+	if len(rentals) != 1 || rentals[0].Location != "Lekki, Lagos, Nigeria" || rentals[0].Coordinates[0] != 3.47 {
+		t.Errorf("rental mapped wrongly: %+v", rentals)
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		display  string
+		amount   float64
+		currency string
+		ok       bool
+	}{
+		{"$1,234", 1234, "USD", true},
+		{"€99", 99, "EUR", true},
+		{"NGN 45,000", 45000, "NGN", true},
+		{"12.50", 12.5, "", true},
+		{"", 0, "", false},
+		{"Sold out", 0, "", false},
+	}
+	for _, tt := range tests {
+		amount, currency, ok := parsePrice(tt.display)
+		if amount != tt.amount || currency != tt.currency || ok != tt.ok {
+			t.Errorf("parsePrice(%q) = %v, %q, %v", tt.display, amount, currency, ok)
+		}
+	}
+}
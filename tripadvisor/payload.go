@@ -0,0 +1,254 @@
+package tripadvisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The payload types mirror the upstream search responses described in
+// travel.sql. Only the fields the importer maps are declared.
+
+type hotelItem struct {
+	ID                 string      `json:"id"`
+	Title              string      `json:"title"`
+	PrimaryInfo        string      `json:"primaryInfo"`
+	SecondaryInfo      string      `json:"secondaryInfo"`
+	BubbleRating       *bubble     `json:"bubbleRating"`
+	IsSponsored        bool        `json:"isSponsored"`
+	AccentedLabel      bool        `json:"accentedLabel"`
+	Provider           string      `json:"provider"`
+	PriceForDisplay    string      `json:"priceForDisplay"`
+	StrikethroughPrice string      `json:"strikethroughPrice"`
+	PriceDetails       string      `json:"priceDetails"`
+	PriceSummary       string      `json:"priceSummary"`
+	CardPhotos         []cardPhoto `json:"cardPhotos"`
+}
+
+type bubble struct {
+	Count  string `json:"count"`
+	Rating number `json:"rating"`
+}
+
+type cardPhoto struct {
+	Sizes photoSizes `json:"sizes"`
+}
+
+// UnmarshalJSON accepts the sizes under "sizes", as the API sends them, or
+// inline as travel.sql stores them
+func (c *cardPhoto) UnmarshalJSON(data []byte) error {
+	var wrapped struct {
+		Sizes *photoSizes `json:"sizes"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	if wrapped.Sizes != nil {
+		c.Sizes = *wrapped.Sizes
+		return nil
+	}
+	return json.Unmarshal(data, &c.Sizes)
+}
+
+// photoSizes describes an image available in any size up to the maximum.
+// urlTemplate contains {width} and {height} placeholders.
+type photoSizes struct {
+	MaxHeight   int    `json:"maxHeight"`
+	MaxWidth    int    `json:"maxWidth"`
+	URLTemplate string `json:"urlTemplate"`
+}
+
+// UnmarshalJSON accepts the sizes inline, as in travel.sql, or nested under
+// photoSizeDynamic as the rental search returns them
+func (p *photoSizes) UnmarshalJSON(data []byte) error {
+	type flat photoSizes
+	var nested struct {
+		flat
+		PhotoSizeDynamic *flat `json:"photoSizeDynamic"`
+	}
+	if err := json.Unmarshal(data, &nested); err != nil {
+		return err
+	}
+	if nested.PhotoSizeDynamic != nil {
+		*p = photoSizes(*nested.PhotoSizeDynamic)
+	} else {
+		*p = photoSizes(nested.flat)
+	}
+	return nil
+}
+
+type restaurantItem struct {
+	RestaurantsID                   number       `json:"restaurantsId"`
+	LocationID                      number       `json:"locationId"`
+	Name                            string       `json:"name"`
+	AverageRating                   number       `json:"averageRating"`
+	UserReviewCount                 number       `json:"userReviewCount"`
+	EstablishmentTypeAndCuisineTags []string     `json:"establishmentTypeAndCuisineTags"`
+	PriceTag                        string       `json:"priceTag"`
+	Offers                          *offers      `json:"offers"`
+	HasMenu                         bool         `json:"hasMenu"`
+	MenuURL                         string       `json:"menuUrl"`
+	ParentGeoName                   string       `json:"parentGeoName"`
+	DistanceTo                      string       `json:"distanceTo"`
+	ReviewSnippets                  *snippetList `json:"reviewSnippets"`
+	HeroImgURL                      string       `json:"heroImgUrl"`
+	HeroImgRawHeight                int          `json:"heroImgRawHeight"`
+	HeroImgRawWidth                 int          `json:"heroImgRawWidth"`
+	SquareImgURL                    string       `json:"squareImgUrl"`
+	SquareImgRawLength              int          `json:"squareImgRawLength"`
+}
+
+type offers struct {
+	Slot1Offer *offer `json:"slot1Offer"`
+	Slot2Offer *offer `json:"slot2Offer"`
+}
+
+type offer struct {
+	ButtonText   string `json:"buttonText"`
+	Href         string `json:"href"`
+	OfferURL     string `json:"offerURL"`
+	ProviderName string `json:"providerName"`
+	OfferType    string `json:"offerType"`
+}
+
+type snippetList struct {
+	ReviewSnippetsList []struct {
+		ReviewText string `json:"reviewText"`
+		ReviewURL  string `json:"reviewUrl"`
+	} `json:"reviewSnippetsList"`
+}
+
+type rentalItem struct {
+	GeoID                    number      `json:"geoId"`
+	LocationID               number      `json:"locationId"`
+	LocalizedName            string      `json:"localizedName"`
+	LocalizedAdditionalNames names       `json:"localizedAdditionalNames"`
+	PlaceType                string      `json:"placeType"`
+	Latitude                 number      `json:"latitude"`
+	Longitude                number      `json:"longitude"`
+	Thumbnail                *photoSizes `json:"thumbnail"`
+}
+
+// names is a place's hierarchy, sent either as a plain string or as an
+// object with a longOnlyHierarchy field
+type names string
+
+func (n *names) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*n = names(s)
+		return nil
+	}
+	var object struct {
+		LongOnlyHierarchy string `json:"longOnlyHierarchy"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*n = names(object.LongOnlyHierarchy)
+	return nil
+}
+
+// number is a numeric field the upstream API sends either as a JSON number
+// or as a formatted string such as "1,234" or "(56 reviews)"
+type number struct {
+	Value float64
+	Valid bool
+}
+
+var digits = regexp.MustCompile(`-?[0-9][0-9,]*(\.[0-9]+)?`)
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*n = number{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	match := digits.FindString(s)
+	if match == "" {
+		*n = number{}
+		return nil
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s: %w", data, err)
+	}
+	*n = number{Value: value, Valid: true}
+	return nil
+}
+
+// String formats an ID-like number without a fraction
+func (n number) String() string {
+	if !n.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(n.Value, 'f', -1, 64)
+}
+
+// decodeItems returns the result items of a search payload. It accepts a
+// full response ({"status": ..., "data": {"data": [...]}}), its inner data
+// object, or a bare array.
+func decodeItems(r io.Reader) ([]json.RawMessage, error) {
+	var payload json.RawMessage
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	for depth := 0; depth < 3; depth++ {
+		var items []json.RawMessage
+		if err := json.Unmarshal(payload, &items); err == nil {
+			return items, nil
+		}
+		var wrapper struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &wrapper); err != nil || wrapper.Data == nil {
+			break
+		}
+		payload = wrapper.Data
+	}
+	return nil, fmt.Errorf("payload has no list of results")
+}
+
+// priceSymbols maps the currency symbols used in display prices to ISO codes
+var priceSymbols = map[string]string{
+	"$": "USD", "US$": "USD", "€": "EUR", "£": "GBP", "₦": "NGN", "KSh": "KES",
+	"R": "ZAR", "MAD": "MAD", "฿": "THB", "¥": "JPY", "₹": "INR",
+}
+
+// parsePrice splits a display price such as "$1,234" or "€99" into its
+// amount and currency. The currency is empty when it is not recognised.
+func parsePrice(display string) (amount float64, currency string, ok bool) {
+	display = strings.TrimSpace(display)
+	match := digits.FindStringIndex(display)
+	if match == nil {
+		return 0, "", false
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(display[match[0]:match[1]], ",", ""), 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	symbol := strings.TrimSpace(display[:match[0]] + display[match[1]:])
+	if code, known := priceSymbols[symbol]; known {
+		currency = code
+	} else if len(symbol) == 3 && strings.ToUpper(symbol) == symbol {
+		currency = symbol
+	}
+	return amount, currency, true
+}
+
+// expandTemplate fills the {width} and {height} placeholders of an image URL
+func expandTemplate(template string, width, height int) string {
+	return strings.NewReplacer(
+		"{width}", strconv.Itoa(width),
+		"{height}", strconv.Itoa(height),
+	).Replace(template)
+}
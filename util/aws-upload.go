@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // S3Uploader handles file uploads to AWS S3
@@ -64,7 +65,7 @@ func (u *S3Uploader) UploadFile(file multipart.File, fileHeader *multipart.FileH
 		Key:         aws.String(fileName),
 		Body:        bytes.NewReader(buffer),
 		ContentType: aws.String(contentType),
-		ACL:         aws.String("public-read"),
+		ACL:         types.ObjectCannedACLPublicRead,
 	})
 
 	if err != nil {
@@ -1,14 +1,35 @@
 package util
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	DBDriver          string `mapstructure:"DB_DRIVER"`
-	DatabaseURL       string `mapstructure:"DB_SOURCE"`
+	DBDriver          string `mapstructure:"DB_DRIVER"` // mongodb or postgres
+	DatabaseURL       string `mapstructure:"DB_SOURCE"` // PostgreSQL connection URL
 	HTTPServerAddress string `mapstructure:"HTTP_SERVER_ADDRESS"`
 	Environment       string `mapstructure:"ENVIRONMENT"`
+
+	HTTPReadTimeout  time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout  time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout  time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+
+	TokenSecret string `mapstructure:"TOKEN_SECRET"` // signs the access tokens of API callers
+
+	BookingHoldTTL     time.Duration `mapstructure:"BOOKING_HOLD_TTL"`     // how long a hold keeps its inventory
+	HoldExpiryInterval time.Duration `mapstructure:"HOLD_EXPIRY_INTERVAL"` // how often expired holds are released
+
+	MongoURI         string `mapstructure:"MONGODB_URI"`
+	MongoDBName      string `mapstructure:"MONGODB_DB_NAME"`
+	MongoMaxPoolSize uint64 `mapstructure:"MONGODB_MAX_POOL_SIZE"`
+	// The connect timeout, retries and backoff apply to PostgreSQL as well
+	MongoConnectTimeout time.Duration `mapstructure:"MONGODB_CONNECT_TIMEOUT"`
+	MongoTimeout        time.Duration `mapstructure:"MONGODB_TIMEOUT"`
+	MongoConnectRetries int           `mapstructure:"MONGODB_CONNECT_RETRIES"`
+	MongoRetryBackoff   time.Duration `mapstructure:"MONGODB_RETRY_BACKOFF"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -18,6 +39,24 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
 
+	// Defaults also register the keys, so they can be set from the environment alone
+	viper.SetDefault("DB_DRIVER", DriverMongo)
+	viper.SetDefault("DB_SOURCE", "")
+	viper.SetDefault("TOKEN_SECRET", "")
+	viper.SetDefault("HTTP_READ_TIMEOUT", 15*time.Second)
+	viper.SetDefault("HTTP_WRITE_TIMEOUT", 15*time.Second)
+	viper.SetDefault("HTTP_IDLE_TIMEOUT", 60*time.Second)
+	viper.SetDefault("SHUTDOWN_TIMEOUT", 30*time.Second)
+	viper.SetDefault("BOOKING_HOLD_TTL", 15*time.Minute)
+	viper.SetDefault("HOLD_EXPIRY_INTERVAL", time.Minute)
+	viper.SetDefault("MONGODB_URI", "mongodb://localhost:27017")
+	viper.SetDefault("MONGODB_DB_NAME", "travel")
+	viper.SetDefault("MONGODB_MAX_POOL_SIZE", 100)
+	viper.SetDefault("MONGODB_CONNECT_TIMEOUT", 10*time.Second)
+	viper.SetDefault("MONGODB_TIMEOUT", 10*time.Second)
+	viper.SetDefault("MONGODB_CONNECT_RETRIES", 5)
+	viper.SetDefault("MONGODB_RETRY_BACKOFF", time.Second)
+
 	err = viper.ReadInConfig()
 	if err != nil {
 		// panic(fmt.Errorf("fatal error config file: %w", err))
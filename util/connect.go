@@ -2,8 +2,11 @@ package util
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -11,124 +14,173 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-// Define MongoDB connection string - better to use environment variables
-var uri = getEnv("MONGODB_URI", "mongodb://localhost:27017")
-var DbName = getEnv("MONGODB_DB_NAME", "travel")
+// ConnState describes where the database connection is in its lifecycle
+type ConnState int32
+
+const (
+	StateDisconnected ConnState = iota
+	StateConnecting
+	StateReady
+	StateClosing
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateReady:
+		return "ready"
+	case StateClosing:
+		return "closing"
+	}
+	return "disconnected"
+}
+
+// maxRetryBackoff caps the delay between two connection attempts
+const maxRetryBackoff = 30 * time.Second
 
-// Create global variables to hold MongoDB connection
 var (
-	MongoClient *mongo.Client
-	Db          *mongo.Collection
-	ctx         context.Context
-	cancel      context.CancelFunc
+	mu       sync.Mutex
+	client   *mongo.Client
+	database *mongo.Database
+	state    atomic.Int32
 )
 
-// Initialize MongoDB connection
-func init() {
-	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+// Connect opens the MongoDB connection described by config and returns the
+// configured database. Failed attempts are retried with exponential backoff
+// up to config.MongoConnectRetries times, or until ctx is done.
+func Connect(ctx context.Context, config Config) (*mongo.Database, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if client != nil || pool != nil {
+		return nil, errors.New("database is already connected")
+	}
 
-	if err := ConnectToMongoDB(); err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	opts := options.Client().
+		ApplyURI(config.MongoURI).
+		SetServerAPIOptions(options.ServerAPI(options.ServerAPIVersion1)).
+		SetMaxPoolSize(config.MongoMaxPoolSize).
+		SetConnectTimeout(config.MongoConnectTimeout).
+		SetServerSelectionTimeout(config.MongoConnectTimeout).
+		SetTimeout(config.MongoTimeout)
+
+	err := withRetries(ctx, config, "MongoDB", func() error {
+		c, err := connectOnce(ctx, opts, config.MongoConnectTimeout)
+		if err == nil {
+			client = c
+			database = c.Database(config.MongoDBName)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	log.Printf("Successfully connected to MongoDB database %q", config.MongoDBName)
+	return database, nil
+}
 
-	Db = MongoClient.Database(DbName).Collection("hotel")
+// withRetries calls connect until it succeeds, tracking the connection
+// state. Failed attempts are retried with exponential backoff up to
+// config.MongoConnectRetries times, or until ctx is done.
+func withRetries(ctx context.Context, config Config, name string, connect func() error) error {
+	state.Store(int32(StateConnecting))
+	backoff := config.MongoRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			state.Store(int32(StateReady))
+			return nil
+		}
 
-	// Add a clean shutdown hook
-	go func() {
-		c := make(chan os.Signal, 1)
-		<-c
-		DisconnectMongoDB()
-		os.Exit(0)
-	}()
+		if attempt > config.MongoConnectRetries {
+			state.Store(int32(StateDisconnected))
+			return fmt.Errorf("could not connect to %s after %d attempts: %w", name, attempt, err)
+		}
+		log.Printf("%s connection attempt %d failed: %v; retrying in %s", name, attempt, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			state.Store(int32(StateDisconnected))
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
 }
 
-// ConnectToMongoDB establishes connection to MongoDB
-func ConnectToMongoDB() error {
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().
-		ApplyURI(uri).
-		SetServerAPIOptions(serverAPI).
-		SetTimeout(10 * time.Second)
+// connectOnce makes a single connection attempt and verifies it with a ping
+func connectOnce(ctx context.Context, opts *options.ClientOptions, timeout time.Duration) (*mongo.Client, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	var err error
-	MongoClient, err = mongo.Connect(ctx, opts)
+	c, err := mongo.Connect(attemptCtx, opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := c.Ping(attemptCtx, readpref.Primary()); err != nil {
+		c.Disconnect(context.Background())
+		return nil, err
 	}
+	return c, nil
+}
 
-	// Verify connection with ping
-	if err = MongoClient.Ping(ctx, readpref.Primary()); err != nil {
-		return err
+// Close disconnects from the database, waiting for in-use connections until
+// ctx is done. It is a no-op when there is no open connection.
+func Close(ctx context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if pool != nil {
+		state.Store(int32(StateClosing))
+		closePostgres(ctx)
+		state.Store(int32(StateDisconnected))
+		log.Println("PostgreSQL connection closed")
+		return nil
+	}
+	if client == nil {
+		return nil
+	}
+	state.Store(int32(StateClosing))
+
+	err := client.Disconnect(ctx)
+	client = nil
+	database = nil
+	state.Store(int32(StateDisconnected))
+	if err != nil {
+		return fmt.Errorf("could not disconnect from MongoDB: %w", err)
 	}
 
-	log.Println("Successfully connected to MongoDB")
+	log.Println("MongoDB connection closed")
 	return nil
 }
 
-// DisconnectMongoDB closes the MongoDB connection
-func DisconnectMongoDB() {
-	if MongoClient != nil {
-		if err := MongoClient.Disconnect(ctx); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
-		}
-	}
-	cancel()
-	log.Println("MongoDB connection closed")
+// Database returns the connected database, or nil before Connect succeeds
+func Database() *mongo.Database {
+	mu.Lock()
+	defer mu.Unlock()
+	return database
 }
 
-// GetCollection returns a specific collection from the database
-func GetCollection(collectionName string) *mongo.Collection {
-	return MongoClient.Database(DbName).Collection(collectionName)
+// State returns the current lifecycle state of the connection
+func State() ConnState {
+	return ConnState(state.Load())
 }
 
-// Helper function to get environment variables with defaults
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// Ping reports whether the connection is ready and the database answers
+func Ping(ctx context.Context) error {
+	mu.Lock()
+	c, p := client, pool
+	mu.Unlock()
+
+	if State() != StateReady {
+		return fmt.Errorf("database is %s", State())
+	}
+	if p != nil {
+		return p.Ping(ctx)
 	}
-	return value
+	if c == nil {
+		return fmt.Errorf("database is %s", StateDisconnected)
+	}
+	return c.Ping(ctx, readpref.Primary())
 }
-
-// package util
-
-// import (
-// 	"context"
-// 	"log"
-// 	"time"
-
-// 	"go.mongodb.org/mongo-driver/mongo"
-// 	"go.mongodb.org/mongo-driver/mongo/options"
-// )
-
-// // Define your MongoDB connection string
-// const uri = "mongodb://localhost:27017"
-
-// // Create a global variable to hold our MongoDB connection
-// var MongoClient *mongo.Client
-
-// var Db *mongo.Collection
-// var ctx context.Context
-
-// // This function runs before we call our main function and connects to our MongoDB database. If it cannot connect, the application stops.
-// func init() {
-// 	if err := connect_to_mongodb(); err != nil {
-// 		log.Fatal("Could not connect to MongoDB")
-// 	}
-// 	Db = MongoClient.Database("travel").Collection("hotel")
-// 	ctx, _ = context.WithTimeout(context.Background(), 10*time.Second)
-// }
-
-// // Our implementation code to connect to MongoDB at startup
-// func connect_to_mongodb() error {
-// 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-// 	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
-
-// 	client, err := mongo.Connect(context.TODO(), opts)
-// 	if err != nil {
-// 		panic(err)
-// 	}
-// 	err = client.Ping(context.TODO(), nil)
-// 	MongoClient = client
-// 	return err
-// }
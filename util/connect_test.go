@@ -0,0 +1,63 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func unreachableConfig() Config {
+	return Config{
+		// Nothing listens on port 1, so every attempt is refused quickly
+		MongoURI:            "mongodb://127.0.0.1:1",
+		MongoDBName:         "travel_test",
+		MongoMaxPoolSize:    1,
+		MongoConnectTimeout: 200 * time.Millisecond,
+		MongoTimeout:        200 * time.Millisecond,
+		MongoConnectRetries: 2,
+		MongoRetryBackoff:   10 * time.Millisecond,
+	}
+}
+
+func TestConnectGivesUpAfterRetries(t *testing.T) {
+	start := time.Now()
+	db, err := Connect(context.Background(), unreachableConfig())
+	if err == nil {
+		t.Fatal("expected an error connecting to an unreachable server")
+	}
+	if db != nil {
+		t.Errorf("expected no database, got %v", db.Name())
+	}
+	if State() != StateDisconnected {
+		t.Errorf("expected state %s, got %s", StateDisconnected, State())
+	}
+	// Three attempts plus 10ms and 20ms of backoff
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected retries with backoff, finished in %s", elapsed)
+	}
+	if err := Ping(context.Background()); err == nil {
+		t.Error("expected ping to fail while disconnected")
+	}
+}
+
+func TestConnectStopsWhenContextIsCancelled(t *testing.T) {
+	config := unreachableConfig()
+	config.MongoConnectRetries = 100
+	config.MongoRetryBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if _, err := Connect(ctx, config); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if State() != StateDisconnected {
+		t.Errorf("expected state %s, got %s", StateDisconnected, State())
+	}
+}
+
+func TestCloseWithoutConnection(t *testing.T) {
+	if err := Close(context.Background()); err != nil {
+		t.Errorf("expected closing an unopened connection to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogWriter buffers log output and flushes it on a fixed interval, so that
+// request logging does not issue one write per line. It is safe for
+// concurrent use. Call Close during shutdown to flush what is left.
+type LogWriter struct {
+	mu     sync.Mutex
+	buf    *bufio.Writer
+	stop   chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// NewLogWriter returns a LogWriter that flushes to w every interval
+func NewLogWriter(w io.Writer, interval time.Duration) *LogWriter {
+	l := &LogWriter{
+		buf:  bufio.NewWriter(w),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go l.flushEvery(interval)
+	return l
+}
+
+func (l *LogWriter) flushEvery(interval time.Duration) {
+	defer close(l.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Flush()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+func (l *LogWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// Flush writes any buffered output to the underlying writer
+func (l *LogWriter) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Flush()
+}
+
+// Close stops the periodic flush and flushes the remaining output. Writes
+// after Close are still accepted but only reach the underlying writer on
+// the next explicit Flush.
+func (l *LogWriter) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	close(l.stop)
+	<-l.done
+	return l.Flush()
+}
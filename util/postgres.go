@@ -0,0 +1,72 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Database drivers selected by DB_DRIVER
+const (
+	DriverMongo    = "mongodb"
+	DriverPostgres = "postgres"
+)
+
+// pool is the PostgreSQL connection pool, guarded by mu like the MongoDB
+// client. At most one of the two is open.
+var pool *pgxpool.Pool
+
+// ConnectPostgres opens a connection pool to the PostgreSQL database at
+// config.DatabaseURL. Attempts are retried like Connect's.
+func ConnectPostgres(ctx context.Context, config Config) (*pgxpool.Pool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if client != nil || pool != nil {
+		return nil, errors.New("database is already connected")
+	}
+	poolConfig, err := pgxpool.ParseConfig(config.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withRetries(ctx, config, "PostgreSQL", func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, config.MongoConnectTimeout)
+		defer cancel()
+
+		p, err := pgxpool.NewWithConfig(attemptCtx, poolConfig)
+		if err != nil {
+			return err
+		}
+		if err := p.Ping(attemptCtx); err != nil {
+			p.Close()
+			return err
+		}
+		pool = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Successfully connected to PostgreSQL database %q", poolConfig.ConnConfig.Database)
+	return pool, nil
+}
+
+// closePostgres closes the pool, waiting for connections in use until ctx
+// is done. The caller holds mu.
+func closePostgres(ctx context.Context) {
+	closed := make(chan struct{})
+	go func(p *pgxpool.Pool) {
+		p.Close()
+		close(closed)
+	}(pool)
+	pool = nil
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		log.Println("PostgreSQL connections still in use were abandoned")
+	}
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	now := time.Now()
+	claims := TokenClaims{Subject: "user", Admin: true, ExpiresAt: now.Add(time.Hour).Unix()}
+	token, err := NewToken("secret", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified, err := VerifyToken("secret", token, now); err != nil || verified != claims {
+		t.Errorf("expected %+v back, got %+v (%v)", claims, verified, err)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	forged, _ := NewToken("other", claims)
+	_, forgedSignature, _ := strings.Cut(forged, ".")
+	for name, bad := range map[string]struct {
+		secret, token string
+		now           time.Time
+	}{
+		"wrong secret":    {"other", token, now},
+		"empty secret":    {"", token, now},
+		"expired":         {"secret", token, now.Add(2 * time.Hour)},
+		"tampered":        {"secret", "e30." + signature, now},
+		"forged":          {"secret", payload + "." + forgedSignature, now},
+		"missing payload": {"secret", signature, now},
+	} {
+		if _, err := VerifyToken(bad.secret, bad.token, bad.now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	if _, err := NewToken("", claims); err == nil {
+		t.Error("expected an error signing without a secret")
+	}
+}